@@ -0,0 +1,200 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// awsAddressTag marks the Elastic IPs allocated for an instance, so they can
+// be found and released once the instance is gone.
+const awsAddressTag = "cloudfusion:server"
+
+// awsNetworkInterface moves the subnet and security groups of runInput into
+// a primary network interface, which is the only way to choose whether the
+// instance gets a public IP. It does nothing when the mode is left to the
+// subnet default.
+func awsNetworkInterface(runInput *ec2.RunInstancesInput, mode string) {
+	if mode == "" {
+		return
+	}
+	runInput.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{
+		{
+			DeviceIndex:              aws.Int64(0),
+			SubnetId:                 runInput.SubnetId,
+			Groups:                   runInput.SecurityGroupIds,
+			AssociatePublicIpAddress: aws.Bool(mode == "ephemeral"),
+			DeleteOnTermination:      aws.Bool(true),
+		},
+	}
+	runInput.SubnetId = nil
+	runInput.SecurityGroupIds = nil
+}
+
+// awsPublicIPMode tells how the instance got its public IP. An Elastic IP is
+// owned by the account rather than by amazon. A stopped instance loses its
+// ephemeral IP, so the configured mode is kept while it is not running.
+func awsPublicIPMode(instance *ec2.Instance, configured string) string {
+	if aws.StringValue(instance.State.Name) != ec2.InstanceStateNameRunning {
+		return configured
+	}
+	if aws.StringValue(instance.PublicIpAddress) == "" {
+		return "none"
+	}
+	for _, nic := range instance.NetworkInterfaces {
+		if nic.Association != nil && aws.StringValue(nic.Association.PublicIp) == aws.StringValue(instance.PublicIpAddress) {
+			if aws.StringValue(nic.Association.IpOwnerId) != "amazon" {
+				return "static"
+			}
+		}
+	}
+	return "ephemeral"
+}
+
+// associateElasticIP allocates an Elastic IP tagged with the instance ID and
+// associates it once the instance is running.
+func (A *AWSProvider) associateElasticIP(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig) error {
+	tags := map[string]string{awsAddressTag: VM.ID, "Name": VM.Name}
+	address, err := ec2Svc.AllocateAddressWithContext(ctx, &ec2.AllocateAddressInput{
+		Domain:            aws.String(ec2.DomainTypeVpc),
+		TagSpecifications: awsTagSpecifications(tags, ec2.ResourceTypeElasticIp),
+	})
+	if err != nil {
+		return fmt.Errorf("error allocating an Elastic IP for %s: %w", VM.ID, err)
+	}
+	err = ec2Svc.WaitUntilInstanceRunningWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(VM.ID)}})
+	if err != nil {
+		return fmt.Errorf("error waiting for %s to run: %w", VM.ID, err)
+	}
+	_, err = ec2Svc.AssociateAddressWithContext(ctx, &ec2.AssociateAddressInput{
+		AllocationId: address.AllocationId,
+		InstanceId:   aws.String(VM.ID),
+	})
+	if err != nil {
+		return fmt.Errorf("error associating %s with %s: %w", aws.StringValue(address.PublicIp), VM.ID, err)
+	}
+	return nil
+}
+
+// findElasticIPs returns the Elastic IPs allocated for the instance.
+func (A *AWSProvider) findElasticIPs(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig) ([]*ec2.Address, error) {
+	result, err := ec2Svc.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag:" + awsAddressTag),
+				Values: []*string{aws.String(VM.ID)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Addresses, nil
+}
+
+// releaseElasticIPs releases addresses. The instance has to be terminated
+// first, which disassociates them.
+func (A *AWSProvider) releaseElasticIPs(ctx context.Context, ec2Svc *ec2.EC2, addresses []*ec2.Address) error {
+	for _, address := range addresses {
+		_, err := ec2Svc.ReleaseAddressWithContext(ctx, &ec2.ReleaseAddressInput{AllocationId: address.AllocationId})
+		if err != nil {
+			return fmt.Errorf("error releasing Elastic IP %s: %w", aws.StringValue(address.PublicIp), err)
+		}
+	}
+	return nil
+}
+
+// gcpAddressName names the address reserved for a static public IP.
+func gcpAddressName(VM *vmconfig.VMConfig) string {
+	return strings.TrimSuffix(truncate(VM.Name, 60), "-") + "-ip"
+}
+
+// gcpRegion returns VM.Region, or the region of VM.Zone when it is not set.
+func gcpRegion(VM *vmconfig.VMConfig) string {
+	if VM.Region != "" {
+		return VM.Region
+	}
+	return zoneRegion(VM.Zone)
+}
+
+// zoneRegion returns the region of a GCE zone, such as europe-west1 for
+// europe-west1-b.
+func zoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// gcpAccessConfigs returns the access configs of the primary interface: none,
+// an ephemeral IP or the given static IP. An unset mode means ephemeral,
+// which is what instances always got before.
+func gcpAccessConfigs(mode, staticIP string) []*compute.AccessConfig {
+	switch mode {
+	case "none":
+		return nil
+	case "static":
+		return []*compute.AccessConfig{{Name: "External NAT", NatIP: staticIP}}
+	default:
+		return []*compute.AccessConfig{{Name: "External NAT"}}
+	}
+}
+
+// gcpPublicIPMode tells whether the instance has an external access config.
+// Whether its IP is reserved is not visible on the instance, so a static
+// configuration is trusted as long as the access config exists.
+func gcpPublicIPMode(instance *compute.Instance, configured string) string {
+	if len(instance.NetworkInterfaces) == 0 || len(instance.NetworkInterfaces[0].AccessConfigs) == 0 {
+		return "none"
+	}
+	if configured == "static" {
+		return "static"
+	}
+	return "ephemeral"
+}
+
+// reserveAddress reserves a regional external address for the instance and
+// returns its IP.
+func (G *GCProvider) reserveAddress(ctx context.Context, client interface{}, VM *vmconfig.VMConfig, labels map[string]string) (string, error) {
+	computeService := client.(*GCPClient).client
+	region := gcpRegion(VM)
+	name := gcpAddressName(VM)
+	op, err := computeService.Addresses.Insert(VM.GCPProjectID, region, &compute.Address{
+		Name:        name,
+		AddressType: "EXTERNAL",
+		Labels:      labels,
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("error reserving address %s: %w", name, err)
+	}
+	if err := G.waitForRegionOperation(ctx, client, VM.GCPProjectID, region, op.Name); err != nil {
+		return "", err
+	}
+	address, err := computeService.Addresses.Get(VM.GCPProjectID, region, name).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	return address.Address, nil
+}
+
+// releaseAddress deletes the address reserved for the instance, if any.
+func (G *GCProvider) releaseAddress(ctx context.Context, client interface{}, VM *vmconfig.VMConfig) error {
+	computeService := client.(*GCPClient).client
+	region := gcpRegion(VM)
+	op, err := computeService.Addresses.Delete(VM.GCPProjectID, region, gcpAddressName(VM)).Context(ctx).Do()
+	if err != nil {
+		var gceErr *googleapi.Error
+		if errors.As(err, &gceErr) && gceErr.Code == 404 {
+			return nil
+		}
+		return fmt.Errorf("error releasing address %s: %w", gcpAddressName(VM), err)
+	}
+	return G.waitForRegionOperation(ctx, client, VM.GCPProjectID, region, op.Name)
+}
@@ -0,0 +1,105 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestAWSNetworkInterface(t *testing.T) {
+	runInput := &ec2.RunInstancesInput{SubnetId: aws.String("subnet-1"), SecurityGroupIds: []*string{aws.String("sg-1")}}
+	awsNetworkInterface(runInput, "")
+	assert.Nil(t, runInput.NetworkInterfaces, "the subnet default applies when no mode is set")
+
+	awsNetworkInterface(runInput, "none")
+	assert.Nil(t, runInput.SubnetId)
+	assert.Nil(t, runInput.SecurityGroupIds)
+	nic := runInput.NetworkInterfaces[0]
+	assert.Equal(t, "subnet-1", aws.StringValue(nic.SubnetId))
+	assert.Equal(t, []*string{aws.String("sg-1")}, nic.Groups)
+	assert.False(t, aws.BoolValue(nic.AssociatePublicIpAddress))
+
+	runInput = &ec2.RunInstancesInput{}
+	awsNetworkInterface(runInput, "ephemeral")
+	assert.True(t, aws.BoolValue(runInput.NetworkInterfaces[0].AssociatePublicIpAddress))
+}
+
+func TestAWSPublicIPMode(t *testing.T) {
+	instance := func(state, ip, owner string) *ec2.Instance {
+		i := &ec2.Instance{State: &ec2.InstanceState{Name: aws.String(state)}}
+		if ip != "" {
+			i.PublicIpAddress = aws.String(ip)
+			i.NetworkInterfaces = []*ec2.InstanceNetworkInterface{
+				{Association: &ec2.InstanceNetworkInterfaceAssociation{PublicIp: aws.String(ip), IpOwnerId: aws.String(owner)}},
+			}
+		}
+		return i
+	}
+	assert.Equal(t, "none", awsPublicIPMode(instance("running", "", ""), "ephemeral"))
+	assert.Equal(t, "ephemeral", awsPublicIPMode(instance("running", "3.3.3.3", "amazon"), ""))
+	assert.Equal(t, "static", awsPublicIPMode(instance("running", "3.3.3.3", "123456789012"), ""))
+	assert.Equal(t, "ephemeral", awsPublicIPMode(instance("stopped", "", ""), "ephemeral"), "a stopped instance keeps its configured mode")
+}
+
+func TestGCPAccessConfigs(t *testing.T) {
+	assert.Nil(t, gcpAccessConfigs("none", ""))
+	assert.Equal(t, []*compute.AccessConfig{{Name: "External NAT"}}, gcpAccessConfigs("", ""))
+	assert.Equal(t, []*compute.AccessConfig{{Name: "External NAT"}}, gcpAccessConfigs("ephemeral", ""))
+	assert.Equal(t, []*compute.AccessConfig{{Name: "External NAT", NatIP: "34.1.2.3"}}, gcpAccessConfigs("static", "34.1.2.3"))
+}
+
+func TestGCPPublicIPMode(t *testing.T) {
+	withAccess := &compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{{AccessConfigs: []*compute.AccessConfig{{NatIP: "34.1.2.3"}}}}}
+	assert.Equal(t, "none", gcpPublicIPMode(&compute.Instance{NetworkInterfaces: []*compute.NetworkInterface{{}}}, "static"))
+	assert.Equal(t, "ephemeral", gcpPublicIPMode(withAccess, ""))
+	assert.Equal(t, "static", gcpPublicIPMode(withAccess, "static"))
+}
+
+func TestGCPAddressName(t *testing.T) {
+	assert.Equal(t, "web-1-ip", gcpAddressName(&vmconfig.VMConfig{Name: "web-1"}))
+	name := gcpAddressName(&vmconfig.VMConfig{Name: strings.Repeat("a", 59) + "-bbbb"})
+	assert.Equal(t, strings.Repeat("a", 59)+"-ip", name)
+	assert.LessOrEqual(t, len(name), 63)
+}
+
+func TestGCPRegion(t *testing.T) {
+	assert.Equal(t, "europe-west1", gcpRegion(&vmconfig.VMConfig{Zone: "europe-west1-b"}))
+	assert.Equal(t, "us-central1", gcpRegion(&vmconfig.VMConfig{Region: "us-central1", Zone: "europe-west1-b"}))
+}
+
+func TestGCPCreateInstanceReleasesAddress(t *testing.T) {
+	ctx := context.Background()
+	G := &GCProvider{}
+	vm := func() *vmconfig.VMConfig {
+		VM := gcpTestVM()
+		VM.PublicIPMode = "static"
+		return VM
+	}
+
+	f, client := newFakeCompute(t)
+	f.errors["POST zones/europe-west1-b/instances"] = http.StatusBadRequest
+	_, err := G.CreateInstance(ctx, vm(), client)
+	assert.Error(t, err)
+	assert.NotContains(t, f.resources, "regions/europe-west1/addresses/web-ip", "a rejected insert should release the address")
+
+	f, client = newFakeCompute(t)
+	f.failures["POST zones/europe-west1-b/instances"] = true
+	id, err := G.CreateInstance(ctx, vm(), client)
+	assert.ErrorContains(t, err, "operation failed")
+	assert.Empty(t, id)
+	assert.NotContains(t, f.resources, "regions/europe-west1/addresses/web-ip", "a failed insert should release the address")
+
+	f, client = newFakeCompute(t)
+	f.errors["GET zones/europe-west1-b/operations/"] = http.StatusInternalServerError
+	id, err = G.CreateInstance(ctx, vm(), client)
+	assert.Error(t, err)
+	assert.NotEmpty(t, id)
+	assert.Contains(t, f.resources, "regions/europe-west1/addresses/web-ip", "an insert that may still finish should keep its address")
+}
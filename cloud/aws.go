@@ -2,50 +2,208 @@ package cloud
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"sort"
+	"strings"
 )
 
 type AWSClient struct {
 	client *session.Session
 }
 
+// ec2Service returns an EC2 client for region. The session is shared by every
+// region, so the region is supplied per call.
+func (c *AWSClient) ec2Service(region string) *ec2.EC2 {
+	return ec2.New(c.client, aws.NewConfig().WithRegion(region))
+}
+
+// route53Service returns a Route 53 client. Route 53 is global, and signed
+// for us-east-1.
+func (c *AWSClient) route53Service() *route53.Route53 {
+	return route53.New(c.client, aws.NewConfig().WithRegion("us-east-1"))
+}
+
+// elbv2Service returns an Elastic Load Balancing v2 client for region.
+func (c *AWSClient) elbv2Service(region string) *elbv2.ELBV2 {
+	return elbv2.New(c.client, aws.NewConfig().WithRegion(region))
+}
+
+// s3Service returns an S3 client for region, where the bucket must be.
+func (c *AWSClient) s3Service(region string) *s3.S3 {
+	return s3.New(c.client, aws.NewConfig().WithRegion(region))
+}
+
 func (A *AWSProvider) CreateInstance(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) (string, error) {
 	awsClient, ok := client.(*AWSClient)
 	if !ok {
 		return "", fmt.Errorf("invalid AWS client")
 	}
-	ec2Svc := ec2.New(awsClient.client)
+	if VM.AWS == nil {
+		return "", fmt.Errorf("the aws block is required to create an AWS instance")
+	}
+	ec2Svc := awsClient.ec2Service(VM.Region)
+	if VM.Zone == "" && VM.SubnetID == "" {
+		zone, err := A.selectZone(ctx, ec2Svc, VM)
+		if err != nil {
+			return "", err
+		}
+		VM.Zone = zone
+	}
+	imageID, cleanup, err := A.awsLaunchImage(ctx, ec2Svc, VM)
+	defer cleanup()
+	if err != nil {
+		return "", err
+	}
 	runInput := &ec2.RunInstancesInput{
-		ImageId:      aws.String(VM.AWSAMI),
-		InstanceType: aws.String(VM.InstanceType),
-		MaxCount:     aws.Int64(1),
-		MinCount:     aws.Int64(1),
-		SubnetId:     aws.String(VM.SubnetID),
+		ImageId:             aws.String(imageID),
+		InstanceType:        aws.String(VM.InstanceType),
+		MaxCount:            aws.Int64(1),
+		MinCount:            aws.Int64(1),
+		TagSpecifications:   append(awsTagSpecifications(awsServerTags(VM), ec2.ResourceTypeInstance), awsTagSpecifications(VM.Tags, ec2.ResourceTypeVolume)...),
+		BlockDeviceMappings: awsDataDiskMappings(VM),
+	}
+	bootDisk, err := A.awsBootDiskMapping(ctx, ec2Svc, VM, imageID)
+	if err != nil {
+		return "", err
+	}
+	if bootDisk != nil {
+		runInput.BlockDeviceMappings = append([]*ec2.BlockDeviceMapping{bootDisk}, runInput.BlockDeviceMappings...)
+	}
+	if VM.SubnetID != "" {
+		runInput.SubnetId = aws.String(VM.SubnetID)
+	}
+	if VM.AWS.SecurityGroup != "" {
+		runInput.SecurityGroupIds = append(runInput.SecurityGroupIds, aws.String(VM.AWS.SecurityGroup))
+	}
+	launched := false
+	if len(VM.Ingress) > 0 {
+		groupID, err := A.createIngressGroup(ctx, ec2Svc, VM)
+		if err != nil {
+			return "", err
+		}
+		// Only the instance uses the group, so do not leave it behind when
+		// the instance is not launched.
+		defer func() {
+			if !launched {
+				_, _ = ec2Svc.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{GroupId: aws.String(groupID)})
+			}
+		}()
+		runInput.SecurityGroupIds = append(runInput.SecurityGroupIds, aws.String(groupID))
+	}
+	if len(VM.FirewallTags) > 0 {
+		vpcID, err := awsVpcID(ctx, ec2Svc, VM.SubnetID)
+		if err != nil {
+			return "", err
+		}
+		groupIDs, err := A.firewallGroups(ctx, ec2Svc, vpcID, VM.FirewallTags)
+		if err != nil {
+			return "", err
+		}
+		runInput.SecurityGroupIds = append(runInput.SecurityGroupIds, groupIDs...)
+	}
+	switch {
+	case VM.KeyPairName != "":
+		runInput.KeyName = aws.String(VM.KeyPairName)
+	case len(VM.SSHPublicKeys) > 0:
+		keyName, err := A.ensureKeyPair(ctx, ec2Svc, VM.SSHPublicKeys[0])
+		if err != nil {
+			return "", err
+		}
+		runInput.KeyName = aws.String(keyName)
+	}
+	runInput.InstanceMarketOptions = awsMarketOptions(VM.Capacity)
+	runInput.IamInstanceProfile = awsInstanceProfile(VM.ServiceIdentity)
+	if VM.DeletionProtection {
+		runInput.DisableApiTermination = aws.Bool(true)
+	}
+	if VM.AWS.Hibernation {
+		runInput.HibernationOptions = &ec2.HibernationOptionsRequest{Configured: aws.Bool(true)}
+	}
+	if VM.UserData != "" {
+		runInput.UserData = aws.String(base64.StdEncoding.EncodeToString([]byte(VM.UserData)))
+	}
+	if VM.Zone != "" {
+		runInput.Placement = &ec2.Placement{AvailabilityZone: aws.String(VM.Zone)}
 	}
+	awsNetworkInterface(runInput, VM.PublicIPMode)
 
 	// Create the EC2 instance
 	result, err := ec2Svc.RunInstancesWithContext(ctx, runInput)
 	if err != nil {
 		return "", err
 	}
+	launched = true
 	instanceID := aws.StringValue(result.Instances[0].InstanceId)
+	// With a subnet the zone is decided by AWS, so record where it landed.
+	if result.Instances[0].Placement != nil {
+		VM.Zone = aws.StringValue(result.Instances[0].Placement.AvailabilityZone)
+	}
+	if VM.PublicIPMode == "static" {
+		VM.ID = instanceID
+		if err := A.associateElasticIP(ctx, ec2Svc, VM); err != nil {
+			return instanceID, err
+		}
+	}
 	return instanceID, nil
 }
 
+// selectZone picks the first availability zone of VM.Region, in name order,
+// that offers VM.InstanceType.
+func (A *AWSProvider) selectZone(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig) (string, error) {
+	if VM.Region == "" {
+		return "", fmt.Errorf("region must be set")
+	}
+	var zones []string
+	input := &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String(ec2.LocationTypeAvailabilityZone),
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-type"),
+				Values: []*string{aws.String(VM.InstanceType)},
+			},
+		},
+	}
+	err := ec2Svc.DescribeInstanceTypeOfferingsPagesWithContext(ctx, input, func(page *ec2.DescribeInstanceTypeOfferingsOutput, _ bool) bool {
+		for _, offering := range page.InstanceTypeOfferings {
+			zones = append(zones, aws.StringValue(offering.Location))
+		}
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("error describing instance type offerings: %w", err)
+	}
+	if len(zones) == 0 {
+		return "", fmt.Errorf("no availability zone in %s offers instance type %s", VM.Region, VM.InstanceType)
+	}
+	sort.Strings(zones)
+	return zones[0], nil
+}
+
 func (A *AWSProvider) DeleteInstance(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error {
 	awsClient, ok := client.(*AWSClient)
 	if !ok {
 		return fmt.Errorf("invalid AWS client")
 	}
-	ec2Svc := ec2.New(awsClient.client)
+	ec2Svc := awsClient.ec2Service(VM.Region)
 	instanceID := aws.String(VM.ID)
+	// A persistent spot request launches a new instance once its instance is
+	// terminated, so it has to be cancelled first.
+	if err := A.cancelSpotRequest(ctx, ec2Svc, instanceID); err != nil {
+		return err
+	}
 	terminateInput := &ec2.TerminateInstancesInput{
 		InstanceIds: []*string{instanceID},
 	}
@@ -53,7 +211,30 @@ func (A *AWSProvider) DeleteInstance(ctx context.Context, VM *vmconfig.VMConfig,
 	if err != nil {
 		return err
 	}
-	return nil
+	group, err := A.findIngressGroup(ctx, ec2Svc, VM)
+	if err != nil {
+		return err
+	}
+	addresses, err := A.findElasticIPs(ctx, ec2Svc, VM)
+	if err != nil {
+		return err
+	}
+	if group == nil && len(addresses) == 0 {
+		return nil
+	}
+	// The security group and Elastic IPs can only go once the instance no
+	// longer uses them.
+	err = ec2Svc.WaitUntilInstanceTerminatedWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: []*string{instanceID}})
+	if err != nil {
+		return fmt.Errorf("error waiting for %s to terminate: %w", VM.ID, err)
+	}
+	if err := A.releaseElasticIPs(ctx, ec2Svc, addresses); err != nil {
+		return err
+	}
+	if group == nil {
+		return nil
+	}
+	return A.deleteIngressGroup(ctx, ec2Svc, VM)
 }
 
 func (A *AWSProvider) GetInstance(ctx context.Context, data *schema.ResourceData, client interface{}) (interface{}, error) {
@@ -61,50 +242,146 @@ func (A *AWSProvider) GetInstance(ctx context.Context, data *schema.ResourceData
 	if !ok {
 		return nil, fmt.Errorf("invalid aws client")
 	}
-	ec2Svc := ec2.New(awsClient.client)
+	ec2Svc := awsClient.ec2Service(data.Get("region").(string))
 	describeInput := &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{aws.String(data.Id())},
 	}
 	// Describe the instance
 	result, err := ec2Svc.DescribeInstancesWithContext(ctx, describeInput)
 	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == "InvalidInstanceID.NotFound" {
+			return nil, nil
+		}
 		return nil, err
 	}
 
 	// Check if any instances were found
 	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
-		return nil, fmt.Errorf("instance not found")
+		return nil, nil
 	}
 
 	// Extract instance details
 	awsInstance := result.Reservations[0].Instances[0]
-	return awsInstance, nil
+	if aws.StringValue(awsInstance.State.Name) == ec2.InstanceStateNameTerminated {
+		return nil, nil
+	}
+	// DescribeInstances does not return the termination protection flag.
+	attribute, err := ec2Svc.DescribeInstanceAttributeWithContext(ctx, &ec2.DescribeInstanceAttributeInput{
+		InstanceId: awsInstance.InstanceId,
+		Attribute:  aws.String(ec2.InstanceAttributeNameDisableApiTermination),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the termination protection of %s: %w", data.Id(), err)
+	}
+	instance := &AWSInstance{Instance: awsInstance}
+	if attribute.DisableApiTermination != nil {
+		instance.DisableAPITermination = aws.BoolValue(attribute.DisableApiTermination.Value)
+	}
+	return instance, nil
+}
+
+// AWSInstance is an EC2 instance with the attributes DescribeInstances leaves
+// out.
+type AWSInstance struct {
+	Instance              *ec2.Instance
+	DisableAPITermination bool
 }
 
 type AWSProvider struct {
 }
 
 func (A *AWSProvider) VMtoMap(VM *vmconfig.VMConfig) map[string]interface{} {
-	return map[string]interface{}{
-		"name":           VM.Name,
-		"instance_type":  VM.InstanceType,
-		"region":         VM.Region,
-		"id":             VM.ID,
-		"subnet_id":      VM.SubnetID,
-		"security_group": VM.AWSSecurityGroup,
+	vmMap := map[string]interface{}{
+		"name":                VM.Name,
+		"instance_type":       VM.InstanceType,
+		"region":              VM.Region,
+		"zone":                VM.Zone,
+		"id":                  VM.ID,
+		"subnet_id":           VM.SubnetID,
+		"tags_all":            VM.Tags,
+		"capacity":            VM.Capacity.Model,
+		"power_state":         VM.PowerState,
+		"deletion_protection": VM.DeletionProtection,
+		"public_ip":           VM.PublicIPMode,
+		"public_ip_address":   VM.PublicIP,
+		"private_ip_address":  VM.PrivateIP,
+	}
+	if VM.AWS != nil {
+		vmMap["aws"] = []interface{}{
+			map[string]interface{}{
+				"ami_id":         VM.AWS.AMI,
+				"security_group": VM.AWS.SecurityGroup,
+				"hibernation":    VM.AWS.Hibernation,
+			},
+		}
 	}
+	return vmMap
 }
 
 func (A *AWSProvider) UpdateInstance(ctx context.Context, new interface{}, old interface{}, client interface{}, vmConfig *vmconfig.VMConfig) error {
-	//TODO implement me
-	panic("implement me")
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	oldAWSInstance, ok := old.(*AWSInstance)
+	if !ok {
+		return fmt.Errorf("expected *AWSInstance got %T", old)
+	}
+	oldInstance := oldAWSInstance.Instance
+	ec2Svc := awsClient.ec2Service(vmConfig.Region)
+	if err := A.updateTags(ctx, ec2Svc, oldInstance.InstanceId, oldInstance.Tags, awsServerTags(vmConfig), vmConfig.IgnoreTagKeys); err != nil {
+		return err
+	}
+	if err := A.updateIngress(ctx, ec2Svc, oldInstance, vmConfig); err != nil {
+		return err
+	}
+	return A.syncFirewallGroups(ctx, ec2Svc, oldInstance.InstanceId, vmConfig.FirewallTags)
+}
+
+// updateTags makes the tags of resourceID match tags, leaving ignored tags
+// alone.
+func (A *AWSProvider) updateTags(ctx context.Context, ec2Svc *ec2.EC2, resourceID *string, current []*ec2.Tag, tags map[string]string, ignoreTagKeys []string) error {
+	var removed []*ec2.Tag
+	for _, tag := range current {
+		key := aws.StringValue(tag.Key)
+		if _, ok := tags[key]; !ok && !isIgnoredTag(key, ignoreTagKeys) {
+			removed = append(removed, &ec2.Tag{Key: tag.Key})
+		}
+	}
+	if len(removed) > 0 {
+		_, err := ec2Svc.DeleteTagsWithContext(ctx, &ec2.DeleteTagsInput{
+			Resources: []*string{resourceID},
+			Tags:      removed,
+		})
+		if err != nil {
+			return fmt.Errorf("error removing tags: %w", err)
+		}
+	}
+	if len(tags) > 0 {
+		_, err := ec2Svc.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+			Resources: []*string{resourceID},
+			Tags:      awsTags(tags),
+		})
+		if err != nil {
+			return fmt.Errorf("error setting tags: %w", err)
+		}
+	}
+	return nil
 }
 
 func (A *AWSProvider) ProviderName() string {
 	return "aws"
 }
 func (A *AWSProvider) SetDataFromVM(VM *vmconfig.VMConfig, data *schema.ResourceData) diag.Diagnostics {
-	return nil
+	var diags diag.Diagnostics
+	vmMap := A.VMtoMap(VM)
+	for k, v := range vmMap {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
 }
 
 func (A *AWSProvider) CreateClient(credential string) (interface{}, error) {
@@ -121,9 +398,112 @@ func (A *AWSProvider) CreateClient(credential string) (interface{}, error) {
 }
 
 func (G *AWSProvider) NewInstance(instance interface{}, data *schema.ResourceData) (interface{}, error) {
-	return instance.(*AWSProvider), nil
+	awsInstance, ok := instance.(*AWSInstance)
+	if !ok {
+		return nil, fmt.Errorf("expected *AWSInstance got %T", instance)
+	}
+	return awsInstance, nil
 }
 
 func (G *AWSProvider) GetInstanceConfig(instance interface{}, data *schema.ResourceData) *vmconfig.VMConfig {
+	awsInstance := instance.(*AWSInstance).Instance
+	config := &vmconfig.VMConfig{
+		ID:           aws.StringValue(awsInstance.InstanceId),
+		Name:         data.Get("name").(string),
+		Region:       data.Get("region").(string),
+		InstanceType: aws.StringValue(awsInstance.InstanceType),
+		SubnetID:     aws.StringValue(awsInstance.SubnetId),
+		PublicIP:     aws.StringValue(awsInstance.PublicIpAddress),
+		PrivateIP:    aws.StringValue(awsInstance.PrivateIpAddress),
+		PublicIPMode: awsPublicIPMode(awsInstance, data.Get("public_ip").(string)),
+		AWS: &vmconfig.AWSOptions{
+			AMI:           aws.StringValue(awsInstance.ImageId),
+			SecurityGroup: data.Get("aws.0.security_group").(string),
+		},
+		SourceImageID:      data.Get("source_image_id").(string),
+		SourceSnapshot:     data.Get("source_snapshot").(string),
+		Tags:               map[string]string{},
+		Capacity:           vmconfig.Capacity{Model: "on_demand"},
+		PowerState:         awsPowerState(awsInstance),
+		DeletionProtection: instance.(*AWSInstance).DisableAPITermination,
+	}
+	if config.SourceImageID != "" || config.SourceSnapshot != "" {
+		// The instance reports the source image, or the temporary AMI of a
+		// snapshot, which would otherwise show as a change of ami_id.
+		config.AWS.AMI = data.Get("aws.0.ami_id").(string)
+	}
+	if awsInstance.HibernationOptions != nil {
+		config.AWS.Hibernation = aws.BoolValue(awsInstance.HibernationOptions.Configured)
+	}
+	if aws.StringValue(awsInstance.InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot {
+		config.Capacity.Model = "spot"
+	}
+	for _, tag := range awsInstance.Tags {
+		if key := aws.StringValue(tag.Key); !strings.HasPrefix(key, "aws:") && !strings.HasPrefix(key, awsServerTagPrefix) {
+			config.Tags[key] = aws.StringValue(tag.Value)
+		}
+	}
+	// The Name tag carries the name, which is how an imported instance gets one.
+	if name, ok := config.Tags["Name"]; ok && config.Name == "" {
+		config.Name = name
+	}
+	if awsInstance.Placement != nil {
+		config.Zone = aws.StringValue(awsInstance.Placement.AvailabilityZone)
+	}
+	return config
+}
+
+// cancelSpotRequest cancels the spot request that launched instanceID, if
+// any. Cancelling leaves the instance running.
+func (A *AWSProvider) cancelSpotRequest(ctx context.Context, ec2Svc *ec2.EC2, instanceID *string) error {
+	result, err := ec2Svc.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{instanceID},
+	})
+	if isAWSNotFound(err, "InvalidInstanceID.NotFound") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading instance %s: %w", aws.StringValue(instanceID), err)
+	}
+	var requestIDs []*string
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.SpotInstanceRequestId != nil {
+				requestIDs = append(requestIDs, instance.SpotInstanceRequestId)
+			}
+		}
+	}
+	if len(requestIDs) == 0 {
+		return nil
+	}
+	_, err = ec2Svc.CancelSpotInstanceRequestsWithContext(ctx, &ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: requestIDs,
+	})
+	if err != nil && !isAWSNotFound(err, "InvalidSpotInstanceRequestID.NotFound") {
+		return fmt.Errorf("error cancelling the spot request of %s: %w", aws.StringValue(instanceID), err)
+	}
 	return nil
 }
+
+// awsMarketOptions requests spot capacity. Spot instances that stop on
+// interruption must be persistent requests; "delete" maps to terminate.
+func awsMarketOptions(capacity vmconfig.Capacity) *ec2.InstanceMarketOptionsRequest {
+	if capacity.Model != "spot" {
+		return nil
+	}
+	spotOptions := &ec2.SpotMarketOptions{}
+	if capacity.MaxPrice != "" {
+		spotOptions.MaxPrice = aws.String(capacity.MaxPrice)
+	}
+	switch capacity.InterruptionBehavior {
+	case "stop":
+		spotOptions.InstanceInterruptionBehavior = aws.String(ec2.InstanceInterruptionBehaviorStop)
+		spotOptions.SpotInstanceType = aws.String(ec2.SpotInstanceTypePersistent)
+	case "terminate", "delete":
+		spotOptions.InstanceInterruptionBehavior = aws.String(ec2.InstanceInterruptionBehaviorTerminate)
+	}
+	return &ec2.InstanceMarketOptionsRequest{
+		MarketType:  aws.String(ec2.MarketTypeSpot),
+		SpotOptions: spotOptions,
+	}
+}
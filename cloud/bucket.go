@@ -0,0 +1,444 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	gcs "google.golang.org/api/storage/v1"
+)
+
+// awsLifecycleRuleID names the lifecycle rules of a bucket by position.
+const awsLifecycleRuleID = "cloudfusion-%d"
+
+// isS3NotFound reports whether err is one of the 404 errors S3 returns for a
+// missing bucket or a missing bucket setting.
+func isS3NotFound(err error) bool {
+	if failure, ok := err.(awserr.RequestFailure); ok && failure.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	return isAWSNotFound(err, s3.ErrCodeNoSuchBucket)
+}
+
+// awsLifecycleRules converts rules to S3 lifecycle rules.
+func awsLifecycleRules(rules []storage.LifecycleRule) []*s3.LifecycleRule {
+	converted := make([]*s3.LifecycleRule, 0, len(rules))
+	for i, rule := range rules {
+		converted = append(converted, &s3.LifecycleRule{
+			ID:         aws.String(fmt.Sprintf(awsLifecycleRuleID, i)),
+			Status:     aws.String(s3.ExpirationStatusEnabled),
+			Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String(rule.Prefix)},
+			Expiration: &s3.LifecycleExpiration{Days: aws.Int64(rule.ExpirationDays)},
+		})
+	}
+	return converted
+}
+
+// awsBucketLifecycle converts S3 lifecycle rules back. Only enabled rules
+// expiring objects after a number of days are reported.
+func awsBucketLifecycle(rules []*s3.LifecycleRule) []storage.LifecycleRule {
+	var converted []storage.LifecycleRule
+	for _, rule := range rules {
+		if aws.StringValue(rule.Status) != s3.ExpirationStatusEnabled || rule.Expiration == nil || rule.Expiration.Days == nil {
+			continue
+		}
+		prefix := aws.StringValue(rule.Prefix)
+		if rule.Filter != nil {
+			prefix = aws.StringValue(rule.Filter.Prefix)
+			if rule.Filter.And != nil {
+				prefix = aws.StringValue(rule.Filter.And.Prefix)
+			}
+		}
+		converted = append(converted, storage.LifecycleRule{Prefix: prefix, ExpirationDays: aws.Int64Value(rule.Expiration.Days)})
+	}
+	return converted
+}
+
+// CreateBucket creates the S3 bucket of b and applies its settings.
+func (A *AWSProvider) CreateBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	s3Svc := awsClient.s3Service(b.Region)
+	input := &s3.CreateBucketInput{Bucket: aws.String(b.Name)}
+	// us-east-1 is the default location, which S3 refuses as a constraint.
+	if b.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(b.Region)}
+	}
+	if _, err := s3Svc.CreateBucketWithContext(ctx, input); err != nil {
+		return fmt.Errorf("error creating bucket %s: %w", b.Name, err)
+	}
+	b.ID = b.Name
+	if err := s3Svc.WaitUntilBucketExistsWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(b.Name)}); err != nil {
+		return fmt.Errorf("error waiting for bucket %s: %w", b.Name, err)
+	}
+	return A.applyBucket(ctx, s3Svc, b, nil)
+}
+
+// applyBucket applies the versioning, lifecycle rules, encryption and tags
+// of b. Settings equal to the ones of old, when known, are left alone.
+func (A *AWSProvider) applyBucket(ctx context.Context, s3Svc *s3.S3, b, old *storage.BucketConfig) error {
+	bucket := aws.String(b.ID)
+	// A bucket that was never versioned is left unversioned rather than
+	// suspended.
+	if (old == nil && b.Versioning) || (old != nil && old.Versioning != b.Versioning) {
+		status := s3.BucketVersioningStatusSuspended
+		if b.Versioning {
+			status = s3.BucketVersioningStatusEnabled
+		}
+		_, err := s3Svc.PutBucketVersioningWithContext(ctx, &s3.PutBucketVersioningInput{
+			Bucket:                  bucket,
+			VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(status)},
+		})
+		if err != nil {
+			return fmt.Errorf("error setting the versioning of %s: %w", b.ID, err)
+		}
+	}
+
+	switch {
+	case len(b.Lifecycle) > 0:
+		_, err := s3Svc.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 bucket,
+			LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: awsLifecycleRules(b.Lifecycle)},
+		})
+		if err != nil {
+			return fmt.Errorf("error setting the lifecycle rules of %s: %w", b.ID, err)
+		}
+	case old != nil && len(old.Lifecycle) > 0:
+		if _, err := s3Svc.DeleteBucketLifecycleWithContext(ctx, &s3.DeleteBucketLifecycleInput{Bucket: bucket}); err != nil {
+			return fmt.Errorf("error removing the lifecycle rules of %s: %w", b.ID, err)
+		}
+	}
+
+	if old == nil || old.KMSKeyID != b.KMSKeyID {
+		rule := &s3.ServerSideEncryptionByDefault{SSEAlgorithm: aws.String(s3.ServerSideEncryptionAes256)}
+		if b.KMSKeyID != "" {
+			rule = &s3.ServerSideEncryptionByDefault{
+				SSEAlgorithm:   aws.String(s3.ServerSideEncryptionAwsKms),
+				KMSMasterKeyID: aws.String(b.KMSKeyID),
+			}
+		}
+		_, err := s3Svc.PutBucketEncryptionWithContext(ctx, &s3.PutBucketEncryptionInput{
+			Bucket: bucket,
+			ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{
+				Rules: []*s3.ServerSideEncryptionRule{{ApplyServerSideEncryptionByDefault: rule}},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error setting the encryption of %s: %w", b.ID, err)
+		}
+	}
+	return A.updateBucketTags(ctx, s3Svc, b)
+}
+
+// updateBucketTags replaces the tags of the bucket of b, keeping the ignored
+// ones. S3 only replaces the whole tag set.
+func (A *AWSProvider) updateBucketTags(ctx context.Context, s3Svc *s3.S3, b *storage.BucketConfig) error {
+	bucket := aws.String(b.ID)
+	current, err := A.bucketTags(ctx, s3Svc, b.ID)
+	if err != nil {
+		return err
+	}
+	tags := make(map[string]string, len(b.Tags))
+	for k, v := range current {
+		if isIgnoredTag(k, b.IgnoreTagKeys) {
+			tags[k] = v
+		}
+	}
+	for k, v := range b.Tags {
+		tags[k] = v
+	}
+	if len(tags) == 0 {
+		if len(current) == 0 {
+			return nil
+		}
+		if _, err := s3Svc.DeleteBucketTaggingWithContext(ctx, &s3.DeleteBucketTaggingInput{Bucket: bucket}); err != nil {
+			return fmt.Errorf("error removing the tags of %s: %w", b.ID, err)
+		}
+		return nil
+	}
+	tagSet := make([]*s3.Tag, 0, len(tags))
+	for _, tag := range awsTags(tags) {
+		tagSet = append(tagSet, &s3.Tag{Key: tag.Key, Value: tag.Value})
+	}
+	_, err = s3Svc.PutBucketTaggingWithContext(ctx, &s3.PutBucketTaggingInput{Bucket: bucket, Tagging: &s3.Tagging{TagSet: tagSet}})
+	if err != nil {
+		return fmt.Errorf("error setting the tags of %s: %w", b.ID, err)
+	}
+	return nil
+}
+
+func (A *AWSProvider) bucketTags(ctx context.Context, s3Svc *s3.S3, bucket string) (map[string]string, error) {
+	tagging, err := s3Svc.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		if isAWSNotFound(err, "NoSuchTagSet") {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("error reading the tags of %s: %w", bucket, err)
+	}
+	tags := make(map[string]string, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+// GetBucket reads the S3 bucket of b. It returns nil when the bucket no
+// longer exists.
+func (A *AWSProvider) GetBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) (*storage.BucketConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	s3Svc := awsClient.s3Service(b.Region)
+	bucket := aws.String(b.ID)
+	if _, err := s3Svc.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: bucket}); err != nil {
+		if isS3NotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading bucket %s: %w", b.ID, err)
+	}
+	config := *b
+	config.Name = b.ID
+
+	versioning, err := s3Svc.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{Bucket: bucket})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the versioning of %s: %w", b.ID, err)
+	}
+	config.Versioning = aws.StringValue(versioning.Status) == s3.BucketVersioningStatusEnabled
+
+	config.Lifecycle = nil
+	lifecycle, err := s3Svc.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+	switch {
+	case err == nil:
+		config.Lifecycle = awsBucketLifecycle(lifecycle.Rules)
+	case !isAWSNotFound(err, "NoSuchLifecycleConfiguration"):
+		return nil, fmt.Errorf("error reading the lifecycle rules of %s: %w", b.ID, err)
+	}
+
+	config.KMSKeyID = ""
+	encryption, err := s3Svc.GetBucketEncryptionWithContext(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket})
+	switch {
+	case err == nil && encryption.ServerSideEncryptionConfiguration != nil:
+		for _, rule := range encryption.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault != nil {
+				config.KMSKeyID = knownKMSKey(aws.StringValue(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID), b.KMSKeyID)
+			}
+		}
+	case err != nil && !isAWSNotFound(err, "ServerSideEncryptionConfigurationNotFoundError"):
+		return nil, fmt.Errorf("error reading the encryption of %s: %w", b.ID, err)
+	}
+
+	tags, err := A.bucketTags(ctx, s3Svc, b.ID)
+	if err != nil {
+		return nil, err
+	}
+	config.Tags = map[string]string{}
+	for k, v := range tags {
+		if !isIgnoredTag(k, b.IgnoreTagKeys) {
+			config.Tags[k] = v
+		}
+	}
+	return &config, nil
+}
+
+// UpdateBucket applies the settings of b that changed from old.
+func (A *AWSProvider) UpdateBucket(ctx context.Context, b, old *storage.BucketConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	return A.applyBucket(ctx, awsClient.s3Service(b.Region), b, old)
+}
+
+// DeleteBucket deletes the S3 bucket of b, after every object version when
+// ForceDestroy is set. S3 refuses to delete a bucket that is not empty.
+func (A *AWSProvider) DeleteBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	s3Svc := awsClient.s3Service(b.Region)
+	bucket := aws.String(b.ID)
+	if b.ForceDestroy {
+		var deleteErr error
+		err := s3Svc.ListObjectVersionsPagesWithContext(ctx, &s3.ListObjectVersionsInput{Bucket: bucket}, func(page *s3.ListObjectVersionsOutput, _ bool) bool {
+			var objects []*s3.ObjectIdentifier
+			for _, version := range page.Versions {
+				objects = append(objects, &s3.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+			}
+			for _, marker := range page.DeleteMarkers {
+				objects = append(objects, &s3.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+			}
+			if len(objects) == 0 {
+				return true
+			}
+			// A page holds at most 1000 versions, the limit of DeleteObjects.
+			result, err := s3Svc.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+				Bucket: bucket,
+				Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+			})
+			if err == nil && len(result.Errors) > 0 {
+				err = fmt.Errorf("%s: %s", aws.StringValue(result.Errors[0].Key), aws.StringValue(result.Errors[0].Message))
+			}
+			deleteErr = err
+			return err == nil
+		})
+		if err == nil {
+			err = deleteErr
+		}
+		if err != nil && !isS3NotFound(err) {
+			return fmt.Errorf("error emptying bucket %s: %w", b.ID, err)
+		}
+	}
+	if _, err := s3Svc.DeleteBucketWithContext(ctx, &s3.DeleteBucketInput{Bucket: bucket}); err != nil && !isS3NotFound(err) {
+		return fmt.Errorf("error deleting bucket %s: %w", b.ID, err)
+	}
+	return nil
+}
+
+// gcsLifecycle converts rules to the delete rules of a GCS bucket.
+func gcsLifecycle(rules []storage.LifecycleRule) *gcs.BucketLifecycle {
+	lifecycle := &gcs.BucketLifecycle{Rule: []*gcs.BucketLifecycleRule{}, ForceSendFields: []string{"Rule"}}
+	for _, rule := range rules {
+		condition := &gcs.BucketLifecycleRuleCondition{Age: aws.Int64(rule.ExpirationDays)}
+		if rule.Prefix != "" {
+			condition.MatchesPrefix = []string{rule.Prefix}
+		}
+		lifecycle.Rule = append(lifecycle.Rule, &gcs.BucketLifecycleRule{
+			Action:    &gcs.BucketLifecycleRuleAction{Type: "Delete"},
+			Condition: condition,
+		})
+	}
+	return lifecycle
+}
+
+// gcsBucketLifecycle converts GCS lifecycle rules back. Only rules deleting
+// objects by age, with at most one prefix, are reported.
+func gcsBucketLifecycle(lifecycle *gcs.BucketLifecycle) []storage.LifecycleRule {
+	if lifecycle == nil {
+		return nil
+	}
+	var rules []storage.LifecycleRule
+	for _, rule := range lifecycle.Rule {
+		if rule.Action == nil || rule.Action.Type != "Delete" || rule.Condition == nil || rule.Condition.Age == nil || len(rule.Condition.MatchesPrefix) > 1 {
+			continue
+		}
+		converted := storage.LifecycleRule{ExpirationDays: *rule.Condition.Age}
+		if len(rule.Condition.MatchesPrefix) == 1 {
+			converted.Prefix = rule.Condition.MatchesPrefix[0]
+		}
+		rules = append(rules, converted)
+	}
+	return rules
+}
+
+// gcsBucket sets the settings of b on bucket.
+func gcsBucket(bucket *gcs.Bucket, b *storage.BucketConfig) error {
+	labels, err := gcpLabelsKeepingIgnored(b.Tags, bucket.Labels, b.IgnoreTagKeys)
+	if err != nil {
+		return err
+	}
+	bucket.Labels = labels
+	bucket.Versioning = &gcs.BucketVersioning{Enabled: b.Versioning, ForceSendFields: []string{"Enabled"}}
+	bucket.Lifecycle = gcsLifecycle(b.Lifecycle)
+	bucket.Encryption = nil
+	if b.KMSKeyID != "" {
+		bucket.Encryption = &gcs.BucketEncryption{DefaultKmsKeyName: b.KMSKeyID}
+	}
+	return nil
+}
+
+// CreateBucket creates the GCS bucket of b with its settings.
+func (G *GCProvider) CreateBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) error {
+	storageService := client.(*GCPClient).storage
+	bucket := &gcs.Bucket{Name: b.Name, Location: b.Region}
+	if err := gcsBucket(bucket, b); err != nil {
+		return err
+	}
+	if _, err := storageService.Buckets.Insert(b.GCPProjectID, bucket).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("error creating bucket %s: %w", b.Name, err)
+	}
+	b.ID = b.Name
+	return nil
+}
+
+// GetBucket reads the GCS bucket of b. It returns nil when the bucket no
+// longer exists.
+func (G *GCProvider) GetBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) (*storage.BucketConfig, error) {
+	storageService := client.(*GCPClient).storage
+	bucket, err := storageService.Buckets.Get(b.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading bucket %s: %w", b.ID, err)
+	}
+	config := *b
+	config.Name = bucket.Name
+	// GCS reports locations in upper case, whatever the case they were
+	// created with.
+	if !strings.EqualFold(bucket.Location, b.Region) {
+		config.Region = bucket.Location
+	}
+	config.Versioning = bucket.Versioning != nil && bucket.Versioning.Enabled
+	config.Lifecycle = gcsBucketLifecycle(bucket.Lifecycle)
+	config.KMSKeyID = ""
+	if bucket.Encryption != nil {
+		config.KMSKeyID = knownKMSKey(bucket.Encryption.DefaultKmsKeyName, b.KMSKeyID)
+	}
+	config.Tags = map[string]string{}
+	for k, v := range bucket.Labels {
+		if !isIgnoredLabel(k, b.IgnoreTagKeys) {
+			config.Tags[k] = v
+		}
+	}
+	return &config, nil
+}
+
+// UpdateBucket replaces the settings of the GCS bucket of b. The metadata is
+// read first, since an update replaces all of it.
+func (G *GCProvider) UpdateBucket(ctx context.Context, b, _ *storage.BucketConfig, client interface{}) error {
+	storageService := client.(*GCPClient).storage
+	bucket, err := storageService.Buckets.Get(b.ID).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error reading bucket %s: %w", b.ID, err)
+	}
+	if err := gcsBucket(bucket, b); err != nil {
+		return err
+	}
+	if _, err := storageService.Buckets.Update(b.ID, bucket).IfMetagenerationMatch(bucket.Metageneration).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("error updating bucket %s: %w", b.ID, err)
+	}
+	return nil
+}
+
+// DeleteBucket deletes the GCS bucket of b, after every object generation
+// when ForceDestroy is set. GCS refuses to delete a bucket that is not empty.
+func (G *GCProvider) DeleteBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) error {
+	storageService := client.(*GCPClient).storage
+	if b.ForceDestroy {
+		err := storageService.Objects.List(b.ID).Versions(true).Pages(ctx, func(page *gcs.Objects) error {
+			for _, object := range page.Items {
+				err := storageService.Objects.Delete(b.ID, object.Name).Generation(object.Generation).Context(ctx).Do()
+				if err != nil && !isGCPNotFound(err) {
+					return fmt.Errorf("error deleting %s: %w", object.Name, err)
+				}
+			}
+			return nil
+		})
+		if err != nil && !isGCPNotFound(err) {
+			return fmt.Errorf("error emptying bucket %s: %w", b.ID, err)
+		}
+	}
+	if err := storageService.Buckets.Delete(b.ID).Context(ctx).Do(); err != nil && !isGCPNotFound(err) {
+		return fmt.Errorf("error deleting bucket %s: %w", b.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
+)
+
+// fakeS3 is a path-style S3 stand-in keeping the settings of each bucket as
+// the XML documents they were put with.
+type fakeS3 struct {
+	mu       sync.Mutex
+	buckets  map[string]map[string][]byte // bucket name, then setting
+	versions map[string][]string          // bucket name, then key/version of each object
+}
+
+// s3Settings maps the subresources of a bucket to the error S3 returns when
+// they were never put.
+var s3Settings = map[string]string{
+	"versioning": "",
+	"lifecycle":  "NoSuchLifecycleConfiguration",
+	"encryption": "ServerSideEncryptionConfigurationNotFoundError",
+	"tagging":    "NoSuchTagSet",
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := strings.Trim(r.URL.Path, "/")
+	bucket, exists := f.buckets[name]
+	if !exists && !(r.Method == http.MethodPut && r.URL.RawQuery == "") {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	for setting, missing := range s3Settings {
+		if _, ok := query[setting]; !ok {
+			continue
+		}
+		switch r.Method {
+		case http.MethodPut:
+			bucket[setting], _ = io.ReadAll(r.Body)
+		case http.MethodDelete:
+			delete(bucket, setting)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			switch body, ok := bucket[setting]; {
+			case ok:
+				_, _ = w.Write(body)
+			case missing == "":
+				fmt.Fprintf(w, "<VersioningConfiguration/>")
+			default:
+				s3Error(w, http.StatusNotFound, missing)
+			}
+		}
+		return
+	}
+	if _, ok := query["versions"]; ok {
+		fmt.Fprint(w, "<ListVersionsResult><IsTruncated>false</IsTruncated>")
+		for _, object := range f.versions[name] {
+			key, version, _ := strings.Cut(object, "/")
+			fmt.Fprintf(w, "<Version><Key>%s</Key><VersionId>%s</VersionId></Version>", key, version)
+		}
+		fmt.Fprint(w, "</ListVersionsResult>")
+		return
+	}
+	if _, ok := query["delete"]; ok {
+		var request struct {
+			Objects []struct {
+				Key       string
+				VersionId string
+			} `xml:"Object"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			s3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		for _, object := range request.Objects {
+			kept := f.versions[name][:0]
+			for _, v := range f.versions[name] {
+				if v != object.Key+"/"+object.VersionId {
+					kept = append(kept, v)
+				}
+			}
+			f.versions[name] = kept
+		}
+		fmt.Fprint(w, "<DeleteResult/>")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if exists {
+			s3Error(w, http.StatusConflict, "BucketAlreadyOwnedByYou")
+			return
+		}
+		f.buckets[name] = map[string][]byte{}
+	case http.MethodDelete:
+		if len(f.versions[name]) > 0 {
+			s3Error(w, http.StatusConflict, "BucketNotEmpty")
+			return
+		}
+		delete(f.buckets, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *AWSClient) {
+	f := &fakeS3{buckets: map[string]map[string][]byte{}, versions: map[string][]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("eu-west-1"),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	require.NoError(t, err)
+	return f, &AWSClient{client: sess}
+}
+
+func TestAWSBucket(t *testing.T) {
+	ctx := context.Background()
+	f, client := newFakeS3(t)
+	A := &AWSProvider{}
+	b := &storage.BucketConfig{
+		Name:          "cloudfusion-logs",
+		Region:        "eu-west-1",
+		Versioning:    true,
+		Lifecycle:     []storage.LifecycleRule{{Prefix: "tmp/", ExpirationDays: 7}, {ExpirationDays: 365}},
+		KMSKeyID:      "alias/logs",
+		Tags:          map[string]string{"team": "data"},
+		IgnoreTagKeys: []string{"owner"},
+	}
+	require.NoError(t, A.CreateBucket(ctx, b, client))
+	assert.Equal(t, "cloudfusion-logs", b.ID)
+
+	got, err := A.GetBucket(ctx, b, client)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.True(t, got.Versioning)
+	assert.Equal(t, b.Lifecycle, got.Lifecycle)
+	assert.Equal(t, "alias/logs", got.KMSKeyID)
+	assert.Equal(t, map[string]string{"team": "data"}, got.Tags)
+
+	// A tag set outside of Terraform survives the update.
+	f.buckets[b.ID]["tagging"] = []byte("<Tagging><TagSet><Tag><Key>owner</Key><Value>ops</Value></Tag></TagSet></Tagging>")
+	old := *b
+	b.Versioning = false
+	b.Lifecycle = nil
+	b.KMSKeyID = ""
+	b.Tags = map[string]string{}
+	require.NoError(t, A.UpdateBucket(ctx, b, &old, client))
+	got, err = A.GetBucket(ctx, b, client)
+	require.NoError(t, err)
+	assert.False(t, got.Versioning)
+	assert.Contains(t, string(f.buckets[b.ID]["versioning"]), "Suspended", "versioning should be suspended")
+	assert.Empty(t, got.Lifecycle)
+	assert.Empty(t, got.KMSKeyID)
+	assert.Empty(t, got.Tags)
+	assert.Contains(t, string(f.buckets[b.ID]["tagging"]), "owner")
+
+	f.versions[b.ID] = []string{"a.log/1", "a.log/2"}
+	assert.Error(t, A.DeleteBucket(ctx, b, client), "a bucket with objects should only be deleted with force_destroy")
+	b.ForceDestroy = true
+	require.NoError(t, A.DeleteBucket(ctx, b, client))
+	got, err = A.GetBucket(ctx, b, client)
+	assert.NoError(t, err)
+	assert.Nil(t, got, "a deleted bucket should read as gone")
+	assert.NoError(t, A.DeleteBucket(ctx, b, client), "deleting a gone bucket should succeed")
+}
+
+func TestAWSBucketStaysUnversioned(t *testing.T) {
+	ctx := context.Background()
+	f, client := newFakeS3(t)
+	A := &AWSProvider{}
+	b := &storage.BucketConfig{Name: "cloudfusion-plain", Region: "us-east-1"}
+	require.NoError(t, A.CreateBucket(ctx, b, client))
+	_, ok := f.buckets[b.ID]["versioning"]
+	assert.False(t, ok, "a new unversioned bucket should not be suspended")
+	_, ok = f.buckets[b.ID]["lifecycle"]
+	assert.False(t, ok)
+}
+
+// fakeGCS is a GCS JSON API stand-in.
+type fakeGCS struct {
+	mu      sync.Mutex
+	buckets map[string]*gcs.Bucket
+	objects map[string][]*gcs.Object
+}
+
+func gcsError(w http.ResponseWriter, status int) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":"%s"}}`, status, http.StatusText(status))
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/storage/v1/b")
+	if path == "" && r.Method == http.MethodPost {
+		var bucket gcs.Bucket
+		if err := json.NewDecoder(r.Body).Decode(&bucket); err != nil {
+			gcsError(w, http.StatusBadRequest)
+			return
+		}
+		bucket.Metageneration = 1
+		f.buckets[bucket.Name] = &bucket
+		_ = json.NewEncoder(w).Encode(&bucket)
+		return
+	}
+	name, object, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/o")
+	bucket, ok := f.buckets[name]
+	if !ok {
+		gcsError(w, http.StatusNotFound)
+		return
+	}
+	switch {
+	case object == "" && strings.HasSuffix(path, "/o"):
+		_ = json.NewEncoder(w).Encode(&gcs.Objects{Items: f.objects[name]})
+	case object != "":
+		kept := f.objects[name][:0]
+		for _, o := range f.objects[name] {
+			if "/"+o.Name != object || fmt.Sprint(o.Generation) != r.URL.Query().Get("generation") {
+				kept = append(kept, o)
+			}
+		}
+		f.objects[name] = kept
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		if r.URL.Query().Get("ifMetagenerationMatch") != fmt.Sprint(bucket.Metageneration) {
+			gcsError(w, http.StatusPreconditionFailed)
+			return
+		}
+		var updated gcs.Bucket
+		if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+			gcsError(w, http.StatusBadRequest)
+			return
+		}
+		updated.Metageneration = bucket.Metageneration + 1
+		f.buckets[name] = &updated
+		_ = json.NewEncoder(w).Encode(&updated)
+	case r.Method == http.MethodDelete:
+		if len(f.objects[name]) > 0 {
+			gcsError(w, http.StatusConflict)
+			return
+		}
+		delete(f.buckets, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		_ = json.NewEncoder(w).Encode(bucket)
+	}
+}
+
+func TestGCPBucket(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeGCS{buckets: map[string]*gcs.Bucket{}, objects: map[string][]*gcs.Object{}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	service, err := gcs.NewService(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	client := &GCPClient{storage: service}
+	G := &GCProvider{}
+
+	b := &storage.BucketConfig{
+		Name:          "cloudfusion-logs",
+		Region:        "eu",
+		GCPProjectID:  "dantata",
+		Versioning:    true,
+		Lifecycle:     []storage.LifecycleRule{{Prefix: "tmp/", ExpirationDays: 7}},
+		KMSKeyID:      "projects/dantata/locations/eu/keyRings/ring/cryptoKeys/logs",
+		Tags:          map[string]string{"team": "data"},
+		IgnoreTagKeys: []string{"owner"},
+	}
+	require.NoError(t, G.CreateBucket(ctx, b, client))
+	assert.Equal(t, "cloudfusion-logs", b.ID)
+	// GCS reports locations in upper case.
+	f.buckets[b.ID].Location = "EU"
+
+	got, err := G.GetBucket(ctx, b, client)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "eu", got.Region, "the case of the location should be kept")
+	assert.True(t, got.Versioning)
+	assert.Equal(t, b.Lifecycle, got.Lifecycle)
+	assert.Equal(t, b.KMSKeyID, got.KMSKeyID)
+	assert.Equal(t, map[string]string{"team": "data"}, got.Tags)
+
+	f.buckets[b.ID].Labels["owner"] = "ops"
+	b.Versioning = false
+	b.Lifecycle = nil
+	b.KMSKeyID = ""
+	b.Tags = map[string]string{}
+	require.NoError(t, G.UpdateBucket(ctx, b, nil, client))
+	got, err = G.GetBucket(ctx, b, client)
+	require.NoError(t, err)
+	assert.False(t, got.Versioning)
+	assert.Empty(t, got.Lifecycle)
+	assert.Empty(t, got.KMSKeyID)
+	assert.Empty(t, got.Tags)
+	assert.Equal(t, map[string]string{"owner": "ops"}, f.buckets[b.ID].Labels, "ignored labels should be kept")
+
+	f.objects[b.ID] = []*gcs.Object{{Name: "a.log", Generation: 1}, {Name: "a.log", Generation: 2}}
+	assert.Error(t, G.DeleteBucket(ctx, b, client), "a bucket with objects should only be deleted with force_destroy")
+	b.ForceDestroy = true
+	require.NoError(t, G.DeleteBucket(ctx, b, client))
+	got, err = G.GetBucket(ctx, b, client)
+	assert.NoError(t, err)
+	assert.Nil(t, got, "a deleted bucket should read as gone")
+}
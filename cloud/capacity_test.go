@@ -0,0 +1,163 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSMarketOptions(t *testing.T) {
+	assert.Nil(t, awsMarketOptions(vmconfig.Capacity{Model: "on_demand"}))
+
+	options := awsMarketOptions(vmconfig.Capacity{Model: "spot", MaxPrice: "0.05", InterruptionBehavior: "stop"})
+	assert.Equal(t, "spot", aws.StringValue(options.MarketType))
+	assert.Equal(t, "0.05", aws.StringValue(options.SpotOptions.MaxPrice))
+	assert.Equal(t, "stop", aws.StringValue(options.SpotOptions.InstanceInterruptionBehavior))
+	assert.Equal(t, "persistent", aws.StringValue(options.SpotOptions.SpotInstanceType), "stopping spot instances need a persistent request")
+
+	options = awsMarketOptions(vmconfig.Capacity{Model: "spot", InterruptionBehavior: "delete"})
+	assert.Equal(t, "terminate", aws.StringValue(options.SpotOptions.InstanceInterruptionBehavior))
+	assert.Nil(t, options.SpotOptions.MaxPrice)
+}
+
+func TestGCPScheduling(t *testing.T) {
+	assert.Nil(t, gcpScheduling(vmconfig.Capacity{Model: "on_demand"}))
+
+	scheduling := gcpScheduling(vmconfig.Capacity{Model: "spot", InterruptionBehavior: "terminate"})
+	assert.Equal(t, "SPOT", scheduling.ProvisioningModel)
+	assert.Equal(t, "DELETE", scheduling.InstanceTerminationAction)
+	assert.False(t, *scheduling.AutomaticRestart)
+	assert.Equal(t, vmconfig.Capacity{Model: "spot"}, gcpCapacity(scheduling))
+	assert.Equal(t, vmconfig.Capacity{Model: "on_demand"}, gcpCapacity(nil))
+}
+
+// elbv2APIVersion is the Version of ELBv2 requests, which fakeEC2 also
+// answers.
+const elbv2APIVersion = "2015-12-01"
+
+// fakeEC2 answers EC2 and ELBv2 query API actions with canned response bodies
+// and records the calls it receives.
+type fakeEC2 struct {
+	mu        sync.Mutex
+	responses map[string]string // Response body by action
+	errors    map[string]string // Error code by action
+	calls     []url.Values
+}
+
+func (f *fakeEC2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.calls = append(f.calls, r.Form)
+	action := r.Form.Get("Action")
+	elbv2 := r.Form.Get("Version") == elbv2APIVersion
+	code, failed := f.errors[action]
+	body, ok := f.responses[action]
+	if failed || !ok {
+		if !failed {
+			code = "InvalidAction"
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		if elbv2 {
+			fmt.Fprintf(w, "<ErrorResponse><Error><Code>%s</Code><Message>%s</Message></Error><RequestId>1</RequestId></ErrorResponse>", code, code)
+			return
+		}
+		fmt.Fprintf(w, "<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>1</RequestID></Response>", code, code)
+		return
+	}
+	if elbv2 {
+		// Unlike EC2, ELBv2 wraps the result of an action.
+		body = fmt.Sprintf("<%sResult>%s</%sResult>", action, body, action)
+	}
+	fmt.Fprintf(w, "<%sResponse>%s</%sResponse>", action, body, action)
+}
+
+// actions returns the actions called so far, in order.
+func (f *fakeEC2) actions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	actions := make([]string, 0, len(f.calls))
+	for _, call := range f.calls {
+		actions = append(actions, call.Get("Action"))
+	}
+	return actions
+}
+
+// call returns the parameters of the first call of action, or nil.
+func (f *fakeEC2) call(action string) url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, call := range f.calls {
+		if call.Get("Action") == action {
+			return call
+		}
+	}
+	return nil
+}
+
+func newFakeEC2(t *testing.T, responses map[string]string) (*fakeEC2, *AWSClient) {
+	f := &fakeEC2{responses: responses, errors: map[string]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("eu-west-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	require.NoError(t, err)
+	return f, &AWSClient{client: sess}
+}
+
+// ec2DeleteResponses answers the calls DeleteInstance makes for an instance
+// without ingress group or Elastic IP.
+func ec2DeleteResponses(instance string) map[string]string {
+	return map[string]string{
+		"DescribeInstances":          "<reservationSet><item><instancesSet><item>" + instance + "</item></instancesSet></item></reservationSet>",
+		"CancelSpotInstanceRequests": "<spotInstanceRequestSet><item><spotInstanceRequestId>sir-1</spotInstanceRequestId><state>cancelled</state></item></spotInstanceRequestSet>",
+		"TerminateInstances":         "<instancesSet><item><instanceId>i-1</instanceId></item></instancesSet>",
+		"DescribeVpcs":               "<vpcSet><item><vpcId>vpc-1</vpcId></item></vpcSet>",
+		"DescribeSecurityGroups":     "<securityGroupInfo/>",
+		"DescribeAddresses":          "<addressesSet/>",
+	}
+}
+
+func TestAWSDeleteInstanceCancelsSpotRequest(t *testing.T) {
+	ctx := context.Background()
+	A := &AWSProvider{}
+	VM := &vmconfig.VMConfig{ID: "i-1", Name: "web", Region: "eu-west-1"}
+
+	f, client := newFakeEC2(t, ec2DeleteResponses("<instanceId>i-1</instanceId><spotInstanceRequestId>sir-1</spotInstanceRequestId>"))
+	require.NoError(t, A.DeleteInstance(ctx, VM, client))
+	assert.Equal(t, []string{"DescribeInstances", "CancelSpotInstanceRequests", "TerminateInstances"}, f.actions()[:3], "the request must be cancelled before it replaces the instance")
+	assert.Equal(t, "sir-1", f.call("CancelSpotInstanceRequests").Get("SpotInstanceRequestId.1"))
+
+	f, client = newFakeEC2(t, ec2DeleteResponses("<instanceId>i-1</instanceId>"))
+	require.NoError(t, A.DeleteInstance(ctx, VM, client))
+	assert.Nil(t, f.call("CancelSpotInstanceRequests"), "an on-demand instance has no request to cancel")
+	assert.NotNil(t, f.call("TerminateInstances"))
+
+	f, client = newFakeEC2(t, ec2DeleteResponses(""))
+	f.errors["DescribeInstances"] = "InvalidInstanceID.NotFound"
+	require.NoError(t, A.DeleteInstance(ctx, VM, client), "a vanished instance has no request to cancel")
+	assert.Nil(t, f.call("CancelSpotInstanceRequests"))
+
+	f, client = newFakeEC2(t, ec2DeleteResponses("<instanceId>i-1</instanceId><spotInstanceRequestId>sir-1</spotInstanceRequestId>"))
+	f.errors["CancelSpotInstanceRequests"] = "InternalError"
+	assert.Error(t, A.DeleteInstance(ctx, VM, client))
+	assert.Nil(t, f.call("TerminateInstances"), "the instance stays while its request cannot be cancelled")
+}
@@ -0,0 +1,416 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+// dataDiskChange pairs the previous and the desired settings of a data disk
+// that exists on both sides of an update.
+type dataDiskChange struct {
+	old, new vmconfig.DataDisk
+}
+
+// diffDataDisks matches old and new disks by device name.
+func diffDataDisks(old, new []vmconfig.DataDisk) (removed, added []vmconfig.DataDisk, changed []dataDiskChange) {
+	oldByName := make(map[string]vmconfig.DataDisk, len(old))
+	for _, disk := range old {
+		oldByName[disk.DeviceName] = disk
+	}
+	for _, disk := range new {
+		previous, ok := oldByName[disk.DeviceName]
+		if !ok {
+			added = append(added, disk)
+			continue
+		}
+		delete(oldByName, disk.DeviceName)
+		if previous != disk {
+			changed = append(changed, dataDiskChange{old: previous, new: disk})
+		}
+	}
+	for _, disk := range old {
+		if _, ok := oldByName[disk.DeviceName]; ok {
+			removed = append(removed, disk)
+		}
+	}
+	return removed, added, changed
+}
+
+func awsDiskType(class string) string {
+	switch class {
+	case "standard":
+		return ec2.VolumeTypeStandard
+	case "provisioned_iops":
+		return ec2.VolumeTypeIo2
+	default:
+		return ec2.VolumeTypeGp3
+	}
+}
+
+func awsDeviceName(name string) string {
+	if strings.HasPrefix(name, "/dev/") {
+		return name
+	}
+	return "/dev/" + name
+}
+
+func awsEBS(disk vmconfig.DataDisk) *ec2.EbsBlockDevice {
+	ebs := &ec2.EbsBlockDevice{
+		VolumeSize:          aws.Int64(disk.SizeGB),
+		VolumeType:          aws.String(awsDiskType(disk.Type)),
+		DeleteOnTermination: aws.Bool(disk.AutoDelete),
+	}
+	if disk.Type == "provisioned_iops" {
+		ebs.Iops = aws.Int64(disk.IOPS)
+	}
+	if disk.KMSKeyID != "" {
+		ebs.Encrypted = aws.Bool(true)
+		ebs.KmsKeyId = aws.String(disk.KMSKeyID)
+	}
+	return ebs
+}
+
+// awsDataDiskMappings maps the data disks of VM to block device mappings of
+// RunInstances.
+func awsDataDiskMappings(VM *vmconfig.VMConfig) []*ec2.BlockDeviceMapping {
+	mappings := make([]*ec2.BlockDeviceMapping, 0, len(VM.DataDisks))
+	for _, disk := range VM.DataDisks {
+		mappings = append(mappings, &ec2.BlockDeviceMapping{
+			DeviceName: aws.String(awsDeviceName(disk.DeviceName)),
+			Ebs:        awsEBS(disk),
+		})
+	}
+	return mappings
+}
+
+// awsBootDiskMapping maps the boot disk of VM to a block device mapping of
+// the root device of the AMI imageID. It returns nil when the image defaults are kept.
+func (A *AWSProvider) awsBootDiskMapping(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig, imageID string) (*ec2.BlockDeviceMapping, error) {
+	if VM.BootDisk == nil {
+		return nil, nil
+	}
+	images, err := ec2Svc.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(imageID)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading AMI %s: %w", imageID, err)
+	}
+	if len(images.Images) == 0 {
+		return nil, fmt.Errorf("AMI %s not found", imageID)
+	}
+	ebs := &ec2.EbsBlockDevice{DeleteOnTermination: aws.Bool(true)}
+	if VM.BootDisk.SizeGB > 0 {
+		ebs.VolumeSize = aws.Int64(VM.BootDisk.SizeGB)
+	}
+	if VM.BootDisk.Type != "" {
+		ebs.VolumeType = aws.String(awsDiskType(VM.BootDisk.Type))
+	}
+	if VM.BootDisk.Type == "provisioned_iops" {
+		ebs.Iops = aws.Int64(VM.BootDisk.IOPS)
+	}
+	if VM.BootDisk.KMSKeyID != "" {
+		ebs.Encrypted = aws.Bool(true)
+		ebs.KmsKeyId = aws.String(VM.BootDisk.KMSKeyID)
+	}
+	return &ec2.BlockDeviceMapping{
+		DeviceName: images.Images[0].RootDeviceName,
+		Ebs:        ebs,
+	}, nil
+}
+
+// UpdateDataDisks detaches removed volumes, creates and attaches added ones
+// and modifies the ones whose settings changed.
+func (A *AWSProvider) UpdateDataDisks(ctx context.Context, VM *vmconfig.VMConfig, old []vmconfig.DataDisk, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(VM.Region)
+	result, err := ec2Svc.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(VM.ID)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("instance %s not found", VM.ID)
+	}
+	attached := map[string]*string{}
+	for _, mapping := range result.Reservations[0].Instances[0].BlockDeviceMappings {
+		if mapping.Ebs != nil {
+			attached[aws.StringValue(mapping.DeviceName)] = mapping.Ebs.VolumeId
+		}
+	}
+
+	removed, added, changed := diffDataDisks(old, VM.DataDisks)
+	for _, disk := range removed {
+		volumeID, ok := attached[awsDeviceName(disk.DeviceName)]
+		if !ok {
+			continue
+		}
+		if _, err := ec2Svc.DetachVolumeWithContext(ctx, &ec2.DetachVolumeInput{VolumeId: volumeID}); err != nil {
+			return fmt.Errorf("error detaching %s: %w", aws.StringValue(volumeID), err)
+		}
+		volumes := &ec2.DescribeVolumesInput{VolumeIds: []*string{volumeID}}
+		if err := ec2Svc.WaitUntilVolumeAvailableWithContext(ctx, volumes); err != nil {
+			return fmt.Errorf("error waiting for %s to detach: %w", aws.StringValue(volumeID), err)
+		}
+		if disk.AutoDelete {
+			if _, err := ec2Svc.DeleteVolumeWithContext(ctx, &ec2.DeleteVolumeInput{VolumeId: volumeID}); err != nil {
+				return fmt.Errorf("error deleting %s: %w", aws.StringValue(volumeID), err)
+			}
+		}
+	}
+
+	for _, disk := range added {
+		ebs := awsEBS(disk)
+		volume, err := ec2Svc.CreateVolumeWithContext(ctx, &ec2.CreateVolumeInput{
+			AvailabilityZone:  aws.String(VM.Zone),
+			Size:              ebs.VolumeSize,
+			VolumeType:        ebs.VolumeType,
+			Iops:              ebs.Iops,
+			Encrypted:         ebs.Encrypted,
+			KmsKeyId:          ebs.KmsKeyId,
+			TagSpecifications: awsTagSpecifications(VM.Tags, ec2.ResourceTypeVolume),
+		})
+		if err != nil {
+			return fmt.Errorf("error creating volume for %s: %w", disk.DeviceName, err)
+		}
+		volumes := &ec2.DescribeVolumesInput{VolumeIds: []*string{volume.VolumeId}}
+		if err := ec2Svc.WaitUntilVolumeAvailableWithContext(ctx, volumes); err != nil {
+			return fmt.Errorf("error waiting for %s: %w", aws.StringValue(volume.VolumeId), err)
+		}
+		_, err = ec2Svc.AttachVolumeWithContext(ctx, &ec2.AttachVolumeInput{
+			InstanceId: aws.String(VM.ID),
+			VolumeId:   volume.VolumeId,
+			Device:     aws.String(awsDeviceName(disk.DeviceName)),
+		})
+		if err != nil {
+			return fmt.Errorf("error attaching %s: %w", aws.StringValue(volume.VolumeId), err)
+		}
+		if err := ec2Svc.WaitUntilVolumeInUseWithContext(ctx, volumes); err != nil {
+			return fmt.Errorf("error waiting for %s to attach: %w", aws.StringValue(volume.VolumeId), err)
+		}
+		if err := A.setDeleteOnTermination(ctx, ec2Svc, VM.ID, disk); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range changed {
+		volumeID, ok := attached[awsDeviceName(change.new.DeviceName)]
+		if !ok {
+			return fmt.Errorf("no volume attached as %s", change.new.DeviceName)
+		}
+		if change.old.KMSKeyID != change.new.KMSKeyID {
+			return fmt.Errorf("the encryption key of %s cannot be changed", change.new.DeviceName)
+		}
+		if change.old.SizeGB != change.new.SizeGB || change.old.Type != change.new.Type || change.old.IOPS != change.new.IOPS {
+			ebs := awsEBS(change.new)
+			_, err := ec2Svc.ModifyVolumeWithContext(ctx, &ec2.ModifyVolumeInput{
+				VolumeId:   volumeID,
+				Size:       ebs.VolumeSize,
+				VolumeType: ebs.VolumeType,
+				Iops:       ebs.Iops,
+			})
+			if err != nil {
+				return fmt.Errorf("error modifying %s: %w", aws.StringValue(volumeID), err)
+			}
+		}
+		if change.old.AutoDelete != change.new.AutoDelete {
+			if err := A.setDeleteOnTermination(ctx, ec2Svc, VM.ID, change.new); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (A *AWSProvider) setDeleteOnTermination(ctx context.Context, ec2Svc *ec2.EC2, instanceID string, disk vmconfig.DataDisk) error {
+	_, err := ec2Svc.ModifyInstanceAttributeWithContext(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(instanceID),
+		BlockDeviceMappings: []*ec2.InstanceBlockDeviceMappingSpecification{
+			{
+				DeviceName: aws.String(awsDeviceName(disk.DeviceName)),
+				Ebs:        &ec2.EbsInstanceBlockDeviceSpecification{DeleteOnTermination: aws.Bool(disk.AutoDelete)},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error setting auto delete of %s: %w", disk.DeviceName, err)
+	}
+	return nil
+}
+
+func gcpDiskType(project, zone, class string) string {
+	diskType := "pd-ssd"
+	switch class {
+	case "standard":
+		diskType = "pd-standard"
+	case "provisioned_iops":
+		diskType = "pd-extreme"
+	}
+	return fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s", project, zone, diskType)
+}
+
+// gcpDataDiskName is the name of the persistent disk backing a data disk.
+func gcpDataDiskName(instanceName, deviceName string) string {
+	return strings.TrimRight(truncate(instanceName+"-"+deviceName, gceLabelMaxLength), "-")
+}
+
+func gcpEncryptionKey(kmsKeyID string) *compute.CustomerEncryptionKey {
+	if kmsKeyID == "" {
+		return nil
+	}
+	return &compute.CustomerEncryptionKey{KmsKeyName: kmsKeyID}
+}
+
+// gcpDisk returns a standalone persistent disk with the settings of disk.
+func gcpDisk(projectID, zone, name string, disk vmconfig.DataDisk, labels map[string]string) *compute.Disk {
+	newDisk := &compute.Disk{
+		Name:              name,
+		SizeGb:            disk.SizeGB,
+		Type:              gcpDiskType(projectID, zone, disk.Type),
+		Labels:            labels,
+		DiskEncryptionKey: gcpEncryptionKey(disk.KMSKeyID),
+	}
+	if disk.Type == "provisioned_iops" {
+		newDisk.ProvisionedIops = disk.IOPS
+	}
+	return newDisk
+}
+
+// gcpBootDisk returns the boot disk of VM, created from its image family or
+// its source image or snapshot.
+func gcpBootDisk(VM *vmconfig.VMConfig, labels map[string]string) *compute.AttachedDisk {
+	sourceImage, sourceSnapshot := gcpBootDiskSource(VM)
+	disk := &compute.AttachedDisk{
+		AutoDelete: true,
+		Boot:       true,
+		InitializeParams: &compute.AttachedDiskInitializeParams{
+			SourceImage:    sourceImage,
+			SourceSnapshot: sourceSnapshot,
+			Labels:         labels,
+		},
+	}
+	if VM.BootDisk == nil {
+		return disk
+	}
+	disk.InitializeParams.DiskSizeGb = VM.BootDisk.SizeGB
+	if VM.BootDisk.Type != "" {
+		disk.InitializeParams.DiskType = gcpDiskType(VM.GCPProjectID, VM.Zone, VM.BootDisk.Type)
+	}
+	if VM.BootDisk.Type == "provisioned_iops" {
+		disk.InitializeParams.ProvisionedIops = VM.BootDisk.IOPS
+	}
+	disk.DiskEncryptionKey = gcpEncryptionKey(VM.BootDisk.KMSKeyID)
+	return disk
+}
+
+// gcpDataDisks maps the data disks of VM to attached disks of the instance.
+func gcpDataDisks(VM *vmconfig.VMConfig, labels map[string]string) []*compute.AttachedDisk {
+	disks := make([]*compute.AttachedDisk, 0, len(VM.DataDisks))
+	for _, disk := range VM.DataDisks {
+		params := &compute.AttachedDiskInitializeParams{
+			DiskName:   gcpDataDiskName(VM.Name, disk.DeviceName),
+			DiskSizeGb: disk.SizeGB,
+			DiskType:   gcpDiskType(VM.GCPProjectID, VM.Zone, disk.Type),
+			Labels:     labels,
+		}
+		if disk.Type == "provisioned_iops" {
+			params.ProvisionedIops = disk.IOPS
+		}
+		disks = append(disks, &compute.AttachedDisk{
+			DeviceName:        disk.DeviceName,
+			AutoDelete:        disk.AutoDelete,
+			InitializeParams:  params,
+			DiskEncryptionKey: gcpEncryptionKey(disk.KMSKeyID),
+		})
+	}
+	return disks
+}
+
+// UpdateDataDisks detaches removed disks, creates and attaches added ones and
+// resizes the ones that grew.
+func (G *GCProvider) UpdateDataDisks(ctx context.Context, VM *vmconfig.VMConfig, old []vmconfig.DataDisk, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	labels, err := SanitizeGCELabels(VM.Tags)
+	if err != nil {
+		return err
+	}
+	removed, added, changed := diffDataDisks(old, VM.DataDisks)
+	for _, disk := range removed {
+		op, err := computeService.Instances.DetachDisk(VM.GCPProjectID, VM.Zone, VM.Name, disk.DeviceName).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error detaching %s: %w", disk.DeviceName, err)
+		}
+		if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+			return err
+		}
+		if disk.AutoDelete {
+			name := gcpDataDiskName(VM.Name, disk.DeviceName)
+			op, err := computeService.Disks.Delete(VM.GCPProjectID, VM.Zone, name).Context(ctx).Do()
+			if err != nil {
+				return fmt.Errorf("error deleting disk %s: %w", name, err)
+			}
+			if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, disk := range added {
+		name := gcpDataDiskName(VM.Name, disk.DeviceName)
+		newDisk := gcpDisk(VM.GCPProjectID, VM.Zone, name, disk, labels)
+		op, err := computeService.Disks.Insert(VM.GCPProjectID, VM.Zone, newDisk).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error creating disk %s: %w", name, err)
+		}
+		if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+			return err
+		}
+		attachedDisk := &compute.AttachedDisk{
+			Source:     fmt.Sprintf("projects/%s/zones/%s/disks/%s", VM.GCPProjectID, VM.Zone, name),
+			DeviceName: disk.DeviceName,
+			AutoDelete: disk.AutoDelete,
+		}
+		op, err = computeService.Instances.AttachDisk(VM.GCPProjectID, VM.Zone, VM.Name, attachedDisk).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error attaching disk %s: %w", name, err)
+		}
+		if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range changed {
+		name := gcpDataDiskName(VM.Name, change.new.DeviceName)
+		if change.old.Type != change.new.Type || change.old.KMSKeyID != change.new.KMSKeyID || change.old.IOPS != change.new.IOPS {
+			return fmt.Errorf("the type, IOPS and encryption key of disk %s cannot be changed on GCP", name)
+		}
+		if change.old.SizeGB != change.new.SizeGB {
+			op, err := computeService.Disks.Resize(VM.GCPProjectID, VM.Zone, name, &compute.DisksResizeRequest{SizeGb: change.new.SizeGB}).Context(ctx).Do()
+			if err != nil {
+				return fmt.Errorf("error resizing disk %s: %w", name, err)
+			}
+			if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+				return err
+			}
+		}
+		if change.old.AutoDelete != change.new.AutoDelete {
+			op, err := computeService.Instances.SetDiskAutoDelete(VM.GCPProjectID, VM.Zone, VM.Name, change.new.AutoDelete, change.new.DeviceName).Context(ctx).Do()
+			if err != nil {
+				return fmt.Errorf("error setting auto delete of %s: %w", name, err)
+			}
+			if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
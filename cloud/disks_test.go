@@ -0,0 +1,78 @@
+package cloud
+
+import (
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDataDisks(t *testing.T) {
+	old := []vmconfig.DataDisk{
+		{DeviceName: "sdf", SizeGB: 10, Type: "ssd", AutoDelete: true},
+		{DeviceName: "sdg", SizeGB: 20, Type: "ssd", AutoDelete: true},
+		{DeviceName: "sdh", SizeGB: 30, Type: "ssd", AutoDelete: true},
+	}
+	new := []vmconfig.DataDisk{
+		{DeviceName: "sdf", SizeGB: 10, Type: "ssd", AutoDelete: true},
+		{DeviceName: "sdh", SizeGB: 50, Type: "ssd", AutoDelete: true},
+		{DeviceName: "sdi", SizeGB: 5, Type: "standard"},
+	}
+	removed, added, changed := diffDataDisks(old, new)
+	assert.Equal(t, []vmconfig.DataDisk{old[1]}, removed)
+	assert.Equal(t, []vmconfig.DataDisk{new[2]}, added)
+	assert.Equal(t, []dataDiskChange{{old: old[2], new: new[1]}}, changed)
+}
+
+func TestAWSDataDiskMappings(t *testing.T) {
+	mappings := awsDataDiskMappings(&vmconfig.VMConfig{
+		DataDisks: []vmconfig.DataDisk{
+			{DeviceName: "sdf", SizeGB: 100, Type: "provisioned_iops", IOPS: 3000, KMSKeyID: "alias/data", AutoDelete: true},
+			{DeviceName: "/dev/xvdg", SizeGB: 10, Type: "standard"},
+		},
+	})
+	assert.Len(t, mappings, 2)
+	assert.Equal(t, "/dev/sdf", aws.StringValue(mappings[0].DeviceName))
+	assert.Equal(t, "io2", aws.StringValue(mappings[0].Ebs.VolumeType))
+	assert.Equal(t, int64(3000), aws.Int64Value(mappings[0].Ebs.Iops))
+	assert.True(t, aws.BoolValue(mappings[0].Ebs.Encrypted))
+	assert.Equal(t, "/dev/xvdg", aws.StringValue(mappings[1].DeviceName))
+	assert.Nil(t, mappings[1].Ebs.Iops)
+	assert.False(t, aws.BoolValue(mappings[1].Ebs.DeleteOnTermination))
+}
+
+func TestGCPDataDisks(t *testing.T) {
+	disks := gcpDataDisks(&vmconfig.VMConfig{
+		Name:         "db",
+		Zone:         "europe-west1-b",
+		GCPProjectID: "dantata",
+		DataDisks: []vmconfig.DataDisk{
+			{DeviceName: "sdf", SizeGB: 100, Type: "ssd", KMSKeyID: "projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+		},
+	}, nil)
+	assert.Len(t, disks, 1)
+	assert.Equal(t, "db-sdf", disks[0].InitializeParams.DiskName)
+	assert.Equal(t, "projects/dantata/zones/europe-west1-b/diskTypes/pd-ssd", disks[0].InitializeParams.DiskType)
+	assert.Equal(t, "projects/p/locations/l/keyRings/r/cryptoKeys/k", disks[0].DiskEncryptionKey.KmsKeyName)
+	assert.False(t, disks[0].AutoDelete)
+}
+
+func TestGCPBootDisk(t *testing.T) {
+	VM := &vmconfig.VMConfig{
+		Zone:         "europe-west1-b",
+		GCPProjectID: "dantata",
+		GCP:          &vmconfig.GCPOptions{ImageFamily: "ubuntu-2004-lts", ImageProject: "ubuntu-os-cloud"},
+	}
+	disk := gcpBootDisk(VM, nil)
+	assert.True(t, disk.Boot)
+	assert.Equal(t, "projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts", disk.InitializeParams.SourceImage)
+	assert.Zero(t, disk.InitializeParams.DiskSizeGb, "the image size is kept without a boot_disk block")
+	assert.Empty(t, disk.InitializeParams.DiskType)
+
+	VM.BootDisk = &vmconfig.BootDisk{SizeGB: 50, Type: "standard", KMSKeyID: "projects/p/locations/l/keyRings/r/cryptoKeys/k"}
+	disk = gcpBootDisk(VM, nil)
+	assert.Equal(t, int64(50), disk.InitializeParams.DiskSizeGb)
+	assert.Equal(t, "projects/dantata/zones/europe-west1-b/diskTypes/pd-standard", disk.InitializeParams.DiskType)
+	assert.Equal(t, "projects/p/locations/l/keyRings/r/cryptoKeys/k", disk.DiskEncryptionKey.KmsKeyName)
+}
@@ -0,0 +1,232 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	clouddns "google.golang.org/api/dns/v1"
+)
+
+// dnsChangePollInterval is how long to wait between two reads of a pending
+// Cloud DNS change.
+const dnsChangePollInterval = 2 * time.Second
+
+// fqdn returns name with the trailing dot both clouds report.
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// sameDNSName reports whether a and b name the same record. Route 53 escapes
+// the asterisk of wildcard records as \052.
+func sameDNSName(a, b string) bool {
+	unescape := strings.NewReplacer(`\052`, "*").Replace
+	return strings.EqualFold(fqdn(unescape(a)), fqdn(unescape(b)))
+}
+
+// DNSRecordID returns the ID of the record of type recordType named name in
+// zone.
+func DNSRecordID(zone, name, recordType string) string {
+	return fmt.Sprintf("%s/%s/%s", zone, fqdn(name), recordType)
+}
+
+// awsRecordSet converts rec to a Route 53 record set.
+func awsRecordSet(rec *network.DNSRecordConfig) *route53.ResourceRecordSet {
+	records := make([]*route53.ResourceRecord, 0, len(rec.Values))
+	for _, value := range rec.Values {
+		records = append(records, &route53.ResourceRecord{Value: aws.String(value)})
+	}
+	return &route53.ResourceRecordSet{
+		Name:            aws.String(fqdn(rec.Name)),
+		Type:            aws.String(rec.Type),
+		TTL:             aws.Int64(rec.TTL),
+		ResourceRecords: records,
+	}
+}
+
+// changeDNSRecord applies action to the record set of rec and waits for
+// Route 53 to report the change INSYNC.
+func (A *AWSProvider) changeDNSRecord(ctx context.Context, r53 *route53.Route53, action string, rec *network.DNSRecordConfig) error {
+	output, err := r53.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(rec.ZoneID),
+		ChangeBatch: &route53.ChangeBatch{Changes: []*route53.Change{{
+			Action:            aws.String(action),
+			ResourceRecordSet: awsRecordSet(rec),
+		}}},
+	})
+	if err != nil {
+		return fmt.Errorf("error changing record %s %s: %w", rec.Type, rec.Name, err)
+	}
+	err = r53.WaitUntilResourceRecordSetsChangedWithContext(ctx, &route53.GetChangeInput{Id: output.ChangeInfo.Id})
+	if err != nil {
+		return fmt.Errorf("error waiting for record %s %s: %w", rec.Type, rec.Name, err)
+	}
+	return nil
+}
+
+// CreateDNSRecord creates the Route 53 record set of rec.
+func (A *AWSProvider) CreateDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	if err := A.changeDNSRecord(ctx, awsClient.route53Service(), route53.ChangeActionCreate, rec); err != nil {
+		return err
+	}
+	rec.ID = DNSRecordID(rec.ZoneID, rec.Name, rec.Type)
+	return nil
+}
+
+// GetDNSRecord reads the Route 53 record set of rec. It returns nil when the
+// record set or its hosted zone no longer exists.
+func (A *AWSProvider) GetDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) (*network.DNSRecordConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	output, err := awsClient.route53Service().ListResourceRecordSetsWithContext(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(rec.ZoneID),
+		StartRecordName: aws.String(fqdn(rec.Name)),
+		StartRecordType: aws.String(rec.Type),
+		MaxItems:        aws.String("1"),
+	})
+	if err != nil {
+		if isAWSNotFound(err, route53.ErrCodeNoSuchHostedZone) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading record %s %s: %w", rec.Type, rec.Name, err)
+	}
+	if len(output.ResourceRecordSets) == 0 {
+		return nil, nil
+	}
+	set := output.ResourceRecordSets[0]
+	if !sameDNSName(aws.StringValue(set.Name), rec.Name) || aws.StringValue(set.Type) != rec.Type {
+		return nil, nil
+	}
+	config := *rec
+	config.TTL = aws.Int64Value(set.TTL)
+	config.Values = make([]string, 0, len(set.ResourceRecords))
+	for _, record := range set.ResourceRecords {
+		config.Values = append(config.Values, aws.StringValue(record.Value))
+	}
+	return &config, nil
+}
+
+// UpdateDNSRecord replaces the TTL and values of the Route 53 record set of
+// rec in a single change.
+func (A *AWSProvider) UpdateDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	return A.changeDNSRecord(ctx, awsClient.route53Service(), route53.ChangeActionUpsert, rec)
+}
+
+// DeleteDNSRecord deletes the Route 53 record set of rec. Route 53 only
+// deletes a record set given its current TTL and values, so they are read
+// first.
+func (A *AWSProvider) DeleteDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	current, err := A.GetDNSRecord(ctx, rec, client)
+	if err != nil || current == nil {
+		return err
+	}
+	return A.changeDNSRecord(ctx, awsClient.route53Service(), route53.ChangeActionDelete, current)
+}
+
+// gcpRecordSet converts rec to a Cloud DNS record set.
+func gcpRecordSet(rec *network.DNSRecordConfig) *clouddns.ResourceRecordSet {
+	return &clouddns.ResourceRecordSet{
+		Name:    fqdn(rec.Name),
+		Type:    rec.Type,
+		Ttl:     rec.TTL,
+		Rrdatas: rec.Values,
+	}
+}
+
+// changeDNSRecord applies change to the managed zone of rec and waits for
+// Cloud DNS to report it done.
+func (G *GCProvider) changeDNSRecord(ctx context.Context, client interface{}, rec *network.DNSRecordConfig, change *clouddns.Change) error {
+	dnsService := client.(*GCPClient).dns
+	change, err := dnsService.Changes.Create(rec.GCPProjectID, rec.ZoneID, change).Context(ctx).Do()
+	for err == nil && change.Status != "done" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dnsChangePollInterval):
+		}
+		change, err = dnsService.Changes.Get(rec.GCPProjectID, rec.ZoneID, change.Id).Context(ctx).Do()
+	}
+	if err != nil {
+		return fmt.Errorf("error changing record %s %s: %w", rec.Type, rec.Name, err)
+	}
+	return nil
+}
+
+// CreateDNSRecord creates the Cloud DNS record set of rec.
+func (G *GCProvider) CreateDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error {
+	change := &clouddns.Change{Additions: []*clouddns.ResourceRecordSet{gcpRecordSet(rec)}}
+	if err := G.changeDNSRecord(ctx, client, rec, change); err != nil {
+		return err
+	}
+	rec.ID = DNSRecordID(rec.ZoneID, rec.Name, rec.Type)
+	return nil
+}
+
+// GetDNSRecord reads the Cloud DNS record set of rec. It returns nil when the
+// record set or its managed zone no longer exists.
+func (G *GCProvider) GetDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) (*network.DNSRecordConfig, error) {
+	dnsService := client.(*GCPClient).dns
+	list, err := dnsService.ResourceRecordSets.List(rec.GCPProjectID, rec.ZoneID).Name(fqdn(rec.Name)).Type(rec.Type).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading record %s %s: %w", rec.Type, rec.Name, err)
+	}
+	for _, set := range list.Rrsets {
+		if sameDNSName(set.Name, rec.Name) && set.Type == rec.Type {
+			config := *rec
+			config.TTL = set.Ttl
+			config.Values = set.Rrdatas
+			return &config, nil
+		}
+	}
+	return nil, nil
+}
+
+// UpdateDNSRecord replaces the Cloud DNS record set of rec in a single
+// change, so the name always resolves.
+func (G *GCProvider) UpdateDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error {
+	current, err := G.GetDNSRecord(ctx, rec, client)
+	if err != nil {
+		return err
+	}
+	change := &clouddns.Change{Additions: []*clouddns.ResourceRecordSet{gcpRecordSet(rec)}}
+	if current != nil {
+		change.Deletions = []*clouddns.ResourceRecordSet{gcpRecordSet(current)}
+	}
+	return G.changeDNSRecord(ctx, client, rec, change)
+}
+
+// DeleteDNSRecord deletes the Cloud DNS record set of rec. Cloud DNS only
+// deletes a record set given its current TTL and values, so they are read
+// first.
+func (G *GCProvider) DeleteDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error {
+	current, err := G.GetDNSRecord(ctx, rec, client)
+	if err != nil || current == nil {
+		return err
+	}
+	change := &clouddns.Change{Deletions: []*clouddns.ResourceRecordSet{gcpRecordSet(current)}}
+	return G.changeDNSRecord(ctx, client, rec, change)
+}
@@ -0,0 +1,247 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clouddns "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+)
+
+func TestSameDNSName(t *testing.T) {
+	assert.Equal(t, "web.example.com.", fqdn("web.example.com"))
+	assert.Equal(t, "web.example.com.", fqdn("web.example.com."))
+	assert.True(t, sameDNSName("Web.Example.com.", "web.example.com"))
+	assert.True(t, sameDNSName(`\052.example.com.`, "*.example.com"), "Route 53 escapes wildcards")
+	assert.False(t, sameDNSName("web.example.com.", "api.example.com."))
+	assert.Equal(t, "Z1/web.example.com./A", DNSRecordID("Z1", "web.example.com", "A"))
+}
+
+// fakeRecords holds the record sets of the zones of a fake DNS service,
+// keyed by zone, then name and type.
+type fakeRecords struct {
+	mu    sync.Mutex
+	zones map[string]map[string]*network.DNSRecordConfig
+}
+
+func newFakeRecords(zones ...string) *fakeRecords {
+	f := &fakeRecords{zones: map[string]map[string]*network.DNSRecordConfig{}}
+	for _, zone := range zones {
+		f.zones[zone] = map[string]*network.DNSRecordConfig{}
+	}
+	return f
+}
+
+// fakeRoute53 is a Route 53 stand-in whose changes are INSYNC at once.
+type fakeRoute53 struct {
+	*fakeRecords
+}
+
+// route53ChangeRequest is the body of ChangeResourceRecordSets.
+type route53ChangeRequest struct {
+	Changes []struct {
+		Action            string
+		ResourceRecordSet struct {
+			Name   string
+			Type   string
+			TTL    int64
+			Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+		}
+	} `xml:"ChangeBatch>Changes>Change"`
+}
+
+func route53Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error></ErrorResponse>", code, code)
+}
+
+func route53ChangeInfo(w http.ResponseWriter, root string) {
+	fmt.Fprintf(w, "<%s><ChangeInfo><Id>/change/C1</Id><Status>INSYNC</Status><SubmittedAt>2023-01-01T00:00:00Z</SubmittedAt></ChangeInfo></%s>", root, root)
+}
+
+func (f *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/2013-04-01"), "/")
+	if strings.HasPrefix(path, "change/") {
+		route53ChangeInfo(w, "GetChangeResponse")
+		return
+	}
+	zone, ok := f.zones[strings.TrimSuffix(strings.TrimPrefix(path, "hostedzone/"), "/rrset")]
+	if !ok {
+		route53Error(w, http.StatusNotFound, "NoSuchHostedZone")
+		return
+	}
+	if r.Method == http.MethodGet {
+		fmt.Fprint(w, "<ListResourceRecordSetsResponse><ResourceRecordSets>")
+		query := r.URL.Query()
+		if rec, ok := zone[query.Get("name")+query.Get("type")]; ok {
+			fmt.Fprintf(w, "<ResourceRecordSet><Name>%s</Name><Type>%s</Type><TTL>%d</TTL><ResourceRecords>", rec.Name, rec.Type, rec.TTL)
+			for _, value := range rec.Values {
+				fmt.Fprintf(w, "<ResourceRecord><Value>%s</Value></ResourceRecord>", value)
+			}
+			fmt.Fprint(w, "</ResourceRecords></ResourceRecordSet>")
+		}
+		fmt.Fprint(w, "</ResourceRecordSets><IsTruncated>false</IsTruncated><MaxItems>1</MaxItems></ListResourceRecordSetsResponse>")
+		return
+	}
+	var request route53ChangeRequest
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		route53Error(w, http.StatusBadRequest, "InvalidInput")
+		return
+	}
+	for _, change := range request.Changes {
+		set := change.ResourceRecordSet
+		key := set.Name + set.Type
+		current, exists := zone[key]
+		switch {
+		case change.Action == "CREATE" && exists,
+			change.Action == "DELETE" && (!exists || current.TTL != set.TTL || !assert.ObjectsAreEqual(current.Values, set.Values)):
+			route53Error(w, http.StatusBadRequest, "InvalidChangeBatch")
+			return
+		case change.Action == "DELETE":
+			delete(zone, key)
+		default:
+			zone[key] = &network.DNSRecordConfig{Name: set.Name, Type: set.Type, TTL: set.TTL, Values: set.Values}
+		}
+	}
+	route53ChangeInfo(w, "ChangeResourceRecordSetsResponse")
+}
+
+func TestAWSDNSRecord(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeRoute53{newFakeRecords("Z1")}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("eu-west-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	require.NoError(t, err)
+	client := &AWSClient{client: sess}
+	A := &AWSProvider{}
+
+	rec := &network.DNSRecordConfig{ZoneID: "Z1", Name: "web.example.com", Type: "A", TTL: 300, Values: []string{"203.0.113.10"}}
+	require.NoError(t, A.CreateDNSRecord(ctx, rec, client))
+	assert.Equal(t, "Z1/web.example.com./A", rec.ID)
+	assert.Error(t, A.CreateDNSRecord(ctx, rec, client), "creating an existing record should fail")
+
+	got, err := A.GetDNSRecord(ctx, rec, client)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, []string{"203.0.113.10"}, got.Values)
+
+	rec.TTL, rec.Values = 60, []string{"203.0.113.20", "203.0.113.21"}
+	require.NoError(t, A.UpdateDNSRecord(ctx, rec, client))
+	got, err = A.GetDNSRecord(ctx, rec, client)
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), got.TTL)
+	assert.Equal(t, []string{"203.0.113.20", "203.0.113.21"}, got.Values)
+
+	// Deleting takes the values from Route 53, not from the configuration.
+	rec.Values = []string{"198.51.100.1"}
+	require.NoError(t, A.DeleteDNSRecord(ctx, rec, client))
+	got, err = A.GetDNSRecord(ctx, rec, client)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	assert.NoError(t, A.DeleteDNSRecord(ctx, rec, client), "deleting a deleted record should succeed")
+
+	got, err = A.GetDNSRecord(ctx, &network.DNSRecordConfig{ZoneID: "Z2", Name: "web.example.com", Type: "A"}, client)
+	require.NoError(t, err)
+	assert.Nil(t, got, "a record of a deleted zone should be gone")
+}
+
+// fakeCloudDNS is a Cloud DNS JSON API stand-in whose changes are done at
+// once.
+type fakeCloudDNS struct {
+	*fakeRecords
+}
+
+func (f *fakeCloudDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/dns/v1/projects/dantata/managedZones/"), "/")
+	zone, ok := f.zones[parts[0]]
+	if !ok || len(parts) < 2 {
+		gcsError(w, http.StatusNotFound)
+		return
+	}
+	if parts[1] == "rrsets" {
+		list := &clouddns.ResourceRecordSetsListResponse{}
+		if rec, ok := zone[r.URL.Query().Get("name")+r.URL.Query().Get("type")]; ok {
+			list.Rrsets = append(list.Rrsets, gcpRecordSet(rec))
+		}
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+	if r.Method == http.MethodGet {
+		_ = json.NewEncoder(w).Encode(&clouddns.Change{Id: parts[2], Status: "done"})
+		return
+	}
+	var change clouddns.Change
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		gcsError(w, http.StatusBadRequest)
+		return
+	}
+	for _, set := range change.Deletions {
+		current, exists := zone[set.Name+set.Type]
+		if !exists || current.TTL != set.Ttl || !assert.ObjectsAreEqual(current.Values, set.Rrdatas) {
+			gcsError(w, http.StatusPreconditionFailed)
+			return
+		}
+		delete(zone, set.Name+set.Type)
+	}
+	for _, set := range change.Additions {
+		if _, exists := zone[set.Name+set.Type]; exists {
+			gcsError(w, http.StatusConflict)
+			return
+		}
+		zone[set.Name+set.Type] = &network.DNSRecordConfig{Name: set.Name, Type: set.Type, TTL: set.Ttl, Values: set.Rrdatas}
+	}
+	change.Id, change.Status = "1", "done"
+	_ = json.NewEncoder(w).Encode(&change)
+}
+
+func TestGCPDNSRecord(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(&fakeCloudDNS{newFakeRecords("example")})
+	defer server.Close()
+	service, err := clouddns.NewService(ctx, option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	client := &GCPClient{dns: service}
+	G := &GCProvider{}
+
+	rec := &network.DNSRecordConfig{ZoneID: "example", GCPProjectID: "dantata", Name: "www.example.com.", Type: "CNAME", TTL: 300, Values: []string{"web.example.com."}}
+	require.NoError(t, G.CreateDNSRecord(ctx, rec, client))
+	assert.Equal(t, "example/www.example.com./CNAME", rec.ID)
+
+	rec.Values = []string{"api.example.com."}
+	require.NoError(t, G.UpdateDNSRecord(ctx, rec, client), "an update should replace the record in one change")
+	got, err := G.GetDNSRecord(ctx, rec, client)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, []string{"api.example.com."}, got.Values)
+
+	require.NoError(t, G.DeleteDNSRecord(ctx, rec, client))
+	got, err = G.GetDNSRecord(ctx, rec, client)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = G.GetDNSRecord(ctx, &network.DNSRecordConfig{ZoneID: "gone", GCPProjectID: "dantata", Name: "www.example.com", Type: "A"}, client)
+	require.NoError(t, err)
+	assert.Nil(t, got, "a record of a deleted zone should be gone")
+}
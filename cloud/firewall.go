@@ -0,0 +1,647 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	// awsFirewallTag marks the security groups of cloudfusion_firewall
+	// resources; its value is the firewall name.
+	awsFirewallTag = "cloudfusion:firewall"
+	// awsTargetTagPrefix prefixes a tag key per target tag of a firewall, so
+	// servers can find the security groups targeting them.
+	awsTargetTagPrefix = "cloudfusion:target:"
+	// awsServerTagPrefix prefixes a tag key per firewall tag of a server, so
+	// firewalls can find the instances they target.
+	awsServerTagPrefix = "cloudfusion:firewall-tag:"
+	// gcpEgressDenyPriority is the priority of the rule denying the egress
+	// not allowed by a firewall. It loses to every rule but the implied ones.
+	gcpEgressDenyPriority = 65534
+)
+
+// ingressRules converts firewall rules to the identical vm.IngressRule, so
+// they share the EC2 conversion.
+func ingressRules(rules []network.FirewallRule) []vmconfig.IngressRule {
+	converted := make([]vmconfig.IngressRule, 0, len(rules))
+	for _, rule := range rules {
+		converted = append(converted, vmconfig.IngressRule(rule))
+	}
+	return converted
+}
+
+// awsFirewallTags returns the tags of the security group of fw, including the
+// markers of the firewall and its targets.
+func awsFirewallTags(fw *network.FirewallConfig) map[string]string {
+	tags := make(map[string]string, len(fw.Tags)+len(fw.TargetTags)+1)
+	for k, v := range fw.Tags {
+		tags[k] = v
+	}
+	tags[awsFirewallTag] = fw.Name
+	for _, target := range fw.TargetTags {
+		tags[awsTargetTagPrefix+target] = "true"
+	}
+	return tags
+}
+
+// awsServerTags returns the tags of the instance of VM, including a marker
+// per firewall tag.
+func awsServerTags(VM *vmconfig.VMConfig) map[string]string {
+	tags := make(map[string]string, len(VM.Tags)+len(VM.FirewallTags))
+	for k, v := range VM.Tags {
+		tags[k] = v
+	}
+	for _, tag := range VM.FirewallTags {
+		tags[awsServerTagPrefix+tag] = "true"
+	}
+	return tags
+}
+
+// awsAllowAllEgress is the egress rule of a new security group.
+func awsAllowAllEgress() *ec2.IpPermission {
+	return &ec2.IpPermission{
+		IpProtocol: aws.String("-1"),
+		IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+	}
+}
+
+// awsPermissionSet flattens permissions into one entry per protocol, port
+// range and CIDR block, since EC2 merges permissions that only differ in
+// their CIDR blocks.
+func awsPermissionSet(permissions []*ec2.IpPermission) map[string]bool {
+	set := map[string]bool{}
+	for _, permission := range permissions {
+		for _, ipRange := range permission.IpRanges {
+			key := fmt.Sprintf("%s/%d/%d/%s", aws.StringValue(permission.IpProtocol),
+				aws.Int64Value(permission.FromPort), aws.Int64Value(permission.ToPort), aws.StringValue(ipRange.CidrIp))
+			set[key] = true
+		}
+	}
+	return set
+}
+
+func equalSets(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if !b[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// awsFirewallRules converts the permissions of a security group to rules.
+// known is returned when it describes the same permissions, so rules read
+// back keep the shape of the configuration.
+func awsFirewallRules(permissions []*ec2.IpPermission, known []network.FirewallRule) ([]network.FirewallRule, error) {
+	knownPermissions, err := awsIpPermissions(ingressRules(known))
+	if err != nil {
+		return nil, err
+	}
+	if equalSets(awsPermissionSet(permissions), awsPermissionSet(knownPermissions)) {
+		return known, nil
+	}
+	rules := make([]network.FirewallRule, 0, len(permissions))
+	for _, permission := range permissions {
+		rule := network.FirewallRule{Protocol: aws.StringValue(permission.IpProtocol)}
+		from, to := aws.Int64Value(permission.FromPort), aws.Int64Value(permission.ToPort)
+		switch rule.Protocol {
+		case "-1":
+			rule.Protocol = "all"
+		case "tcp", "udp":
+			if from == to {
+				rule.Ports = []string{strconv.FormatInt(from, 10)}
+			} else if from != 0 || to != 65535 {
+				rule.Ports = []string{fmt.Sprintf("%d-%d", from, to)}
+			}
+		}
+		for _, ipRange := range permission.IpRanges {
+			rule.CIDRBlocks = append(rule.CIDRBlocks, aws.StringValue(ipRange.CidrIp))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// CreateFirewall creates the security group of fw with its rules. Egress
+// rules replace the allow-all rule of a new group.
+func (A *AWSProvider) CreateFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(fw.Region)
+	if fw.Network == "" {
+		vpcID, err := awsVpcID(ctx, ec2Svc, "")
+		if err != nil {
+			return err
+		}
+		fw.Network = vpcID
+	}
+	group, err := ec2Svc.CreateSecurityGroupWithContext(ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:         aws.String(fw.Name),
+		Description:       aws.String(fw.Description),
+		VpcId:             aws.String(fw.Network),
+		TagSpecifications: awsTagSpecifications(awsFirewallTags(fw), ec2.ResourceTypeSecurityGroup),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating security group %s: %w", fw.Name, err)
+	}
+	fw.ID = aws.StringValue(group.GroupId)
+	if err := A.setFirewallRules(ctx, ec2Svc, fw, nil, []*ec2.IpPermission{awsAllowAllEgress()}); err != nil {
+		return err
+	}
+	return A.syncFirewallTargets(ctx, ec2Svc, fw.ID, fw.Network, fw.TargetTags)
+}
+
+// setFirewallRules replaces the current permissions of the security group of
+// fw with its rules.
+func (A *AWSProvider) setFirewallRules(ctx context.Context, ec2Svc *ec2.EC2, fw *network.FirewallConfig, ingress, egress []*ec2.IpPermission) error {
+	groupID := aws.String(fw.ID)
+	wantIngress, err := awsIpPermissions(ingressRules(fw.Ingress))
+	if err != nil {
+		return err
+	}
+	wantEgress := []*ec2.IpPermission{awsAllowAllEgress()}
+	if len(fw.Egress) > 0 {
+		if wantEgress, err = awsIpPermissions(ingressRules(fw.Egress)); err != nil {
+			return err
+		}
+	}
+	if !equalSets(awsPermissionSet(ingress), awsPermissionSet(wantIngress)) {
+		if len(ingress) > 0 {
+			_, err := ec2Svc.RevokeSecurityGroupIngressWithContext(ctx, &ec2.RevokeSecurityGroupIngressInput{GroupId: groupID, IpPermissions: ingress})
+			if err != nil {
+				return fmt.Errorf("error revoking ingress on %s: %w", fw.Name, err)
+			}
+		}
+		if len(wantIngress) > 0 {
+			_, err := ec2Svc.AuthorizeSecurityGroupIngressWithContext(ctx, &ec2.AuthorizeSecurityGroupIngressInput{GroupId: groupID, IpPermissions: wantIngress})
+			if err != nil {
+				return fmt.Errorf("error authorizing ingress on %s: %w", fw.Name, err)
+			}
+		}
+	}
+	if !equalSets(awsPermissionSet(egress), awsPermissionSet(wantEgress)) {
+		if len(egress) > 0 {
+			_, err := ec2Svc.RevokeSecurityGroupEgressWithContext(ctx, &ec2.RevokeSecurityGroupEgressInput{GroupId: groupID, IpPermissions: egress})
+			if err != nil {
+				return fmt.Errorf("error revoking egress on %s: %w", fw.Name, err)
+			}
+		}
+		_, err := ec2Svc.AuthorizeSecurityGroupEgressWithContext(ctx, &ec2.AuthorizeSecurityGroupEgressInput{GroupId: groupID, IpPermissions: wantEgress})
+		if err != nil {
+			return fmt.Errorf("error authorizing egress on %s: %w", fw.Name, err)
+		}
+	}
+	return nil
+}
+
+func (A *AWSProvider) describeFirewall(ctx context.Context, ec2Svc *ec2.EC2, fw *network.FirewallConfig) (*ec2.SecurityGroup, error) {
+	groups, err := ec2Svc.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{GroupIds: []*string{aws.String(fw.ID)}})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidGroup.NotFound") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(groups.SecurityGroups) == 0 {
+		return nil, nil
+	}
+	return groups.SecurityGroups[0], nil
+}
+
+// GetFirewall reads the security group of fw. It returns nil when the group
+// no longer exists.
+func (A *AWSProvider) GetFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) (*network.FirewallConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	group, err := A.describeFirewall(ctx, awsClient.ec2Service(fw.Region), fw)
+	if err != nil || group == nil {
+		return nil, err
+	}
+	config := &network.FirewallConfig{
+		ID:           fw.ID,
+		Name:         aws.StringValue(group.GroupName),
+		Description:  aws.StringValue(group.Description),
+		Region:       fw.Region,
+		GCPProjectID: fw.GCPProjectID,
+		Network:      aws.StringValue(group.VpcId),
+		Tags:         map[string]string{},
+	}
+	var targets []string
+	for _, tag := range group.Tags {
+		key := aws.StringValue(tag.Key)
+		switch {
+		case strings.HasPrefix(key, awsTargetTagPrefix):
+			targets = append(targets, strings.TrimPrefix(key, awsTargetTagPrefix))
+		case key == awsFirewallTag || isIgnoredTag(key, fw.IgnoreTagKeys):
+		default:
+			config.Tags[key] = aws.StringValue(tag.Value)
+		}
+	}
+	config.TargetTags = sameStrings(targets, fw.TargetTags)
+	if config.Ingress, err = awsFirewallRules(group.IpPermissions, fw.Ingress); err != nil {
+		return nil, err
+	}
+	egress := group.IpPermissionsEgress
+	if len(fw.Egress) == 0 && equalSets(awsPermissionSet(egress), awsPermissionSet([]*ec2.IpPermission{awsAllowAllEgress()})) {
+		egress = nil
+	}
+	if config.Egress, err = awsFirewallRules(egress, fw.Egress); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// sameStrings returns known when it holds the same strings as read, which
+// keeps the configured order, and read sorted otherwise.
+func sameStrings(read, known []string) []string {
+	set := make(map[string]bool, len(read))
+	for _, s := range read {
+		set[s] = true
+	}
+	knownSet := make(map[string]bool, len(known))
+	for _, s := range known {
+		knownSet[s] = true
+	}
+	if equalSets(set, knownSet) {
+		return known
+	}
+	sort.Strings(read)
+	return read
+}
+
+// UpdateFirewall replaces the rules and tags of the security group of fw.
+func (A *AWSProvider) UpdateFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(fw.Region)
+	group, err := A.describeFirewall(ctx, ec2Svc, fw)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return fmt.Errorf("security group %s not found", fw.ID)
+	}
+	if err := A.setFirewallRules(ctx, ec2Svc, fw, group.IpPermissions, group.IpPermissionsEgress); err != nil {
+		return err
+	}
+	if err := A.updateTags(ctx, ec2Svc, group.GroupId, group.Tags, awsFirewallTags(fw), fw.IgnoreTagKeys); err != nil {
+		return err
+	}
+	return A.syncFirewallTargets(ctx, ec2Svc, fw.ID, aws.StringValue(group.VpcId), fw.TargetTags)
+}
+
+// DeleteFirewall detaches the security group of fw from the instances it
+// targets and deletes it, waiting for instances that are being terminated to
+// release it.
+func (A *AWSProvider) DeleteFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(fw.Region)
+	group, err := A.describeFirewall(ctx, ec2Svc, fw)
+	if err != nil || group == nil {
+		return err
+	}
+	if err := A.syncFirewallTargets(ctx, ec2Svc, fw.ID, aws.StringValue(group.VpcId), nil); err != nil {
+		return err
+	}
+	err = retryWhileInUse(ctx, func() error {
+		_, err := ec2Svc.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{GroupId: aws.String(fw.ID)})
+		return err
+	})
+	if err != nil && !isAWSNotFound(err, "InvalidGroup.NotFound") {
+		return fmt.Errorf("error deleting security group %s: %w", fw.Name, err)
+	}
+	return nil
+}
+
+// syncFirewallTargets attaches the security group groupID to the instances of
+// vpcID whose firewall tags match targets, and detaches it from the other
+// instances with firewall tags. Instances without the firewall tag markers,
+// launched before servers carried them, are left to the server resource.
+func (A *AWSProvider) syncFirewallTargets(ctx context.Context, ec2Svc *ec2.EC2, groupID, vpcID string, targets []string) error {
+	states := &ec2.Filter{
+		Name:   aws.String("instance-state-name"),
+		Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning, ec2.InstanceStateNameStopping, ec2.InstanceStateNameStopped}),
+	}
+	instances := map[string]*ec2.Instance{}
+	collect := func(filters ...*ec2.Filter) error {
+		input := &ec2.DescribeInstancesInput{Filters: append(filters, states)}
+		return ec2Svc.DescribeInstancesPagesWithContext(ctx, input, func(page *ec2.DescribeInstancesOutput, _ bool) bool {
+			for _, reservation := range page.Reservations {
+				for _, instance := range reservation.Instances {
+					instances[aws.StringValue(instance.InstanceId)] = instance
+				}
+			}
+			return true
+		})
+	}
+	wanted := map[string]bool{}
+	if len(targets) > 0 {
+		keys := make([]*string, 0, len(targets))
+		for _, target := range targets {
+			keys = append(keys, aws.String(awsServerTagPrefix+target))
+			wanted[awsServerTagPrefix+target] = true
+		}
+		err := collect(
+			&ec2.Filter{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+			&ec2.Filter{Name: aws.String("tag-key"), Values: keys},
+		)
+		if err != nil {
+			return fmt.Errorf("error reading the instances targeted by %s: %w", groupID, err)
+		}
+	}
+	if err := collect(&ec2.Filter{Name: aws.String("instance.group-id"), Values: []*string{aws.String(groupID)}}); err != nil {
+		return fmt.Errorf("error reading the instances of %s: %w", groupID, err)
+	}
+
+	ids := make([]string, 0, len(instances))
+	for id := range instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		instance := instances[id]
+		targeted, marked := false, false
+		for _, tag := range instance.Tags {
+			key := aws.StringValue(tag.Key)
+			marked = marked || strings.HasPrefix(key, awsServerTagPrefix)
+			targeted = targeted || wanted[key]
+		}
+		var groupIDs []*string
+		attached := false
+		for _, group := range instance.SecurityGroups {
+			if aws.StringValue(group.GroupId) == groupID {
+				attached = true
+				continue
+			}
+			groupIDs = append(groupIDs, group.GroupId)
+		}
+		switch {
+		case targeted && !attached:
+			groupIDs = append(groupIDs, aws.String(groupID))
+		case !targeted && attached && marked:
+			if len(groupIDs) == 0 {
+				return fmt.Errorf("cannot remove the last security group of %s", id)
+			}
+		default:
+			continue
+		}
+		if err := A.setInstanceGroups(ctx, ec2Svc, instance.InstanceId, groupIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firewallGroups returns the IDs of the firewall security groups of vpcID
+// that target one of tags.
+func (A *AWSProvider) firewallGroups(ctx context.Context, ec2Svc *ec2.EC2, vpcID string, tags []string) ([]*string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	keys := make([]*string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, aws.String(awsTargetTagPrefix+tag))
+	}
+	groups, err := ec2Svc.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag-key"), Values: keys},
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading firewall security groups: %w", err)
+	}
+	ids := make([]*string, 0, len(groups.SecurityGroups))
+	for _, group := range groups.SecurityGroups {
+		ids = append(ids, group.GroupId)
+	}
+	return ids, nil
+}
+
+// syncFirewallGroups attaches the firewall security groups targeting
+// firewallTags to instanceID and detaches the other firewall groups, leaving
+// groups not managed by a firewall alone. The instance is read again since
+// updateIngress may have changed its groups.
+func (A *AWSProvider) syncFirewallGroups(ctx context.Context, ec2Svc *ec2.EC2, instanceID *string, firewallTags []string) error {
+	result, err := ec2Svc.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: []*string{instanceID}})
+	if err != nil {
+		return err
+	}
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("instance %s not found", aws.StringValue(instanceID))
+	}
+	instance := result.Reservations[0].Instances[0]
+	wanted, err := A.firewallGroups(ctx, ec2Svc, aws.StringValue(instance.VpcId), firewallTags)
+	if err != nil {
+		return err
+	}
+	var attached []*string
+	for _, group := range instance.SecurityGroups {
+		attached = append(attached, group.GroupId)
+	}
+	managed := map[string]bool{}
+	if len(attached) > 0 {
+		groups, err := ec2Svc.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+			GroupIds: attached,
+			Filters:  []*ec2.Filter{{Name: aws.String("tag-key"), Values: []*string{aws.String(awsFirewallTag)}}},
+		})
+		if err != nil {
+			return fmt.Errorf("error reading the security groups of %s: %w", aws.StringValue(instanceID), err)
+		}
+		for _, group := range groups.SecurityGroups {
+			managed[aws.StringValue(group.GroupId)] = true
+		}
+	}
+
+	var groupIDs []*string
+	current := map[string]bool{}
+	for _, id := range attached {
+		current[aws.StringValue(id)] = true
+		if !managed[aws.StringValue(id)] {
+			groupIDs = append(groupIDs, id)
+		}
+	}
+	desired := map[string]bool{}
+	for _, id := range groupIDs {
+		desired[aws.StringValue(id)] = true
+	}
+	for _, id := range wanted {
+		desired[aws.StringValue(id)] = true
+		groupIDs = append(groupIDs, id)
+	}
+	if equalSets(current, desired) {
+		return nil
+	}
+	if len(groupIDs) == 0 {
+		return fmt.Errorf("cannot remove the last security group of %s", aws.StringValue(instanceID))
+	}
+	return A.setInstanceGroups(ctx, ec2Svc, instance.InstanceId, groupIDs)
+}
+
+// gcpFirewallRuleName names rule i of direction "in" or "eg" of fw.
+func gcpFirewallRuleName(fw *network.FirewallConfig, direction string, i int) string {
+	return fmt.Sprintf("%s-%s-%d", fw.Name, direction, i)
+}
+
+// gcpFirewallFilter matches every firewall rule of fw.
+func gcpFirewallFilter(fw *network.FirewallConfig) string {
+	return fmt.Sprintf(`name eq "%s-(in|eg)-([0-9]+|deny)"`, fw.Name)
+}
+
+// gcpFirewallRules converts fw into one GCE firewall rule per rule. With
+// egress rules, a last rule denies the remaining egress, which GCE allows by
+// default.
+func gcpFirewallRules(fw *network.FirewallConfig) []*compute.Firewall {
+	networkURL := gcpNetworkURL(fw.GCPProjectID, fw.Network)
+	rule := func(name, direction string, r network.FirewallRule) *compute.Firewall {
+		firewall := &compute.Firewall{
+			Name:        name,
+			Description: fw.Description,
+			Network:     networkURL,
+			Direction:   direction,
+			TargetTags:  fw.TargetTags,
+			Allowed:     []*compute.FirewallAllowed{{IPProtocol: r.Protocol, Ports: r.Ports}},
+		}
+		if direction == "INGRESS" {
+			firewall.SourceRanges = r.CIDRBlocks
+		} else {
+			firewall.DestinationRanges = r.CIDRBlocks
+		}
+		return firewall
+	}
+	var firewalls []*compute.Firewall
+	for i, r := range fw.Ingress {
+		firewalls = append(firewalls, rule(gcpFirewallRuleName(fw, "in", i), "INGRESS", r))
+	}
+	for i, r := range fw.Egress {
+		firewalls = append(firewalls, rule(gcpFirewallRuleName(fw, "eg", i), "EGRESS", r))
+	}
+	if len(fw.Egress) > 0 {
+		firewalls = append(firewalls, &compute.Firewall{
+			Name:              fw.Name + "-eg-deny",
+			Description:       fw.Description,
+			Network:           networkURL,
+			Direction:         "EGRESS",
+			Priority:          gcpEgressDenyPriority,
+			TargetTags:        fw.TargetTags,
+			DestinationRanges: []string{"0.0.0.0/0"},
+			Denied:            []*compute.FirewallDenied{{IPProtocol: "all"}},
+		})
+	}
+	return firewalls
+}
+
+// gcpFirewallConfig rebuilds the firewall from its GCE firewall rules, which
+// are ordered by the index in their name.
+func gcpFirewallConfig(fw *network.FirewallConfig, firewalls []*compute.Firewall) *network.FirewallConfig {
+	config := &network.FirewallConfig{
+		ID:           fw.ID,
+		Name:         fw.ID,
+		Region:       fw.Region,
+		GCPProjectID: fw.GCPProjectID,
+		Tags:         map[string]string{},
+	}
+	ingress := map[int]network.FirewallRule{}
+	egress := map[int]network.FirewallRule{}
+	for _, firewall := range firewalls {
+		config.Network = lastSegment(firewall.Network)
+		config.Description = firewall.Description
+		config.TargetTags = firewall.TargetTags
+		suffix := strings.TrimPrefix(firewall.Name, fw.ID+"-")
+		direction, index, _ := strings.Cut(suffix, "-")
+		i, err := strconv.Atoi(index)
+		if err != nil || len(firewall.Allowed) == 0 {
+			continue
+		}
+		rule := network.FirewallRule{
+			Protocol: firewall.Allowed[0].IPProtocol,
+			Ports:    firewall.Allowed[0].Ports,
+		}
+		if direction == "in" {
+			rule.CIDRBlocks = firewall.SourceRanges
+			ingress[i] = rule
+		} else {
+			rule.CIDRBlocks = firewall.DestinationRanges
+			egress[i] = rule
+		}
+	}
+	config.Ingress = orderedRules(ingress)
+	config.Egress = orderedRules(egress)
+	return config
+}
+
+func orderedRules(rules map[int]network.FirewallRule) []network.FirewallRule {
+	indexes := make([]int, 0, len(rules))
+	for i := range rules {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	ordered := make([]network.FirewallRule, 0, len(rules))
+	for _, i := range indexes {
+		ordered = append(ordered, rules[i])
+	}
+	return ordered
+}
+
+// CreateFirewall creates the firewall rules of fw.
+func (G *GCProvider) CreateFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error {
+	if fw.Network == "" {
+		fw.Network = "default"
+	}
+	// The rules are named after the firewall, so the ID also tracks a
+	// partly created firewall.
+	fw.ID = fw.Name
+	return G.applyFirewalls(ctx, client, fw.GCPProjectID, gcpFirewallFilter(fw), gcpFirewallRules(fw))
+}
+
+// GetFirewall reads the firewall rules of fw. It returns nil when none is
+// left.
+func (G *GCProvider) GetFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) (*network.FirewallConfig, error) {
+	computeService := client.(*GCPClient).client
+	lookup := *fw
+	lookup.Name = fw.ID
+	var firewalls []*compute.Firewall
+	err := computeService.Firewalls.List(fw.GCPProjectID).Filter(gcpFirewallFilter(&lookup)).Pages(ctx, func(page *compute.FirewallList) error {
+		firewalls = append(firewalls, page.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing firewall rules: %w", err)
+	}
+	if len(firewalls) == 0 {
+		return nil, nil
+	}
+	return gcpFirewallConfig(fw, firewalls), nil
+}
+
+// UpdateFirewall rewrites the firewall rules of fw and deletes the ones left
+// over.
+func (G *GCProvider) UpdateFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error {
+	return G.applyFirewalls(ctx, client, fw.GCPProjectID, gcpFirewallFilter(fw), gcpFirewallRules(fw))
+}
+
+// DeleteFirewall deletes every firewall rule of fw.
+func (G *GCProvider) DeleteFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error {
+	return G.applyFirewalls(ctx, client, fw.GCPProjectID, gcpFirewallFilter(fw), nil)
+}
@@ -0,0 +1,142 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestAWSFirewallTags(t *testing.T) {
+	fw := &network.FirewallConfig{Name: "web", TargetTags: []string{"web"}, Tags: map[string]string{"team": "a"}}
+	tags := awsFirewallTags(fw)
+	assert.Equal(t, map[string]string{"team": "a", "cloudfusion:firewall": "web", "cloudfusion:target:web": "true"}, tags)
+	assert.Len(t, fw.Tags, 1, "the markers should not leak into the configured tags")
+}
+
+func TestAWSServerTags(t *testing.T) {
+	VM := &vmconfig.VMConfig{Tags: map[string]string{"Name": "web-1"}, FirewallTags: []string{"web"}}
+	assert.Equal(t, map[string]string{"Name": "web-1", "cloudfusion:firewall-tag:web": "true"}, awsServerTags(VM))
+	assert.Len(t, VM.Tags, 1, "the markers should not leak into the configured tags")
+}
+
+func TestAWSSyncFirewallTargets(t *testing.T) {
+	instance := func(id string, groups []string, tags ...string) string {
+		body := fmt.Sprintf("<item><instanceId>%s</instanceId><groupSet>", id)
+		for _, group := range groups {
+			body += fmt.Sprintf("<item><groupId>%s</groupId></item>", group)
+		}
+		body += "</groupSet><tagSet>"
+		for _, tag := range tags {
+			body += fmt.Sprintf("<item><key>%s</key><value>true</value></item>", tag)
+		}
+		return body + "</tagSet></item>"
+	}
+	f, client := newFakeEC2(t, map[string]string{
+		"DescribeInstances": "<reservationSet><item><instancesSet>" +
+			instance("i-1", []string{"sg-1"}, "cloudfusion:firewall-tag:web") +
+			instance("i-2", []string{"sg-1", "sg-fw"}, "cloudfusion:firewall-tag:db") +
+			instance("i-3", []string{"sg-1", "sg-fw"}) +
+			instance("i-4", []string{"sg-fw"}, "cloudfusion:firewall-tag:web") +
+			instance("i-5", []string{"sg-fw"}, "cloudfusion:firewall-tag:db") +
+			"</instancesSet></item></reservationSet>",
+		"ModifyInstanceAttribute": "<return>true</return>",
+	})
+	ec2Svc := client.ec2Service("eu-west-1")
+
+	err := (&AWSProvider{}).syncFirewallTargets(context.Background(), ec2Svc, "sg-fw", "vpc-1", []string{"web"})
+	assert.ErrorContains(t, err, "cannot remove the last security group of i-5")
+
+	var describes, modified []string
+	for _, call := range f.calls {
+		switch call.Get("Action") {
+		case "DescribeInstances":
+			describes = append(describes, call.Get("Filter.1.Name")+"="+call.Get("Filter.1.Value.1"))
+		case "ModifyInstanceAttribute":
+			modified = append(modified, fmt.Sprintf("%s:%s,%s", call.Get("InstanceId"), call.Get("GroupId.1"), call.Get("GroupId.2")))
+		}
+	}
+	assert.Equal(t, []string{"vpc-id=vpc-1", "instance.group-id=sg-fw"}, describes)
+	assert.Equal(t, []string{"i-1:sg-1,sg-fw", "i-2:sg-1,"}, modified, "tagged instances gain the group, untagged ones keep it")
+}
+
+func TestAWSFirewallRules(t *testing.T) {
+	known := []network.FirewallRule{
+		{Protocol: "tcp", Ports: []string{"22", "443"}, CIDRBlocks: []string{"10.0.0.0/8"}},
+	}
+	// EC2 reports one permission per port range with its CIDR blocks merged.
+	permissions := []*ec2.IpPermission{
+		{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(443), ToPort: aws.Int64(443), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/8")}}},
+		{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(22), ToPort: aws.Int64(22), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/8")}}},
+	}
+	rules, err := awsFirewallRules(permissions, known)
+	assert.NoError(t, err)
+	assert.Equal(t, known, rules, "equivalent permissions should keep the configured rules")
+
+	permissions = append(permissions,
+		&ec2.IpPermission{IpProtocol: aws.String("udp"), FromPort: aws.Int64(0), ToPort: aws.Int64(65535), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}},
+		&ec2.IpPermission{IpProtocol: aws.String("-1"), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("192.168.0.0/16")}}},
+	)
+	rules, err = awsFirewallRules(permissions, known)
+	assert.NoError(t, err)
+	assert.Equal(t, []network.FirewallRule{
+		{Protocol: "tcp", Ports: []string{"443"}, CIDRBlocks: []string{"10.0.0.0/8"}},
+		{Protocol: "tcp", Ports: []string{"22"}, CIDRBlocks: []string{"10.0.0.0/8"}},
+		{Protocol: "udp", CIDRBlocks: []string{"0.0.0.0/0"}},
+		{Protocol: "all", CIDRBlocks: []string{"192.168.0.0/16"}},
+	}, rules, "changed permissions should be read back as they are")
+}
+
+func TestGCPFirewallRules(t *testing.T) {
+	fw := &network.FirewallConfig{
+		ID:           "web",
+		Name:         "web",
+		Description:  "Managed by cloudfusion",
+		GCPProjectID: "dantata",
+		Network:      "default",
+		TargetTags:   []string{"web"},
+		Ingress: []network.FirewallRule{
+			{Protocol: "tcp", Ports: []string{"80", "443"}, CIDRBlocks: []string{"0.0.0.0/0"}},
+			{Protocol: "icmp", CIDRBlocks: []string{"10.0.0.0/8"}},
+		},
+		Egress: []network.FirewallRule{
+			{Protocol: "tcp", Ports: []string{"443"}, CIDRBlocks: []string{"0.0.0.0/0"}},
+		},
+	}
+	firewalls := gcpFirewallRules(fw)
+	assert.Len(t, firewalls, 4, "egress rules should come with a rule denying the rest")
+	assert.Equal(t, []string{"web-in-0", "web-in-1", "web-eg-0", "web-eg-deny"},
+		[]string{firewalls[0].Name, firewalls[1].Name, firewalls[2].Name, firewalls[3].Name})
+	assert.Equal(t, []string{"0.0.0.0/0"}, firewalls[0].SourceRanges)
+	assert.Equal(t, []string{"0.0.0.0/0"}, firewalls[2].DestinationRanges)
+	assert.Equal(t, int64(gcpEgressDenyPriority), firewalls[3].Priority)
+	assert.Equal(t, "projects/dantata/global/networks/default", firewalls[3].Network)
+
+	// The rules come back in name order, which is not the index order.
+	reversed := []*compute.Firewall{firewalls[3], firewalls[1], firewalls[2], firewalls[0]}
+	read := gcpFirewallConfig(&network.FirewallConfig{ID: "web", GCPProjectID: "dantata"}, reversed)
+	assert.Equal(t, fw.Ingress, read.Ingress)
+	assert.Equal(t, fw.Egress, read.Egress)
+	assert.Equal(t, fw.TargetTags, read.TargetTags)
+	assert.Equal(t, "web", read.Name)
+	assert.Equal(t, "default", read.Network)
+}
+
+func TestGCPCreateFirewallTracksPartialFirewall(t *testing.T) {
+	f, client := newFakeCompute(t)
+	f.errors["POST global/firewalls"] = http.StatusForbidden
+	fw := &network.FirewallConfig{
+		Name:         "web",
+		GCPProjectID: "dantata",
+		Ingress:      []network.FirewallRule{{Protocol: "tcp", Ports: []string{"443"}, CIDRBlocks: []string{"0.0.0.0/0"}}},
+	}
+	assert.Error(t, (&GCProvider{}).CreateFirewall(context.Background(), fw, client))
+	assert.Equal(t, "web", fw.ID, "a firewall whose rules failed should be tracked so destroy cleans it up")
+}
@@ -9,15 +9,21 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/compute/v1"
+	clouddns "google.golang.org/api/dns/v1"
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
 	"io/ioutil"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type GCPClient struct {
-	client *compute.Service
+	client  *compute.Service
+	storage *gcs.Service
+	dns     *clouddns.Service
 }
 type GCPInstance struct {
 	Instance *compute.Instance
@@ -27,23 +33,45 @@ type GCProvider struct{}
 
 func (G *GCProvider) DeleteInstance(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error {
 	computeService := client.(*GCPClient).client
-	op, err := computeService.Instances.Delete(VM.GCPProjectID, VM.Region, VM.Name).Context(ctx).Do()
+	op, err := computeService.Instances.Delete(VM.GCPProjectID, VM.Zone, VM.Name).Context(ctx).Do()
 	if err != nil {
 		return fmt.Errorf("error deleting instance %s", err.Error())
 	}
-	err = G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Region, op.Name)
+	err = G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name)
 	if err != nil {
 		return err
 	}
-	return nil
+	if VM.PublicIPMode == "static" {
+		if err := G.releaseAddress(ctx, client, VM); err != nil {
+			return err
+		}
+	}
+	return G.deleteFirewalls(ctx, client, VM)
+}
+
+// deleteFirewalls removes every firewall rule managed for the ingress rules
+// of VM.
+func (G *GCProvider) deleteFirewalls(ctx context.Context, client interface{}, VM *vmconfig.VMConfig) error {
+	// Without ingress rules every managed firewall rule is removed.
+	cleanup := *VM
+	cleanup.Ingress = nil
+	return G.syncFirewalls(ctx, client, &cleanup)
 }
 
 func (G *GCProvider) VMtoMap(VM *vmconfig.VMConfig) map[string]interface{} {
 	return map[string]interface{}{
-		"name":          VM.Name,
-		"instance_type": VM.InstanceType,
-		"region":        VM.Region,
-		"id":            VM.ID,
+		"name":                VM.Name,
+		"instance_type":       VM.InstanceType,
+		"region":              VM.Region,
+		"zone":                VM.Zone,
+		"id":                  VM.ID,
+		"tags_all":            VM.Tags,
+		"capacity":            VM.Capacity.Model,
+		"power_state":         VM.PowerState,
+		"deletion_protection": VM.DeletionProtection,
+		"public_ip":           VM.PublicIPMode,
+		"public_ip_address":   VM.PublicIP,
+		"private_ip_address":  VM.PrivateIP,
 	}
 }
 
@@ -51,51 +79,121 @@ func (G *GCProvider) UpdateInstance(ctx context.Context, new interface{}, old in
 	computeService := client.(*GCPClient).client
 	newInstance := new.(*GCPInstance).Instance
 	oldInstance := old.(*GCPInstance).Instance
-	_, err := computeService.Instances.Update(vmConfig.GCPProjectID, vmConfig.Region, oldInstance.Name, newInstance).Context(ctx).Do()
+	labels, err := SanitizeGCELabels(vmConfig.Tags)
 	if err != nil {
 		return err
 	}
-	return nil
+	for k, v := range oldInstance.Labels {
+		if isIgnoredLabel(k, vmConfig.IgnoreTagKeys) {
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[k] = v
+		}
+	}
+	newInstance.Labels = labels
+	if newInstance.Metadata == nil {
+		newInstance.Metadata = &compute.Metadata{}
+	}
+	setMetadataItem(newInstance.Metadata, "ssh-keys", gcpSSHKeys(vmConfig))
+	if vmConfig.GCP != nil {
+		if err := G.syncFirewalls(ctx, client, vmConfig); err != nil {
+			return err
+		}
+	}
+	newInstance.Tags = gcpNetworkTags(newInstance.Tags, vmConfig)
+	op, err := computeService.Instances.Update(vmConfig.GCPProjectID, vmConfig.Zone, oldInstance.Name, newInstance).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	return G.waitForOperation(ctx, client, vmConfig.GCPProjectID, vmConfig.Zone, op.Name)
 }
 
 func (G *GCProvider) CreateInstance(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) (string, error) {
 	computeService := client.(*GCPClient).client
+	if VM.GCP == nil {
+		return "", fmt.Errorf("the gcp block is required to create a GCP instance")
+	}
+	if VM.Zone == "" {
+		zone, err := G.selectZone(ctx, computeService, VM)
+		if err != nil {
+			return "", err
+		}
+		VM.Zone = zone
+	}
+	labels, err := SanitizeGCELabels(VM.Tags)
+	if err != nil {
+		return "", err
+	}
+	var rules []string
+	var staticIP string
+	// The firewall rules and the address made by this call only serve the
+	// instance, so they are removed as long as it certainly does not exist.
+	cleanup := true
+	defer func() {
+		if !cleanup {
+			return
+		}
+		if staticIP != "" {
+			_ = G.releaseAddress(ctx, client, VM)
+		}
+		_ = G.deleteFirewallRules(ctx, client, VM.GCPProjectID, rules)
+	}()
+	for _, firewall := range gcpFirewalls(VM) {
+		// An existing rule belongs to another instance of the same name, so
+		// inserting fails rather than taking it over.
+		op, err := computeService.Firewalls.Insert(VM.GCPProjectID, firewall).Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("error creating firewall rule %s: %w", firewall.Name, err)
+		}
+		rules = append(rules, firewall.Name)
+		if err := G.waitForGlobalOperation(ctx, client, VM.GCPProjectID, op.Name); err != nil {
+			return "", err
+		}
+	}
+	if VM.PublicIPMode == "static" {
+		staticIP, err = G.reserveAddress(ctx, client, VM, labels)
+		if err != nil {
+			return "", err
+		}
+	}
 	instance := &compute.Instance{
-		Name:        VM.Name,
-		MachineType: fmt.Sprintf("projects/%s/zones/%s/machineTypes/%s", VM.GCPProjectID, VM.Region, VM.InstanceType),
-		Disks: []*compute.AttachedDisk{
-			{
-				AutoDelete: true,
-				Boot:       true,
-				InitializeParams: &compute.AttachedDiskInitializeParams{
-					SourceImage: fmt.Sprintf("projects/%s/global/images/family/%s", VM.GCPImageProject, VM.GCPImageFamily),
-				},
-			},
-		},
+		Name:               VM.Name,
+		Labels:             labels,
+		Metadata:           gcpMetadata(VM),
+		Tags:               gcpNetworkTags(nil, VM),
+		Scheduling:         gcpScheduling(VM.Capacity),
+		ServiceAccounts:    gcpServiceAccounts(VM.ServiceIdentity),
+		DeletionProtection: VM.DeletionProtection,
+		MachineType:        fmt.Sprintf("projects/%s/zones/%s/machineTypes/%s", VM.GCPProjectID, VM.Zone, VM.InstanceType),
+		Disks:              []*compute.AttachedDisk{gcpBootDisk(VM, labels)},
 		NetworkInterfaces: []*compute.NetworkInterface{
 			{
-				Network: fmt.Sprintf("global/networks/%s", VM.GCPNetworkName),
-				AccessConfigs: []*compute.AccessConfig{
-					{
-						Name: "External NAT",
-					},
-				},
+				Network:       fmt.Sprintf("global/networks/%s", VM.GCP.NetworkName),
+				Subnetwork:    VM.SubnetID,
+				AccessConfigs: gcpAccessConfigs(VM.PublicIPMode, staticIP),
 			},
 		},
 	}
-	op, err := computeService.Instances.Insert(VM.GCPProjectID, VM.Region, instance).Context(ctx).Do()
-	if err != nil {
-		return "", err
-	}
-	err = G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Region, op.Name)
+	instance.Disks = append(instance.Disks, gcpDataDisks(VM, labels)...)
+	op, err := computeService.Instances.Insert(VM.GCPProjectID, VM.Zone, instance).Context(ctx).Do()
 	if err != nil {
+		// Without an answer from GCE, the insert may still go through.
+		var gceErr *googleapi.Error
+		cleanup = errors.As(err, &gceErr)
 		return "", err
 	}
-	createInstance, err := computeService.Instances.Get(VM.GCPProjectID, VM.Region, VM.Name).Context(ctx).Do()
-	if err != nil {
-		return "", err
+	// The operation targets the new instance, so its ID is known before the
+	// instance is done, and an instance still being created is tracked.
+	instanceID := strconv.FormatUint(op.TargetId, 10)
+	if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+		if isOperationFailed(err) {
+			return "", err
+		}
+		cleanup = false
+		return instanceID, err
 	}
-	instanceID := strconv.FormatUint(createInstance.Id, 10)
+	cleanup = false
 	return instanceID, nil
 }
 
@@ -106,9 +204,9 @@ func (G *GCProvider) ProviderName() string {
 func (G *GCProvider) GetInstance(ctx context.Context, data *schema.ResourceData, client interface{}) (interface{}, error) {
 	computeService := client.(*GCPClient).client
 	project := data.Get("gcp_project").(string)
-	region := data.Get("region").(string)
+	zone := data.Get("zone").(string)
 	name := data.Get("name").(string)
-	instance, err := computeService.Instances.Get(project, region, data.Id()).Context(ctx).Do()
+	instance, err := computeService.Instances.Get(project, zone, data.Id()).Context(ctx).Do()
 	if err != nil {
 		var gceErr *googleapi.Error
 		if errors.As(err, &gceErr) && gceErr.Code == 404 {
@@ -136,7 +234,7 @@ func (G *GCProvider) CreateClient(credential string) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	cred, err := google.CredentialsFromJSON(ctx, credentialsJSON, compute.ComputeScope)
+	cred, err := google.CredentialsFromJSON(ctx, credentialsJSON, compute.ComputeScope, gcs.DevstorageFullControlScope, clouddns.NdevClouddnsReadwriteScope)
 	if err != nil {
 		return nil, err
 	}
@@ -144,23 +242,70 @@ func (G *GCProvider) CreateClient(credential string) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	storageService, err := gcs.NewService(ctx, option.WithCredentials(cred))
+	if err != nil {
+		return nil, err
+	}
+	dnsService, err := clouddns.NewService(ctx, option.WithCredentials(cred))
+	if err != nil {
+		return nil, err
+	}
 	gcpClient := &GCPClient{
-		client: sa,
+		client:  sa,
+		storage: storageService,
+		dns:     dnsService,
 	}
 	return gcpClient, nil
 }
 
 func (G *GCProvider) waitForOperation(ctx context.Context, client interface{}, projectID, Zone, operationName string) error {
 	computeService := client.(*GCPClient).client
+	return G.pollOperation(ctx, func() (*compute.Operation, error) {
+		return computeService.ZoneOperations.Get(projectID, Zone, operationName).Context(ctx).Do()
+	})
+}
+
+func (G *GCProvider) waitForRegionOperation(ctx context.Context, client interface{}, projectID, region, operationName string) error {
+	computeService := client.(*GCPClient).client
+	return G.pollOperation(ctx, func() (*compute.Operation, error) {
+		return computeService.RegionOperations.Get(projectID, region, operationName).Context(ctx).Do()
+	})
+}
+
+func (G *GCProvider) waitForGlobalOperation(ctx context.Context, client interface{}, projectID, operationName string) error {
+	computeService := client.(*GCPClient).client
+	return G.pollOperation(ctx, func() (*compute.Operation, error) {
+		return computeService.GlobalOperations.Get(projectID, operationName).Context(ctx).Do()
+	})
+}
+
+// operationError is the error of an operation that is done and failed, as
+// opposed to one that could not be followed to its end.
+type operationError struct {
+	errors []*compute.OperationErrorErrors
+}
+
+func (e *operationError) Error() string {
+	return fmt.Sprintf("operation failed: %v", e.errors)
+}
+
+// isOperationFailed reports whether err is the error of a failed operation.
+func isOperationFailed(err error) bool {
+	var opErr *operationError
+	return errors.As(err, &opErr)
+}
+
+// pollOperation calls get until the operation it returns is done.
+func (G *GCProvider) pollOperation(ctx context.Context, get func() (*compute.Operation, error)) error {
 	for {
-		operation, err := computeService.ZoneOperations.Get(projectID, Zone, operationName).Do()
+		operation, err := get()
 		if err != nil {
 			return err
 		}
 
 		if operation.Status == "DONE" {
 			if operation.Error != nil {
-				return fmt.Errorf("operation failed: %v", operation.Error.Errors)
+				return &operationError{errors: operation.Error.Errors}
 			}
 			return nil
 		}
@@ -181,7 +326,7 @@ func (G *GCProvider) NewInstance(instance interface{}, data *schema.ResourceData
 		return nil, fmt.Errorf("expected GCPInstance got %T", instance)
 	}
 	project := data.Get("gcp_project").(string)
-	zone := data.Get("region").(string)
+	zone := data.Get("zone").(string)
 	machineType := data.Get("instance_type").(string)
 	newInstance.Instance.MachineType = fmt.Sprintf("projects/%s/zones/%s/machineTypes/%s", project, zone, machineType)
 	return newInstance, nil
@@ -189,10 +334,111 @@ func (G *GCProvider) NewInstance(instance interface{}, data *schema.ResourceData
 
 func (G *GCProvider) GetInstanceConfig(instance interface{}, data *schema.ResourceData) *vmconfig.VMConfig {
 	newInstance := instance.(*GCPInstance).Instance
-	return &vmconfig.VMConfig{
-		Name:         newInstance.Name,
-		InstanceType: newInstance.MachineType,
-		GCPProjectID: data.Get("gcp_project").(string),
-		Region:       data.Get("region").(string),
+	config := &vmconfig.VMConfig{
+		ID:                 strconv.FormatUint(newInstance.Id, 10),
+		Name:               newInstance.Name,
+		InstanceType:       lastSegment(newInstance.MachineType),
+		GCPProjectID:       data.Get("gcp_project").(string),
+		Region:             data.Get("region").(string),
+		Zone:               lastSegment(newInstance.Zone),
+		Tags:               newInstance.Labels,
+		Capacity:           gcpCapacity(newInstance.Scheduling),
+		PowerState:         gcpPowerState(newInstance),
+		DeletionProtection: newInstance.DeletionProtection,
+	}
+	config.PublicIPMode = gcpPublicIPMode(newInstance, data.Get("public_ip").(string))
+	if len(newInstance.NetworkInterfaces) > 0 {
+		nic := newInstance.NetworkInterfaces[0]
+		config.PrivateIP = nic.NetworkIP
+		if len(nic.AccessConfigs) > 0 {
+			config.PublicIP = nic.AccessConfigs[0].NatIP
+		}
+	}
+	return config
+}
+
+// gcpScheduling requests spot capacity. Spot VMs cannot be live migrated or
+// restarted automatically; "terminate" maps to DELETE.
+func gcpScheduling(capacity vmconfig.Capacity) *compute.Scheduling {
+	if capacity.Model != "spot" {
+		return nil
+	}
+	scheduling := &compute.Scheduling{
+		ProvisioningModel: "SPOT",
+		AutomaticRestart:  googleapi.Bool(false),
+		OnHostMaintenance: "TERMINATE",
+	}
+	switch capacity.InterruptionBehavior {
+	case "stop":
+		scheduling.InstanceTerminationAction = "STOP"
+	case "terminate", "delete":
+		scheduling.InstanceTerminationAction = "DELETE"
 	}
+	return scheduling
+}
+
+func gcpCapacity(scheduling *compute.Scheduling) vmconfig.Capacity {
+	if scheduling != nil && (scheduling.ProvisioningModel == "SPOT" || scheduling.Preemptible) {
+		return vmconfig.Capacity{Model: "spot"}
+	}
+	return vmconfig.Capacity{Model: "on_demand"}
+}
+
+// gcpMetadata builds the instance metadata. cloud-init documents go to the
+// user-data key read by cloud-init, anything else to the startup-script key
+// read by the guest environment.
+func gcpMetadata(VM *vmconfig.VMConfig) *compute.Metadata {
+	metadata := &compute.Metadata{}
+	if VM.UserData != "" {
+		key := "startup-script"
+		if isCloudInit(VM.UserData) {
+			key = "user-data"
+		}
+		setMetadataItem(metadata, key, VM.UserData)
+	}
+	setMetadataItem(metadata, "ssh-keys", gcpSSHKeys(VM))
+	if len(metadata.Items) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// isCloudInit reports whether userData is a cloud-config or MIME multipart
+// document rather than a plain script.
+func isCloudInit(userData string) bool {
+	return strings.HasPrefix(userData, "#cloud-config") || strings.HasPrefix(userData, "Content-Type: multipart/")
+}
+
+// selectZone picks the first zone of VM.Region, in name order, that offers
+// VM.InstanceType.
+func (G *GCProvider) selectZone(ctx context.Context, computeService *compute.Service, VM *vmconfig.VMConfig) (string, error) {
+	if VM.Region == "" {
+		return "", fmt.Errorf("either zone or region must be set")
+	}
+	region, err := computeService.Regions.Get(VM.GCPProjectID, VM.Region).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("error reading region %s: %w", VM.Region, err)
+	}
+	zones := make([]string, 0, len(region.Zones))
+	for _, zone := range region.Zones {
+		zones = append(zones, lastSegment(zone))
+	}
+	sort.Strings(zones)
+	filter := fmt.Sprintf("name = %q", VM.InstanceType)
+	for _, zone := range zones {
+		machineTypes, err := computeService.MachineTypes.List(VM.GCPProjectID, zone).Filter(filter).Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("error listing machine types in %s: %w", zone, err)
+		}
+		if len(machineTypes.Items) > 0 {
+			return zone, nil
+		}
+	}
+	return "", fmt.Errorf("no zone in %s offers machine type %s", VM.Region, VM.InstanceType)
+}
+
+// lastSegment returns the resource name at the end of a Compute Engine URL
+// such as ".../zones/europe-west1-b".
+func lastSegment(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
 }
@@ -0,0 +1,49 @@
+package cloud
+
+import (
+	"strings"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+const gcpScopePrefix = "https://www.googleapis.com/auth/"
+
+// awsInstanceProfile refers to the instance profile by ARN or by name,
+// whichever the identity holds.
+func awsInstanceProfile(identity *vmconfig.ServiceIdentity) *ec2.IamInstanceProfileSpecification {
+	if identity == nil || identity.InstanceProfile == "" {
+		return nil
+	}
+	if strings.HasPrefix(identity.InstanceProfile, "arn:") {
+		return &ec2.IamInstanceProfileSpecification{Arn: aws.String(identity.InstanceProfile)}
+	}
+	return &ec2.IamInstanceProfileSpecification{Name: aws.String(identity.InstanceProfile)}
+}
+
+// gcpServiceAccounts attaches the service account of the identity, or the
+// default compute service account when no email is set. Short scope names
+// are expanded to their URL.
+func gcpServiceAccounts(identity *vmconfig.ServiceIdentity) []*compute.ServiceAccount {
+	if identity == nil {
+		return nil
+	}
+	email := identity.ServiceAccountEmail
+	if email == "" {
+		email = "default"
+	}
+	scopes := identity.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"cloud-platform"}
+	}
+	account := &compute.ServiceAccount{Email: email}
+	for _, scope := range scopes {
+		if !strings.HasPrefix(scope, "https://") {
+			scope = gcpScopePrefix + scope
+		}
+		account.Scopes = append(account.Scopes, scope)
+	}
+	return []*compute.ServiceAccount{account}
+}
@@ -0,0 +1,39 @@
+package cloud
+
+import (
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSInstanceProfile(t *testing.T) {
+	assert.Nil(t, awsInstanceProfile(nil))
+
+	profile := awsInstanceProfile(&vmconfig.ServiceIdentity{InstanceProfile: "web"})
+	assert.Equal(t, "web", aws.StringValue(profile.Name))
+	assert.Nil(t, profile.Arn)
+
+	profile = awsInstanceProfile(&vmconfig.ServiceIdentity{InstanceProfile: "arn:aws:iam::123456789012:instance-profile/web"})
+	assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/web", aws.StringValue(profile.Arn))
+	assert.Nil(t, profile.Name)
+}
+
+func TestGCPServiceAccounts(t *testing.T) {
+	assert.Nil(t, gcpServiceAccounts(nil))
+
+	accounts := gcpServiceAccounts(&vmconfig.ServiceIdentity{})
+	assert.Equal(t, "default", accounts[0].Email)
+	assert.Equal(t, []string{"https://www.googleapis.com/auth/cloud-platform"}, accounts[0].Scopes)
+
+	accounts = gcpServiceAccounts(&vmconfig.ServiceIdentity{
+		ServiceAccountEmail: "app@project.iam.gserviceaccount.com",
+		Scopes:              []string{"devstorage.read_only", "https://www.googleapis.com/auth/logging.write"},
+	})
+	assert.Equal(t, "app@project.iam.gserviceaccount.com", accounts[0].Email)
+	assert.Equal(t, []string{
+		"https://www.googleapis.com/auth/devstorage.read_only",
+		"https://www.googleapis.com/auth/logging.write",
+	}, accounts[0].Scopes)
+}
@@ -0,0 +1,335 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	// awsImageWaitAttempts bounds the wait for AMIs and snapshots, which can
+	// take far longer than the default of the SDK waiters. The context of the
+	// operation still applies.
+	awsImageWaitAttempts = 360
+	// awsSnapshotRootDevice is the root device of the AMIs registered to
+	// restore a snapshot.
+	awsSnapshotRootDevice = "/dev/xvda"
+)
+
+// awsLaunchImage returns the AMI to launch VM from. A snapshot is registered
+// as a temporary AMI, which cleanup deregisters once the instance is
+// launched: instances do not need their AMI to keep running.
+func (A *AWSProvider) awsLaunchImage(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig) (imageID string, cleanup func(), err error) {
+	cleanup = func() {}
+	switch {
+	case VM.SourceImageID != "":
+		return VM.SourceImageID, cleanup, nil
+	case VM.SourceSnapshot == "":
+		return VM.AWS.AMI, cleanup, nil
+	}
+	architecture, err := A.awsArchitecture(ctx, ec2Svc, VM.InstanceType)
+	if err != nil {
+		return "", cleanup, err
+	}
+	image, err := ec2Svc.RegisterImageWithContext(ctx, &ec2.RegisterImageInput{
+		Name:               aws.String(fmt.Sprintf("%s-%s-%d", VM.Name, VM.SourceSnapshot, time.Now().Unix())),
+		Description:        aws.String(fmt.Sprintf("Restores %s for %s", VM.SourceSnapshot, VM.Name)),
+		Architecture:       aws.String(architecture),
+		RootDeviceName:     aws.String(awsSnapshotRootDevice),
+		VirtualizationType: aws.String(ec2.VirtualizationTypeHvm),
+		EnaSupport:         aws.Bool(true),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{{
+			DeviceName: aws.String(awsSnapshotRootDevice),
+			Ebs: &ec2.EbsBlockDevice{
+				SnapshotId:          aws.String(VM.SourceSnapshot),
+				VolumeType:          aws.String(ec2.VolumeTypeGp3),
+				DeleteOnTermination: aws.Bool(true),
+			},
+		}},
+	})
+	if err != nil {
+		return "", cleanup, fmt.Errorf("error registering an AMI from %s: %w", VM.SourceSnapshot, err)
+	}
+	cleanup = func() {
+		// The AMI only exists for this launch, so a failure leaves nothing
+		// worth reporting over the result of the launch.
+		_, _ = ec2Svc.DeregisterImageWithContext(context.Background(), &ec2.DeregisterImageInput{ImageId: image.ImageId})
+	}
+	images := &ec2.DescribeImagesInput{ImageIds: []*string{image.ImageId}}
+	if err := ec2Svc.WaitUntilImageAvailableWithContext(ctx, images, request.WithWaiterMaxAttempts(awsImageWaitAttempts)); err != nil {
+		return "", cleanup, fmt.Errorf("error waiting for the AMI of %s: %w", VM.SourceSnapshot, err)
+	}
+	return aws.StringValue(image.ImageId), cleanup, nil
+}
+
+// awsArchitecture returns the architecture instanceType runs AMIs of. Types
+// that also support i386 list it first, so x86_64 and arm64 are preferred.
+func (A *AWSProvider) awsArchitecture(ctx context.Context, ec2Svc *ec2.EC2, instanceType string) (string, error) {
+	types, err := ec2Svc.DescribeInstanceTypesWithContext(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{aws.String(instanceType)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reading instance type %s: %w", instanceType, err)
+	}
+	if len(types.InstanceTypes) == 0 || types.InstanceTypes[0].ProcessorInfo == nil || len(types.InstanceTypes[0].ProcessorInfo.SupportedArchitectures) == 0 {
+		return "", fmt.Errorf("instance type %s not found", instanceType)
+	}
+	return preferredArchitecture(aws.StringValueSlice(types.InstanceTypes[0].ProcessorInfo.SupportedArchitectures)), nil
+}
+
+// preferredArchitecture returns x86_64 or arm64 when architectures holds one
+// of them, and the first architecture otherwise.
+func preferredArchitecture(architectures []string) string {
+	for _, architecture := range architectures {
+		if architecture == ec2.ArchitectureTypeX8664 || architecture == ec2.ArchitectureTypeArm64 {
+			return architecture
+		}
+	}
+	return architectures[0]
+}
+
+// CreateImage creates an AMI from the instance of img and waits for it to be
+// available. EC2 reboots the instance for a consistent image unless NoReboot
+// is set.
+func (A *AWSProvider) CreateImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(img.Region)
+	image, err := ec2Svc.CreateImageWithContext(ctx, &ec2.CreateImageInput{
+		InstanceId:        aws.String(img.ServerID),
+		Name:              aws.String(img.Name),
+		Description:       aws.String(img.Description),
+		NoReboot:          aws.Bool(img.NoReboot),
+		TagSpecifications: awsTagSpecifications(img.Tags, ec2.ResourceTypeImage, ec2.ResourceTypeSnapshot),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating image %s: %w", img.Name, err)
+	}
+	img.ID = aws.StringValue(image.ImageId)
+	images := &ec2.DescribeImagesInput{ImageIds: []*string{image.ImageId}}
+	if err := ec2Svc.WaitUntilImageAvailableWithContext(ctx, images, request.WithWaiterMaxAttempts(awsImageWaitAttempts)); err != nil {
+		return fmt.Errorf("error waiting for %s: %w", img.ID, err)
+	}
+	return nil
+}
+
+func (A *AWSProvider) describeImage(ctx context.Context, ec2Svc *ec2.EC2, imageID string) (*ec2.Image, error) {
+	images, err := ec2Svc.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{ImageIds: []*string{aws.String(imageID)}})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidAMIID.NotFound") || isAWSNotFound(err, "InvalidAMIID.Unavailable") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(images.Images) == 0 || aws.StringValue(images.Images[0].State) == ec2.ImageStateDeregistered {
+		return nil, nil
+	}
+	return images.Images[0], nil
+}
+
+// GetImage reads the AMI of img. It returns nil when the AMI was deregistered.
+func (A *AWSProvider) GetImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) (*vmconfig.ImageConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	image, err := A.describeImage(ctx, awsClient.ec2Service(img.Region), img.ID)
+	if err != nil || image == nil {
+		return nil, err
+	}
+	config := *img
+	config.Name = aws.StringValue(image.Name)
+	config.Description = aws.StringValue(image.Description)
+	config.Tags = map[string]string{}
+	for _, tag := range image.Tags {
+		if key := aws.StringValue(tag.Key); !isIgnoredTag(key, img.IgnoreTagKeys) {
+			config.Tags[key] = aws.StringValue(tag.Value)
+		}
+	}
+	return &config, nil
+}
+
+// UpdateImage updates the tags of the AMI of img.
+func (A *AWSProvider) UpdateImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(img.Region)
+	image, err := A.describeImage(ctx, ec2Svc, img.ID)
+	if err != nil {
+		return err
+	}
+	if image == nil {
+		return fmt.Errorf("image %s not found", img.ID)
+	}
+	return A.updateTags(ctx, ec2Svc, image.ImageId, image.Tags, img.Tags, img.IgnoreTagKeys)
+}
+
+// DeleteImage deregisters the AMI of img and deletes the snapshots backing
+// it, which would otherwise be left behind.
+func (A *AWSProvider) DeleteImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(img.Region)
+	image, err := A.describeImage(ctx, ec2Svc, img.ID)
+	if err != nil || image == nil {
+		return err
+	}
+	if _, err := ec2Svc.DeregisterImageWithContext(ctx, &ec2.DeregisterImageInput{ImageId: image.ImageId}); err != nil {
+		return fmt.Errorf("error deregistering %s: %w", img.ID, err)
+	}
+	for _, mapping := range image.BlockDeviceMappings {
+		if mapping.Ebs == nil || mapping.Ebs.SnapshotId == nil {
+			continue
+		}
+		_, err := ec2Svc.DeleteSnapshotWithContext(ctx, &ec2.DeleteSnapshotInput{SnapshotId: mapping.Ebs.SnapshotId})
+		if err != nil && !isAWSNotFound(err, "InvalidSnapshot.NotFound") {
+			return fmt.Errorf("error deleting snapshot %s of %s: %w", aws.StringValue(mapping.Ebs.SnapshotId), img.ID, err)
+		}
+	}
+	return nil
+}
+
+// gcpGlobalResource returns the partial URL of a global resource of kind,
+// such as images, for an ID that is either a name in projectID or already a
+// path.
+func gcpGlobalResource(projectID, kind, id string) string {
+	if strings.Contains(id, "/") {
+		return id
+	}
+	return fmt.Sprintf("projects/%s/global/%s/%s", projectID, kind, id)
+}
+
+// gcpBootDiskSource returns the disk the boot disk of VM is created from,
+// as a source image and a source snapshot of which one is set.
+func gcpBootDiskSource(VM *vmconfig.VMConfig) (sourceImage, sourceSnapshot string) {
+	switch {
+	case VM.SourceSnapshot != "":
+		return "", gcpGlobalResource(VM.GCPProjectID, "snapshots", VM.SourceSnapshot)
+	case VM.SourceImageID != "":
+		return gcpGlobalResource(VM.GCPProjectID, "images", VM.SourceImageID), ""
+	default:
+		return fmt.Sprintf("projects/%s/global/images/family/%s", VM.GCP.ImageProject, VM.GCP.ImageFamily), ""
+	}
+}
+
+// CreateImage creates a GCE image from the boot disk of the instance of img.
+// The image of a running instance is only crash consistent.
+func (G *GCProvider) CreateImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	instance, err := computeService.Instances.Get(img.GCPProjectID, img.Zone, img.ServerID).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error reading instance %s: %w", img.ServerID, err)
+	}
+	var sourceDisk string
+	for _, disk := range instance.Disks {
+		if disk.Boot {
+			sourceDisk = disk.Source
+		}
+	}
+	if sourceDisk == "" {
+		return fmt.Errorf("instance %s has no boot disk", img.ServerID)
+	}
+	labels, err := SanitizeGCELabels(img.Tags)
+	if err != nil {
+		return err
+	}
+	op, err := computeService.Images.Insert(img.GCPProjectID, &compute.Image{
+		Name:        img.Name,
+		Description: img.Description,
+		SourceDisk:  sourceDisk,
+		Labels:      labels,
+	}).ForceCreate(true).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error creating image %s: %w", img.Name, err)
+	}
+	img.ID = img.Name
+	return G.waitForGlobalOperation(ctx, client, img.GCPProjectID, op.Name)
+}
+
+// GetImage reads the GCE image of img. It returns nil when the image no
+// longer exists.
+func (G *GCProvider) GetImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) (*vmconfig.ImageConfig, error) {
+	computeService := client.(*GCPClient).client
+	image, err := computeService.Images.Get(img.GCPProjectID, img.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	config := *img
+	config.Name = image.Name
+	config.Description = image.Description
+	config.Tags = map[string]string{}
+	for k, v := range image.Labels {
+		if !isIgnoredLabel(k, img.IgnoreTagKeys) {
+			config.Tags[k] = v
+		}
+	}
+	return &config, nil
+}
+
+// UpdateImage updates the labels of the GCE image of img.
+func (G *GCProvider) UpdateImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	image, err := computeService.Images.Get(img.GCPProjectID, img.ID).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	labels, err := gcpLabelsKeepingIgnored(img.Tags, image.Labels, img.IgnoreTagKeys)
+	if err != nil {
+		return err
+	}
+	op, err := computeService.Images.SetLabels(img.GCPProjectID, img.ID, &compute.GlobalSetLabelsRequest{
+		Labels:           labels,
+		LabelFingerprint: image.LabelFingerprint,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error setting the labels of image %s: %w", img.ID, err)
+	}
+	return G.waitForGlobalOperation(ctx, client, img.GCPProjectID, op.Name)
+}
+
+// DeleteImage deletes the GCE image of img.
+func (G *GCProvider) DeleteImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Images.Delete(img.GCPProjectID, img.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting image %s: %w", img.ID, err)
+	}
+	return G.waitForGlobalOperation(ctx, client, img.GCPProjectID, op.Name)
+}
+
+// gcpLabelsKeepingIgnored converts tags to labels and keeps the current
+// labels whose keys are ignored, since label updates replace every label.
+func gcpLabelsKeepingIgnored(tags, current map[string]string, ignoreTagKeys []string) (map[string]string, error) {
+	labels, err := SanitizeGCELabels(tags)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range current {
+		if isIgnoredLabel(k, ignoreTagKeys) {
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[k] = v
+		}
+	}
+	return labels, nil
+}
@@ -0,0 +1,65 @@
+package cloud
+
+import (
+	"context"
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCPBootDiskSource(t *testing.T) {
+	VM := &vmconfig.VMConfig{
+		GCPProjectID: "dantata",
+		GCP:          &vmconfig.GCPOptions{ImageFamily: "debian-12", ImageProject: "debian-cloud"},
+	}
+	disk := gcpBootDisk(VM, nil)
+	assert.Equal(t, "projects/debian-cloud/global/images/family/debian-12", disk.InitializeParams.SourceImage)
+	assert.Empty(t, disk.InitializeParams.SourceSnapshot)
+
+	VM.SourceImageID = "golden"
+	disk = gcpBootDisk(VM, nil)
+	assert.Equal(t, "projects/dantata/global/images/golden", disk.InitializeParams.SourceImage, "an image name should be looked up in the project")
+
+	VM.SourceImageID = "projects/shared/global/images/golden"
+	disk = gcpBootDisk(VM, nil)
+	assert.Equal(t, "projects/shared/global/images/golden", disk.InitializeParams.SourceImage, "an image path should be kept")
+
+	VM.SourceImageID = ""
+	VM.SourceSnapshot = "nightly"
+	disk = gcpBootDisk(VM, nil)
+	assert.Empty(t, disk.InitializeParams.SourceImage, "a snapshot should replace the image")
+	assert.Equal(t, "projects/dantata/global/snapshots/nightly", disk.InitializeParams.SourceSnapshot)
+}
+
+func TestGCPLabelsKeepingIgnored(t *testing.T) {
+	labels, err := gcpLabelsKeepingIgnored(
+		map[string]string{"Team": "Data"},
+		map[string]string{"team": "old", "owner": "ops", "stale": "x"},
+		[]string{"owner"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "data", "owner": "ops"}, labels)
+
+	labels, err = gcpLabelsKeepingIgnored(nil, map[string]string{"owner": "ops"}, []string{"owner"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "ops"}, labels, "ignored labels should be kept without tags")
+
+	labels, err = gcpLabelsKeepingIgnored(nil, map[string]string{"cost_center": "42"}, []string{"Cost Center"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"cost_center": "42"}, labels, "ignored keys should be compared as label keys")
+}
+
+func TestAWSArchitecture(t *testing.T) {
+	assert.Equal(t, "x86_64", preferredArchitecture([]string{"i386", "x86_64"}), "i386 is listed first but AMIs are 64-bit")
+	assert.Equal(t, "arm64", preferredArchitecture([]string{"arm64"}))
+	assert.Equal(t, "x86_64_mac", preferredArchitecture([]string{"x86_64_mac"}))
+
+	_, client := newFakeEC2(t, map[string]string{
+		"DescribeInstanceTypes": "<instanceTypeSet><item><instanceType>t2.micro</instanceType><processorInfo><supportedArchitectures><item>i386</item><item>x86_64</item></supportedArchitectures></processorInfo></item></instanceTypeSet>",
+	})
+	architecture, err := (&AWSProvider{}).awsArchitecture(context.Background(), client.ec2Service("eu-west-1"), "t2.micro")
+	require.NoError(t, err)
+	assert.Equal(t, "x86_64", architecture)
+}
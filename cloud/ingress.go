@@ -0,0 +1,374 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+// ParsePortRange parses a port ("22") or an inclusive port range
+// ("8000-8080").
+func ParsePortRange(ports string) (int64, int64, error) {
+	fromPort, toPort, isRange := strings.Cut(ports, "-")
+	from, err := strconv.ParseInt(fromPort, 10, 64)
+	if err != nil || from < 0 || from > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q", ports)
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	to, err := strconv.ParseInt(toPort, 10, 64)
+	if err != nil || to < from || to > 65535 {
+		return 0, 0, fmt.Errorf("invalid port range %q", ports)
+	}
+	return from, to, nil
+}
+
+// ingressName is the name of the security group, firewall rules and network
+// tag managed for the ingress rules of the server called name.
+func ingressName(name string) string {
+	return strings.TrimRight(truncate("cf-ingress-"+name, gceLabelMaxLength), "-")
+}
+
+// awsProtocol maps a rule protocol to the EC2 IpProtocol.
+func awsProtocol(protocol string) string {
+	if protocol == "all" {
+		return "-1"
+	}
+	return protocol
+}
+
+// awsIpPermissions converts the ingress rules into EC2 permissions, one per
+// port range.
+func awsIpPermissions(rules []vmconfig.IngressRule) ([]*ec2.IpPermission, error) {
+	var permissions []*ec2.IpPermission
+	for _, rule := range rules {
+		var ranges []*ec2.IpRange
+		for _, cidr := range rule.CIDRBlocks {
+			ranges = append(ranges, &ec2.IpRange{CidrIp: aws.String(cidr)})
+		}
+		if len(rule.Ports) == 0 {
+			permission := &ec2.IpPermission{
+				IpProtocol: aws.String(awsProtocol(rule.Protocol)),
+				IpRanges:   ranges,
+			}
+			switch rule.Protocol {
+			case "icmp":
+				// Every ICMP type and code.
+				permission.FromPort = aws.Int64(-1)
+				permission.ToPort = aws.Int64(-1)
+			case "tcp", "udp":
+				permission.FromPort = aws.Int64(0)
+				permission.ToPort = aws.Int64(65535)
+			}
+			permissions = append(permissions, permission)
+			continue
+		}
+		for _, ports := range rule.Ports {
+			from, to, err := ParsePortRange(ports)
+			if err != nil {
+				return nil, err
+			}
+			permissions = append(permissions, &ec2.IpPermission{
+				IpProtocol: aws.String(rule.Protocol),
+				FromPort:   aws.Int64(from),
+				ToPort:     aws.Int64(to),
+				IpRanges:   ranges,
+			})
+		}
+	}
+	return permissions, nil
+}
+
+// awsVpcID returns the VPC of subnetID, or the default VPC of the region when
+// no subnet is given.
+func awsVpcID(ctx context.Context, ec2Svc *ec2.EC2, subnetID string) (string, error) {
+	if subnetID != "" {
+		subnets, err := ec2Svc.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+			SubnetIds: []*string{aws.String(subnetID)},
+		})
+		if err != nil {
+			return "", fmt.Errorf("error reading subnet %s: %w", subnetID, err)
+		}
+		if len(subnets.Subnets) == 0 {
+			return "", fmt.Errorf("subnet %s not found", subnetID)
+		}
+		return aws.StringValue(subnets.Subnets[0].VpcId), nil
+	}
+	vpcs, err := ec2Svc.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("is-default"), Values: []*string{aws.String("true")}}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reading the default VPC: %w", err)
+	}
+	if len(vpcs.Vpcs) == 0 {
+		return "", fmt.Errorf("no default VPC, set subnet_id")
+	}
+	return aws.StringValue(vpcs.Vpcs[0].VpcId), nil
+}
+
+// findIngressGroup returns the security group managed for the ingress rules
+// of VM, or nil when there is none. Group names are only unique within a
+// VPC, so the lookup is limited to the VPC of VM.
+func (A *AWSProvider) findIngressGroup(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig) (*ec2.SecurityGroup, error) {
+	vpcID, err := awsVpcID(ctx, ec2Svc, VM.SubnetID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := ec2Svc.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("group-name"), Values: []*string{aws.String(ingressName(VM.Name))}},
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading security groups: %w", err)
+	}
+	if len(groups.SecurityGroups) == 0 {
+		return nil, nil
+	}
+	return groups.SecurityGroups[0], nil
+}
+
+// createIngressGroup creates the security group holding the ingress rules of
+// VM and returns its ID.
+func (A *AWSProvider) createIngressGroup(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig) (string, error) {
+	permissions, err := awsIpPermissions(VM.Ingress)
+	if err != nil {
+		return "", err
+	}
+	vpcID, err := awsVpcID(ctx, ec2Svc, VM.SubnetID)
+	if err != nil {
+		return "", err
+	}
+	name := ingressName(VM.Name)
+	group, err := ec2Svc.CreateSecurityGroupWithContext(ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:         aws.String(name),
+		Description:       aws.String(fmt.Sprintf("Ingress rules of %s, managed by cloudfusion", VM.Name)),
+		VpcId:             aws.String(vpcID),
+		TagSpecifications: awsTagSpecifications(VM.Tags, ec2.ResourceTypeSecurityGroup),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating security group %s: %w", name, err)
+	}
+	_, err = ec2Svc.AuthorizeSecurityGroupIngressWithContext(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       group.GroupId,
+		IpPermissions: permissions,
+	})
+	if err != nil {
+		_, _ = ec2Svc.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{GroupId: group.GroupId})
+		return "", fmt.Errorf("error authorizing ingress on %s: %w", name, err)
+	}
+	return aws.StringValue(group.GroupId), nil
+}
+
+// updateIngress makes the security groups of instance match VM: the managed
+// group is created, its rules replaced, or removed when no rule is left.
+func (A *AWSProvider) updateIngress(ctx context.Context, ec2Svc *ec2.EC2, instance *ec2.Instance, VM *vmconfig.VMConfig) error {
+	group, err := A.findIngressGroup(ctx, ec2Svc, VM)
+	if err != nil {
+		return err
+	}
+	var groupIDs []*string
+	for _, attached := range instance.SecurityGroups {
+		if group == nil || aws.StringValue(attached.GroupId) != aws.StringValue(group.GroupId) {
+			groupIDs = append(groupIDs, attached.GroupId)
+		}
+	}
+
+	switch {
+	case len(VM.Ingress) == 0 && group == nil:
+		return nil
+	case len(VM.Ingress) == 0:
+		if len(groupIDs) == 0 {
+			return fmt.Errorf("cannot remove the last security group of %s", VM.ID)
+		}
+		if err := A.setInstanceGroups(ctx, ec2Svc, instance.InstanceId, groupIDs); err != nil {
+			return err
+		}
+		return A.deleteIngressGroup(ctx, ec2Svc, VM)
+	case group == nil:
+		groupID, err := A.createIngressGroup(ctx, ec2Svc, VM)
+		if err != nil {
+			return err
+		}
+		return A.setInstanceGroups(ctx, ec2Svc, instance.InstanceId, append(groupIDs, aws.String(groupID)))
+	}
+
+	permissions, err := awsIpPermissions(VM.Ingress)
+	if err != nil {
+		return err
+	}
+	if len(group.IpPermissions) > 0 {
+		_, err = ec2Svc.RevokeSecurityGroupIngressWithContext(ctx, &ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       group.GroupId,
+			IpPermissions: group.IpPermissions,
+		})
+		if err != nil {
+			return fmt.Errorf("error revoking ingress on %s: %w", aws.StringValue(group.GroupName), err)
+		}
+	}
+	_, err = ec2Svc.AuthorizeSecurityGroupIngressWithContext(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       group.GroupId,
+		IpPermissions: permissions,
+	})
+	if err != nil {
+		return fmt.Errorf("error authorizing ingress on %s: %w", aws.StringValue(group.GroupName), err)
+	}
+	return nil
+}
+
+func (A *AWSProvider) setInstanceGroups(ctx context.Context, ec2Svc *ec2.EC2, instanceID *string, groupIDs []*string) error {
+	_, err := ec2Svc.ModifyInstanceAttributeWithContext(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId: instanceID,
+		Groups:     groupIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("error setting the security groups of %s: %w", aws.StringValue(instanceID), err)
+	}
+	return nil
+}
+
+// deleteIngressGroup deletes the security group managed for VM, if any. The
+// group must no longer be attached to the instance.
+func (A *AWSProvider) deleteIngressGroup(ctx context.Context, ec2Svc *ec2.EC2, VM *vmconfig.VMConfig) error {
+	group, err := A.findIngressGroup(ctx, ec2Svc, VM)
+	if err != nil || group == nil {
+		return err
+	}
+	_, err = ec2Svc.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{GroupId: group.GroupId})
+	var awsErr awserr.Error
+	if err != nil && !(errors.As(err, &awsErr) && awsErr.Code() == "InvalidGroup.NotFound") {
+		return fmt.Errorf("error deleting security group %s: %w", aws.StringValue(group.GroupName), err)
+	}
+	return nil
+}
+
+// gcpFirewalls converts the ingress rules of VM into firewall rules targeting
+// the ingress network tag. GCE firewall rules have a single set of source
+// ranges, so each ingress rule becomes its own firewall rule.
+func gcpFirewalls(VM *vmconfig.VMConfig) []*compute.Firewall {
+	name := ingressName(VM.Name)
+	firewalls := make([]*compute.Firewall, 0, len(VM.Ingress))
+	for i, rule := range VM.Ingress {
+		firewalls = append(firewalls, &compute.Firewall{
+			Name:         fmt.Sprintf("%s-%d", truncate(name, gceLabelMaxLength-4), i),
+			Description:  fmt.Sprintf("Ingress rule %d of %s, managed by cloudfusion", i, VM.Name),
+			Network:      fmt.Sprintf("projects/%s/global/networks/%s", VM.GCPProjectID, VM.GCP.NetworkName),
+			Direction:    "INGRESS",
+			SourceRanges: rule.CIDRBlocks,
+			TargetTags:   []string{name},
+			Allowed: []*compute.FirewallAllowed{
+				{
+					IPProtocol: rule.Protocol,
+					Ports:      rule.Ports,
+				},
+			},
+		})
+	}
+	return firewalls
+}
+
+// syncFirewalls creates, updates and deletes the firewall rules managed for
+// VM so that they match its ingress rules.
+func (G *GCProvider) syncFirewalls(ctx context.Context, client interface{}, VM *vmconfig.VMConfig) error {
+	filter := fmt.Sprintf(`name eq "%s-[0-9]+"`, truncate(ingressName(VM.Name), gceLabelMaxLength-4))
+	return G.applyFirewalls(ctx, client, VM.GCPProjectID, filter, gcpFirewalls(VM))
+}
+
+// applyFirewalls makes the firewall rules matching filter equal to
+// firewalls: missing rules are created, existing ones updated and the others
+// deleted.
+func (G *GCProvider) applyFirewalls(ctx context.Context, client interface{}, projectID, filter string, firewalls []*compute.Firewall) error {
+	computeService := client.(*GCPClient).client
+	existing := map[string]bool{}
+	err := computeService.Firewalls.List(projectID).Filter(filter).Pages(ctx, func(page *compute.FirewallList) error {
+		for _, firewall := range page.Items {
+			existing[firewall.Name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error listing firewall rules: %w", err)
+	}
+
+	for _, firewall := range firewalls {
+		var op *compute.Operation
+		if existing[firewall.Name] {
+			op, err = computeService.Firewalls.Update(projectID, firewall.Name, firewall).Context(ctx).Do()
+		} else {
+			op, err = computeService.Firewalls.Insert(projectID, firewall).Context(ctx).Do()
+		}
+		if err != nil {
+			return fmt.Errorf("error writing firewall rule %s: %w", firewall.Name, err)
+		}
+		if err := G.waitForGlobalOperation(ctx, client, projectID, op.Name); err != nil {
+			return err
+		}
+		delete(existing, firewall.Name)
+	}
+
+	removed := make([]string, 0, len(existing))
+	for name := range existing {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	return G.deleteFirewallRules(ctx, client, projectID, removed)
+}
+
+// deleteFirewallRules deletes the firewall rules of names, ignoring the ones
+// already gone.
+func (G *GCProvider) deleteFirewallRules(ctx context.Context, client interface{}, projectID string, names []string) error {
+	computeService := client.(*GCPClient).client
+	for _, name := range names {
+		op, err := computeService.Firewalls.Delete(projectID, name).Context(ctx).Do()
+		if isGCPNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting firewall rule %s: %w", name, err)
+		}
+		if err := G.waitForGlobalOperation(ctx, client, projectID, op.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gcpNetworkTags adds the firewall tags of VM to tags, and the ingress
+// network tag when VM has ingress rules. The ingress tag and removed firewall
+// tags are dropped otherwise; the fingerprint and other tags are kept.
+func gcpNetworkTags(tags *compute.Tags, VM *vmconfig.VMConfig) *compute.Tags {
+	if tags == nil {
+		tags = &compute.Tags{}
+	}
+	name := ingressName(VM.Name)
+	drop := map[string]bool{name: true}
+	for _, tag := range VM.RemovedFirewallTags {
+		drop[tag] = true
+	}
+	for _, tag := range VM.FirewallTags {
+		drop[tag] = true
+	}
+	items := make([]string, 0, len(tags.Items)+len(VM.FirewallTags)+1)
+	for _, item := range tags.Items {
+		if !drop[item] {
+			items = append(items, item)
+		}
+	}
+	items = append(items, VM.FirewallTags...)
+	if len(VM.Ingress) > 0 {
+		items = append(items, name)
+	}
+	tags.Items = items
+	return tags
+}
@@ -0,0 +1,130 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestParsePortRange(t *testing.T) {
+	from, to, err := ParsePortRange("22")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{22, 22}, []int64{from, to})
+
+	from, to, err = ParsePortRange("8000-8080")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{8000, 8080}, []int64{from, to})
+
+	for _, invalid := range []string{"", "http", "80-", "8080-8000", "70000"} {
+		_, _, err := ParsePortRange(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestAWSIpPermissions(t *testing.T) {
+	permissions, err := awsIpPermissions([]vmconfig.IngressRule{
+		{Protocol: "tcp", Ports: []string{"22", "8000-8080"}, CIDRBlocks: []string{"10.0.0.0/8"}},
+		{Protocol: "icmp", CIDRBlocks: []string{"0.0.0.0/0"}},
+		{Protocol: "all", CIDRBlocks: []string{"192.168.0.0/16"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, permissions, 4, "each port range becomes a permission")
+	assert.Equal(t, int64(8000), aws.Int64Value(permissions[1].FromPort))
+	assert.Equal(t, int64(8080), aws.Int64Value(permissions[1].ToPort))
+	assert.Equal(t, int64(-1), aws.Int64Value(permissions[2].FromPort), "icmp allows every type")
+	assert.Equal(t, "-1", aws.StringValue(permissions[3].IpProtocol), "all maps to -1")
+	assert.Nil(t, permissions[3].FromPort)
+}
+
+func TestGCPFirewalls(t *testing.T) {
+	VM := &vmconfig.VMConfig{
+		Name:         "web",
+		GCPProjectID: "dantata",
+		GCP:          &vmconfig.GCPOptions{NetworkName: "default"},
+		Ingress: []vmconfig.IngressRule{
+			{Protocol: "tcp", Ports: []string{"80", "443"}, CIDRBlocks: []string{"0.0.0.0/0"}},
+			{Protocol: "tcp", Ports: []string{"22"}, CIDRBlocks: []string{"10.0.0.0/8"}},
+		},
+	}
+	firewalls := gcpFirewalls(VM)
+	assert.Len(t, firewalls, 2, "each rule has its own source ranges")
+	assert.Equal(t, "cf-ingress-web-0", firewalls[0].Name)
+	assert.Equal(t, []string{"cf-ingress-web"}, firewalls[0].TargetTags)
+	assert.Equal(t, []string{"80", "443"}, firewalls[0].Allowed[0].Ports)
+	assert.Equal(t, "projects/dantata/global/networks/default", firewalls[1].Network)
+}
+
+func TestIngressName(t *testing.T) {
+	name := ingressName(strings.Repeat("a", 52) + "-b")
+	assert.LessOrEqual(t, len(name), 63, "names must fit GCE limits")
+	assert.False(t, strings.HasSuffix(name, "-"), "names must not end with a hyphen")
+}
+
+func TestGCPNetworkTags(t *testing.T) {
+	VM := &vmconfig.VMConfig{Name: "web", Ingress: []vmconfig.IngressRule{{Protocol: "all"}}}
+	tags := gcpNetworkTags(&compute.Tags{Fingerprint: "abc", Items: []string{"http-server"}}, VM)
+	assert.Equal(t, []string{"http-server", "cf-ingress-web"}, tags.Items)
+	assert.Equal(t, "abc", tags.Fingerprint)
+
+	VM.Ingress = nil
+	tags = gcpNetworkTags(tags, VM)
+	assert.Equal(t, []string{"http-server"}, tags.Items, "the ingress tag should be removed with the rules")
+
+	VM.FirewallTags = []string{"web", "ssh"}
+	tags = gcpNetworkTags(tags, VM)
+	assert.Equal(t, []string{"http-server", "web", "ssh"}, tags.Items)
+
+	VM.FirewallTags = []string{"web"}
+	VM.RemovedFirewallTags = []string{"ssh"}
+	tags = gcpNetworkTags(tags, VM)
+	assert.Equal(t, []string{"http-server", "web"}, tags.Items, "removed firewall tags should be dropped")
+}
+
+func gcpTestVM() *vmconfig.VMConfig {
+	return &vmconfig.VMConfig{
+		Name:         "web",
+		Region:       "europe-west1",
+		Zone:         "europe-west1-b",
+		InstanceType: "e2-micro",
+		GCPProjectID: "dantata",
+		Ingress: []vmconfig.IngressRule{
+			{Protocol: "tcp", Ports: []string{"22"}, CIDRBlocks: []string{"10.0.0.0/8"}},
+			{Protocol: "tcp", Ports: []string{"443"}, CIDRBlocks: []string{"0.0.0.0/0"}},
+		},
+		GCP: &vmconfig.GCPOptions{NetworkName: "default"},
+	}
+}
+
+func TestGCPCreateInstanceFirewallRules(t *testing.T) {
+	ctx := context.Background()
+	G := &GCProvider{}
+
+	f, client := newFakeCompute(t)
+	f.put("global/firewalls/cf-ingress-web-0", &compute.Firewall{Name: "cf-ingress-web-0"})
+	f.put("global/firewalls/cf-ingress-web-1", &compute.Firewall{Name: "cf-ingress-web-1"})
+	_, err := G.CreateInstance(ctx, gcpTestVM(), client)
+	assert.Error(t, err, "the rules of another instance of the same name should not be taken over")
+	assert.Contains(t, f.resources, "global/firewalls/cf-ingress-web-0", "the rules of another instance should be left alone")
+	assert.Contains(t, f.resources, "global/firewalls/cf-ingress-web-1")
+	assert.Equal(t, -1, f.index("POST zones/europe-west1-b/instances"))
+
+	f, client = newFakeCompute(t)
+	f.errors["POST zones/europe-west1-b/instances"] = http.StatusBadRequest
+	_, err = G.CreateInstance(ctx, gcpTestVM(), client)
+	assert.Error(t, err)
+	assert.NotContains(t, f.resources, "global/firewalls/cf-ingress-web-0", "the rules of an instance that was not created should be removed")
+	assert.NotContains(t, f.resources, "global/firewalls/cf-ingress-web-1")
+
+	f, client = newFakeCompute(t)
+	f.errors["GET zones/europe-west1-b/operations/"] = http.StatusInternalServerError
+	id, err := G.CreateInstance(ctx, gcpTestVM(), client)
+	assert.Error(t, err)
+	assert.NotEmpty(t, id, "an inserted instance should be tracked even when it cannot be followed")
+	assert.Contains(t, f.resources, "global/firewalls/cf-ingress-web-0", "the rules of an inserted instance should be kept")
+}
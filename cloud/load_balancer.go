@@ -0,0 +1,908 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"google.golang.org/api/compute/v1"
+)
+
+// listenerKeys returns the listeners as "<protocol>:<port>" strings, so they
+// can be compared with sameStrings.
+func listenerKeys(listeners []network.Listener) []string {
+	keys := make([]string, 0, len(listeners))
+	for _, listener := range listeners {
+		keys = append(keys, fmt.Sprintf("%s:%d", listener.Protocol, listener.Port))
+	}
+	return keys
+}
+
+// sameListeners returns known when it holds the same listeners as read, which
+// keeps the configured order, and read sorted otherwise.
+func sameListeners(read, known []network.Listener) []network.Listener {
+	keys := sameStrings(listenerKeys(read), listenerKeys(known))
+	listeners := make([]network.Listener, 0, len(keys))
+	for _, key := range keys {
+		protocol, port, _ := strings.Cut(key, ":")
+		number, _ := strconv.ParseInt(port, 10, 64)
+		listeners = append(listeners, network.Listener{Port: number, Protocol: protocol})
+	}
+	return listeners
+}
+
+// awsTargetGroupName names the target group of a listener. The listeners of a
+// load balancer use distinct ports, so the names are unique.
+func awsTargetGroupName(lb *network.LoadBalancerConfig, listener network.Listener) string {
+	return fmt.Sprintf("%s-%d", lb.Name, listener.Port)
+}
+
+func awsELBTags(tags map[string]string) []*elbv2.Tag {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	elbTags := make([]*elbv2.Tag, 0, len(keys))
+	for _, k := range keys {
+		elbTags = append(elbTags, &elbv2.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return elbTags
+}
+
+// awsHealthCheck returns the health check settings of a target group.
+func awsHealthCheck(hc network.HealthCheck, arn *string) *elbv2.ModifyTargetGroupInput {
+	input := &elbv2.ModifyTargetGroupInput{
+		TargetGroupArn:             arn,
+		HealthCheckProtocol:        aws.String(strings.ToUpper(hc.Protocol)),
+		HealthCheckPort:            aws.String(strconv.FormatInt(hc.Port, 10)),
+		HealthCheckIntervalSeconds: aws.Int64(hc.IntervalSeconds),
+		HealthyThresholdCount:      aws.Int64(hc.HealthyThreshold),
+		UnhealthyThresholdCount:    aws.Int64(hc.UnhealthyThreshold),
+	}
+	if hc.Protocol == "http" {
+		input.HealthCheckPath = aws.String(hc.Path)
+	}
+	return input
+}
+
+// awsDefaultSubnets returns the default subnets of the default VPC, one per
+// availability zone.
+func awsDefaultSubnets(ctx context.Context, ec2Svc *ec2.EC2) ([]string, error) {
+	subnets, err := ec2Svc.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("default-for-az"), Values: []*string{aws.String("true")}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the default subnets: %w", err)
+	}
+	if len(subnets.Subnets) == 0 {
+		return nil, fmt.Errorf("no default subnets, set subnet_ids")
+	}
+	ids := make([]string, 0, len(subnets.Subnets))
+	for _, subnet := range subnets.Subnets {
+		ids = append(ids, aws.StringValue(subnet.SubnetId))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// CreateLoadBalancer creates the load balancer of lb, and a target group of
+// the servers and a listener forwarding to it per listener of lb.
+func (A *AWSProvider) CreateLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(lb.Region)
+	if len(lb.SubnetIDs) == 0 {
+		subnets, err := awsDefaultSubnets(ctx, ec2Svc)
+		if err != nil {
+			return err
+		}
+		lb.SubnetIDs = subnets
+	}
+	vpcID, err := awsVpcID(ctx, ec2Svc, lb.SubnetIDs[0])
+	if err != nil {
+		return err
+	}
+	elbSvc := awsClient.elbv2Service(lb.Region)
+	scheme := elbv2.LoadBalancerSchemeEnumInternetFacing
+	if lb.Internal {
+		scheme = elbv2.LoadBalancerSchemeEnumInternal
+	}
+	input := &elbv2.CreateLoadBalancerInput{
+		Name:    aws.String(lb.Name),
+		Type:    aws.String(lb.LoadBalancerType()),
+		Scheme:  aws.String(scheme),
+		Subnets: aws.StringSlice(lb.SubnetIDs),
+		Tags:    awsELBTags(lb.Tags),
+	}
+	if lb.LoadBalancerType() == "application" && len(lb.FirewallIDs) > 0 {
+		input.SecurityGroups = aws.StringSlice(lb.FirewallIDs)
+	}
+	output, err := elbSvc.CreateLoadBalancerWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error creating load balancer %s: %w", lb.Name, err)
+	}
+	lb.ID = aws.StringValue(output.LoadBalancers[0].LoadBalancerArn)
+	lb.Address = aws.StringValue(output.LoadBalancers[0].DNSName)
+	for _, listener := range lb.Listeners {
+		hc := awsHealthCheck(lb.HealthCheck, nil)
+		group, err := elbSvc.CreateTargetGroupWithContext(ctx, &elbv2.CreateTargetGroupInput{
+			Name:                       aws.String(awsTargetGroupName(lb, listener)),
+			Protocol:                   aws.String(strings.ToUpper(listener.Protocol)),
+			Port:                       aws.Int64(listener.Port),
+			VpcId:                      aws.String(vpcID),
+			TargetType:                 aws.String(elbv2.TargetTypeEnumInstance),
+			HealthCheckProtocol:        hc.HealthCheckProtocol,
+			HealthCheckPort:            hc.HealthCheckPort,
+			HealthCheckPath:            hc.HealthCheckPath,
+			HealthCheckIntervalSeconds: hc.HealthCheckIntervalSeconds,
+			HealthyThresholdCount:      hc.HealthyThresholdCount,
+			UnhealthyThresholdCount:    hc.UnhealthyThresholdCount,
+			Tags:                       awsELBTags(lb.Tags),
+		})
+		if err != nil {
+			return fmt.Errorf("error creating target group %s: %w", awsTargetGroupName(lb, listener), err)
+		}
+		groupARN := group.TargetGroups[0].TargetGroupArn
+		if err := A.syncTargets(ctx, elbSvc, groupARN, lb.ServerIDs); err != nil {
+			return err
+		}
+		_, err = elbSvc.CreateListenerWithContext(ctx, &elbv2.CreateListenerInput{
+			LoadBalancerArn: aws.String(lb.ID),
+			Port:            aws.Int64(listener.Port),
+			Protocol:        aws.String(strings.ToUpper(listener.Protocol)),
+			DefaultActions:  []*elbv2.Action{{Type: aws.String(elbv2.ActionTypeEnumForward), TargetGroupArn: groupARN}},
+		})
+		if err != nil {
+			return fmt.Errorf("error creating listener %s:%d on %s: %w", listener.Protocol, listener.Port, lb.Name, err)
+		}
+	}
+	err = elbSvc.WaitUntilLoadBalancerAvailableWithContext(ctx, &elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []*string{aws.String(lb.ID)}})
+	if err != nil {
+		return fmt.Errorf("error waiting for load balancer %s: %w", lb.Name, err)
+	}
+	return nil
+}
+
+// syncTargets registers serverIDs in the target group and deregisters the
+// other instances.
+func (A *AWSProvider) syncTargets(ctx context.Context, elbSvc *elbv2.ELBV2, groupARN *string, serverIDs []string) error {
+	current, err := awsTargets(ctx, elbSvc, groupARN)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool, len(serverIDs))
+	var register, deregister []*elbv2.TargetDescription
+	for _, id := range serverIDs {
+		wanted[id] = true
+		if !current[id] {
+			register = append(register, &elbv2.TargetDescription{Id: aws.String(id)})
+		}
+	}
+	for id := range current {
+		if !wanted[id] {
+			deregister = append(deregister, &elbv2.TargetDescription{Id: aws.String(id)})
+		}
+	}
+	if len(register) > 0 {
+		_, err := elbSvc.RegisterTargetsWithContext(ctx, &elbv2.RegisterTargetsInput{TargetGroupArn: groupARN, Targets: register})
+		if err != nil {
+			return fmt.Errorf("error registering servers in %s: %w", aws.StringValue(groupARN), err)
+		}
+	}
+	if len(deregister) > 0 {
+		_, err := elbSvc.DeregisterTargetsWithContext(ctx, &elbv2.DeregisterTargetsInput{TargetGroupArn: groupARN, Targets: deregister})
+		if err != nil {
+			return fmt.Errorf("error deregistering servers from %s: %w", aws.StringValue(groupARN), err)
+		}
+	}
+	return nil
+}
+
+// awsTargets returns the instances registered in a target group, less the
+// ones draining after being deregistered.
+func awsTargets(ctx context.Context, elbSvc *elbv2.ELBV2, groupARN *string) (map[string]bool, error) {
+	health, err := elbSvc.DescribeTargetHealthWithContext(ctx, &elbv2.DescribeTargetHealthInput{TargetGroupArn: groupARN})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the targets of %s: %w", aws.StringValue(groupARN), err)
+	}
+	targets := make(map[string]bool, len(health.TargetHealthDescriptions))
+	for _, target := range health.TargetHealthDescriptions {
+		if target.TargetHealth != nil && aws.StringValue(target.TargetHealth.State) == elbv2.TargetHealthStateEnumDraining {
+			continue
+		}
+		targets[aws.StringValue(target.Target.Id)] = true
+	}
+	return targets, nil
+}
+
+// GetLoadBalancer reads the load balancer of lb and its target groups. It
+// returns nil when the load balancer no longer exists.
+func (A *AWSProvider) GetLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) (*network.LoadBalancerConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	elbSvc := awsClient.elbv2Service(lb.Region)
+	balancers, err := elbSvc.DescribeLoadBalancersWithContext(ctx, &elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []*string{aws.String(lb.ID)}})
+	if err != nil {
+		if isAWSNotFound(err, elbv2.ErrCodeLoadBalancerNotFoundException) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading load balancer %s: %w", lb.Name, err)
+	}
+	if len(balancers.LoadBalancers) == 0 {
+		return nil, nil
+	}
+	found := balancers.LoadBalancers[0]
+	config := &network.LoadBalancerConfig{
+		ID:           lb.ID,
+		Name:         aws.StringValue(found.LoadBalancerName),
+		Region:       lb.Region,
+		GCPProjectID: lb.GCPProjectID,
+		Internal:     aws.StringValue(found.Scheme) == elbv2.LoadBalancerSchemeEnumInternal,
+		Address:      aws.StringValue(found.DNSName),
+		Tags:         map[string]string{},
+	}
+	var subnets []string
+	for _, zone := range found.AvailabilityZones {
+		subnets = append(subnets, aws.StringValue(zone.SubnetId))
+	}
+	config.SubnetIDs = sameStrings(subnets, lb.SubnetIDs)
+	if aws.StringValue(found.Type) == elbv2.LoadBalancerTypeEnumApplication {
+		config.FirewallIDs = sameStrings(aws.StringValueSlice(found.SecurityGroups), lb.FirewallIDs)
+	}
+
+	listeners, err := elbSvc.DescribeListenersWithContext(ctx, &elbv2.DescribeListenersInput{LoadBalancerArn: found.LoadBalancerArn})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the listeners of %s: %w", lb.Name, err)
+	}
+	var read []network.Listener
+	for _, listener := range listeners.Listeners {
+		read = append(read, network.Listener{Port: aws.Int64Value(listener.Port), Protocol: strings.ToLower(aws.StringValue(listener.Protocol))})
+	}
+	config.Listeners = sameListeners(read, lb.Listeners)
+
+	groups, err := elbSvc.DescribeTargetGroupsWithContext(ctx, &elbv2.DescribeTargetGroupsInput{LoadBalancerArn: found.LoadBalancerArn})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the target groups of %s: %w", lb.Name, err)
+	}
+	if len(groups.TargetGroups) > 0 {
+		// Every target group has the same health check and servers.
+		group := groups.TargetGroups[0]
+		port, _ := strconv.ParseInt(aws.StringValue(group.HealthCheckPort), 10, 64)
+		config.HealthCheck = network.HealthCheck{
+			Protocol:           strings.ToLower(aws.StringValue(group.HealthCheckProtocol)),
+			Port:               port,
+			Path:               aws.StringValue(group.HealthCheckPath),
+			IntervalSeconds:    aws.Int64Value(group.HealthCheckIntervalSeconds),
+			HealthyThreshold:   aws.Int64Value(group.HealthyThresholdCount),
+			UnhealthyThreshold: aws.Int64Value(group.UnhealthyThresholdCount),
+		}
+		targets, err := awsTargets(ctx, elbSvc, group.TargetGroupArn)
+		if err != nil {
+			return nil, err
+		}
+		for id := range targets {
+			config.ServerIDs = append(config.ServerIDs, id)
+		}
+		config.ServerIDs = sameStrings(config.ServerIDs, lb.ServerIDs)
+	}
+
+	tags, err := elbSvc.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{ResourceArns: []*string{found.LoadBalancerArn}})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the tags of %s: %w", lb.Name, err)
+	}
+	for _, description := range tags.TagDescriptions {
+		for _, tag := range description.Tags {
+			if key := aws.StringValue(tag.Key); !isIgnoredTag(key, lb.IgnoreTagKeys) {
+				config.Tags[key] = aws.StringValue(tag.Value)
+			}
+		}
+	}
+	return config, nil
+}
+
+// UpdateLoadBalancer applies the security groups, health check, servers and
+// tags of lb. The listeners, scheme and subnets never change in place.
+func (A *AWSProvider) UpdateLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	elbSvc := awsClient.elbv2Service(lb.Region)
+	arn := aws.String(lb.ID)
+	if lb.LoadBalancerType() == "application" {
+		_, err := elbSvc.SetSecurityGroupsWithContext(ctx, &elbv2.SetSecurityGroupsInput{LoadBalancerArn: arn, SecurityGroups: aws.StringSlice(lb.FirewallIDs)})
+		if err != nil {
+			return fmt.Errorf("error setting the security groups of %s: %w", lb.Name, err)
+		}
+	}
+	groups, err := elbSvc.DescribeTargetGroupsWithContext(ctx, &elbv2.DescribeTargetGroupsInput{LoadBalancerArn: arn})
+	if err != nil {
+		return fmt.Errorf("error reading the target groups of %s: %w", lb.Name, err)
+	}
+	resources := []*string{arn}
+	for _, group := range groups.TargetGroups {
+		if _, err := elbSvc.ModifyTargetGroupWithContext(ctx, awsHealthCheck(lb.HealthCheck, group.TargetGroupArn)); err != nil {
+			return fmt.Errorf("error updating the health check of %s: %w", aws.StringValue(group.TargetGroupName), err)
+		}
+		if err := A.syncTargets(ctx, elbSvc, group.TargetGroupArn, lb.ServerIDs); err != nil {
+			return err
+		}
+		resources = append(resources, group.TargetGroupArn)
+	}
+	return A.updateLoadBalancerTags(ctx, elbSvc, resources, lb)
+}
+
+// updateLoadBalancerTags replaces the tags of the load balancer and its
+// target groups with the tags of lb, keeping the ignored ones.
+func (A *AWSProvider) updateLoadBalancerTags(ctx context.Context, elbSvc *elbv2.ELBV2, resources []*string, lb *network.LoadBalancerConfig) error {
+	tags, err := elbSvc.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{ResourceArns: resources})
+	if err != nil {
+		return fmt.Errorf("error reading the tags of %s: %w", lb.Name, err)
+	}
+	for _, description := range tags.TagDescriptions {
+		var removed []*string
+		for _, tag := range description.Tags {
+			key := aws.StringValue(tag.Key)
+			if _, ok := lb.Tags[key]; !ok && !isIgnoredTag(key, lb.IgnoreTagKeys) {
+				removed = append(removed, tag.Key)
+			}
+		}
+		resource := []*string{description.ResourceArn}
+		if len(removed) > 0 {
+			if _, err := elbSvc.RemoveTagsWithContext(ctx, &elbv2.RemoveTagsInput{ResourceArns: resource, TagKeys: removed}); err != nil {
+				return fmt.Errorf("error removing tags from %s: %w", aws.StringValue(description.ResourceArn), err)
+			}
+		}
+		if len(lb.Tags) > 0 {
+			if _, err := elbSvc.AddTagsWithContext(ctx, &elbv2.AddTagsInput{ResourceArns: resource, Tags: awsELBTags(lb.Tags)}); err != nil {
+				return fmt.Errorf("error tagging %s: %w", aws.StringValue(description.ResourceArn), err)
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteLoadBalancer deletes the load balancer of lb with its listeners, then
+// its target groups once they are no longer in use.
+func (A *AWSProvider) DeleteLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	elbSvc := awsClient.elbv2Service(lb.Region)
+	_, err := elbSvc.DeleteLoadBalancerWithContext(ctx, &elbv2.DeleteLoadBalancerInput{LoadBalancerArn: aws.String(lb.ID)})
+	if err != nil && !isAWSNotFound(err, elbv2.ErrCodeLoadBalancerNotFoundException) {
+		return fmt.Errorf("error deleting load balancer %s: %w", lb.Name, err)
+	}
+	err = elbSvc.WaitUntilLoadBalancersDeletedWithContext(ctx, &elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []*string{aws.String(lb.ID)}})
+	if err != nil {
+		return fmt.Errorf("error waiting for load balancer %s to be deleted: %w", lb.Name, err)
+	}
+	for _, listener := range lb.Listeners {
+		name := awsTargetGroupName(lb, listener)
+		groups, err := elbSvc.DescribeTargetGroupsWithContext(ctx, &elbv2.DescribeTargetGroupsInput{Names: []*string{aws.String(name)}})
+		if isAWSNotFound(err, elbv2.ErrCodeTargetGroupNotFoundException) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading target group %s: %w", name, err)
+		}
+		for _, group := range groups.TargetGroups {
+			err := retryWhileCode(ctx, elbv2.ErrCodeResourceInUseException, func() error {
+				_, err := elbSvc.DeleteTargetGroupWithContext(ctx, &elbv2.DeleteTargetGroupInput{TargetGroupArn: group.TargetGroupArn})
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error deleting target group %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// gcpHealthCheckName names the regional health check of a load balancer.
+func gcpHealthCheckName(lb *network.LoadBalancerConfig) string {
+	return lb.Name + "-hc"
+}
+
+// gcpInstanceGroupName names the unmanaged instance group holding the servers
+// of a load balancer in zone.
+func gcpInstanceGroupName(lb *network.LoadBalancerConfig, zone string) string {
+	return lb.Name + "-" + zone
+}
+
+func gcpRegionalURL(projectID, region, collection, name string) string {
+	return fmt.Sprintf("projects/%s/regions/%s/%s/%s", projectID, region, collection, name)
+}
+
+func gcpLoadBalancingScheme(lb *network.LoadBalancerConfig) string {
+	if lb.Internal {
+		return "INTERNAL"
+	}
+	return "EXTERNAL"
+}
+
+// gcpGroupZone returns the zone of an instance group from its URL, such as
+// ".../zones/europe-west1-b/instanceGroups/web-europe-west1-b".
+func gcpGroupZone(groupURL string) string {
+	return lastSegment(strings.TrimSuffix(groupURL, "/instanceGroups/"+lastSegment(groupURL)))
+}
+
+func gcpHealthCheck(lb *network.LoadBalancerConfig) *compute.HealthCheck {
+	hc := &compute.HealthCheck{
+		Name:               gcpHealthCheckName(lb),
+		Type:               strings.ToUpper(lb.HealthCheck.Protocol),
+		CheckIntervalSec:   lb.HealthCheck.IntervalSeconds,
+		TimeoutSec:         5,
+		HealthyThreshold:   lb.HealthCheck.HealthyThreshold,
+		UnhealthyThreshold: lb.HealthCheck.UnhealthyThreshold,
+	}
+	if lb.HealthCheck.Protocol == "http" {
+		hc.HttpHealthCheck = &compute.HTTPHealthCheck{Port: lb.HealthCheck.Port, RequestPath: lb.HealthCheck.Path}
+	} else {
+		hc.TcpHealthCheck = &compute.TCPHealthCheck{Port: lb.HealthCheck.Port}
+	}
+	return hc
+}
+
+func gcpHealthCheckConfig(hc *compute.HealthCheck) network.HealthCheck {
+	config := network.HealthCheck{
+		Protocol:           strings.ToLower(hc.Type),
+		IntervalSeconds:    hc.CheckIntervalSec,
+		HealthyThreshold:   hc.HealthyThreshold,
+		UnhealthyThreshold: hc.UnhealthyThreshold,
+	}
+	switch {
+	case hc.HttpHealthCheck != nil:
+		config.Port = hc.HttpHealthCheck.Port
+		config.Path = hc.HttpHealthCheck.RequestPath
+	case hc.TcpHealthCheck != nil:
+		config.Port = hc.TcpHealthCheck.Port
+	}
+	return config
+}
+
+// serverZones returns the URLs of the instances of serverIDs by zone. The
+// instances must be in the region of lb.
+func (G *GCProvider) serverZones(ctx context.Context, computeService *compute.Service, lb *network.LoadBalancerConfig) (map[string][]string, error) {
+	zones := map[string][]string{}
+	for _, id := range lb.ServerIDs {
+		var found *compute.Instance
+		err := computeService.Instances.AggregatedList(lb.GCPProjectID).Filter("id = "+id).Pages(ctx, func(list *compute.InstanceAggregatedList) error {
+			for _, scoped := range list.Items {
+				for _, instance := range scoped.Instances {
+					found = instance
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading server %s: %w", id, err)
+		}
+		if found == nil {
+			return nil, fmt.Errorf("server %s not found in project %s", id, lb.GCPProjectID)
+		}
+		zone := lastSegment(found.Zone)
+		if zoneRegion(zone) != lb.Region {
+			return nil, fmt.Errorf("server %s is in %s, outside of the region %s of load balancer %s", id, zone, lb.Region, lb.Name)
+		}
+		zones[zone] = append(zones[zone], found.SelfLink)
+	}
+	return zones, nil
+}
+
+// groupInstances returns the instances of an instance group.
+func (G *GCProvider) groupInstances(ctx context.Context, computeService *compute.Service, projectID, groupURL string) ([]*compute.Instance, error) {
+	zone := gcpGroupZone(groupURL)
+	var instances []*compute.Instance
+	request := &compute.InstanceGroupsListInstancesRequest{InstanceState: "ALL"}
+	err := computeService.InstanceGroups.ListInstances(projectID, zone, lastSegment(groupURL), request).Pages(ctx, func(list *compute.InstanceGroupsListInstances) error {
+		for _, item := range list.Items {
+			instance, err := computeService.Instances.Get(projectID, zone, lastSegment(item.Instance)).Context(ctx).Do()
+			if err != nil {
+				return err
+			}
+			instances = append(instances, instance)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the instances of %s: %w", lastSegment(groupURL), err)
+	}
+	return instances, nil
+}
+
+// createInstanceGroup creates the instance group of lb in zone with
+// instances, and returns its URL. The URL is also returned when adding the
+// instances fails, so the caller can delete the group.
+func (G *GCProvider) createInstanceGroup(ctx context.Context, client interface{}, lb *network.LoadBalancerConfig, zone string, instances []string) (string, error) {
+	computeService := client.(*GCPClient).client
+	name := gcpInstanceGroupName(lb, zone)
+	op, err := computeService.InstanceGroups.Insert(lb.GCPProjectID, zone, &compute.InstanceGroup{Name: name}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("error creating instance group %s: %w", name, err)
+	}
+	groupURL := fmt.Sprintf("projects/%s/zones/%s/instanceGroups/%s", lb.GCPProjectID, zone, name)
+	if err := G.waitForOperation(ctx, client, lb.GCPProjectID, zone, op.Name); err != nil {
+		return groupURL, err
+	}
+	if err := G.addGroupInstances(ctx, client, lb.GCPProjectID, zone, name, instances); err != nil {
+		return groupURL, err
+	}
+	return groupURL, nil
+}
+
+func (G *GCProvider) addGroupInstances(ctx context.Context, client interface{}, projectID, zone, group string, instances []string) error {
+	if len(instances) == 0 {
+		return nil
+	}
+	request := &compute.InstanceGroupsAddInstancesRequest{}
+	for _, instance := range instances {
+		request.Instances = append(request.Instances, &compute.InstanceReference{Instance: instance})
+	}
+	op, err := client.(*GCPClient).client.InstanceGroups.AddInstances(projectID, zone, group, request).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error adding servers to %s: %w", group, err)
+	}
+	return G.waitForOperation(ctx, client, projectID, zone, op.Name)
+}
+
+func (G *GCProvider) removeGroupInstances(ctx context.Context, client interface{}, projectID, zone, group string, instances []string) error {
+	if len(instances) == 0 {
+		return nil
+	}
+	request := &compute.InstanceGroupsRemoveInstancesRequest{}
+	for _, instance := range instances {
+		request.Instances = append(request.Instances, &compute.InstanceReference{Instance: instance})
+	}
+	op, err := client.(*GCPClient).client.InstanceGroups.RemoveInstances(projectID, zone, group, request).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error removing servers from %s: %w", group, err)
+	}
+	return G.waitForOperation(ctx, client, projectID, zone, op.Name)
+}
+
+// deleteInstanceGroups deletes instance groups, ignoring the ones already
+// gone.
+func (G *GCProvider) deleteInstanceGroups(ctx context.Context, client interface{}, projectID string, groupURLs []string) error {
+	computeService := client.(*GCPClient).client
+	for _, groupURL := range groupURLs {
+		zone := gcpGroupZone(groupURL)
+		op, err := computeService.InstanceGroups.Delete(projectID, zone, lastSegment(groupURL)).Context(ctx).Do()
+		if isGCPNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting instance group %s: %w", lastSegment(groupURL), err)
+		}
+		if err := G.waitForOperation(ctx, client, projectID, zone, op.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateLoadBalancer creates a regional passthrough load balancer: a health
+// check, an instance group of the servers per zone, a backend service of the
+// groups and a forwarding rule of the listener ports.
+func (G *GCProvider) CreateLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	project, region := lb.GCPProjectID, lb.Region
+	zones, err := G.serverZones(ctx, computeService, lb)
+	if err != nil {
+		return err
+	}
+	op, err := computeService.RegionHealthChecks.Insert(project, region, gcpHealthCheck(lb)).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error creating health check %s: %w", gcpHealthCheckName(lb), err)
+	}
+	// The parts are named after the load balancer, so destroy finds the ones
+	// created before a failure.
+	lb.ID = lb.Name
+	if err := G.waitForRegionOperation(ctx, client, project, region, op.Name); err != nil {
+		return err
+	}
+
+	zoneNames := make([]string, 0, len(zones))
+	for zone := range zones {
+		zoneNames = append(zoneNames, zone)
+	}
+	sort.Strings(zoneNames)
+	service := &compute.BackendService{
+		Name:                lb.Name,
+		LoadBalancingScheme: gcpLoadBalancingScheme(lb),
+		Protocol:            strings.ToUpper(lb.Listeners[0].Protocol),
+		HealthChecks:        []string{gcpRegionalURL(project, region, "healthChecks", gcpHealthCheckName(lb))},
+	}
+	var groups []string
+	for _, zone := range zoneNames {
+		group, err := G.createInstanceGroup(ctx, client, lb, zone, zones[zone])
+		if group != "" {
+			groups = append(groups, group)
+		}
+		if err != nil {
+			_ = G.deleteInstanceGroups(ctx, client, project, groups)
+			return err
+		}
+		service.Backends = append(service.Backends, &compute.Backend{Group: group, BalancingMode: "CONNECTION"})
+	}
+	op, err = computeService.RegionBackendServices.Insert(project, region, service).Context(ctx).Do()
+	if err == nil {
+		err = G.waitForRegionOperation(ctx, client, project, region, op.Name)
+	}
+	if err != nil {
+		// Nothing uses the groups yet, so do not leave them behind.
+		_ = G.deleteInstanceGroups(ctx, client, project, groups)
+		return fmt.Errorf("error creating backend service %s: %w", lb.Name, err)
+	}
+
+	rule := &compute.ForwardingRule{
+		Name:                lb.Name,
+		IPProtocol:          strings.ToUpper(lb.Listeners[0].Protocol),
+		BackendService:      gcpRegionalURL(project, region, "backendServices", lb.Name),
+		LoadBalancingScheme: gcpLoadBalancingScheme(lb),
+		Labels:              lb.Tags,
+	}
+	for _, listener := range lb.Listeners {
+		rule.Ports = append(rule.Ports, strconv.FormatInt(listener.Port, 10))
+	}
+	if lb.Internal {
+		networkName := lb.Network
+		if networkName == "" {
+			networkName = "default"
+		}
+		rule.Network = gcpNetworkURL(project, networkName)
+	}
+	op, err = computeService.ForwardingRules.Insert(project, region, rule).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error creating forwarding rule %s: %w", lb.Name, err)
+	}
+	if err := G.waitForRegionOperation(ctx, client, project, region, op.Name); err != nil {
+		return err
+	}
+	created, err := computeService.ForwardingRules.Get(project, region, lb.Name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error reading forwarding rule %s: %w", lb.Name, err)
+	}
+	lb.Address = created.IPAddress
+	return nil
+}
+
+// GetLoadBalancer reads the forwarding rule, health check and backend
+// service of lb. It returns nil when the forwarding rule no longer exists.
+func (G *GCProvider) GetLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) (*network.LoadBalancerConfig, error) {
+	computeService := client.(*GCPClient).client
+	project, region := lb.GCPProjectID, lb.Region
+	rule, err := computeService.ForwardingRules.Get(project, region, lb.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading forwarding rule %s: %w", lb.ID, err)
+	}
+	config := &network.LoadBalancerConfig{
+		ID:           lb.ID,
+		Name:         rule.Name,
+		Region:       region,
+		GCPProjectID: project,
+		Internal:     rule.LoadBalancingScheme == "INTERNAL",
+		Address:      rule.IPAddress,
+		Tags:         map[string]string{},
+	}
+	if config.Internal {
+		config.Network = lastSegment(rule.Network)
+	}
+	for k, v := range rule.Labels {
+		if !isIgnoredLabel(k, lb.IgnoreTagKeys) {
+			config.Tags[k] = v
+		}
+	}
+	var read []network.Listener
+	for _, port := range rule.Ports {
+		number, err := strconv.ParseInt(port, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected port %q on forwarding rule %s", port, rule.Name)
+		}
+		read = append(read, network.Listener{Port: number, Protocol: strings.ToLower(rule.IPProtocol)})
+	}
+	config.Listeners = sameListeners(read, lb.Listeners)
+
+	hc, err := computeService.RegionHealthChecks.Get(project, region, gcpHealthCheckName(config)).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error reading health check %s: %w", gcpHealthCheckName(config), err)
+	}
+	config.HealthCheck = gcpHealthCheckConfig(hc)
+
+	service, err := computeService.RegionBackendServices.Get(project, region, config.Name).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error reading backend service %s: %w", config.Name, err)
+	}
+	for _, backend := range service.Backends {
+		instances, err := G.groupInstances(ctx, computeService, project, backend.Group)
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range instances {
+			config.ServerIDs = append(config.ServerIDs, strconv.FormatUint(instance.Id, 10))
+		}
+	}
+	config.ServerIDs = sameStrings(config.ServerIDs, lb.ServerIDs)
+	return config, nil
+}
+
+// UpdateLoadBalancer applies the health check, servers and labels of lb.
+// Servers in a new zone get a new instance group, and the groups of the
+// zones left empty are removed from the backend service and deleted.
+func (G *GCProvider) UpdateLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	project, region := lb.GCPProjectID, lb.Region
+	op, err := computeService.RegionHealthChecks.Update(project, region, gcpHealthCheckName(lb), gcpHealthCheck(lb)).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error updating health check %s: %w", gcpHealthCheckName(lb), err)
+	}
+	if err := G.waitForRegionOperation(ctx, client, project, region, op.Name); err != nil {
+		return err
+	}
+
+	zones, err := G.serverZones(ctx, computeService, lb)
+	if err != nil {
+		return err
+	}
+	service, err := computeService.RegionBackendServices.Get(project, region, lb.Name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error reading backend service %s: %w", lb.Name, err)
+	}
+	var backends []*compute.Backend
+	var dropped []string
+	for _, backend := range service.Backends {
+		zone := gcpGroupZone(backend.Group)
+		wanted, ok := zones[zone]
+		if !ok {
+			dropped = append(dropped, backend.Group)
+			continue
+		}
+		delete(zones, zone)
+		backends = append(backends, backend)
+		instances, err := G.groupInstances(ctx, computeService, project, backend.Group)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]bool, len(instances))
+		var removed []string
+		for _, instance := range instances {
+			current[instance.SelfLink] = true
+			if !containsString(wanted, instance.SelfLink) {
+				removed = append(removed, instance.SelfLink)
+			}
+		}
+		var added []string
+		for _, instance := range wanted {
+			if !current[instance] {
+				added = append(added, instance)
+			}
+		}
+		group := lastSegment(backend.Group)
+		if err := G.addGroupInstances(ctx, client, project, zone, group, added); err != nil {
+			return err
+		}
+		if err := G.removeGroupInstances(ctx, client, project, zone, group, removed); err != nil {
+			return err
+		}
+	}
+	zoneNames := make([]string, 0, len(zones))
+	for zone := range zones {
+		zoneNames = append(zoneNames, zone)
+	}
+	sort.Strings(zoneNames)
+	var created []string
+	for _, zone := range zoneNames {
+		group, err := G.createInstanceGroup(ctx, client, lb, zone, zones[zone])
+		if group != "" {
+			created = append(created, group)
+		}
+		if err != nil {
+			// The backend service does not use the new groups yet.
+			_ = G.deleteInstanceGroups(ctx, client, project, created)
+			return err
+		}
+		backends = append(backends, &compute.Backend{Group: group, BalancingMode: "CONNECTION"})
+	}
+	if len(zoneNames) > 0 || len(dropped) > 0 {
+		patch := &compute.BackendService{Backends: backends, Fingerprint: service.Fingerprint, ForceSendFields: []string{"Backends"}}
+		op, err := computeService.RegionBackendServices.Patch(project, region, lb.Name, patch).Context(ctx).Do()
+		if err != nil {
+			_ = G.deleteInstanceGroups(ctx, client, project, created)
+			return fmt.Errorf("error updating backend service %s: %w", lb.Name, err)
+		}
+		if err := G.waitForRegionOperation(ctx, client, project, region, op.Name); err != nil {
+			return err
+		}
+		if err := G.deleteInstanceGroups(ctx, client, project, dropped); err != nil {
+			return err
+		}
+	}
+
+	rule, err := computeService.ForwardingRules.Get(project, region, lb.Name).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error reading forwarding rule %s: %w", lb.Name, err)
+	}
+	labels, err := gcpLabelsKeepingIgnored(lb.Tags, rule.Labels, lb.IgnoreTagKeys)
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 && len(rule.Labels) == 0 {
+		return nil
+	}
+	op, err = computeService.ForwardingRules.SetLabels(project, region, lb.Name, &compute.RegionSetLabelsRequest{
+		Labels:           labels,
+		LabelFingerprint: rule.LabelFingerprint,
+		ForceSendFields:  []string{"Labels"},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error labeling forwarding rule %s: %w", lb.Name, err)
+	}
+	return G.waitForRegionOperation(ctx, client, project, region, op.Name)
+}
+
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteLoadBalancer deletes the forwarding rule, backend service, instance
+// groups and health check of lb, ignoring the ones already gone.
+func (G *GCProvider) DeleteLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	project, region := lb.GCPProjectID, lb.Region
+	op, err := computeService.ForwardingRules.Delete(project, region, lb.Name).Context(ctx).Do()
+	if err == nil {
+		err = G.waitForRegionOperation(ctx, client, project, region, op.Name)
+	}
+	if err != nil && !isGCPNotFound(err) {
+		return fmt.Errorf("error deleting forwarding rule %s: %w", lb.Name, err)
+	}
+	service, err := computeService.RegionBackendServices.Get(project, region, lb.Name).Context(ctx).Do()
+	switch {
+	case isGCPNotFound(err):
+	case err != nil:
+		return fmt.Errorf("error reading backend service %s: %w", lb.Name, err)
+	default:
+		op, err := computeService.RegionBackendServices.Delete(project, region, lb.Name).Context(ctx).Do()
+		if err == nil {
+			err = G.waitForRegionOperation(ctx, client, project, region, op.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting backend service %s: %w", lb.Name, err)
+		}
+		groups := make([]string, 0, len(service.Backends))
+		for _, backend := range service.Backends {
+			groups = append(groups, backend.Group)
+		}
+		if err := G.deleteInstanceGroups(ctx, client, project, groups); err != nil {
+			return err
+		}
+	}
+	op, err = computeService.RegionHealthChecks.Delete(project, region, gcpHealthCheckName(lb)).Context(ctx).Do()
+	if err == nil {
+		err = G.waitForRegionOperation(ctx, client, project, region, op.Name)
+	}
+	if err != nil && !isGCPNotFound(err) {
+		return fmt.Errorf("error deleting health check %s: %w", gcpHealthCheckName(lb), err)
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func TestLoadBalancerType(t *testing.T) {
+	lb := &network.LoadBalancerConfig{Listeners: []network.Listener{{Port: 53, Protocol: "udp"}, {Port: 443, Protocol: "tcp"}}}
+	assert.Equal(t, "network", lb.LoadBalancerType())
+	lb.Listeners = []network.Listener{{Port: 80, Protocol: "http"}}
+	assert.Equal(t, "application", lb.LoadBalancerType())
+}
+
+func TestSameListeners(t *testing.T) {
+	known := []network.Listener{{Port: 443, Protocol: "tcp"}, {Port: 80, Protocol: "tcp"}}
+	read := []network.Listener{{Port: 80, Protocol: "tcp"}, {Port: 443, Protocol: "tcp"}}
+	assert.Equal(t, known, sameListeners(read, known), "the configured order should be kept")
+	assert.Equal(t, []network.Listener{{Port: 80, Protocol: "tcp"}}, sameListeners(read[:1], known))
+}
+
+func TestLoadBalancerNames(t *testing.T) {
+	lb := &network.LoadBalancerConfig{Name: "web"}
+	assert.Equal(t, "web-443", awsTargetGroupName(lb, network.Listener{Port: 443, Protocol: "tcp"}))
+	assert.Equal(t, "web-hc", gcpHealthCheckName(lb))
+	assert.Equal(t, "web-europe-west1-b", gcpInstanceGroupName(lb, "europe-west1-b"))
+	assert.Equal(t, "europe-west1-b", gcpGroupZone("https://www.googleapis.com/compute/v1/projects/dantata/zones/europe-west1-b/instanceGroups/web-europe-west1-b"))
+	assert.Equal(t, "europe-west1", zoneRegion("europe-west1-b"))
+}
+
+func TestAWSCreateLoadBalancer(t *testing.T) {
+	f, client := newFakeEC2(t, map[string]string{
+		"DescribeSubnets": "<subnetSet>" +
+			"<item><subnetId>subnet-b</subnetId><vpcId>vpc-1</vpcId></item>" +
+			"<item><subnetId>subnet-a</subnetId><vpcId>vpc-1</vpcId></item>" +
+			"</subnetSet>",
+		"CreateLoadBalancer":    "<LoadBalancers><member><LoadBalancerArn>arn:lb</LoadBalancerArn><DNSName>web.elb.amazonaws.com</DNSName></member></LoadBalancers>",
+		"CreateTargetGroup":     "<TargetGroups><member><TargetGroupArn>arn:tg</TargetGroupArn></member></TargetGroups>",
+		"DescribeTargetHealth":  "<TargetHealthDescriptions/>",
+		"RegisterTargets":       "",
+		"CreateListener":        "<Listeners><member><ListenerArn>arn:listener</ListenerArn></member></Listeners>",
+		"DescribeLoadBalancers": "<LoadBalancers><member><State><Code>active</Code></State></member></LoadBalancers>",
+	})
+	lb := &network.LoadBalancerConfig{
+		Name:        "web",
+		Region:      "eu-west-1",
+		FirewallIDs: []string{"sg-lb"},
+		Listeners:   []network.Listener{{Port: 80, Protocol: "http"}},
+		HealthCheck: network.HealthCheck{Protocol: "http", Port: 80, Path: "/health", IntervalSeconds: 30, HealthyThreshold: 3, UnhealthyThreshold: 3},
+		ServerIDs:   []string{"i-1", "i-2"},
+		Tags:        map[string]string{"team": "web"},
+	}
+	require.NoError(t, (&AWSProvider{}).CreateLoadBalancer(context.Background(), lb, client))
+	assert.Equal(t, []string{
+		"DescribeSubnets", "DescribeSubnets", "CreateLoadBalancer", "CreateTargetGroup",
+		"DescribeTargetHealth", "RegisterTargets", "CreateListener", "DescribeLoadBalancers",
+	}, f.actions(), "the servers should be registered before the listener forwards to them")
+	assert.Equal(t, []string{"subnet-a", "subnet-b"}, lb.SubnetIDs, "the default subnets should be used")
+	assert.Equal(t, "arn:lb", lb.ID)
+	assert.Equal(t, "web.elb.amazonaws.com", lb.Address)
+
+	create := f.call("CreateLoadBalancer")
+	assert.Equal(t, "application", create.Get("Type"))
+	assert.Equal(t, "internet-facing", create.Get("Scheme"))
+	assert.Equal(t, "subnet-a", create.Get("Subnets.member.1"))
+	assert.Equal(t, "sg-lb", create.Get("SecurityGroups.member.1"))
+	assert.Equal(t, "team", create.Get("Tags.member.1.Key"))
+	group := f.call("CreateTargetGroup")
+	assert.Equal(t, "web-80", group.Get("Name"))
+	assert.Equal(t, "vpc-1", group.Get("VpcId"))
+	assert.Equal(t, "/health", group.Get("HealthCheckPath"))
+	register := f.call("RegisterTargets")
+	assert.Equal(t, []string{"i-1", "i-2"}, []string{register.Get("Targets.member.1.Id"), register.Get("Targets.member.2.Id")})
+	assert.Equal(t, "arn:tg", f.call("CreateListener").Get("DefaultActions.member.1.TargetGroupArn"))
+}
+
+func TestAWSSyncTargets(t *testing.T) {
+	f, client := newFakeEC2(t, map[string]string{
+		"DescribeTargetHealth": "<TargetHealthDescriptions>" +
+			"<member><Target><Id>i-1</Id></Target><TargetHealth><State>healthy</State></TargetHealth></member>" +
+			"<member><Target><Id>i-2</Id></Target><TargetHealth><State>draining</State></TargetHealth></member>" +
+			"<member><Target><Id>i-3</Id></Target><TargetHealth><State>unhealthy</State></TargetHealth></member>" +
+			"</TargetHealthDescriptions>",
+		"RegisterTargets":   "",
+		"DeregisterTargets": "",
+	})
+	elbSvc := client.elbv2Service("eu-west-1")
+	require.NoError(t, (&AWSProvider{}).syncTargets(context.Background(), elbSvc, aws.String("arn:tg"), []string{"i-1", "i-2"}))
+	assert.Equal(t, []string{"DescribeTargetHealth", "RegisterTargets", "DeregisterTargets"}, f.actions())
+	register := f.call("RegisterTargets")
+	assert.Equal(t, "i-2", register.Get("Targets.member.1.Id"), "a draining server should be registered again")
+	assert.False(t, register.Has("Targets.member.2.Id"), "a registered server should be left alone")
+	deregister := f.call("DeregisterTargets")
+	assert.Equal(t, "i-3", deregister.Get("Targets.member.1.Id"))
+	assert.False(t, deregister.Has("Targets.member.2.Id"))
+}
+
+func TestAWSUpdateLoadBalancerClearsSecurityGroups(t *testing.T) {
+	f, client := newFakeEC2(t, map[string]string{
+		"SetSecurityGroups":    "",
+		"DescribeTargetGroups": "<TargetGroups><member><TargetGroupArn>arn:tg</TargetGroupArn><TargetGroupName>web-80</TargetGroupName></member></TargetGroups>",
+		"ModifyTargetGroup":    "",
+		"DescribeTargetHealth": "<TargetHealthDescriptions/>",
+		"DescribeTags":         "<TagDescriptions><member><ResourceArn>arn:lb</ResourceArn><Tags/></member></TagDescriptions>",
+	})
+	lb := &network.LoadBalancerConfig{
+		ID:          "arn:lb",
+		Name:        "web",
+		Region:      "eu-west-1",
+		Listeners:   []network.Listener{{Port: 80, Protocol: "http"}},
+		HealthCheck: network.HealthCheck{Protocol: "tcp", Port: 80, IntervalSeconds: 30, HealthyThreshold: 3, UnhealthyThreshold: 3},
+	}
+	require.NoError(t, (&AWSProvider{}).UpdateLoadBalancer(context.Background(), lb, client))
+	call := f.call("SetSecurityGroups")
+	require.NotNil(t, call, "removing every security group should still be applied")
+	assert.True(t, call.Has("SecurityGroups"))
+	assert.Equal(t, "", call.Get("SecurityGroups"))
+}
+
+func TestAWSDeleteLoadBalancer(t *testing.T) {
+	f, client := newFakeEC2(t, map[string]string{
+		"DeleteLoadBalancer":   "",
+		"DescribeTargetGroups": "<TargetGroups><member><TargetGroupArn>arn:tg</TargetGroupArn></member></TargetGroups>",
+		"DeleteTargetGroup":    "",
+	})
+	f.errors["DescribeLoadBalancers"] = "LoadBalancerNotFound"
+	lb := &network.LoadBalancerConfig{ID: "arn:lb", Name: "web", Region: "eu-west-1", Listeners: []network.Listener{{Port: 80, Protocol: "tcp"}}}
+	require.NoError(t, (&AWSProvider{}).DeleteLoadBalancer(context.Background(), lb, client))
+	assert.Equal(t, []string{"DeleteLoadBalancer", "DescribeLoadBalancers", "DescribeTargetGroups", "DeleteTargetGroup"}, f.actions(),
+		"the target groups should be deleted once the load balancer is gone")
+	assert.Equal(t, "web-80", f.call("DescribeTargetGroups").Get("Names.member.1"))
+	assert.Equal(t, "arn:tg", f.call("DeleteTargetGroup").Get("TargetGroupArn"))
+
+	f, client = newFakeEC2(t, map[string]string{})
+	f.errors["DeleteLoadBalancer"] = "LoadBalancerNotFound"
+	f.errors["DescribeLoadBalancers"] = "LoadBalancerNotFound"
+	f.errors["DescribeTargetGroups"] = "TargetGroupNotFound"
+	assert.NoError(t, (&AWSProvider{}).DeleteLoadBalancer(context.Background(), lb, client), "deleting a gone load balancer should succeed")
+}
+
+// fakeCompute is a Compute Engine stand-in for the regional load balancer
+// parts and instances. Resources are kept as JSON by path below the project,
+// operations are done at once, and the calls are recorded as
+// "<method> <path>".
+type fakeCompute struct {
+	mu        sync.Mutex
+	resources map[string]json.RawMessage
+	members   map[string][]string // Instance URLs by instance group path
+	errors    map[string]int      // Status by call prefix
+	failures  map[string]bool     // Inserts whose operation fails, by call prefix
+	calls     []string
+}
+
+func newFakeCompute(t *testing.T) (*fakeCompute, *GCPClient) {
+	f := &fakeCompute{resources: map[string]json.RawMessage{}, members: map[string][]string{}, errors: map[string]int{}, failures: map[string]bool{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	service, err := compute.NewService(context.Background(), option.WithEndpoint(server.URL+"/compute/v1/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	return f, &GCPClient{client: service}
+}
+
+// put stores a resource at path.
+func (f *fakeCompute) put(path string, resource interface{}) {
+	encoded, _ := json.Marshal(resource)
+	f.resources[path] = encoded
+}
+
+// addInstance stores an instance of the project in zone and returns its URL.
+func (f *fakeCompute) addInstance(zone, name string, id uint64) string {
+	selfLink := "https://www.googleapis.com/compute/v1/projects/dantata/zones/" + zone + "/instances/" + name
+	f.put("zones/"+zone+"/instances/"+name, &compute.Instance{Id: id, Name: name, Zone: zone, SelfLink: selfLink})
+	return selfLink
+}
+
+// addGroup stores an instance group of zone holding instances.
+func (f *fakeCompute) addGroup(zone, name string, instances ...string) string {
+	path := "zones/" + zone + "/instanceGroups/" + name
+	f.put(path, &compute.InstanceGroup{Name: name})
+	f.members[path] = instances
+	return "projects/dantata/" + path
+}
+
+// index returns the position of call in the recorded calls, or -1.
+func (f *fakeCompute) index(call string) int {
+	for i, c := range f.calls {
+		if c == call {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeCompute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/compute/v1/projects/dantata/")
+	call := r.Method + " " + path
+	f.calls = append(f.calls, call)
+	for prefix, status := range f.errors {
+		if strings.HasPrefix(call, prefix) {
+			gcsError(w, status)
+			return
+		}
+	}
+	dir, last := path[:strings.LastIndex(path, "/")+1], path[strings.LastIndex(path, "/")+1:]
+	var body map[string]interface{}
+	if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	switch {
+	case strings.HasSuffix(dir, "/operations/"):
+		operation := &compute.Operation{Name: last, Status: "DONE"}
+		if strings.HasPrefix(last, "failed-") {
+			operation.Error = &compute.OperationError{Errors: []*compute.OperationErrorErrors{{Code: "QUOTA_EXCEEDED"}}}
+		}
+		_ = json.NewEncoder(w).Encode(operation)
+		return
+	case path == "aggregated/instances":
+		list := &compute.InstanceAggregatedList{Items: map[string]compute.InstancesScopedList{}}
+		for key, raw := range f.resources {
+			var instance compute.Instance
+			if strings.Contains(key, "/instances/") && json.Unmarshal(raw, &instance) == nil &&
+				r.URL.Query().Get("filter") == fmt.Sprintf("id = %d", instance.Id) {
+				list.Items["zones/"+instance.Zone] = compute.InstancesScopedList{Instances: []*compute.Instance{&instance}}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	case r.Method == http.MethodPost && body["name"] != nil:
+		for prefix := range f.failures {
+			if strings.HasPrefix(call, prefix) {
+				_ = json.NewEncoder(w).Encode(&compute.Operation{Name: "failed-" + fmt.Sprint(len(f.calls)), TargetId: 1})
+				return
+			}
+		}
+		if strings.HasSuffix(path, "/addresses") {
+			body["address"] = "203.0.113.10"
+		}
+		path += "/" + body["name"].(string)
+		if _, ok := f.resources[path]; ok {
+			gcsError(w, http.StatusConflict)
+			return
+		}
+		id := uint64(1000 + len(f.calls))
+		body["id"] = fmt.Sprint(id)
+		f.resources[path], _ = json.Marshal(body)
+		_ = json.NewEncoder(w).Encode(&compute.Operation{Name: "operation-" + fmt.Sprint(len(f.calls)), TargetId: id})
+		return
+	case r.Method == http.MethodPost && last == "listInstances":
+		list := &compute.InstanceGroupsListInstances{}
+		for _, instance := range f.members[strings.TrimSuffix(dir, "/")] {
+			list.Items = append(list.Items, &compute.InstanceWithNamedPorts{Instance: instance})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	case r.Method == http.MethodPost && (last == "addInstances" || last == "removeInstances"):
+		group := strings.TrimSuffix(dir, "/")
+		for _, raw := range body["instances"].([]interface{}) {
+			instance := raw.(map[string]interface{})["instance"].(string)
+			if last == "addInstances" {
+				f.members[group] = append(f.members[group], instance)
+			} else {
+				f.members[group] = removeString(f.members[group], instance)
+			}
+		}
+	case r.Method == http.MethodPost && last == "setLabels":
+		var rule map[string]interface{}
+		_ = json.Unmarshal(f.resources[strings.TrimSuffix(dir, "/")], &rule)
+		rule["labels"] = body["labels"]
+		f.resources[strings.TrimSuffix(dir, "/")], _ = json.Marshal(rule)
+	case r.Method == http.MethodGet && path == "global/firewalls":
+		// Filters are left to the caller.
+		var items []json.RawMessage
+		for key, raw := range f.resources {
+			if strings.HasPrefix(key, path+"/") {
+				items = append(items, raw)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		return
+	default:
+		raw, ok := f.resources[path]
+		if !ok {
+			gcsError(w, http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write(raw)
+			return
+		case http.MethodDelete:
+			delete(f.resources, path)
+			delete(f.members, path)
+		case http.MethodPut, http.MethodPatch:
+			var resource map[string]interface{}
+			_ = json.Unmarshal(raw, &resource)
+			for k, v := range body {
+				resource[k] = v
+			}
+			f.resources[path], _ = json.Marshal(resource)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(&compute.Operation{Name: "operation-" + fmt.Sprint(len(f.calls))})
+}
+
+func removeString(l []string, s string) []string {
+	var kept []string
+	for _, v := range l {
+		if v != s {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// gcpTestLoadBalancer stores a load balancer whose backend service has a
+// group in europe-west1-b holding a and b, and one in europe-west1-d holding
+// d. It returns the config of the load balancer and the instance URLs.
+func gcpTestLoadBalancer(f *fakeCompute) (*network.LoadBalancerConfig, map[string]string) {
+	urls := map[string]string{
+		"a": f.addInstance("europe-west1-b", "a", 1),
+		"b": f.addInstance("europe-west1-b", "b", 2),
+		"c": f.addInstance("europe-west1-c", "c", 3),
+		"d": f.addInstance("europe-west1-d", "d", 4),
+		"e": f.addInstance("europe-west1-b", "e", 5),
+	}
+	f.put("regions/europe-west1/healthChecks/web-hc", &compute.HealthCheck{Name: "web-hc"})
+	f.put("regions/europe-west1/backendServices/web", &compute.BackendService{
+		Name:        "web",
+		Fingerprint: "fp",
+		Backends: []*compute.Backend{
+			{Group: f.addGroup("europe-west1-b", "web-europe-west1-b", urls["a"], urls["b"]), BalancingMode: "CONNECTION"},
+			{Group: f.addGroup("europe-west1-d", "web-europe-west1-d", urls["d"]), BalancingMode: "CONNECTION"},
+		},
+	})
+	f.put("regions/europe-west1/forwardingRules/web", &compute.ForwardingRule{Name: "web", LabelFingerprint: "lfp"})
+	lb := &network.LoadBalancerConfig{
+		ID:           "web",
+		Name:         "web",
+		Region:       "europe-west1",
+		GCPProjectID: "dantata",
+		Listeners:    []network.Listener{{Port: 80, Protocol: "tcp"}},
+		HealthCheck:  network.HealthCheck{Protocol: "tcp", Port: 80, IntervalSeconds: 10, HealthyThreshold: 2, UnhealthyThreshold: 2},
+	}
+	return lb, urls
+}
+
+func TestGCPUpdateLoadBalancer(t *testing.T) {
+	f, client := newFakeCompute(t)
+	lb, urls := gcpTestLoadBalancer(f)
+	// a stays, b leaves and e joins the group of europe-west1-b, c needs a
+	// group in europe-west1-c, and europe-west1-d is left empty.
+	lb.ServerIDs = []string{"1", "5", "3"}
+	require.NoError(t, (&GCProvider{}).UpdateLoadBalancer(context.Background(), lb, client))
+
+	assert.Equal(t, []string{urls["a"], urls["e"]}, f.members["zones/europe-west1-b/instanceGroups/web-europe-west1-b"])
+	assert.Equal(t, []string{urls["c"]}, f.members["zones/europe-west1-c/instanceGroups/web-europe-west1-c"], "a new zone should get a group")
+	assert.NotContains(t, f.resources, "zones/europe-west1-d/instanceGroups/web-europe-west1-d", "the group of an empty zone should be deleted")
+
+	var service compute.BackendService
+	require.NoError(t, json.Unmarshal(f.resources["regions/europe-west1/backendServices/web"], &service))
+	var groups []string
+	for _, backend := range service.Backends {
+		groups = append(groups, backend.Group)
+	}
+	assert.Equal(t, []string{
+		"projects/dantata/zones/europe-west1-b/instanceGroups/web-europe-west1-b",
+		"projects/dantata/zones/europe-west1-c/instanceGroups/web-europe-west1-c",
+	}, groups)
+
+	patch := f.index("PATCH regions/europe-west1/backendServices/web")
+	require.NotEqual(t, -1, patch)
+	assert.Less(t, f.index("POST zones/europe-west1-c/instanceGroups"), patch, "a new group should exist before the backend service uses it")
+	assert.Greater(t, f.index("DELETE zones/europe-west1-d/instanceGroups/web-europe-west1-d"), patch, "a group should only be deleted once the backend service dropped it")
+}
+
+func TestGCPUpdateLoadBalancerKeepsServersInPlace(t *testing.T) {
+	f, client := newFakeCompute(t)
+	lb, _ := gcpTestLoadBalancer(f)
+	lb.ServerIDs = []string{"1", "2", "4"}
+	require.NoError(t, (&GCProvider{}).UpdateLoadBalancer(context.Background(), lb, client))
+	for _, call := range f.calls {
+		assert.False(t, strings.HasPrefix(call, "PATCH"), "the backend service should be left alone when no zone changes")
+		assert.False(t, strings.HasSuffix(call, "/addInstances") || strings.HasSuffix(call, "/removeInstances"), "the groups should be left alone, got %s", call)
+	}
+}
+
+func TestGCPUpdateLoadBalancerDeletesFailedGroup(t *testing.T) {
+	f, client := newFakeCompute(t)
+	lb, _ := gcpTestLoadBalancer(f)
+	lb.ServerIDs = []string{"1", "2", "3", "4"}
+	f.errors["POST zones/europe-west1-c/instanceGroups/web-europe-west1-c/addInstances"] = http.StatusBadRequest
+	assert.Error(t, (&GCProvider{}).UpdateLoadBalancer(context.Background(), lb, client))
+	assert.NotContains(t, f.resources, "zones/europe-west1-c/instanceGroups/web-europe-west1-c", "a group whose servers could not be added should be deleted")
+	assert.Equal(t, -1, f.index("PATCH regions/europe-west1/backendServices/web"))
+}
+
+func TestGCPDeleteLoadBalancer(t *testing.T) {
+	f, client := newFakeCompute(t)
+	lb, _ := gcpTestLoadBalancer(f)
+	G := &GCProvider{}
+	require.NoError(t, G.DeleteLoadBalancer(context.Background(), lb, client))
+	for path := range f.resources {
+		assert.Contains(t, path, "/instances/", "only the servers should be left")
+	}
+	assert.Less(t, f.index("DELETE regions/europe-west1/forwardingRules/web"), f.index("DELETE regions/europe-west1/backendServices/web"))
+	assert.Less(t, f.index("DELETE regions/europe-west1/backendServices/web"), f.index("DELETE zones/europe-west1-b/instanceGroups/web-europe-west1-b"),
+		"the groups should be deleted once the backend service no longer uses them")
+
+	assert.NoError(t, G.DeleteLoadBalancer(context.Background(), lb, client), "deleting a gone load balancer should succeed")
+}
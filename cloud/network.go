@@ -0,0 +1,581 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+// dependencyRetryInterval is how long to wait before deleting a resource
+// again that is still in use, such as a subnet of a terminating instance.
+const dependencyRetryInterval = 5 * time.Second
+
+// diffSubnets returns the subnets of old that are not in new and the subnets
+// of new that are not in old. Subnets are matched by name; a subnet whose
+// settings changed is in both. Kept subnets of new get the ID of their old
+// counterpart and the others get no ID, since IDs read from a list shift when
+// subnets are removed.
+func diffSubnets(old, new []network.Subnet) (removed, added []network.Subnet) {
+	oldByName := make(map[string]network.Subnet, len(old))
+	for _, subnet := range old {
+		oldByName[subnet.Name] = subnet
+	}
+	kept := make(map[string]bool, len(new))
+	for i := range new {
+		subnet := new[i]
+		subnet.ID = ""
+		previous, ok := oldByName[subnet.Name]
+		previousID := previous.ID
+		previous.ID = ""
+		if ok && previous == subnet {
+			new[i].ID = previousID
+			kept[subnet.Name] = true
+			continue
+		}
+		new[i].ID = ""
+		added = append(added, new[i])
+	}
+	for _, subnet := range old {
+		if !kept[subnet.Name] {
+			removed = append(removed, subnet)
+		}
+	}
+	return removed, added
+}
+
+// subnetRegion returns the region of subnet, which defaults to the region of
+// the network.
+func subnetRegion(net *network.NetworkConfig, subnet network.Subnet) string {
+	if subnet.Region != "" {
+		return subnet.Region
+	}
+	return net.Region
+}
+
+// withName returns a copy of tags with the Name tag set to name.
+func withName(tags map[string]string, name string) map[string]string {
+	named := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		named[k] = v
+	}
+	named["Name"] = name
+	return named
+}
+
+// retryWhileInUse calls fn until it no longer fails with a
+// DependencyViolation, which EC2 returns while a resource is still in use.
+func retryWhileInUse(ctx context.Context, fn func() error) error {
+	return retryWhileCode(ctx, "DependencyViolation", fn)
+}
+
+// retryWhileCode calls fn until it no longer fails with the AWS error code.
+func retryWhileCode(ctx context.Context, code string, fn func() error) error {
+	for {
+		err := fn()
+		var awsErr awserr.Error
+		if err == nil || !errors.As(err, &awsErr) || awsErr.Code() != code {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(dependencyRetryInterval):
+		}
+	}
+}
+
+// vpcFilter selects the resources of the VPC of net.
+func vpcFilter(name string, net *network.NetworkConfig) []*ec2.Filter {
+	return []*ec2.Filter{{Name: aws.String(name), Values: []*string{aws.String(net.ID)}}}
+}
+
+// CreateNetwork creates a VPC with an internet gateway, a route table routing
+// to it for the public subnets, and the subnets.
+func (A *AWSProvider) CreateNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(net.Region)
+	vpc, err := ec2Svc.CreateVpcWithContext(ctx, &ec2.CreateVpcInput{
+		CidrBlock:         aws.String(net.CIDRBlock),
+		TagSpecifications: awsTagSpecifications(net.Tags, ec2.ResourceTypeVpc),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating VPC %s: %w", net.Name, err)
+	}
+	net.ID = aws.StringValue(vpc.Vpc.VpcId)
+	err = ec2Svc.WaitUntilVpcAvailableWithContext(ctx, &ec2.DescribeVpcsInput{VpcIds: []*string{vpc.Vpc.VpcId}})
+	if err != nil {
+		return fmt.Errorf("error waiting for VPC %s: %w", net.ID, err)
+	}
+	// Instances get DNS names like they do in the default VPC.
+	_, err = ec2Svc.ModifyVpcAttributeWithContext(ctx, &ec2.ModifyVpcAttributeInput{
+		VpcId:              vpc.Vpc.VpcId,
+		EnableDnsHostnames: &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+	})
+	if err != nil {
+		return err
+	}
+
+	gateway, err := ec2Svc.CreateInternetGatewayWithContext(ctx, &ec2.CreateInternetGatewayInput{
+		TagSpecifications: awsTagSpecifications(net.Tags, ec2.ResourceTypeInternetGateway),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating internet gateway: %w", err)
+	}
+	net.InternetGatewayID = aws.StringValue(gateway.InternetGateway.InternetGatewayId)
+	_, err = ec2Svc.AttachInternetGatewayWithContext(ctx, &ec2.AttachInternetGatewayInput{
+		InternetGatewayId: gateway.InternetGateway.InternetGatewayId,
+		VpcId:             vpc.Vpc.VpcId,
+	})
+	if err != nil {
+		return fmt.Errorf("error attaching internet gateway %s: %w", net.InternetGatewayID, err)
+	}
+	routeTable, err := ec2Svc.CreateRouteTableWithContext(ctx, &ec2.CreateRouteTableInput{
+		VpcId:             vpc.Vpc.VpcId,
+		TagSpecifications: awsTagSpecifications(net.Tags, ec2.ResourceTypeRouteTable),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating route table: %w", err)
+	}
+	net.RouteTableID = aws.StringValue(routeTable.RouteTable.RouteTableId)
+	_, err = ec2Svc.CreateRouteWithContext(ctx, &ec2.CreateRouteInput{
+		RouteTableId:         routeTable.RouteTable.RouteTableId,
+		DestinationCidrBlock: aws.String("0.0.0.0/0"),
+		GatewayId:            gateway.InternetGateway.InternetGatewayId,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating the default route of %s: %w", net.RouteTableID, err)
+	}
+
+	for i := range net.Subnets {
+		if err := A.createSubnet(ctx, ec2Svc, net, &net.Subnets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSubnet creates subnet in the VPC of net and records its ID. Public
+// subnets assign public IPs and use the route table of the internet gateway.
+func (A *AWSProvider) createSubnet(ctx context.Context, ec2Svc *ec2.EC2, net *network.NetworkConfig, subnet *network.Subnet) error {
+	input := &ec2.CreateSubnetInput{
+		VpcId:             aws.String(net.ID),
+		CidrBlock:         aws.String(subnet.CIDRBlock),
+		TagSpecifications: awsTagSpecifications(withName(net.Tags, subnet.Name), ec2.ResourceTypeSubnet),
+	}
+	if subnet.Zone != "" {
+		input.AvailabilityZone = aws.String(subnet.Zone)
+	}
+	result, err := ec2Svc.CreateSubnetWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error creating subnet %s: %w", subnet.Name, err)
+	}
+	subnet.ID = aws.StringValue(result.Subnet.SubnetId)
+	err = ec2Svc.WaitUntilSubnetAvailableWithContext(ctx, &ec2.DescribeSubnetsInput{SubnetIds: []*string{result.Subnet.SubnetId}})
+	if err != nil {
+		return fmt.Errorf("error waiting for subnet %s: %w", subnet.ID, err)
+	}
+	if !subnet.Public {
+		return nil
+	}
+	_, err = ec2Svc.ModifySubnetAttributeWithContext(ctx, &ec2.ModifySubnetAttributeInput{
+		SubnetId:            result.Subnet.SubnetId,
+		MapPublicIpOnLaunch: &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = ec2Svc.AssociateRouteTableWithContext(ctx, &ec2.AssociateRouteTableInput{
+		RouteTableId: aws.String(net.RouteTableID),
+		SubnetId:     result.Subnet.SubnetId,
+	})
+	if err != nil {
+		return fmt.Errorf("error routing subnet %s to the internet gateway: %w", subnet.ID, err)
+	}
+	return nil
+}
+
+// GetNetwork reads the VPC of net and the subnets it knows of. It returns nil
+// when the VPC no longer exists.
+func (A *AWSProvider) GetNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) (*network.NetworkConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(net.Region)
+	vpcs, err := ec2Svc.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{VpcIds: []*string{aws.String(net.ID)}})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidVpcID.NotFound") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(vpcs.Vpcs) == 0 {
+		return nil, nil
+	}
+	vpc := vpcs.Vpcs[0]
+	config := &network.NetworkConfig{
+		ID:           net.ID,
+		Name:         net.Name,
+		Region:       net.Region,
+		CIDRBlock:    aws.StringValue(vpc.CidrBlock),
+		GCPProjectID: net.GCPProjectID,
+		RouteTableID: net.RouteTableID,
+		Tags:         map[string]string{},
+	}
+	for _, tag := range vpc.Tags {
+		if key := aws.StringValue(tag.Key); !isIgnoredTag(key, net.IgnoreTagKeys) {
+			config.Tags[key] = aws.StringValue(tag.Value)
+		}
+	}
+
+	gateways, err := ec2Svc.DescribeInternetGatewaysWithContext(ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: vpcFilter("attachment.vpc-id", net),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(gateways.InternetGateways) > 0 {
+		config.InternetGatewayID = aws.StringValue(gateways.InternetGateways[0].InternetGatewayId)
+	}
+
+	subnets, err := ec2Svc.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: vpcFilter("vpc-id", net)})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*ec2.Subnet, len(subnets.Subnets))
+	for _, subnet := range subnets.Subnets {
+		byID[aws.StringValue(subnet.SubnetId)] = subnet
+	}
+	// Keep the order of the configuration; a missing subnet is planned again.
+	for _, known := range net.Subnets {
+		subnet, ok := byID[known.ID]
+		if !ok {
+			continue
+		}
+		known.CIDRBlock = aws.StringValue(subnet.CidrBlock)
+		// A zone picked by AWS is not reported, so it is not planned as a change.
+		if known.Zone != "" {
+			known.Zone = aws.StringValue(subnet.AvailabilityZone)
+		}
+		known.Public = aws.BoolValue(subnet.MapPublicIpOnLaunch)
+		config.Subnets = append(config.Subnets, known)
+	}
+	return config, nil
+}
+
+// UpdateNetwork applies the tags of net to everything in the VPC and creates
+// and deletes subnets to match net.Subnets.
+func (A *AWSProvider) UpdateNetwork(ctx context.Context, net, old *network.NetworkConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(net.Region)
+	removed, _ := diffSubnets(old.Subnets, net.Subnets)
+	for _, subnet := range removed {
+		if err := A.deleteSubnet(ctx, ec2Svc, subnet.ID); err != nil {
+			return err
+		}
+	}
+	for i := range net.Subnets {
+		subnet := &net.Subnets[i]
+		if subnet.ID != "" {
+			continue
+		}
+		if err := A.createSubnet(ctx, ec2Svc, net, subnet); err != nil {
+			return err
+		}
+	}
+	return A.updateNetworkTags(ctx, ec2Svc, net)
+}
+
+// updateNetworkTags makes the tags of the VPC, its internet gateway, route
+// table and subnets match net.Tags. Subnets keep their Name tag.
+func (A *AWSProvider) updateNetworkTags(ctx context.Context, ec2Svc *ec2.EC2, net *network.NetworkConfig) error {
+	vpcs, err := ec2Svc.DescribeVpcsWithContext(ctx, &ec2.DescribeVpcsInput{VpcIds: []*string{aws.String(net.ID)}})
+	if err != nil {
+		return err
+	}
+	for _, vpc := range vpcs.Vpcs {
+		if err := A.updateTags(ctx, ec2Svc, vpc.VpcId, vpc.Tags, net.Tags, net.IgnoreTagKeys); err != nil {
+			return err
+		}
+	}
+	gateways, err := ec2Svc.DescribeInternetGatewaysWithContext(ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: vpcFilter("attachment.vpc-id", net),
+	})
+	if err != nil {
+		return err
+	}
+	for _, gateway := range gateways.InternetGateways {
+		if err := A.updateTags(ctx, ec2Svc, gateway.InternetGatewayId, gateway.Tags, net.Tags, net.IgnoreTagKeys); err != nil {
+			return err
+		}
+	}
+	if net.RouteTableID != "" {
+		routeTables, err := ec2Svc.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+			RouteTableIds: []*string{aws.String(net.RouteTableID)},
+		})
+		if err != nil {
+			return err
+		}
+		for _, routeTable := range routeTables.RouteTables {
+			if err := A.updateTags(ctx, ec2Svc, routeTable.RouteTableId, routeTable.Tags, net.Tags, net.IgnoreTagKeys); err != nil {
+				return err
+			}
+		}
+	}
+	subnets, err := ec2Svc.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: vpcFilter("vpc-id", net)})
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string, len(net.Subnets))
+	for _, subnet := range net.Subnets {
+		names[subnet.ID] = subnet.Name
+	}
+	for _, subnet := range subnets.Subnets {
+		name, ok := names[aws.StringValue(subnet.SubnetId)]
+		if !ok {
+			continue
+		}
+		if err := A.updateTags(ctx, ec2Svc, subnet.SubnetId, subnet.Tags, withName(net.Tags, name), net.IgnoreTagKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (A *AWSProvider) deleteSubnet(ctx context.Context, ec2Svc *ec2.EC2, subnetID string) error {
+	err := retryWhileInUse(ctx, func() error {
+		_, err := ec2Svc.DeleteSubnetWithContext(ctx, &ec2.DeleteSubnetInput{SubnetId: aws.String(subnetID)})
+		return err
+	})
+	if err != nil && !isAWSNotFound(err, "InvalidSubnetID.NotFound") {
+		return fmt.Errorf("error deleting subnet %s: %w", subnetID, err)
+	}
+	return nil
+}
+
+// DeleteNetwork deletes every subnet, route table and internet gateway of the
+// VPC, then the VPC. They are looked up in the VPC rather than taken from
+// net, so a partly created network is cleaned up too.
+func (A *AWSProvider) DeleteNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(net.Region)
+	subnets, err := ec2Svc.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: vpcFilter("vpc-id", net)})
+	if err != nil {
+		return err
+	}
+	for _, subnet := range subnets.Subnets {
+		if err := A.deleteSubnet(ctx, ec2Svc, aws.StringValue(subnet.SubnetId)); err != nil {
+			return err
+		}
+	}
+	routeTables, err := ec2Svc.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{Filters: vpcFilter("vpc-id", net)})
+	if err != nil {
+		return err
+	}
+	for _, routeTable := range routeTables.RouteTables {
+		main := false
+		for _, association := range routeTable.Associations {
+			main = main || aws.BoolValue(association.Main)
+		}
+		// The main route table goes with the VPC.
+		if main {
+			continue
+		}
+		_, err := ec2Svc.DeleteRouteTableWithContext(ctx, &ec2.DeleteRouteTableInput{RouteTableId: routeTable.RouteTableId})
+		if err != nil {
+			return fmt.Errorf("error deleting route table %s: %w", aws.StringValue(routeTable.RouteTableId), err)
+		}
+	}
+	gateways, err := ec2Svc.DescribeInternetGatewaysWithContext(ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: vpcFilter("attachment.vpc-id", net),
+	})
+	if err != nil {
+		return err
+	}
+	for _, gateway := range gateways.InternetGateways {
+		err := retryWhileInUse(ctx, func() error {
+			_, err := ec2Svc.DetachInternetGatewayWithContext(ctx, &ec2.DetachInternetGatewayInput{
+				InternetGatewayId: gateway.InternetGatewayId,
+				VpcId:             aws.String(net.ID),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error detaching internet gateway %s: %w", aws.StringValue(gateway.InternetGatewayId), err)
+		}
+		_, err = ec2Svc.DeleteInternetGatewayWithContext(ctx, &ec2.DeleteInternetGatewayInput{InternetGatewayId: gateway.InternetGatewayId})
+		if err != nil {
+			return fmt.Errorf("error deleting internet gateway %s: %w", aws.StringValue(gateway.InternetGatewayId), err)
+		}
+	}
+	err = retryWhileInUse(ctx, func() error {
+		_, err := ec2Svc.DeleteVpcWithContext(ctx, &ec2.DeleteVpcInput{VpcId: aws.String(net.ID)})
+		return err
+	})
+	if err != nil && !isAWSNotFound(err, "InvalidVpcID.NotFound") {
+		return fmt.Errorf("error deleting VPC %s: %w", net.ID, err)
+	}
+	return nil
+}
+
+// gcpSubnetworkID returns the partial URL of a subnetwork, which instances
+// accept as their subnetwork.
+func gcpSubnetworkID(projectID, region, name string) string {
+	return fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", projectID, region, name)
+}
+
+func gcpNetworkURL(projectID, name string) string {
+	return fmt.Sprintf("projects/%s/global/networks/%s", projectID, name)
+}
+
+// CreateNetwork creates a custom-mode VPC network and its subnetworks.
+func (G *GCProvider) CreateNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Networks.Insert(net.GCPProjectID, &compute.Network{
+		Name:                  net.Name,
+		Description:           "Managed by cloudfusion",
+		AutoCreateSubnetworks: false,
+		ForceSendFields:       []string{"AutoCreateSubnetworks"},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error creating network %s: %w", net.Name, err)
+	}
+	net.ID = net.Name
+	if err := G.waitForGlobalOperation(ctx, client, net.GCPProjectID, op.Name); err != nil {
+		return err
+	}
+	for i := range net.Subnets {
+		if err := G.createSubnetwork(ctx, client, net, &net.Subnets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (G *GCProvider) createSubnetwork(ctx context.Context, client interface{}, net *network.NetworkConfig, subnet *network.Subnet) error {
+	computeService := client.(*GCPClient).client
+	region := subnetRegion(net, *subnet)
+	op, err := computeService.Subnetworks.Insert(net.GCPProjectID, region, &compute.Subnetwork{
+		Name:        subnet.Name,
+		IpCidrRange: subnet.CIDRBlock,
+		Network:     gcpNetworkURL(net.GCPProjectID, net.Name),
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error creating subnetwork %s: %w", subnet.Name, err)
+	}
+	if err := G.waitForRegionOperation(ctx, client, net.GCPProjectID, region, op.Name); err != nil {
+		return err
+	}
+	subnet.ID = gcpSubnetworkID(net.GCPProjectID, region, subnet.Name)
+	return nil
+}
+
+func (G *GCProvider) deleteSubnetwork(ctx context.Context, client interface{}, projectID, region, name string) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Subnetworks.Delete(projectID, region, name).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting subnetwork %s: %w", name, err)
+	}
+	return G.waitForRegionOperation(ctx, client, projectID, region, op.Name)
+}
+
+// GetNetwork reads the network of net and the subnetworks it knows of. It
+// returns nil when the network no longer exists.
+func (G *GCProvider) GetNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) (*network.NetworkConfig, error) {
+	computeService := client.(*GCPClient).client
+	_, err := computeService.Networks.Get(net.GCPProjectID, net.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	config := &network.NetworkConfig{
+		ID:           net.ID,
+		Name:         net.ID,
+		Region:       net.Region,
+		CIDRBlock:    net.CIDRBlock,
+		GCPProjectID: net.GCPProjectID,
+		Tags:         map[string]string{},
+	}
+	for _, known := range net.Subnets {
+		subnetwork, err := computeService.Subnetworks.Get(net.GCPProjectID, subnetRegion(net, known), known.Name).Context(ctx).Do()
+		if err != nil {
+			if isGCPNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		known.CIDRBlock = subnetwork.IpCidrRange
+		config.Subnets = append(config.Subnets, known)
+	}
+	return config, nil
+}
+
+// UpdateNetwork creates and deletes subnetworks to match net.Subnets.
+func (G *GCProvider) UpdateNetwork(ctx context.Context, net, old *network.NetworkConfig, client interface{}) error {
+	removed, _ := diffSubnets(old.Subnets, net.Subnets)
+	for _, subnet := range removed {
+		if err := G.deleteSubnetwork(ctx, client, net.GCPProjectID, subnetRegion(old, subnet), subnet.Name); err != nil {
+			return err
+		}
+	}
+	for i := range net.Subnets {
+		if net.Subnets[i].ID != "" {
+			continue
+		}
+		if err := G.createSubnetwork(ctx, client, net, &net.Subnets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteNetwork deletes every subnetwork of the network, including ones left
+// behind by a failed create, then the network.
+func (G *GCProvider) DeleteNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	filter := fmt.Sprintf("network = \"https://www.googleapis.com/compute/v1/%s\"", gcpNetworkURL(net.GCPProjectID, net.ID))
+	var subnetworks []*compute.Subnetwork
+	err := computeService.Subnetworks.AggregatedList(net.GCPProjectID).Filter(filter).Pages(ctx, func(page *compute.SubnetworkAggregatedList) error {
+		for _, scoped := range page.Items {
+			subnetworks = append(subnetworks, scoped.Subnetworks...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, subnetwork := range subnetworks {
+		if err := G.deleteSubnetwork(ctx, client, net.GCPProjectID, lastSegment(subnetwork.Region), subnetwork.Name); err != nil {
+			return err
+		}
+	}
+	op, err := computeService.Networks.Delete(net.GCPProjectID, net.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting network %s: %w", net.ID, err)
+	}
+	return G.waitForGlobalOperation(ctx, client, net.GCPProjectID, op.Name)
+}
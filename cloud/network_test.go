@@ -0,0 +1,53 @@
+package cloud
+
+import (
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSubnets(t *testing.T) {
+	old := []network.Subnet{
+		{ID: "subnet-a", Name: "a", CIDRBlock: "10.0.1.0/24", Public: true},
+		{ID: "subnet-b", Name: "b", CIDRBlock: "10.0.2.0/24"},
+		{ID: "subnet-c", Name: "c", CIDRBlock: "10.0.3.0/24"},
+	}
+	// "a" was removed, so the IDs read from the list shifted by one.
+	new := []network.Subnet{
+		{ID: "subnet-a", Name: "b", CIDRBlock: "10.0.2.0/24"},
+		{ID: "subnet-b", Name: "c", CIDRBlock: "10.0.30.0/24"},
+		{ID: "subnet-c", Name: "d", CIDRBlock: "10.0.4.0/24"},
+	}
+	removed, added := diffSubnets(old, new)
+
+	assert.Equal(t, []string{"a", "c"}, subnetNames(removed))
+	assert.Equal(t, []string{"c", "d"}, subnetNames(added))
+	assert.Equal(t, "subnet-b", new[0].ID, "a kept subnet keeps its ID")
+	assert.Empty(t, new[1].ID, "a changed subnet is created again")
+	assert.Empty(t, new[2].ID)
+}
+
+func subnetNames(subnets []network.Subnet) []string {
+	var names []string
+	for _, subnet := range subnets {
+		names = append(names, subnet.Name)
+	}
+	return names
+}
+
+func TestSubnetRegion(t *testing.T) {
+	net := &network.NetworkConfig{Region: "europe-west1"}
+	assert.Equal(t, "europe-west1", subnetRegion(net, network.Subnet{}))
+	assert.Equal(t, "us-east1", subnetRegion(net, network.Subnet{Region: "us-east1"}))
+}
+
+func TestWithName(t *testing.T) {
+	tags := map[string]string{"env": "prod", "Name": "vpc"}
+	assert.Equal(t, map[string]string{"env": "prod", "Name": "public"}, withName(tags, "public"))
+	assert.Equal(t, "vpc", tags["Name"], "the tags of the network are not modified")
+}
+
+func TestGCPSubnetworkID(t *testing.T) {
+	assert.Equal(t, "projects/p/regions/europe-west1/subnetworks/web", gcpSubnetworkID("p", "europe-west1", "web"))
+}
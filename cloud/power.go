@@ -0,0 +1,152 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+// awsPowerState maps the state of an EC2 instance to a power state. A
+// hibernated instance is reported as suspended.
+func awsPowerState(instance *ec2.Instance) string {
+	switch aws.StringValue(instance.State.Name) {
+	case ec2.InstanceStateNameStopping, ec2.InstanceStateNameStopped:
+		if instance.StateReason != nil && aws.StringValue(instance.StateReason.Code) == "Client.UserInitiatedHibernate" {
+			return "suspended"
+		}
+		return "stopped"
+	default:
+		return "running"
+	}
+}
+
+// SetPowerState starts, stops or hibernates the instance and waits until it
+// reaches VM.PowerState.
+func (A *AWSProvider) SetPowerState(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(VM.Region)
+	describeInput := &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(VM.ID)}}
+	result, err := ec2Svc.DescribeInstancesWithContext(ctx, describeInput)
+	if err != nil {
+		return err
+	}
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return fmt.Errorf("instance %s not found", VM.ID)
+	}
+	current := awsPowerState(result.Reservations[0].Instances[0])
+	if current == VM.PowerState {
+		return nil
+	}
+
+	start := func() error {
+		// A stopping instance cannot be started yet.
+		if err := ec2Svc.WaitUntilInstanceStoppedWithContext(ctx, describeInput); err != nil {
+			return fmt.Errorf("error waiting for %s to stop: %w", VM.ID, err)
+		}
+		if _, err := ec2Svc.StartInstancesWithContext(ctx, &ec2.StartInstancesInput{InstanceIds: describeInput.InstanceIds}); err != nil {
+			return fmt.Errorf("error starting %s: %w", VM.ID, err)
+		}
+		if err := ec2Svc.WaitUntilInstanceRunningWithContext(ctx, describeInput); err != nil {
+			return fmt.Errorf("error waiting for %s to start: %w", VM.ID, err)
+		}
+		return nil
+	}
+	switch VM.PowerState {
+	case "running":
+		return start()
+	case "stopped", "suspended":
+		// Only a running instance can be stopped or hibernated, so a stopped
+		// instance is started first, as on GCP.
+		if current != "running" {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := ec2Svc.WaitUntilInstanceRunningWithContext(ctx, describeInput); err != nil {
+			return fmt.Errorf("error waiting for %s to start: %w", VM.ID, err)
+		}
+		stopInput := &ec2.StopInstancesInput{
+			InstanceIds: describeInput.InstanceIds,
+			Hibernate:   aws.Bool(VM.PowerState == "suspended"),
+		}
+		if _, err := ec2Svc.StopInstancesWithContext(ctx, stopInput); err != nil {
+			return fmt.Errorf("error stopping %s: %w", VM.ID, err)
+		}
+		if err := ec2Svc.WaitUntilInstanceStoppedWithContext(ctx, describeInput); err != nil {
+			return fmt.Errorf("error waiting for %s to stop: %w", VM.ID, err)
+		}
+	default:
+		return fmt.Errorf("unsupported power state %q", VM.PowerState)
+	}
+	return nil
+}
+
+// gcpPowerState maps the status of a GCE instance to a power state.
+func gcpPowerState(instance *compute.Instance) string {
+	switch instance.Status {
+	case "STOPPING", "TERMINATED":
+		return "stopped"
+	case "SUSPENDING", "SUSPENDED":
+		return "suspended"
+	default:
+		return "running"
+	}
+}
+
+// SetPowerState starts, stops, suspends or resumes the instance and waits
+// for the operation to finish.
+func (G *GCProvider) SetPowerState(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	instance, err := computeService.Instances.Get(VM.GCPProjectID, VM.Zone, VM.Name).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	current := gcpPowerState(instance)
+	if current == VM.PowerState {
+		return nil
+	}
+
+	var calls []func() (*compute.Operation, error)
+	start := func() (*compute.Operation, error) {
+		return computeService.Instances.Start(VM.GCPProjectID, VM.Zone, VM.Name).Context(ctx).Do()
+	}
+	switch {
+	case VM.PowerState == "running" && current == "suspended":
+		calls = append(calls, func() (*compute.Operation, error) {
+			return computeService.Instances.Resume(VM.GCPProjectID, VM.Zone, VM.Name).Context(ctx).Do()
+		})
+	case VM.PowerState == "running":
+		calls = append(calls, start)
+	case VM.PowerState == "stopped":
+		calls = append(calls, func() (*compute.Operation, error) {
+			return computeService.Instances.Stop(VM.GCPProjectID, VM.Zone, VM.Name).Context(ctx).Do()
+		})
+	case VM.PowerState == "suspended":
+		// Only a running instance can be suspended.
+		if current == "stopped" {
+			calls = append(calls, start)
+		}
+		calls = append(calls, func() (*compute.Operation, error) {
+			return computeService.Instances.Suspend(VM.GCPProjectID, VM.Zone, VM.Name).Context(ctx).Do()
+		})
+	default:
+		return fmt.Errorf("unsupported power state %q", VM.PowerState)
+	}
+	for _, call := range calls {
+		op, err := call()
+		if err != nil {
+			return fmt.Errorf("error changing the power state of %s: %w", VM.Name, err)
+		}
+		if err := G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package cloud
+
+import (
+	"context"
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestAWSPowerState(t *testing.T) {
+	instance := func(state, reason string) *ec2.Instance {
+		i := &ec2.Instance{State: &ec2.InstanceState{Name: aws.String(state)}}
+		if reason != "" {
+			i.StateReason = &ec2.StateReason{Code: aws.String(reason)}
+		}
+		return i
+	}
+	assert.Equal(t, "running", awsPowerState(instance("pending", "")))
+	assert.Equal(t, "running", awsPowerState(instance("running", "")))
+	assert.Equal(t, "stopped", awsPowerState(instance("stopped", "Client.UserInitiatedShutdown")))
+	assert.Equal(t, "suspended", awsPowerState(instance("stopping", "Client.UserInitiatedHibernate")))
+}
+
+func TestGCPPowerState(t *testing.T) {
+	assert.Equal(t, "running", gcpPowerState(&compute.Instance{Status: "PROVISIONING"}))
+	assert.Equal(t, "stopped", gcpPowerState(&compute.Instance{Status: "TERMINATED"}))
+	assert.Equal(t, "suspended", gcpPowerState(&compute.Instance{Status: "SUSPENDED"}))
+}
+
+func TestAWSSuspendStartsStoppedInstance(t *testing.T) {
+	f, client := newFakeEC2(t, map[string]string{
+		"DescribeInstances": "<reservationSet><item><instancesSet><item><instanceId>i-1</instanceId><instanceState><name>stopped</name></instanceState></item></instancesSet></item></reservationSet>",
+	})
+	f.errors["StartInstances"] = "IncorrectInstanceState"
+	VM := &vmconfig.VMConfig{ID: "i-1", Region: "eu-west-1", PowerState: "suspended"}
+	assert.ErrorContains(t, (&AWSProvider{}).SetPowerState(context.Background(), VM, client), "error starting i-1")
+	assert.Equal(t, []string{"DescribeInstances", "DescribeInstances", "StartInstances"}, f.actions(), "a stopped instance should be started before it is hibernated")
+}
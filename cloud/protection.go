@@ -0,0 +1,39 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// SetDeletionProtection turns termination protection of the instance on or
+// off to match VM.DeletionProtection.
+func (A *AWSProvider) SetDeletionProtection(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	_, err := awsClient.ec2Service(VM.Region).ModifyInstanceAttributeWithContext(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:            aws.String(VM.ID),
+		DisableApiTermination: &ec2.AttributeBooleanValue{Value: aws.Bool(VM.DeletionProtection)},
+	})
+	if err != nil {
+		return fmt.Errorf("error setting termination protection on %s: %w", VM.ID, err)
+	}
+	return nil
+}
+
+// SetDeletionProtection turns deletion protection of the instance on or off
+// to match VM.DeletionProtection.
+func (G *GCProvider) SetDeletionProtection(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Instances.SetDeletionProtection(VM.GCPProjectID, VM.Zone, VM.Name).
+		DeletionProtection(VM.DeletionProtection).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error setting deletion protection on %s: %w", VM.Name, err)
+	}
+	return G.waitForOperation(ctx, client, VM.GCPProjectID, VM.Zone, op.Name)
+}
@@ -0,0 +1,28 @@
+package cloud
+
+import (
+	"context"
+	"testing"
+
+	multicloudcompute "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSReadsDeletionProtection(t *testing.T) {
+	A := &AWSProvider{}
+	data := schema.TestResourceDataRaw(t, multicloudcompute.GetVMResourceSchema(), map[string]interface{}{
+		"region":              "eu-west-1",
+		"deletion_protection": false,
+	})
+	data.SetId("i-1")
+	f, client := newFakeEC2(t, map[string]string{
+		"DescribeInstances":         "<reservationSet><item><instancesSet><item><instanceId>i-1</instanceId><instanceState><name>running</name></instanceState></item></instancesSet></item></reservationSet>",
+		"DescribeInstanceAttribute": "<instanceId>i-1</instanceId><disableApiTermination><value>true</value></disableApiTermination>",
+	})
+	instance, err := A.GetInstance(context.Background(), data, client)
+	require.NoError(t, err)
+	assert.True(t, A.GetInstanceConfig(instance, data).DeletionProtection, "protection enabled outside of Terraform should show as drift")
+	assert.Equal(t, "disableApiTermination", f.call("DescribeInstanceAttribute").Get("Attribute"))
+}
@@ -42,13 +42,16 @@ func TestGCProvider_CreateInstance(t *testing.T) {
 	provider := &GCProvider{}
 	client, _ := provider.CreateClient(getCredentialFilePath("dantata-b059eea46359.json"))
 	vmConfig := &vm.VMConfig{
-		Name:            "toto",
-		Region:          "europe-west1-b",
-		InstanceType:    "e2-small",
-		GCPProjectID:    "dantata",
-		GCPImageFamily:  "ubuntu-2004-lts",
-		GCPImageProject: "ubuntu-os-cloud",
-		GCPNetworkName:  "default",
+		Name:         "toto",
+		Region:       "europe-west1",
+		Zone:         "europe-west1-b",
+		InstanceType: "e2-small",
+		GCPProjectID: "dantata",
+		GCP: &vm.GCPOptions{
+			ImageFamily:  "ubuntu-2004-lts",
+			ImageProject: "ubuntu-os-cloud",
+			NetworkName:  "default",
+		},
 	}
 	id, err := provider.CreateInstance(context.Background(), vmConfig, client)
 	assert.NoError(t, err, "CreateInstance should not return an error")
@@ -62,7 +65,8 @@ func TestGCProvider_DeleteInstance(t *testing.T) {
 	data := schema.TestResourceDataRaw(t, resourceSchema, map[string]interface{}{
 		"gcp_project": "dantata",
 		"name":        "example-vm-1",
-		"region":      "europe-west1-b",
+		"region":      "europe-west1",
+		"zone":        "europe-west1-b",
 	})
 	data.SetId("3007912269376857942")
 	gcpInstance, err := provider.GetInstance(context.Background(), data, client)
@@ -83,7 +87,8 @@ func TestGCProvider_UpdateInstance(t *testing.T) {
 	resourceSchema := multicloudcompute.GetVMResourceSchema()
 	data := schema.TestResourceDataRaw(t, resourceSchema, map[string]interface{}{
 		"gcp_project":   "dantata",
-		"region":        "europe-west1-b",
+		"region":        "europe-west1",
+		"zone":          "europe-west1-b",
 		"instance_type": "e2-medium",
 	})
 	data.SetId("1009513919837837499")
@@ -0,0 +1,181 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+)
+
+// CreateSnapshot snapshots the EBS volume of snap and waits for the snapshot
+// to complete.
+func (A *AWSProvider) CreateSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(snap.Region)
+	snapshot, err := ec2Svc.CreateSnapshotWithContext(ctx, &ec2.CreateSnapshotInput{
+		VolumeId:          aws.String(snap.VolumeID),
+		Description:       aws.String(snap.Description),
+		TagSpecifications: awsTagSpecifications(snap.Tags, ec2.ResourceTypeSnapshot),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating snapshot %s of %s: %w", snap.Name, snap.VolumeID, err)
+	}
+	snap.ID = aws.StringValue(snapshot.SnapshotId)
+	snapshots := &ec2.DescribeSnapshotsInput{SnapshotIds: []*string{snapshot.SnapshotId}}
+	if err := ec2Svc.WaitUntilSnapshotCompletedWithContext(ctx, snapshots, request.WithWaiterMaxAttempts(awsImageWaitAttempts)); err != nil {
+		return fmt.Errorf("error waiting for %s: %w", snap.ID, err)
+	}
+	return nil
+}
+
+func (A *AWSProvider) describeSnapshot(ctx context.Context, ec2Svc *ec2.EC2, snapshotID string) (*ec2.Snapshot, error) {
+	snapshots, err := ec2Svc.DescribeSnapshotsWithContext(ctx, &ec2.DescribeSnapshotsInput{SnapshotIds: []*string{aws.String(snapshotID)}})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidSnapshot.NotFound") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(snapshots.Snapshots) == 0 {
+		return nil, nil
+	}
+	return snapshots.Snapshots[0], nil
+}
+
+// GetSnapshot reads the EBS snapshot of snap. It returns nil when the
+// snapshot no longer exists.
+func (A *AWSProvider) GetSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) (*storage.SnapshotConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	snapshot, err := A.describeSnapshot(ctx, awsClient.ec2Service(snap.Region), snap.ID)
+	if err != nil || snapshot == nil {
+		return nil, err
+	}
+	config := *snap
+	config.Description = aws.StringValue(snapshot.Description)
+	config.VolumeID = aws.StringValue(snapshot.VolumeId)
+	config.SizeGB = aws.Int64Value(snapshot.VolumeSize)
+	config.Tags = map[string]string{}
+	for _, tag := range snapshot.Tags {
+		if key := aws.StringValue(tag.Key); !isIgnoredTag(key, snap.IgnoreTagKeys) {
+			config.Tags[key] = aws.StringValue(tag.Value)
+		}
+	}
+	return &config, nil
+}
+
+// UpdateSnapshot updates the tags of the EBS snapshot of snap.
+func (A *AWSProvider) UpdateSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(snap.Region)
+	snapshot, err := A.describeSnapshot(ctx, ec2Svc, snap.ID)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return fmt.Errorf("snapshot %s not found", snap.ID)
+	}
+	return A.updateTags(ctx, ec2Svc, snapshot.SnapshotId, snapshot.Tags, snap.Tags, snap.IgnoreTagKeys)
+}
+
+// DeleteSnapshot deletes the EBS snapshot of snap.
+func (A *AWSProvider) DeleteSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	_, err := awsClient.ec2Service(snap.Region).DeleteSnapshotWithContext(ctx, &ec2.DeleteSnapshotInput{SnapshotId: aws.String(snap.ID)})
+	if err != nil && !isAWSNotFound(err, "InvalidSnapshot.NotFound") {
+		return fmt.Errorf("error deleting %s: %w", snap.ID, err)
+	}
+	return nil
+}
+
+// CreateSnapshot snapshots the persistent disk of snap.
+func (G *GCProvider) CreateSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	labels, err := SanitizeGCELabels(snap.Tags)
+	if err != nil {
+		return err
+	}
+	op, err := computeService.Disks.CreateSnapshot(snap.GCPProjectID, snap.Zone, snap.VolumeID, &compute.Snapshot{
+		Name:        snap.Name,
+		Description: snap.Description,
+		Labels:      labels,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error creating snapshot %s of %s: %w", snap.Name, snap.VolumeID, err)
+	}
+	snap.ID = snap.Name
+	return G.waitForOperation(ctx, client, snap.GCPProjectID, snap.Zone, op.Name)
+}
+
+// GetSnapshot reads the GCE snapshot of snap. It returns nil when the
+// snapshot no longer exists.
+func (G *GCProvider) GetSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) (*storage.SnapshotConfig, error) {
+	computeService := client.(*GCPClient).client
+	snapshot, err := computeService.Snapshots.Get(snap.GCPProjectID, snap.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	config := *snap
+	config.Name = snapshot.Name
+	config.Description = snapshot.Description
+	config.SizeGB = snapshot.DiskSizeGb
+	config.Tags = map[string]string{}
+	for k, v := range snapshot.Labels {
+		if !isIgnoredLabel(k, snap.IgnoreTagKeys) {
+			config.Tags[k] = v
+		}
+	}
+	return &config, nil
+}
+
+// UpdateSnapshot updates the labels of the GCE snapshot of snap.
+func (G *GCProvider) UpdateSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	snapshot, err := computeService.Snapshots.Get(snap.GCPProjectID, snap.ID).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	labels, err := gcpLabelsKeepingIgnored(snap.Tags, snapshot.Labels, snap.IgnoreTagKeys)
+	if err != nil {
+		return err
+	}
+	op, err := computeService.Snapshots.SetLabels(snap.GCPProjectID, snap.ID, &compute.GlobalSetLabelsRequest{
+		Labels:           labels,
+		LabelFingerprint: snapshot.LabelFingerprint,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error setting the labels of snapshot %s: %w", snap.ID, err)
+	}
+	return G.waitForGlobalOperation(ctx, client, snap.GCPProjectID, op.Name)
+}
+
+// DeleteSnapshot deletes the GCE snapshot of snap.
+func (G *GCProvider) DeleteSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Snapshots.Delete(snap.GCPProjectID, snap.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting snapshot %s: %w", snap.ID, err)
+	}
+	return G.waitForGlobalOperation(ctx, client, snap.GCPProjectID, op.Name)
+}
@@ -0,0 +1,89 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// ValidateSSHPublicKey checks that key is a single line in authorized_keys
+// format.
+func ValidateSSHPublicKey(key string) error {
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+		return fmt.Errorf("invalid SSH public key: %w", err)
+	}
+	return nil
+}
+
+// SSHKeyFingerprint returns the MD5 fingerprint of key, as shown by EC2 for
+// imported key pairs.
+func SSHKeyFingerprint(key string) (string, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH public key: %w", err)
+	}
+	return ssh.FingerprintLegacyMD5(publicKey), nil
+}
+
+// ensureKeyPair imports key as an EC2 key pair unless a key pair with the
+// same content was imported before, and returns its name. The name is derived
+// from the fingerprint so servers sharing a key share the key pair.
+func (A *AWSProvider) ensureKeyPair(ctx context.Context, ec2Svc *ec2.EC2, key string) (string, error) {
+	fingerprint, err := SSHKeyFingerprint(key)
+	if err != nil {
+		return "", err
+	}
+	name := "cloudfusion-" + strings.ReplaceAll(fingerprint, ":", "")
+	_, err = ec2Svc.DescribeKeyPairsWithContext(ctx, &ec2.DescribeKeyPairsInput{
+		KeyNames: []*string{aws.String(name)},
+	})
+	if err == nil {
+		return name, nil
+	}
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) || awsErr.Code() != "InvalidKeyPair.NotFound" {
+		return "", fmt.Errorf("error reading key pair %s: %w", name, err)
+	}
+	_, err = ec2Svc.ImportKeyPairWithContext(ctx, &ec2.ImportKeyPairInput{
+		KeyName:           aws.String(name),
+		PublicKeyMaterial: []byte(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error importing key pair %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// gcpSSHKeys formats the keys of VM as the value of the GCE ssh-keys metadata
+// key, one "user:key" entry per line.
+func gcpSSHKeys(VM *vmconfig.VMConfig) string {
+	lines := make([]string, 0, len(VM.SSHPublicKeys))
+	for _, key := range VM.SSHPublicKeys {
+		lines = append(lines, fmt.Sprintf("%s:%s", VM.SSHUser, strings.TrimSpace(key)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// setMetadataItem sets key to value in metadata, removing the key when value
+// is empty. Other items are left as they are.
+func setMetadataItem(metadata *compute.Metadata, key, value string) {
+	items := metadata.Items[:0]
+	for _, item := range metadata.Items {
+		if item.Key != key {
+			items = append(items, item)
+		}
+	}
+	if value != "" {
+		items = append(items, &compute.MetadataItems{Key: key, Value: googleapi.String(value)})
+	}
+	metadata.Items = items
+}
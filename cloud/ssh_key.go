@@ -0,0 +1,307 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// metadataRetryInterval is how long to wait before writing the project
+	// metadata again after a concurrent change.
+	metadataRetryInterval = 2 * time.Second
+	// gcpSSHKeyMarker prefixes the key name in the comment of the ssh-keys
+	// lines written by cloudfusion_ssh_key, telling them apart from the
+	// lines of users and other tools.
+	gcpSSHKeyMarker = "cloudfusion-"
+)
+
+// sameSSHKey reports whether the authorized_keys lines a and b hold the same
+// key, whatever their options and comments.
+func sameSSHKey(a, b string) bool {
+	keyA, _, _, _, errA := ssh.ParseAuthorizedKey([]byte(a))
+	keyB, _, _, _, errB := ssh.ParseAuthorizedKey([]byte(b))
+	return errA == nil && errB == nil && bytes.Equal(keyA.Marshal(), keyB.Marshal())
+}
+
+// CreateSSHKey imports the public key of key as an EC2 key pair.
+func (A *AWSProvider) CreateSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	return A.importSSHKey(ctx, awsClient.ec2Service(key.Region), key)
+}
+
+func (A *AWSProvider) importSSHKey(ctx context.Context, ec2Svc *ec2.EC2, key *vmconfig.SSHKeyConfig) error {
+	output, err := ec2Svc.ImportKeyPairWithContext(ctx, &ec2.ImportKeyPairInput{
+		KeyName:           aws.String(key.Name),
+		PublicKeyMaterial: []byte(key.PublicKey),
+	})
+	if err != nil {
+		return fmt.Errorf("error importing key pair %s: %w", key.Name, err)
+	}
+	key.ID = key.Name
+	key.Fingerprint = aws.StringValue(output.KeyFingerprint)
+	return nil
+}
+
+// GetSSHKey reads the EC2 key pair of key. It returns nil when the key pair
+// no longer exists.
+func (A *AWSProvider) GetSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) (*vmconfig.SSHKeyConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	output, err := awsClient.ec2Service(key.Region).DescribeKeyPairsWithContext(ctx, &ec2.DescribeKeyPairsInput{
+		KeyNames:         []*string{aws.String(key.ID)},
+		IncludePublicKey: aws.Bool(true),
+	})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidKeyPair.NotFound") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading key pair %s: %w", key.ID, err)
+	}
+	if len(output.KeyPairs) == 0 {
+		return nil, nil
+	}
+	pair := output.KeyPairs[0]
+	config := *key
+	config.Name = aws.StringValue(pair.KeyName)
+	config.Fingerprint = aws.StringValue(pair.KeyFingerprint)
+	// EC2 returns the key with the key pair name as comment.
+	if publicKey := aws.StringValue(pair.PublicKey); publicKey != "" && !sameSSHKey(publicKey, key.PublicKey) {
+		config.PublicKey = strings.TrimSpace(publicKey)
+	}
+	return &config, nil
+}
+
+// UpdateSSHKey replaces the key pair of key with its new public key. EC2
+// cannot change the material of a key pair, so it is deleted and imported
+// again under the same name: the servers referencing it are kept, and the
+// servers launched from now on get the new key. When the import fails, the
+// previous key is imported back so the key pair does not go missing.
+func (A *AWSProvider) UpdateSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(key.Region)
+	output, err := ec2Svc.DescribeKeyPairsWithContext(ctx, &ec2.DescribeKeyPairsInput{
+		KeyNames:         []*string{aws.String(key.ID)},
+		IncludePublicKey: aws.Bool(true),
+	})
+	if err != nil && !isAWSNotFound(err, "InvalidKeyPair.NotFound") {
+		return fmt.Errorf("error reading key pair %s: %w", key.ID, err)
+	}
+	var previous string
+	if output != nil && len(output.KeyPairs) > 0 {
+		previous = aws.StringValue(output.KeyPairs[0].PublicKey)
+	}
+	_, err = ec2Svc.DeleteKeyPairWithContext(ctx, &ec2.DeleteKeyPairInput{KeyName: aws.String(key.ID)})
+	if err != nil && !isAWSNotFound(err, "InvalidKeyPair.NotFound") {
+		return fmt.Errorf("error deleting key pair %s: %w", key.ID, err)
+	}
+	err = A.importSSHKey(ctx, ec2Svc, key)
+	if err != nil && previous != "" {
+		_, restoreErr := ec2Svc.ImportKeyPairWithContext(ctx, &ec2.ImportKeyPairInput{
+			KeyName:           aws.String(key.ID),
+			PublicKeyMaterial: []byte(previous),
+		})
+		if restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("error importing the previous key of key pair %s: %w", key.ID, restoreErr))
+		}
+	}
+	return err
+}
+
+// DeleteSSHKey deletes the EC2 key pair of key. Running servers keep the key
+// in their authorized_keys.
+func (A *AWSProvider) DeleteSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	_, err := awsClient.ec2Service(key.Region).DeleteKeyPairWithContext(ctx, &ec2.DeleteKeyPairInput{KeyName: aws.String(key.ID)})
+	if err != nil && !isAWSNotFound(err, "InvalidKeyPair.NotFound") {
+		return fmt.Errorf("error deleting key pair %s: %w", key.ID, err)
+	}
+	return nil
+}
+
+// gcpSSHKeyEntry formats key as a line of the ssh-keys metadata. The marked
+// key name replaces the comment of the key to find the line again.
+func gcpSSHKeyEntry(key *vmconfig.SSHKeyConfig) (string, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH public key: %w", err)
+	}
+	return fmt.Sprintf("%s:%s %s%s", key.User, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), gcpSSHKeyMarker, key.Name), nil
+}
+
+// gcpSSHKeyComment returns the comment of an ssh-keys metadata line, or ""
+// when the line has none.
+func gcpSSHKeyComment(line string) string {
+	_, key, ok := strings.Cut(line, ":")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(key)
+	if len(fields) != 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+// gcpSSHKeyName returns the name of the key of an ssh-keys metadata line, or
+// "" when the line was not written by cloudfusion_ssh_key.
+func gcpSSHKeyName(line string) string {
+	comment := gcpSSHKeyComment(line)
+	if !strings.HasPrefix(comment, gcpSSHKeyMarker) {
+		return ""
+	}
+	return strings.TrimPrefix(comment, gcpSSHKeyMarker)
+}
+
+// replaceSSHKeyEntry returns the ssh-keys metadata value with the line of the
+// key name replaced by entry, or removed when entry is empty. The entry is
+// appended when the key has no line yet.
+func replaceSSHKeyEntry(value, name, entry string) string {
+	var lines []string
+	replaced := false
+	for _, line := range strings.Split(value, "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case gcpSSHKeyName(line) == name:
+			if entry != "" && !replaced {
+				lines = append(lines, entry)
+			}
+			replaced = true
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if !replaced && entry != "" {
+		lines = append(lines, entry)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func metadataItem(metadata *compute.Metadata, key string) string {
+	if metadata == nil {
+		return ""
+	}
+	for _, item := range metadata.Items {
+		if item.Key == key && item.Value != nil {
+			return *item.Value
+		}
+	}
+	return ""
+}
+
+// setProjectSSHKey writes entry as the line of the key name in the project
+// ssh-keys metadata, which every server of the project picks up unless it
+// blocks project keys. The metadata fingerprint guards against concurrent
+// changes, which are retried.
+func (G *GCProvider) setProjectSSHKey(ctx context.Context, client interface{}, projectID, name, entry string) error {
+	computeService := client.(*GCPClient).client
+	for {
+		project, err := computeService.Projects.Get(projectID).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error reading project %s: %w", projectID, err)
+		}
+		metadata := project.CommonInstanceMetadata
+		if metadata == nil {
+			metadata = &compute.Metadata{}
+		}
+		setMetadataItem(metadata, "ssh-keys", replaceSSHKeyEntry(metadataItem(metadata, "ssh-keys"), name, entry))
+		operation, err := computeService.Projects.SetCommonInstanceMetadata(projectID, metadata).Context(ctx).Do()
+		var gceErr *googleapi.Error
+		if errors.As(err, &gceErr) && gceErr.Code == 412 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(metadataRetryInterval):
+				continue
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error setting the ssh-keys metadata of %s: %w", projectID, err)
+		}
+		return G.waitForGlobalOperation(ctx, client, projectID, operation.Name)
+	}
+}
+
+// CreateSSHKey adds the key to the project ssh-keys metadata.
+func (G *GCProvider) CreateSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error {
+	entry, err := gcpSSHKeyEntry(key)
+	if err != nil {
+		return err
+	}
+	if err := G.setProjectSSHKey(ctx, client, key.GCPProjectID, key.Name, entry); err != nil {
+		return err
+	}
+	key.ID = key.Name
+	key.Fingerprint, err = SSHKeyFingerprint(key.PublicKey)
+	return err
+}
+
+// GetSSHKey reads the line of the key from the project ssh-keys metadata. It
+// returns nil when the line no longer exists.
+func (G *GCProvider) GetSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) (*vmconfig.SSHKeyConfig, error) {
+	computeService := client.(*GCPClient).client
+	project, err := computeService.Projects.Get(key.GCPProjectID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error reading project %s: %w", key.GCPProjectID, err)
+	}
+	for _, line := range strings.Split(metadataItem(project.CommonInstanceMetadata, "ssh-keys"), "\n") {
+		if gcpSSHKeyName(line) != key.ID {
+			continue
+		}
+		config := *key
+		config.Name = key.ID
+		user, publicKey, _ := strings.Cut(line, ":")
+		config.User = user
+		if !sameSSHKey(publicKey, key.PublicKey) {
+			config.PublicKey = strings.TrimSpace(publicKey)
+		}
+		config.Fingerprint, err = SSHKeyFingerprint(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &config, nil
+	}
+	return nil, nil
+}
+
+// UpdateSSHKey replaces the line of the key in the project ssh-keys metadata.
+// The guest agent of the running servers applies the change in place.
+func (G *GCProvider) UpdateSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error {
+	entry, err := gcpSSHKeyEntry(key)
+	if err != nil {
+		return err
+	}
+	if err := G.setProjectSSHKey(ctx, client, key.GCPProjectID, key.ID, entry); err != nil {
+		return err
+	}
+	key.Fingerprint, err = SSHKeyFingerprint(key.PublicKey)
+	return err
+}
+
+// DeleteSSHKey removes the line of the key from the project ssh-keys
+// metadata.
+func (G *GCProvider) DeleteSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error {
+	return G.setProjectSSHKey(ctx, client, key.GCPProjectID, key.ID, "")
+}
@@ -0,0 +1,54 @@
+package cloud
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSameSSHKey(t *testing.T) {
+	key := testPublicKey(t)
+	assert.True(t, sameSSHKey(key, key+" deploy@laptop"), "comments should be ignored")
+	assert.False(t, sameSSHKey(key, testPublicKey(t)))
+	assert.False(t, sameSSHKey(key, "not-a-key"))
+}
+
+func TestReplaceSSHKeyEntry(t *testing.T) {
+	old, rotated := testPublicKey(t), testPublicKey(t)
+	entry, err := gcpSSHKeyEntry(&vmconfig.SSHKeyConfig{Name: "deploy", User: "ci", PublicKey: old + " ci@host"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ci:"+old+" cloudfusion-deploy", entry, "the marked name should replace the comment")
+	assert.Equal(t, "deploy", gcpSSHKeyName(entry))
+	assert.Empty(t, gcpSSHKeyName("alice:"+old), "a key without a comment has no name")
+	assert.Empty(t, gcpSSHKeyName("alice:"+old+" deploy"), "a comment without the marker is not a name")
+
+	manual := "alice:" + testPublicKey(t) + " deploy"
+	value := replaceSSHKeyEntry(manual, "deploy", entry)
+	assert.Equal(t, manual+"\n"+entry, value, "a new key should be appended, leaving a line with the same comment alone")
+
+	rotatedEntry, err := gcpSSHKeyEntry(&vmconfig.SSHKeyConfig{Name: "deploy", User: "ci", PublicKey: rotated})
+	assert.NoError(t, err)
+	value = replaceSSHKeyEntry(value, "deploy", rotatedEntry)
+	assert.Equal(t, manual+"\n"+rotatedEntry, value, "a rotated key should replace its line")
+
+	assert.Equal(t, manual, replaceSSHKeyEntry(value+"\n", "deploy", ""), "a deleted key should lose its line")
+}
+
+func TestAWSUpdateSSHKeyRestoresPreviousKey(t *testing.T) {
+	previous, rotated := testPublicKey(t), testPublicKey(t)
+	f, client := newFakeEC2(t, map[string]string{
+		"DescribeKeyPairs": "<keySet><item><keyName>deploy</keyName><publicKey>" + previous + " deploy</publicKey></item></keySet>",
+		"DeleteKeyPair":    "<return>true</return>",
+	})
+	f.errors["ImportKeyPair"] = "InvalidKey.Format"
+	key := &vmconfig.SSHKeyConfig{ID: "deploy", Name: "deploy", PublicKey: rotated, Region: "eu-west-1"}
+	assert.ErrorContains(t, (&AWSProvider{}).UpdateSSHKey(context.Background(), key, client), "InvalidKey.Format")
+	assert.Equal(t, []string{"DescribeKeyPairs", "DeleteKeyPair", "ImportKeyPair", "ImportKeyPair"}, f.actions())
+	restored, err := base64.StdEncoding.DecodeString(f.calls[3].Get("PublicKeyMaterial"))
+	require.NoError(t, err)
+	assert.Equal(t, previous+" deploy", string(restored), "a failed import should bring the previous key back")
+}
@@ -0,0 +1,53 @@
+package cloud
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func testPublicKey(t *testing.T) string {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	sshPub, err := ssh.NewPublicKey(pub)
+	assert.NoError(t, err)
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+}
+
+func TestValidateSSHPublicKey(t *testing.T) {
+	assert.NoError(t, ValidateSSHPublicKey(testPublicKey(t)))
+	assert.Error(t, ValidateSSHPublicKey("ssh-rsa not-a-key"))
+}
+
+func TestGCPSSHKeys(t *testing.T) {
+	first, second := testPublicKey(t), testPublicKey(t)
+	VM := &vmconfig.VMConfig{
+		SSHUser:       "deploy",
+		SSHPublicKeys: []string{first + "\n", second},
+	}
+	assert.Equal(t, "deploy:"+first+"\ndeploy:"+second, gcpSSHKeys(VM))
+}
+
+func TestSetMetadataItem(t *testing.T) {
+	metadata := &compute.Metadata{
+		Fingerprint: "abc",
+		Items: []*compute.MetadataItems{
+			{Key: "enable-oslogin", Value: googleapi.String("FALSE")},
+			{Key: "ssh-keys", Value: googleapi.String("old")},
+		},
+	}
+	setMetadataItem(metadata, "ssh-keys", "new")
+	assert.Len(t, metadata.Items, 2, "other items should be kept")
+	assert.Equal(t, "new", *metadata.Items[1].Value)
+
+	setMetadataItem(metadata, "ssh-keys", "")
+	assert.Len(t, metadata.Items, 1, "an empty value should remove the item")
+	assert.Equal(t, "enable-oslogin", metadata.Items[0].Key)
+}
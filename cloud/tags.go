@@ -0,0 +1,126 @@
+package cloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// gceLabelMaxLength is the maximum length of a GCE label key or value.
+const gceLabelMaxLength = 63
+
+// SanitizeGCELabels converts tags into valid GCE labels. Keys and values are
+// lowercased, every character other than a lowercase letter, digit, '_' or '-'
+// becomes '_', keys that do not start with a letter are prefixed with "tag_",
+// and both are truncated to 63 characters. An error is returned when two tags
+// end up with the same label key.
+func SanitizeGCELabels(tags map[string]string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	labels := make(map[string]string, len(tags))
+	origins := make(map[string]string, len(tags))
+	for _, k := range keys {
+		label := GCELabelKey(k)
+		if origin, ok := origins[label]; ok {
+			return nil, fmt.Errorf("tags %q and %q both map to the GCE label %q", origin, k, label)
+		}
+		origins[label] = k
+		labels[label] = truncate(sanitizeGCELabelPart(tags[k]), gceLabelMaxLength)
+	}
+	return labels, nil
+}
+
+// GCELabelKey returns the GCE label key SanitizeGCELabels makes of the tag key.
+func GCELabelKey(key string) string {
+	label := sanitizeGCELabelPart(key)
+	if label == "" || label[0] < 'a' || label[0] > 'z' {
+		label = "tag_" + label
+	}
+	return truncate(label, gceLabelMaxLength)
+}
+
+func sanitizeGCELabelPart(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// awsTags converts tags into EC2 tags, sorted by key so requests are stable.
+func awsTags(tags map[string]string) []*ec2.Tag {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ec2Tags := make([]*ec2.Tag, 0, len(keys))
+	for _, k := range keys {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return ec2Tags
+}
+
+// awsTagSpecifications tags each of the given resource types with tags.
+func awsTagSpecifications(tags map[string]string, resourceTypes ...string) []*ec2.TagSpecification {
+	if len(tags) == 0 {
+		return nil
+	}
+	specs := make([]*ec2.TagSpecification, 0, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		specs = append(specs, &ec2.TagSpecification{
+			ResourceType: aws.String(resourceType),
+			Tags:         awsTags(tags),
+		})
+	}
+	return specs
+}
+
+// isIgnoredTag reports whether key is managed outside of Terraform, either
+// because the user listed it in ignore_tag_keys or because it is reserved by
+// the cloud.
+func isIgnoredTag(key string, ignoreTagKeys []string) bool {
+	if strings.HasPrefix(key, "aws:") {
+		return true
+	}
+	for _, ignored := range ignoreTagKeys {
+		if key == ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// isIgnoredLabel reports whether the GCE label key is managed outside of
+// Terraform. ignore_tag_keys lists tag keys, which become label keys the way
+// tags do.
+func isIgnoredLabel(key string, ignoreTagKeys []string) bool {
+	for _, ignored := range ignoreTagKeys {
+		if key == GCELabelKey(ignored) {
+			return true
+		}
+	}
+	return false
+}
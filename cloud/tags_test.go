@@ -0,0 +1,44 @@
+package cloud
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeGCELabels(t *testing.T) {
+	labels, err := SanitizeGCELabels(map[string]string{
+		"CostCenter":            "R&D",
+		"team":                  "platform",
+		"2fa":                   "on",
+		"app.kubernetes.io/web": "Front End",
+		strings.Repeat("k", 70): strings.Repeat("V", 70),
+	})
+	assert.NoError(t, err, "sanitizing should not return an error")
+	assert.Equal(t, map[string]string{
+		"costcenter":            "r_d",
+		"team":                  "platform",
+		"tag_2fa":               "on",
+		"app_kubernetes_io_web": "front_end",
+		strings.Repeat("k", 63): strings.Repeat("v", 63),
+	}, labels)
+}
+
+func TestSanitizeGCELabels_Collision(t *testing.T) {
+	_, err := SanitizeGCELabels(map[string]string{
+		"Owner": "alice",
+		"owner": "bob",
+	})
+	assert.Error(t, err, "keys differing only by case should collide")
+}
+
+func TestAWSTagSpecifications(t *testing.T) {
+	specs := awsTagSpecifications(map[string]string{"b": "2", "a": "1"}, "instance", "volume")
+	assert.Len(t, specs, 2)
+	for _, spec := range specs {
+		assert.Equal(t, "a", *spec.Tags[0].Key, "tags should be sorted by key")
+		assert.Equal(t, "b", *spec.Tags[1].Key, "tags should be sorted by key")
+	}
+	assert.Nil(t, awsTagSpecifications(nil, "instance"))
+}
@@ -0,0 +1,435 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// volumeDisk returns the data disk settings of vol, so volumes share the
+// conversions of the data disks of servers.
+func volumeDisk(vol *storage.VolumeConfig) vmconfig.DataDisk {
+	return vmconfig.DataDisk{SizeGB: vol.SizeGB, Type: vol.Type, IOPS: vol.IOPS, KMSKeyID: vol.KMSKeyID}
+}
+
+// awsVolumeClass maps an EBS volume type back to a performance class. Types
+// cloudfusion does not create are reported as they are.
+func awsVolumeClass(volumeType string) string {
+	switch volumeType {
+	case ec2.VolumeTypeStandard:
+		return "standard"
+	case ec2.VolumeTypeGp2, ec2.VolumeTypeGp3:
+		return "ssd"
+	case ec2.VolumeTypeIo1, ec2.VolumeTypeIo2:
+		return "provisioned_iops"
+	}
+	return volumeType
+}
+
+// gcpVolumeClass maps the URL of a GCE disk type back to a performance class.
+// Types cloudfusion does not create are reported by name.
+func gcpVolumeClass(diskType string) string {
+	switch name := lastSegment(diskType); name {
+	case "pd-standard":
+		return "standard"
+	case "pd-ssd":
+		return "ssd"
+	case "pd-extreme":
+		return "provisioned_iops"
+	default:
+		return name
+	}
+}
+
+// knownKMSKey returns known when the cloud reports it in another form, such
+// as an ARN for a key ID or a key version for a key name.
+func knownKMSKey(read, known string) string {
+	if read == "" || known == "" {
+		return read
+	}
+	if read == known || strings.HasSuffix(read, "/"+known) || strings.HasPrefix(read, known+"/") {
+		return known
+	}
+	return read
+}
+
+func isAWSNotFound(err error, code string) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == code
+}
+
+func isGCPNotFound(err error) bool {
+	var gceErr *googleapi.Error
+	return errors.As(err, &gceErr) && gceErr.Code == 404
+}
+
+// CreateVolume creates the EBS volume of vol and waits for it to be
+// available.
+func (A *AWSProvider) CreateVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(vol.Region)
+	ebs := awsEBS(volumeDisk(vol))
+	volume, err := ec2Svc.CreateVolumeWithContext(ctx, &ec2.CreateVolumeInput{
+		AvailabilityZone:  aws.String(vol.Zone),
+		Size:              ebs.VolumeSize,
+		VolumeType:        ebs.VolumeType,
+		Iops:              ebs.Iops,
+		Encrypted:         ebs.Encrypted,
+		KmsKeyId:          ebs.KmsKeyId,
+		TagSpecifications: awsTagSpecifications(vol.Tags, ec2.ResourceTypeVolume),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating volume %s: %w", vol.Name, err)
+	}
+	vol.ID = aws.StringValue(volume.VolumeId)
+	volumes := &ec2.DescribeVolumesInput{VolumeIds: []*string{volume.VolumeId}}
+	if err := ec2Svc.WaitUntilVolumeAvailableWithContext(ctx, volumes); err != nil {
+		return fmt.Errorf("error waiting for %s: %w", vol.ID, err)
+	}
+	return nil
+}
+
+func (A *AWSProvider) describeVolume(ctx context.Context, ec2Svc *ec2.EC2, volumeID string) (*ec2.Volume, error) {
+	volumes, err := ec2Svc.DescribeVolumesWithContext(ctx, &ec2.DescribeVolumesInput{VolumeIds: []*string{aws.String(volumeID)}})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidVolume.NotFound") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(volumes.Volumes) == 0 || aws.StringValue(volumes.Volumes[0].State) == ec2.VolumeStateDeleted {
+		return nil, nil
+	}
+	return volumes.Volumes[0], nil
+}
+
+// GetVolume reads the EBS volume of vol. It returns nil when the volume no
+// longer exists.
+func (A *AWSProvider) GetVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) (*storage.VolumeConfig, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	volume, err := A.describeVolume(ctx, awsClient.ec2Service(vol.Region), vol.ID)
+	if err != nil || volume == nil {
+		return nil, err
+	}
+	config := &storage.VolumeConfig{
+		ID:           vol.ID,
+		Name:         vol.Name,
+		Region:       vol.Region,
+		Zone:         aws.StringValue(volume.AvailabilityZone),
+		GCPProjectID: vol.GCPProjectID,
+		SizeGB:       aws.Int64Value(volume.Size),
+		Type:         awsVolumeClass(aws.StringValue(volume.VolumeType)),
+		Tags:         map[string]string{},
+	}
+	if config.Type == "provisioned_iops" {
+		config.IOPS = aws.Int64Value(volume.Iops)
+	}
+	if aws.BoolValue(volume.Encrypted) {
+		config.KMSKeyID = knownKMSKey(aws.StringValue(volume.KmsKeyId), vol.KMSKeyID)
+	}
+	for _, tag := range volume.Tags {
+		if key := aws.StringValue(tag.Key); !isIgnoredTag(key, vol.IgnoreTagKeys) {
+			config.Tags[key] = aws.StringValue(tag.Value)
+		}
+	}
+	return config, nil
+}
+
+// UpdateVolume modifies the size, type and IOPS of the EBS volume of vol in
+// place and updates its tags.
+func (A *AWSProvider) UpdateVolume(ctx context.Context, vol, old *storage.VolumeConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(vol.Region)
+	volume, err := A.describeVolume(ctx, ec2Svc, vol.ID)
+	if err != nil {
+		return err
+	}
+	if volume == nil {
+		return fmt.Errorf("volume %s not found", vol.ID)
+	}
+	if vol.SizeGB != old.SizeGB || vol.Type != old.Type || vol.IOPS != old.IOPS {
+		ebs := awsEBS(volumeDisk(vol))
+		_, err := ec2Svc.ModifyVolumeWithContext(ctx, &ec2.ModifyVolumeInput{
+			VolumeId:   volume.VolumeId,
+			Size:       ebs.VolumeSize,
+			VolumeType: ebs.VolumeType,
+			Iops:       ebs.Iops,
+		})
+		if err != nil {
+			return fmt.Errorf("error modifying %s: %w", vol.ID, err)
+		}
+	}
+	return A.updateTags(ctx, ec2Svc, volume.VolumeId, volume.Tags, vol.Tags, vol.IgnoreTagKeys)
+}
+
+// DeleteVolume deletes the EBS volume of vol.
+func (A *AWSProvider) DeleteVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	_, err := awsClient.ec2Service(vol.Region).DeleteVolumeWithContext(ctx, &ec2.DeleteVolumeInput{VolumeId: aws.String(vol.ID)})
+	if err != nil && !isAWSNotFound(err, "InvalidVolume.NotFound") {
+		return fmt.Errorf("error deleting %s: %w", vol.ID, err)
+	}
+	return nil
+}
+
+// AttachVolume attaches the EBS volume of att to its instance and waits for
+// the attachment.
+func (A *AWSProvider) AttachVolume(ctx context.Context, att *storage.VolumeAttachment, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(att.Region)
+	_, err := ec2Svc.AttachVolumeWithContext(ctx, &ec2.AttachVolumeInput{
+		InstanceId: aws.String(att.ServerID),
+		VolumeId:   aws.String(att.VolumeID),
+		Device:     aws.String(awsDeviceName(att.DeviceName)),
+	})
+	if err != nil {
+		return fmt.Errorf("error attaching %s to %s: %w", att.VolumeID, att.ServerID, err)
+	}
+	volumes := &ec2.DescribeVolumesInput{VolumeIds: []*string{aws.String(att.VolumeID)}}
+	if err := ec2Svc.WaitUntilVolumeInUseWithContext(ctx, volumes); err != nil {
+		return fmt.Errorf("error waiting for %s to attach: %w", att.VolumeID, err)
+	}
+	return nil
+}
+
+// GetVolumeAttachment reads the attachment of att. It returns nil when the
+// volume is no longer attached to the instance.
+func (A *AWSProvider) GetVolumeAttachment(ctx context.Context, att *storage.VolumeAttachment, client interface{}) (*storage.VolumeAttachment, error) {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS client")
+	}
+	volume, err := A.describeVolume(ctx, awsClient.ec2Service(att.Region), att.VolumeID)
+	if err != nil || volume == nil {
+		return nil, err
+	}
+	for _, attachment := range volume.Attachments {
+		state := aws.StringValue(attachment.State)
+		if aws.StringValue(attachment.InstanceId) != att.ServerID || (state != ec2.VolumeAttachmentStateAttached && state != ec2.VolumeAttachmentStateAttaching) {
+			continue
+		}
+		config := *att
+		config.DeviceName = strings.TrimPrefix(aws.StringValue(attachment.Device), "/dev/")
+		if strings.HasPrefix(att.DeviceName, "/dev/") {
+			config.DeviceName = aws.StringValue(attachment.Device)
+		}
+		return &config, nil
+	}
+	return nil, nil
+}
+
+// DetachVolume detaches the EBS volume of att and waits for it to be
+// available. A volume already detached, or whose instance is gone, is left
+// alone.
+func (A *AWSProvider) DetachVolume(ctx context.Context, att *storage.VolumeAttachment, client interface{}) error {
+	awsClient, ok := client.(*AWSClient)
+	if !ok {
+		return fmt.Errorf("invalid AWS client")
+	}
+	ec2Svc := awsClient.ec2Service(att.Region)
+	_, err := ec2Svc.DetachVolumeWithContext(ctx, &ec2.DetachVolumeInput{
+		InstanceId: aws.String(att.ServerID),
+		VolumeId:   aws.String(att.VolumeID),
+		Device:     aws.String(awsDeviceName(att.DeviceName)),
+	})
+	if err != nil {
+		if isAWSNotFound(err, "InvalidAttachment.NotFound") || isAWSNotFound(err, "InvalidVolume.NotFound") ||
+			isAWSNotFound(err, "InvalidInstanceID.NotFound") || isAWSNotFound(err, "IncorrectState") {
+			return nil
+		}
+		return fmt.Errorf("error detaching %s from %s: %w", att.VolumeID, att.ServerID, err)
+	}
+	volumes := &ec2.DescribeVolumesInput{VolumeIds: []*string{aws.String(att.VolumeID)}}
+	if err := ec2Svc.WaitUntilVolumeAvailableWithContext(ctx, volumes); err != nil {
+		return fmt.Errorf("error waiting for %s to detach: %w", att.VolumeID, err)
+	}
+	return nil
+}
+
+// gcpDiskSource is the partial URL of a persistent disk.
+func gcpDiskSource(projectID, zone, name string) string {
+	return fmt.Sprintf("projects/%s/zones/%s/disks/%s", projectID, zone, name)
+}
+
+// CreateVolume creates the persistent disk of vol.
+func (G *GCProvider) CreateVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	labels, err := SanitizeGCELabels(vol.Tags)
+	if err != nil {
+		return err
+	}
+	disk := gcpDisk(vol.GCPProjectID, vol.Zone, vol.Name, volumeDisk(vol), labels)
+	op, err := computeService.Disks.Insert(vol.GCPProjectID, vol.Zone, disk).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error creating disk %s: %w", vol.Name, err)
+	}
+	vol.ID = vol.Name
+	return G.waitForOperation(ctx, client, vol.GCPProjectID, vol.Zone, op.Name)
+}
+
+// GetVolume reads the persistent disk of vol. It returns nil when the disk no
+// longer exists.
+func (G *GCProvider) GetVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) (*storage.VolumeConfig, error) {
+	computeService := client.(*GCPClient).client
+	disk, err := computeService.Disks.Get(vol.GCPProjectID, vol.Zone, vol.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	config := &storage.VolumeConfig{
+		ID:           vol.ID,
+		Name:         disk.Name,
+		Region:       vol.Region,
+		Zone:         lastSegment(disk.Zone),
+		GCPProjectID: vol.GCPProjectID,
+		SizeGB:       disk.SizeGb,
+		Type:         gcpVolumeClass(disk.Type),
+		Tags:         map[string]string{},
+	}
+	if config.Type == "provisioned_iops" {
+		config.IOPS = disk.ProvisionedIops
+	}
+	if disk.DiskEncryptionKey != nil {
+		config.KMSKeyID = knownKMSKey(disk.DiskEncryptionKey.KmsKeyName, vol.KMSKeyID)
+	}
+	for k, v := range disk.Labels {
+		if !isIgnoredLabel(k, vol.IgnoreTagKeys) {
+			config.Tags[k] = v
+		}
+	}
+	return config, nil
+}
+
+// UpdateVolume grows the persistent disk of vol and updates its labels. The
+// type and IOPS of a persistent disk cannot change, which the resource
+// checks when planning.
+func (G *GCProvider) UpdateVolume(ctx context.Context, vol, old *storage.VolumeConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	if vol.SizeGB != old.SizeGB {
+		op, err := computeService.Disks.Resize(vol.GCPProjectID, vol.Zone, vol.ID, &compute.DisksResizeRequest{SizeGb: vol.SizeGB}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("error resizing disk %s: %w", vol.ID, err)
+		}
+		if err := G.waitForOperation(ctx, client, vol.GCPProjectID, vol.Zone, op.Name); err != nil {
+			return err
+		}
+	}
+	disk, err := computeService.Disks.Get(vol.GCPProjectID, vol.Zone, vol.ID).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	labels, err := gcpLabelsKeepingIgnored(vol.Tags, disk.Labels, vol.IgnoreTagKeys)
+	if err != nil {
+		return err
+	}
+	op, err := computeService.Disks.SetLabels(vol.GCPProjectID, vol.Zone, vol.ID, &compute.ZoneSetLabelsRequest{
+		Labels:           labels,
+		LabelFingerprint: disk.LabelFingerprint,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error setting the labels of disk %s: %w", vol.ID, err)
+	}
+	return G.waitForOperation(ctx, client, vol.GCPProjectID, vol.Zone, op.Name)
+}
+
+// DeleteVolume deletes the persistent disk of vol.
+func (G *GCProvider) DeleteVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Disks.Delete(vol.GCPProjectID, vol.Zone, vol.ID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting disk %s: %w", vol.ID, err)
+	}
+	return G.waitForOperation(ctx, client, vol.GCPProjectID, vol.Zone, op.Name)
+}
+
+// AttachVolume attaches the persistent disk of att to its instance. The disk
+// is never deleted with the instance, since its lifecycle is the volume's.
+func (G *GCProvider) AttachVolume(ctx context.Context, att *storage.VolumeAttachment, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Instances.AttachDisk(att.GCPProjectID, att.Zone, att.ServerID, &compute.AttachedDisk{
+		Source:     gcpDiskSource(att.GCPProjectID, att.Zone, att.VolumeID),
+		DeviceName: att.DeviceName,
+		AutoDelete: false,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error attaching disk %s to %s: %w", att.VolumeID, att.ServerID, err)
+	}
+	if err := G.waitForOperation(ctx, client, att.GCPProjectID, att.Zone, op.Name); err != nil {
+		return err
+	}
+	if att.DeviceName == "" {
+		// GCE names the device; read it back so the disk can be detached.
+		attached, err := G.GetVolumeAttachment(ctx, att, client)
+		if err != nil {
+			return err
+		}
+		if attached != nil {
+			att.DeviceName = attached.DeviceName
+		}
+	}
+	return nil
+}
+
+// GetVolumeAttachment reads the attachment of att. It returns nil when the
+// disk is no longer attached to the instance.
+func (G *GCProvider) GetVolumeAttachment(ctx context.Context, att *storage.VolumeAttachment, client interface{}) (*storage.VolumeAttachment, error) {
+	computeService := client.(*GCPClient).client
+	instance, err := computeService.Instances.Get(att.GCPProjectID, att.Zone, att.ServerID).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, disk := range instance.Disks {
+		if lastSegment(disk.Source) == att.VolumeID {
+			config := *att
+			config.DeviceName = disk.DeviceName
+			return &config, nil
+		}
+	}
+	return nil, nil
+}
+
+// DetachVolume detaches the persistent disk of att. A disk whose instance is
+// gone is left alone.
+func (G *GCProvider) DetachVolume(ctx context.Context, att *storage.VolumeAttachment, client interface{}) error {
+	computeService := client.(*GCPClient).client
+	op, err := computeService.Instances.DetachDisk(att.GCPProjectID, att.Zone, att.ServerID, att.DeviceName).Context(ctx).Do()
+	if err != nil {
+		if isGCPNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error detaching disk %s from %s: %w", att.VolumeID, att.ServerID, err)
+	}
+	return G.waitForOperation(ctx, client, att.GCPProjectID, att.Zone, op.Name)
+}
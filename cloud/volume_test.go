@@ -0,0 +1,38 @@
+package cloud
+
+import (
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeClasses(t *testing.T) {
+	for class := range map[string]bool{"standard": true, "ssd": true, "provisioned_iops": true} {
+		assert.Equal(t, class, awsVolumeClass(awsDiskType(class)), "the EBS type of %s should map back", class)
+		assert.Equal(t, class, gcpVolumeClass(gcpDiskType("dantata", "us-central1-a", class)), "the GCE type of %s should map back", class)
+	}
+	assert.Equal(t, "ssd", awsVolumeClass("gp2"))
+	assert.Equal(t, "st1", awsVolumeClass("st1"), "unknown types should be reported as they are")
+	assert.Equal(t, "pd-balanced", gcpVolumeClass("https://www.googleapis.com/compute/v1/projects/dantata/zones/us-central1-a/diskTypes/pd-balanced"))
+}
+
+func TestKnownKMSKey(t *testing.T) {
+	arn := "arn:aws:kms:eu-west-1:123456789012:key/1234abcd"
+	assert.Equal(t, "1234abcd", knownKMSKey(arn, "1234abcd"), "a key ID should match its ARN")
+	assert.Equal(t, arn, knownKMSKey(arn, ""), "the key should be read when none is known")
+	version := "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+	assert.Equal(t, "projects/p/locations/l/keyRings/r/cryptoKeys/k", knownKMSKey(version, "projects/p/locations/l/keyRings/r/cryptoKeys/k"))
+	assert.Equal(t, "other", knownKMSKey("other", "1234abcd"), "a different key should be reported")
+}
+
+func TestGCPVolumeDisk(t *testing.T) {
+	vol := &storage.VolumeConfig{Name: "data", Zone: "us-central1-a", GCPProjectID: "dantata", SizeGB: 100, Type: "provisioned_iops", IOPS: 10000}
+	disk := gcpDisk(vol.GCPProjectID, vol.Zone, vol.Name, volumeDisk(vol), nil)
+	assert.Equal(t, "data", disk.Name)
+	assert.Equal(t, int64(100), disk.SizeGb)
+	assert.Equal(t, int64(10000), disk.ProvisionedIops)
+	assert.Equal(t, "projects/dantata/zones/us-central1-a/diskTypes/pd-extreme", disk.Type)
+	assert.Nil(t, disk.DiskEncryptionKey)
+	assert.Equal(t, "projects/dantata/zones/us-central1-a/disks/data", gcpDiskSource(vol.GCPProjectID, vol.Zone, vol.Name))
+}
@@ -6,10 +6,13 @@ provider "cloudfusion" {
 
 resource "cloudfusion_server" "toto" {
   name          = "example-vm"
-  region        = "europe-west1-b"
+  region        = "europe-west1"
+  zone          = "europe-west1-b"
   instance_type = "e2-small"
-  gcp_image_family = "ubuntu-2004-lts"
-  gcp_image_project = "ubuntu-os-cloud"
   gcp_project = "dantata"
-}
 
+  gcp {
+    image_family  = "ubuntu-2004-lts"
+    image_project = "ubuntu-os-cloud"
+  }
+}
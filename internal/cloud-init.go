@@ -0,0 +1,92 @@
+package multi_cloud_compute
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// awsUserDataMaxSize is the EC2 limit on user data before base64 encoding.
+	awsUserDataMaxSize = 16 * 1024
+	// gcpMetadataValueMaxSize is the GCE limit on a single metadata value.
+	gcpMetadataValueMaxSize = 256 * 1024
+)
+
+// cloudConfig is the subset of the cloud-config format exposed by the
+// cloud_init block.
+type cloudConfig struct {
+	PackageUpdate bool            `yaml:"package_update,omitempty"`
+	Packages      []string        `yaml:"packages,omitempty"`
+	WriteFiles    []cloudInitFile `yaml:"write_files,omitempty"`
+	RunCmd        []string        `yaml:"runcmd,omitempty"`
+}
+
+type cloudInitFile struct {
+	Path        string `yaml:"path"`
+	Content     string `yaml:"content"`
+	Owner       string `yaml:"owner,omitempty"`
+	Permissions string `yaml:"permissions,omitempty"`
+}
+
+// renderCloudInit renders the cloud_init block to a cloud-config document.
+func renderCloudInit(l []interface{}) (string, error) {
+	if len(l) == 0 || l[0] == nil {
+		return "", nil
+	}
+	m := l[0].(map[string]interface{})
+	config := cloudConfig{
+		PackageUpdate: m["package_update"].(bool),
+		Packages:      expandStringList(m["packages"].([]interface{})),
+		RunCmd:        expandStringList(m["runcmd"].([]interface{})),
+	}
+	for _, f := range m["write_files"].([]interface{}) {
+		file := f.(map[string]interface{})
+		config.WriteFiles = append(config.WriteFiles, cloudInitFile{
+			Path:        file["path"].(string),
+			Content:     file["content"].(string),
+			Owner:       file["owner"].(string),
+			Permissions: file["permissions"].(string),
+		})
+	}
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("error rendering cloud_init: %w", err)
+	}
+	return "#cloud-config\n" + string(out), nil
+}
+
+// userDataFor returns the user data of the server, either given verbatim in
+// user_data or rendered from the cloud_init block.
+func userDataFor(userData string, cloudInit []interface{}) (string, error) {
+	if userData != "" {
+		return userData, nil
+	}
+	return renderCloudInit(cloudInit)
+}
+
+// validateUserDataSize checks userData against the limit of cloudProvider.
+func validateUserDataSize(cloudProvider, userData string) error {
+	limit := 0
+	switch cloudProvider {
+	case "aws":
+		limit = awsUserDataMaxSize
+	case "gcp":
+		limit = gcpMetadataValueMaxSize
+	}
+	if limit > 0 && len(userData) > limit {
+		return fmt.Errorf("user data is %d bytes, %s allows at most %d", len(userData), strings.ToUpper(cloudProvider), limit)
+	}
+	return nil
+}
+
+func expandStringList(l []interface{}) []string {
+	result := make([]string, 0, len(l))
+	for _, v := range l {
+		if v != nil {
+			result = append(result, v.(string))
+		}
+	}
+	return result
+}
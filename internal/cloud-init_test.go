@@ -0,0 +1,57 @@
+package multi_cloud_compute
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderCloudInit(t *testing.T) {
+	rendered, err := renderCloudInit([]interface{}{
+		map[string]interface{}{
+			"package_update": true,
+			"packages":       []interface{}{"nginx"},
+			"runcmd":         []interface{}{"systemctl enable --now nginx"},
+			"write_files": []interface{}{
+				map[string]interface{}{
+					"path":        "/var/www/html/index.html",
+					"content":     "hello\n",
+					"owner":       "",
+					"permissions": "0644",
+				},
+			},
+		},
+	})
+	assert.NoError(t, err, "rendering should not return an error")
+	expected := `#cloud-config
+package_update: true
+packages:
+    - nginx
+write_files:
+    - path: /var/www/html/index.html
+      content: |
+        hello
+      permissions: "0644"
+runcmd:
+    - systemctl enable --now nginx
+`
+	assert.Equal(t, expected, rendered)
+}
+
+func TestUserDataFor(t *testing.T) {
+	userData, err := userDataFor("#!/bin/sh\necho hi\n", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho hi\n", userData, "user_data is passed through")
+
+	userData, err = userDataFor("", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, userData, "no user data without user_data or cloud_init")
+}
+
+func TestValidateUserDataSize(t *testing.T) {
+	script := strings.Repeat("x", awsUserDataMaxSize+1)
+	assert.Error(t, validateUserDataSize("aws", script), "AWS limits user data to 16KB")
+	assert.NoError(t, validateUserDataSize("gcp", script), "GCE allows 256KB metadata values")
+	assert.Error(t, validateUserDataSize("gcp", strings.Repeat("x", gcpMetadataValueMaxSize+1)))
+}
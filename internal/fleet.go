@@ -0,0 +1,214 @@
+package multi_cloud_compute
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fleetPlacement is a placement block of cloudfusion_fleet.
+type fleetPlacement struct {
+	Cloud        string
+	Weight       int
+	Region       string
+	Zones        []string
+	InstanceType string
+	GCPProject   string
+	SubnetID     string
+	AMI          string
+	ImageFamily  string
+	ImageProject string
+	NetworkName  string
+}
+
+// fleetSpec holds the settings shared by the servers of a fleet.
+type fleetSpec struct {
+	KeyPairName   string
+	SSHPublicKeys []string
+	SSHUser       string
+	UserData      string
+	PublicIP      string
+	FirewallTags  []string
+	Tags          map[string]string
+}
+
+// fleetMember is a server of a fleet, as tracked in the members attribute.
+type fleetMember struct {
+	Name       string
+	ID         string
+	Cloud      string
+	Region     string
+	Zone       string
+	GCPProject string
+	// PublicIPMode is the public_ip the server was created with, which
+	// decides whether deleting it releases a static address.
+	PublicIPMode string
+	PublicIP     string
+	PrivateIP    string
+	Revision     string
+}
+
+// fleetSlot is a server a fleet should have: a zone of a placement, with the
+// revision of the settings of the placement.
+type fleetSlot struct {
+	Placement int
+	Zone      string
+	Revision  string
+}
+
+func (s fleetSlot) key() string {
+	return s.Revision + "/" + s.Zone
+}
+
+func (m fleetMember) key() string {
+	return m.Revision + "/" + m.Zone
+}
+
+// fleetRevision returns a digest of the settings a server of placement p is
+// created with. The weight and the zones only decide how many servers go
+// where, and the tags are updated in place, so they are left out.
+func fleetRevision(p fleetPlacement, spec fleetSpec) string {
+	p.Weight = 0
+	p.Zones = nil
+	spec.Tags = nil
+	// json.Marshal sorts map keys, so the digest is stable.
+	encoded, _ := json.Marshal(struct {
+		Placement fleetPlacement
+		Spec      fleetSpec
+	}{p, spec})
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+// distribute splits count in proportion to weights with the largest
+// remainder method, so the shares always add up to count. Ties go to the
+// first weights.
+func distribute(count int, weights []int) []int {
+	shares := make([]int, len(weights))
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		return shares
+	}
+	remainders := make([]int, len(weights))
+	assigned := 0
+	for i, w := range weights {
+		shares[i] = count * w / total
+		remainders[i] = count * w % total
+		assigned += shares[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:count-assigned] {
+		shares[i]++
+	}
+	return shares
+}
+
+// fleetSlots returns the servers a fleet of count servers should have: the
+// share of each placement, spread evenly across its zones.
+func fleetSlots(count int, placements []fleetPlacement, spec fleetSpec) []fleetSlot {
+	weights := make([]int, len(placements))
+	for i, p := range placements {
+		weights[i] = p.Weight
+	}
+	var slots []fleetSlot
+	for i, share := range distribute(count, weights) {
+		p := placements[i]
+		revision := fleetRevision(p, spec)
+		for j := 0; j < share; j++ {
+			slots = append(slots, fleetSlot{Placement: i, Zone: p.Zones[j%len(p.Zones)], Revision: revision})
+		}
+	}
+	return slots
+}
+
+// planFleet matches the members of a fleet with the slots it should have. It
+// returns the members to keep, the slots to create servers for, and the
+// members to remove: the surplus of a zone, and the servers whose settings
+// changed.
+func planFleet(members []fleetMember, slots []fleetSlot) (keep []fleetMember, create []fleetSlot, remove []fleetMember) {
+	wanted := map[string]int{}
+	for _, slot := range slots {
+		wanted[slot.key()]++
+	}
+	for _, member := range members {
+		if wanted[member.key()] > 0 {
+			wanted[member.key()]--
+			keep = append(keep, member)
+		} else {
+			remove = append(remove, member)
+		}
+	}
+	for _, slot := range slots {
+		if wanted[slot.key()] > 0 {
+			wanted[slot.key()]--
+			create = append(create, slot)
+		}
+	}
+	return keep, create, remove
+}
+
+// memberIndex returns the number of a member named <fleet>-<number>, or -1.
+func memberIndex(name string) int {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return -1
+	}
+	index, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// memberNames returns n names for new servers of the fleet, taking the lowest
+// numbers its members do not use.
+func memberNames(fleet string, members []fleetMember, n int) []string {
+	taken := map[int]bool{}
+	for _, member := range members {
+		taken[memberIndex(member.Name)] = true
+	}
+	names := make([]string, 0, n)
+	for i := 0; len(names) < n; i++ {
+		if !taken[i] {
+			names = append(names, fmt.Sprintf("%s-%d", fleet, i))
+		}
+	}
+	return names
+}
+
+// sortMembers orders members by number.
+func sortMembers(members []fleetMember) {
+	sort.SliceStable(members, func(a, b int) bool { return memberIndex(members[a].Name) < memberIndex(members[b].Name) })
+}
+
+// forEach calls fn with 0 to n-1, running at most parallelism calls at once,
+// and returns the errors of the calls joined.
+func forEach(n, parallelism int, fn func(i int) error) error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,162 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistribute(t *testing.T) {
+	assert.Equal(t, []int{6, 4}, distribute(10, []int{60, 40}))
+	assert.Equal(t, []int{4, 3}, distribute(7, []int{60, 40}), "the largest remainder should get the extra server")
+	assert.Equal(t, []int{1, 1, 1}, distribute(3, []int{1, 1, 1}))
+	assert.Equal(t, []int{1, 0}, distribute(1, []int{1, 1}), "ties should go to the first placement")
+	assert.Equal(t, []int{0, 5}, distribute(5, []int{0, 1}))
+	assert.Equal(t, []int{0, 0}, distribute(5, []int{0, 0}))
+}
+
+func testPlacements() []fleetPlacement {
+	return []fleetPlacement{
+		{Cloud: "aws", Weight: 60, Region: "eu-west-1", Zones: []string{"eu-west-1a", "eu-west-1b"}, InstanceType: "t3.micro"},
+		{Cloud: "gcp", Weight: 40, GCPProject: "dantata", Zones: []string{"europe-west1-b"}, InstanceType: "e2-micro"},
+	}
+}
+
+func TestFleetSlots(t *testing.T) {
+	slots := fleetSlots(5, testPlacements(), fleetSpec{})
+	zones := map[string]int{}
+	for _, slot := range slots {
+		zones[slot.Zone]++
+	}
+	assert.Equal(t, map[string]int{"eu-west-1a": 2, "eu-west-1b": 1, "europe-west1-b": 2}, zones, "the servers of a placement should be spread across its zones")
+}
+
+// membersFor returns members filling slots, named in order.
+func membersFor(slots []fleetSlot) []fleetMember {
+	members := make([]fleetMember, 0, len(slots))
+	for i, name := range memberNames("web", nil, len(slots)) {
+		members = append(members, fleetMember{Name: name, Zone: slots[i].Zone, Revision: slots[i].Revision})
+	}
+	return members
+}
+
+func TestPlanFleet(t *testing.T) {
+	placements := testPlacements()
+	members := membersFor(fleetSlots(5, placements, fleetSpec{}))
+
+	keep, create, remove := planFleet(members, fleetSlots(5, placements, fleetSpec{}))
+	assert.Len(t, keep, 5)
+	assert.Empty(t, create, "a fleet matching its settings should be left alone")
+	assert.Empty(t, remove)
+
+	keep, create, remove = planFleet(members, fleetSlots(10, placements, fleetSpec{}))
+	assert.Len(t, keep, 5, "scaling up should keep every server")
+	assert.Len(t, create, 5)
+	assert.Empty(t, remove)
+
+	keep, create, remove = planFleet(members, fleetSlots(3, placements, fleetSpec{}))
+	assert.Len(t, keep, 3)
+	assert.Empty(t, create)
+	assert.Len(t, remove, 2, "scaling down should remove the surplus")
+
+	placements[1].InstanceType = "e2-small"
+	keep, create, remove = planFleet(members, fleetSlots(5, placements, fleetSpec{}))
+	assert.Len(t, keep, 3, "the servers of an unchanged placement should be kept")
+	assert.Len(t, create, 2)
+	assert.Len(t, remove, 2, "the servers of a changed placement should be replaced")
+
+	reweighted := testPlacements()
+	reweighted[0].Weight, reweighted[1].Weight = 50, 50
+	keep, create, remove = planFleet(members, fleetSlots(4, reweighted, fleetSpec{}))
+	assert.Len(t, keep, 4, "a weight change should only move the difference")
+	assert.Empty(t, create)
+	assert.Len(t, remove, 1)
+
+	_, create, remove = planFleet(members, fleetSlots(5, testPlacements(), fleetSpec{UserData: "#!/bin/sh"}))
+	assert.Len(t, create, 5, "a change of the shared settings should replace every server")
+	assert.Len(t, remove, 5)
+
+	keep, create, remove = planFleet(members, fleetSlots(5, testPlacements(), fleetSpec{Tags: map[string]string{"team": "web"}}))
+	assert.Len(t, keep, 5, "a tag change should be applied in place")
+	assert.Empty(t, create)
+	assert.Empty(t, remove)
+}
+
+func TestMemberNames(t *testing.T) {
+	members := []fleetMember{{Name: "web-0"}, {Name: "web-2"}}
+	assert.Equal(t, []string{"web-1", "web-3", "web-4"}, memberNames("web", members, 3))
+	assert.Equal(t, 12, memberIndex("web-blue-12"))
+	assert.Equal(t, -1, memberIndex("web"))
+}
+
+func TestForEach(t *testing.T) {
+	var running, peak int32
+	err := forEach(10, 3, func(i int) error {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			seen := atomic.LoadInt32(&peak)
+			if current <= seen || atomic.CompareAndSwapInt32(&peak, seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if i == 4 {
+			return errors.New("server 4 failed")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "server 4 failed")
+	assert.LessOrEqual(t, peak, int32(3), "at most parallelism calls should run at once")
+}
+
+func TestFleetMemberData(t *testing.T) {
+	p := testPlacements()[1]
+	member := fleetMember{Name: "web-1", Cloud: "gcp", Region: fleetRegion(p, "europe-west1-b"), Zone: "europe-west1-b", GCPProject: "dantata", PublicIPMode: "static"}
+	data := fleetMemberData(member, &p, &fleetSpec{SSHUser: "ci", Tags: map[string]string{"team": "web"}})
+	assert.Equal(t, "europe-west1", data.Get("region"), "the region should come from the GCE zone")
+	assert.Equal(t, "e2-micro", data.Get("instance_type"))
+	assert.Equal(t, "static", data.Get("public_ip"))
+	assert.Equal(t, "running", data.Get("power_state"), "server defaults should apply")
+	assert.Equal(t, "ci", data.Get("ssh_user"))
+	assert.Equal(t, "europe-west1-b", data.Get("zone"))
+
+	data = fleetMemberData(member, nil, nil)
+	assert.Equal(t, "static", data.Get("public_ip"), "deleting a server should know its public IP mode")
+}
+
+func TestProviderConfigForCloud(t *testing.T) {
+	providerConfig := &ProviderConfig{Provider: backends["aws"](), clouds: &fleetClouds{configs: map[string]*ProviderConfig{}}}
+	assert.True(t, providerConfig.hasCloud("aws"))
+	assert.False(t, providerConfig.hasCloud("gcp"), "another cloud needs fleet_credentials")
+	config, err := providerConfig.forCloud("aws")
+	assert.NoError(t, err)
+	assert.Same(t, providerConfig, config, "the selected cloud should use the provider client")
+	_, err = providerConfig.forCloud("gcp")
+	assert.Error(t, err)
+
+	providerConfig.FleetCredentials = map[string]string{"gcp": "{}", "azure": "{}"}
+	assert.True(t, providerConfig.hasCloud("gcp"))
+	assert.False(t, providerConfig.hasCloud("azure"), "only supported clouds can host servers")
+}
+
+func TestFleetMemberNamesCheckedAtPlan(t *testing.T) {
+	r := resourceFleet()
+	name := "w" + strings.Repeat("e", 59) + "b"
+	placement := []interface{}{map[string]interface{}{"cloud": "aws", "weight": 1, "region": "eu-west-1", "zones": []interface{}{"eu-west-1a"}, "instance_type": "t3.micro"}}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{"name": name, "desired_count": 10, "placement": placement})
+	aws := &ProviderConfig{Provider: backends["aws"]()}
+
+	_, err := r.SimpleDiff(context.Background(), &terraform.InstanceState{}, config, aws)
+	assert.NoError(t, err, "the servers of a new fleet are numbered from 0")
+
+	state := &terraform.InstanceState{ID: name, Attributes: map[string]string{"id": name, "name": name, "members.#": "1", "members.0.name": name + "-0"}}
+	_, err = r.SimpleDiff(context.Background(), state, config, aws)
+	assert.ErrorContains(t, err, name+"-10", "a replacement numbers the new servers after the current ones")
+}
@@ -0,0 +1,56 @@
+package multi_cloud_compute
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+)
+
+const (
+	// defaultNamePrefix is used when neither name nor name_prefix is set.
+	defaultNamePrefix = "cloudfusion-"
+	// nameSuffixLength is the number of random characters appended to a prefix.
+	nameSuffixLength = 8
+	// nameMaxLength is the RFC1035 label limit enforced by Compute Engine.
+	nameMaxLength     = 63
+	nameSuffixCharset = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var (
+	namePattern   = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+	prefixPattern = regexp.MustCompile(`^[a-z][-a-z0-9]*$`)
+)
+
+// generateName appends a random lowercase suffix to prefix. The suffix only
+// uses letters and digits, so a valid prefix always gives a valid GCE name.
+func generateName(prefix string) (string, error) {
+	suffix := make([]byte, nameSuffixLength)
+	for i := range suffix {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(nameSuffixCharset))))
+		if err != nil {
+			return "", fmt.Errorf("error generating name: %w", err)
+		}
+		suffix[i] = nameSuffixCharset[n.Int64()]
+	}
+	return prefix + string(suffix), nil
+}
+
+// validateName checks name against the RFC1035 label rules enforced by
+// Compute Engine. They apply on every cloud, so that a configuration moves
+// between clouds without renaming its resources.
+func validateName(name string) error {
+	if len(name) > nameMaxLength || !namePattern.MatchString(name) {
+		return fmt.Errorf("name %q must be 1-%d characters of lowercase letters, digits and hyphens, start with a letter and not end with a hyphen", name, nameMaxLength)
+	}
+	return nil
+}
+
+// validateNamePrefix checks that every name generated from prefix satisfies
+// validateName.
+func validateNamePrefix(prefix string) error {
+	if len(prefix)+nameSuffixLength > nameMaxLength || !prefixPattern.MatchString(prefix) {
+		return fmt.Errorf("name_prefix %q must be 1-%d characters of lowercase letters, digits and hyphens and start with a letter", prefix, nameMaxLength-nameSuffixLength)
+	}
+	return nil
+}
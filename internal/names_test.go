@@ -0,0 +1,35 @@
+package multi_cloud_compute
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateName(t *testing.T) {
+	name, err := generateName("web-")
+	assert.NoError(t, err, "generating a name should not return an error")
+	assert.True(t, strings.HasPrefix(name, "web-"), "name should keep the prefix")
+	assert.Len(t, name, len("web-")+nameSuffixLength)
+	assert.NoError(t, validateName(name), "a generated name should be valid")
+
+	other, _ := generateName("web-")
+	assert.NotEqual(t, name, other, "generated names should not collide")
+}
+
+func TestValidateName(t *testing.T) {
+	assert.NoError(t, validateName("example-vm-1"))
+	assert.Error(t, validateName("Example_VM"), "GCE names are lowercase RFC1035")
+	assert.Error(t, validateName("1-vm"), "GCE names start with a letter")
+	assert.Error(t, validateName("vm-"), "GCE names do not end with a hyphen")
+	assert.Error(t, validateName(strings.Repeat("a", 64)), "GCE names are at most 63 characters")
+}
+
+func TestValidateNamePrefix(t *testing.T) {
+	assert.NoError(t, validateNamePrefix("web-"))
+	assert.Error(t, validateNamePrefix("Web-"))
+	assert.Error(t, validateNamePrefix(strings.Repeat("a", 56)), "the prefix must leave room for the suffix")
+	assert.NoError(t, validateNamePrefix(strings.Repeat("a", 55)))
+	assert.Error(t, validateNamePrefix("web_"), "names apply the GCE rules on every cloud")
+}
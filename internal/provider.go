@@ -3,15 +3,30 @@ package multi_cloud_compute
 import (
 	"context"
 	"fmt"
+	"sync"
+
 	"github.com/Abubakarr99/multi-cloud-compute/cloud"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 type ProviderConfig struct {
-	Provider CloudProvider
-	Client   interface{}
-	Instance interface{}
+	Provider      CloudProvider
+	Client        interface{}
+	Instance      interface{}
+	DefaultTags   map[string]string
+	IgnoreTagKeys []string
+	// FleetCredentials holds the credentials of the other clouds
+	// cloudfusion_fleet places servers on, by cloud_provider name.
+	FleetCredentials map[string]string
+	clouds           *fleetClouds
+}
+
+// fleetClouds caches the configuration of each other cloud, so the client
+// of a cloud is created once however many servers a fleet places on it.
+type fleetClouds struct {
+	mu      sync.Mutex
+	configs map[string]*ProviderConfig
 }
 
 func Provider() *schema.Provider {
@@ -29,38 +44,219 @@ func Provider() *schema.Provider {
 				Description: "Credentials for authenticating to the cloud provider",
 				DefaultFunc: schema.EnvDefaultFunc("CLOUD_CREDS", nil),
 			},
+			"default_tags": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Tags applied to every resource, unless the resource sets the same key",
+			},
+			"ignore_tag_keys": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Tag keys that are managed outside of Terraform and never reported or removed. On GCP they match the labels they become",
+			},
+			"fleet_credentials": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Sensitive:   true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Credentials of the other clouds cloudfusion_fleet places servers on, by cloud provider name, such as gcp when cloud_provider is aws",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"cloudfusion_server": resourceMultiCloudCompute(),
+			"cloudfusion_server":            resourceMultiCloudCompute(),
+			"cloudfusion_network":           resourceNetwork(),
+			"cloudfusion_firewall":          resourceFirewall(),
+			"cloudfusion_volume":            resourceVolume(),
+			"cloudfusion_volume_attachment": resourceVolumeAttachment(),
+			"cloudfusion_image":             resourceImage(),
+			"cloudfusion_snapshot":          resourceSnapshot(),
+			"cloudfusion_bucket":            resourceBucket(),
+			"cloudfusion_ssh_key":           resourceSSHKey(),
+			"cloudfusion_fleet":             resourceFleet(),
+			"cloudfusion_dns_record":        resourceDNSRecord(),
+			"cloudfusion_load_balancer":     resourceLoadBalancer(),
 		},
 		ConfigureContextFunc: configureProvider,
 	}
 }
 
+// backends creates the backend of each supported cloud_provider. A backend
+// implements CloudProvider and, for the other resources, the interface of
+// that resource, such as NetworkProvider.
+var backends = map[string]func() CloudProvider{
+	"aws": func() CloudProvider { return &cloud.AWSProvider{} },
+	"gcp": func() CloudProvider { return &cloud.GCProvider{} },
+}
+
 func configureProvider(_ context.Context, data *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	var diags diag.Diagnostics
 	cloudProvider := data.Get("cloud_provider").(string)
 	credentials := data.Get("credentials").(string)
-	var provider CloudProvider
-	switch cloudProvider {
-	case "aws":
-		provider = &cloud.AWSProvider{}
-	case "gcp":
-		provider = &cloud.GCProvider{}
-	default:
+	newBackend, ok := backends[cloudProvider]
+	if !ok {
 		return nil, append(diags, diag.Diagnostic{
 			Severity: diag.Error,
 			Summary:  "Unsupported cloud provider",
 			Detail:   fmt.Sprintf("The selected cloud provider '%s' is not supported", cloudProvider),
 		})
 	}
+	provider := newBackend()
 	client, err := provider.CreateClient(credentials)
 	if err != nil {
-		diag.FromErr(err)
+		return nil, diag.FromErr(err)
 	}
 	providerConfig := &ProviderConfig{
-		Provider: provider,
-		Client:   client,
+		Provider:         provider,
+		Client:           client,
+		DefaultTags:      expandStringMap(data.Get("default_tags").(map[string]interface{})),
+		IgnoreTagKeys:    expandStringSet(data.Get("ignore_tag_keys").(*schema.Set)),
+		FleetCredentials: expandStringMap(data.Get("fleet_credentials").(map[string]interface{})),
+		clouds:           &fleetClouds{configs: map[string]*ProviderConfig{}},
 	}
 	return providerConfig, diags
 }
+
+// hasCloud reports whether servers can be placed on the named cloud: the
+// selected cloud_provider, or a cloud of fleet_credentials.
+func (p *ProviderConfig) hasCloud(name string) bool {
+	if name == p.Provider.ProviderName() {
+		return true
+	}
+	_, supported := backends[name]
+	_, configured := p.FleetCredentials[name]
+	return supported && configured
+}
+
+// forCloud returns the configuration to manage servers on the named cloud:
+// p itself for the selected cloud_provider, or p with the backend and client
+// of the cloud from fleet_credentials.
+func (p *ProviderConfig) forCloud(name string) (*ProviderConfig, error) {
+	if name == p.Provider.ProviderName() {
+		return p, nil
+	}
+	if !p.hasCloud(name) {
+		return nil, fmt.Errorf("no fleet_credentials for cloud %q", name)
+	}
+	p.clouds.mu.Lock()
+	defer p.clouds.mu.Unlock()
+	if config, ok := p.clouds.configs[name]; ok {
+		return config, nil
+	}
+	provider := backends[name]()
+	client, err := provider.CreateClient(p.FleetCredentials[name])
+	if err != nil {
+		return nil, fmt.Errorf("error creating the %s client: %w", name, err)
+	}
+	config := &ProviderConfig{
+		Provider:      provider,
+		Client:        client,
+		DefaultTags:   p.DefaultTags,
+		IgnoreTagKeys: p.IgnoreTagKeys,
+	}
+	p.clouds.configs[name] = config
+	return config, nil
+}
+
+// mergeTags returns the default tags overridden by the resource tags.
+func (p *ProviderConfig) mergeTags(tags map[string]interface{}) map[string]string {
+	merged := make(map[string]string, len(p.DefaultTags)+len(tags))
+	for k, v := range p.DefaultTags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v.(string)
+	}
+	return merged
+}
+
+// tagsKnown reports whether the planned tags are all known. The SDK reports a
+// map as known even when it is not, so its size and each value are checked.
+func tagsKnown(diff *schema.ResourceDiff) bool {
+	if !diff.NewValueKnown("tags.%") {
+		return false
+	}
+	for k := range diff.Get("tags").(map[string]interface{}) {
+		if !diff.NewValueKnown("tags." + k) {
+			return false
+		}
+	}
+	return true
+}
+
+// labeledTags merges the provider default_tags into tags for resources that
+// take labels on GCP. The name becomes the Name tag on AWS, and the tags
+// become labels on GCP.
+func (p *ProviderConfig) labeledTags(name string, tags map[string]interface{}) (map[string]string, error) {
+	merged := p.mergeTags(tags)
+	switch p.Provider.ProviderName() {
+	case "aws":
+		if _, ok := merged["Name"]; !ok {
+			merged["Name"] = name
+		}
+	case "gcp":
+		labels, err := cloud.SanitizeGCELabels(merged)
+		if err != nil {
+			return nil, err
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		return labels, nil
+	}
+	return merged, nil
+}
+
+// withoutIgnoredTags drops the keys listed in ignore_tag_keys. On GCP, where
+// the tags are labels, the keys are compared as label keys.
+func (p *ProviderConfig) withoutIgnoredTags(tags map[string]string) map[string]string {
+	filtered := make(map[string]string, len(tags))
+	for k, v := range tags {
+		ignored := false
+		for _, key := range p.IgnoreTagKeys {
+			if p.Provider.ProviderName() == "gcp" {
+				key = cloud.GCELabelKey(key)
+			}
+			if k == key {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+func expandStringMap(m map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v.(string)
+	}
+	return result
+}
+
+func expandStringSet(s *schema.Set) []string {
+	result := make([]string, 0, s.Len())
+	for _, v := range s.List() {
+		result = append(result, v.(string))
+	}
+	return result
+}
+
+// removedStrings returns the elements of old that are not in new.
+func removedStrings(old, new []string) []string {
+	kept := make(map[string]bool, len(new))
+	for _, s := range new {
+		kept[s] = true
+	}
+	var removed []string
+	for _, s := range old {
+		if !kept[s] {
+			removed = append(removed, s)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,27 @@
+package multi_cloud_compute
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	assert.NoError(t, Provider().InternalValidate(), "provider schema should be valid")
+}
+
+func TestBackends(t *testing.T) {
+	for name, newBackend := range backends {
+		backend := newBackend()
+		assert.Equal(t, name, backend.ProviderName())
+		assert.Implements(t, (*NetworkProvider)(nil), backend, "%s should support cloudfusion_network", name)
+		assert.Implements(t, (*FirewallProvider)(nil), backend, "%s should support cloudfusion_firewall", name)
+		assert.Implements(t, (*VolumeProvider)(nil), backend, "%s should support cloudfusion_volume", name)
+		assert.Implements(t, (*ImageProvider)(nil), backend, "%s should support cloudfusion_image", name)
+		assert.Implements(t, (*SnapshotProvider)(nil), backend, "%s should support cloudfusion_snapshot", name)
+		assert.Implements(t, (*BucketProvider)(nil), backend, "%s should support cloudfusion_bucket", name)
+		assert.Implements(t, (*SSHKeyProvider)(nil), backend, "%s should support cloudfusion_ssh_key", name)
+		assert.Implements(t, (*DNSProvider)(nil), backend, "%s should support cloudfusion_dns_record", name)
+		assert.Implements(t, (*LoadBalancerProvider)(nil), backend, "%s should support cloudfusion_load_balancer", name)
+	}
+}
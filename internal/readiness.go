@@ -0,0 +1,252 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	readinessInterval     = 5 * time.Second
+	readinessProbeTimeout = 10 * time.Second
+)
+
+// readinessCheck describes the wait_for block: a probe that has to succeed
+// against a new instance before create returns.
+type readinessCheck struct {
+	Type         string // tcp, ssh or http
+	Port         int
+	Path         string
+	StatusCode   int
+	User         string
+	PrivateKey   string // Optional, only used by ssh checks
+	UsePrivateIP bool
+	Timeout      time.Duration
+	Interval     time.Duration
+	Bastion      *bastionHost
+}
+
+// bastionHost is an SSH server the probes are tunnelled through.
+type bastionHost struct {
+	Host       string
+	Port       int
+	User       string
+	PrivateKey string
+	HostKey    string // authorized_keys format, not verified when empty
+}
+
+// expandReadinessCheck reads the wait_for block. It returns nil when the
+// block is not set.
+func expandReadinessCheck(l []interface{}, sshUser string) (*readinessCheck, error) {
+	if len(l) == 0 || l[0] == nil {
+		return nil, nil
+	}
+	m := l[0].(map[string]interface{})
+	timeout, err := time.ParseDuration(m["timeout"].(string))
+	if err != nil {
+		return nil, err
+	}
+	check := &readinessCheck{
+		Type:         m["type"].(string),
+		Port:         m["port"].(int),
+		Path:         m["path"].(string),
+		StatusCode:   m["status_code"].(int),
+		User:         sshUser,
+		PrivateKey:   m["private_key"].(string),
+		UsePrivateIP: m["use_private_ip"].(bool),
+		Timeout:      timeout,
+		Interval:     readinessInterval,
+	}
+	if check.Port == 0 {
+		switch check.Type {
+		case "ssh":
+			check.Port = 22
+		case "http":
+			check.Port = 80
+		default:
+			return nil, fmt.Errorf("wait_for.port is required for %s checks", check.Type)
+		}
+	}
+	if bastions, ok := m["bastion"].([]interface{}); ok && len(bastions) > 0 && bastions[0] != nil {
+		b := bastions[0].(map[string]interface{})
+		check.Bastion = &bastionHost{
+			Host:       b["host"].(string),
+			Port:       b["port"].(int),
+			User:       b["user"].(string),
+			PrivateKey: b["private_key"].(string),
+			HostKey:    b["host_key"].(string),
+		}
+	}
+	return check, nil
+}
+
+// wait probes the instance until the check passes or its timeout expires.
+// address is called before every attempt because a new instance may not have
+// an IP address yet.
+func (c *readinessCheck) wait(ctx context.Context, address func(context.Context) (string, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	var lastErr error
+	for {
+		host, err := address(ctx)
+		if err == nil && host == "" {
+			err = errors.New("the instance has no IP address yet")
+		}
+		if err == nil {
+			if err = c.probe(ctx, host); err == nil {
+				return nil
+			}
+		}
+		// An attempt cut short by the timeout says less than the one before it.
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s check did not pass within %s: %w", c.Type, c.Timeout, lastErr)
+		case <-time.After(c.Interval):
+		}
+	}
+}
+
+// probe runs a single attempt of the check against host.
+func (c *readinessCheck) probe(ctx context.Context, host string) error {
+	dial, closeDialer, err := c.dialer(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDialer()
+	addr := net.JoinHostPort(host, strconv.Itoa(c.Port))
+
+	switch c.Type {
+	case "tcp":
+		conn, err := dial(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case "ssh":
+		conn, err := dial(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return c.sshHandshake(conn, addr)
+	case "http":
+		client := &http.Client{
+			Transport: &http.Transport{DialContext: dial},
+			Timeout:   readinessProbeTimeout,
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+c.Path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.StatusCode {
+			return fmt.Errorf("GET %s returned %d, expected %d", req.URL, resp.StatusCode, c.StatusCode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown readiness check type %q", c.Type)
+	}
+}
+
+// sshHandshake logs in as c.User when a private key is set. Otherwise the
+// server only has to complete the key exchange, so a refused login counts as
+// ready.
+func (c *readinessCheck) sshHandshake(conn net.Conn, addr string) error {
+	config := &ssh.ClientConfig{
+		User:            c.User,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // The host key of a new instance is not known yet.
+		Timeout:         readinessProbeTimeout,
+	}
+	if c.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(c.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("invalid wait_for.private_key: %w", err)
+		}
+		config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	}
+	if err := conn.SetDeadline(time.Now().Add(readinessProbeTimeout)); err != nil {
+		return err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		if c.PrivateKey == "" && strings.Contains(err.Error(), "unable to authenticate") {
+			return nil
+		}
+		return err
+	}
+	return ssh.NewClient(sshConn, chans, reqs).Close()
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialer returns how to reach the instance: directly, or through the bastion
+// host. The returned function closes the bastion connection.
+func (c *readinessCheck) dialer(ctx context.Context) (dialFunc, func(), error) {
+	if c.Bastion == nil {
+		d := &net.Dialer{Timeout: readinessProbeTimeout}
+		return d.DialContext, func() {}, nil
+	}
+	client, err := c.Bastion.connect(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to bastion %s: %w", c.Bastion.Host, err)
+	}
+	dial := func(_ context.Context, network, addr string) (net.Conn, error) {
+		return client.Dial(network, addr)
+	}
+	return dial, func() { client.Close() }, nil
+}
+
+func (b *bastionHost) connect(ctx context.Context) (*ssh.Client, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(b.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bastion private_key: %w", err)
+	}
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if b.HostKey != "" {
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(b.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bastion host_key: %w", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(hostKey)
+	}
+	config := &ssh.ClientConfig{
+		User:            b.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         readinessProbeTimeout,
+	}
+	addr := net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
+	conn, err := (&net.Dialer{Timeout: readinessProbeTimeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// The handshake takes no context, so the connection is closed if ctx ends
+	// before the handshake does.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
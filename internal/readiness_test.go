@@ -0,0 +1,270 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// splitHostPort returns the host and port of a local listener address.
+func splitHostPort(t *testing.T, addr string) (string, int) {
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return host, p
+}
+
+func staticAddress(host string) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) { return host, nil }
+}
+
+func newTestCheck(checkType string, port int) *readinessCheck {
+	return &readinessCheck{
+		Type:       checkType,
+		Port:       port,
+		Path:       "/",
+		StatusCode: 200,
+		User:       "cloudfusion",
+		Timeout:    time.Second,
+		Interval:   10 * time.Millisecond,
+	}
+}
+
+// privateKeyPEM returns a new ed25519 key as PEM and as an ssh.Signer.
+func privateKeyPEM(t *testing.T) (string, ssh.Signer) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), signer
+}
+
+// startSSHServer serves SSH on a local port. With forward set it accepts any
+// public key and forwards direct-tcpip channels, like a bastion host;
+// otherwise it refuses every login.
+func startSSHServer(t *testing.T, hostKey ssh.Signer, forward bool) string {
+	config := &ssh.ServerConfig{}
+	config.PublicKeyCallback = func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+		if forward {
+			return nil, nil
+		}
+		return nil, assert.AnError
+	}
+	config.AddHostKey(hostKey)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					var target struct {
+						Host     string
+						Port     uint32
+						OrigHost string
+						OrigPort uint32
+					}
+					if newChannel.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+						newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+						continue
+					}
+					upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+					if err != nil {
+						newChannel.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					channel, channelReqs, err := newChannel.Accept()
+					if err != nil {
+						upstream.Close()
+						continue
+					}
+					go ssh.DiscardRequests(channelReqs)
+					go func() {
+						defer channel.Close()
+						defer upstream.Close()
+						go io.Copy(upstream, channel)
+						io.Copy(channel, upstream)
+					}()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestReadinessTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	host, port := splitHostPort(t, listener.Addr().String())
+
+	assert.NoError(t, newTestCheck("tcp", port).wait(context.Background(), staticAddress(host)))
+
+	// Nothing listens on the port once the listener is closed.
+	listener.Close()
+	check := newTestCheck("tcp", port)
+	check.Timeout = 500 * time.Millisecond
+	err = check.wait(context.Background(), staticAddress(host))
+	assert.ErrorContains(t, err, "tcp check did not pass within 500ms")
+}
+
+func TestReadinessWaitsForAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	host, port := splitHostPort(t, listener.Addr().String())
+
+	calls := 0
+	address := func(context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", nil
+		}
+		return host, nil
+	}
+	assert.NoError(t, newTestCheck("tcp", port).wait(context.Background(), address))
+	assert.Equal(t, 3, calls)
+}
+
+func TestReadinessHTTP(t *testing.T) {
+	healthy := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	host, port := splitHostPort(t, server.Listener.Addr().String())
+
+	check := newTestCheck("http", port)
+	check.Path = "/healthz"
+	assert.ErrorContains(t, check.probe(context.Background(), host), "returned 503, expected 200")
+
+	// The deadline cuts the last attempt short, but the error still tells why
+	// the check failed.
+	check.Timeout = 200 * time.Millisecond
+	err := check.wait(context.Background(), staticAddress(host))
+	assert.ErrorContains(t, err, "http check did not pass within 200ms")
+	assert.ErrorContains(t, err, "returned 503, expected 200")
+
+	healthy = true
+	check.Timeout = time.Second
+	assert.NoError(t, check.wait(context.Background(), staticAddress(host)))
+}
+
+func TestReadinessSSH(t *testing.T) {
+	_, hostKey := privateKeyPEM(t)
+	host, port := splitHostPort(t, startSSHServer(t, hostKey, false))
+
+	// Without a private key a refused login still proves sshd is up.
+	assert.NoError(t, newTestCheck("ssh", port).wait(context.Background(), staticAddress(host)))
+
+	clientKey, _ := privateKeyPEM(t)
+	check := newTestCheck("ssh", port)
+	check.PrivateKey = clientKey
+	assert.ErrorContains(t, check.probe(context.Background(), host), "unable to authenticate")
+}
+
+func TestReadinessThroughBastion(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer target.Close()
+	targetHost, targetPort := splitHostPort(t, target.Addr().String())
+
+	_, hostKey := privateKeyPEM(t)
+	bastionHostName, bastionPort := splitHostPort(t, startSSHServer(t, hostKey, true))
+	clientKey, _ := privateKeyPEM(t)
+
+	check := newTestCheck("tcp", targetPort)
+	check.Bastion = &bastionHost{
+		Host:       bastionHostName,
+		Port:       bastionPort,
+		User:       "jump",
+		PrivateKey: clientKey,
+		HostKey:    string(ssh.MarshalAuthorizedKey(hostKey.PublicKey())),
+	}
+	assert.NoError(t, check.wait(context.Background(), staticAddress(targetHost)))
+
+	// A bastion presenting another host key is refused.
+	_, otherKey := privateKeyPEM(t)
+	check.Bastion.HostKey = string(ssh.MarshalAuthorizedKey(otherKey.PublicKey()))
+	assert.ErrorContains(t, check.probe(context.Background(), targetHost), "error connecting to bastion")
+}
+
+func TestBastionConnectStopsWithContext(t *testing.T) {
+	// The bastion accepts the connection but never starts the handshake.
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port := splitHostPort(t, silent.Addr().String())
+	clientKey, _ := privateKeyPEM(t)
+	bastion := &bastionHost{Host: host, Port: port, User: "jump", PrivateKey: clientKey}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = bastion.connect(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), readinessProbeTimeout, "the handshake should end with the context")
+}
+
+func TestExpandReadinessCheck(t *testing.T) {
+	check, err := expandReadinessCheck(nil, "cloudfusion")
+	assert.NoError(t, err)
+	assert.Nil(t, check)
+
+	check, err = expandReadinessCheck([]interface{}{map[string]interface{}{
+		"type":           "ssh",
+		"port":           0,
+		"path":           "/",
+		"status_code":    200,
+		"private_key":    "",
+		"use_private_ip": true,
+		"timeout":        "2m",
+		"bastion":        []interface{}{},
+	}}, "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, 22, check.Port)
+	assert.Equal(t, "admin", check.User)
+	assert.Equal(t, 2*time.Minute, check.Timeout)
+	assert.True(t, check.UsePrivateIP)
+	assert.Nil(t, check.Bastion)
+}
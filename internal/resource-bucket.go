@@ -0,0 +1,236 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/cloud"
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// BucketProvider is implemented by the backends that support
+// cloudfusion_bucket.
+type BucketProvider interface {
+	CreateBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) error
+	GetBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) (*storage.BucketConfig, error)
+	UpdateBucket(ctx context.Context, b, old *storage.BucketConfig, client interface{}) error
+	DeleteBucket(ctx context.Context, b *storage.BucketConfig, client interface{}) error
+}
+
+func resourceBucket() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateBucket,
+		ReadContext:   ReadBucket,
+		UpdateContext: UpdateBucket,
+		DeleteContext: DeleteBucket,
+		Importer: &schema.ResourceImporter{
+			StateContext: importBucket,
+		},
+		Schema:        schema2.GetBucketResourceSchema(),
+		CustomizeDiff: customizeBucketDiff,
+	}
+}
+
+// bucketProvider returns the backend of the provider as a BucketProvider.
+func bucketProvider(m interface{}) (*ProviderConfig, BucketProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(BucketProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_bucket is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateBucket(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := bucketProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	b, err := createBucketConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = backend.CreateBucket(ctx, b, providerConfig.Client)
+	if b.ID != "" {
+		// A bucket whose settings failed to apply is tracked so the next
+		// apply fixes them.
+		data.SetId(b.ID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return ReadBucket(ctx, data, m)
+}
+
+func ReadBucket(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := bucketProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	b, err := createBucketConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	b, err = backend.GetBucket(ctx, b, providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if b == nil {
+		data.SetId("")
+		return nil
+	}
+	b.Tags = providerConfig.withoutIgnoredTags(b.Tags)
+	return setBucketData(b, data)
+}
+
+func UpdateBucket(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := bucketProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	b, err := createBucketConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	old := *b
+	oldVersioning, _ := data.GetChange("versioning")
+	oldRules, _ := data.GetChange("lifecycle_rule")
+	oldKey, _ := data.GetChange("kms_key_id")
+	old.Versioning = oldVersioning.(bool)
+	old.Lifecycle = lifecycleRules(oldRules.([]interface{}))
+	old.KMSKeyID = oldKey.(string)
+	if err := backend.UpdateBucket(ctx, b, &old, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return ReadBucket(ctx, data, m)
+}
+
+func DeleteBucket(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := bucketProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	b, err := createBucketConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.DeleteBucket(ctx, b, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// importBucket takes an ID of the form <region>/<name>, since the region
+// of an S3 bucket selects the endpoint to reach it. The project of a GCS
+// bucket comes from the configuration.
+func importBucket(_ context.Context, data *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	region, name, ok := strings.Cut(data.Id(), "/")
+	if !ok || region == "" || name == "" {
+		return nil, fmt.Errorf("expected an ID of the form <region or location>/<name>, got %q", data.Id())
+	}
+	if err := data.Set("region", region); err != nil {
+		return nil, err
+	}
+	data.SetId(name)
+	return []*schema.ResourceData{data}, nil
+}
+
+// bucketTags merges the provider default_tags into tags. Buckets are named
+// by their name rather than by a Name tag, and take labels on GCP.
+func bucketTags(providerConfig *ProviderConfig, tags map[string]interface{}) (map[string]string, error) {
+	merged := providerConfig.mergeTags(tags)
+	if providerConfig.Provider.ProviderName() != "gcp" {
+		return merged, nil
+	}
+	labels, err := cloud.SanitizeGCELabels(merged)
+	if err != nil {
+		return nil, err
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return labels, nil
+}
+
+func lifecycleRules(raw []interface{}) []storage.LifecycleRule {
+	rules := make([]storage.LifecycleRule, 0, len(raw))
+	for _, r := range raw {
+		rule := r.(map[string]interface{})
+		rules = append(rules, storage.LifecycleRule{
+			Prefix:         rule["prefix"].(string),
+			ExpirationDays: int64(rule["expiration_days"].(int)),
+		})
+	}
+	return rules
+}
+
+func createBucketConfig(providerConfig *ProviderConfig, data *schema.ResourceData) (*storage.BucketConfig, error) {
+	tags, err := bucketTags(providerConfig, data.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	return &storage.BucketConfig{
+		ID:            data.Id(),
+		Name:          data.Get("name").(string),
+		Region:        data.Get("region").(string),
+		GCPProjectID:  data.Get("gcp_project").(string),
+		Versioning:    data.Get("versioning").(bool),
+		Lifecycle:     lifecycleRules(data.Get("lifecycle_rule").([]interface{})),
+		KMSKeyID:      data.Get("kms_key_id").(string),
+		ForceDestroy:  data.Get("force_destroy").(bool),
+		Tags:          tags,
+		IgnoreTagKeys: providerConfig.IgnoreTagKeys,
+	}, nil
+}
+
+func setBucketData(b *storage.BucketConfig, data *schema.ResourceData) diag.Diagnostics {
+	rules := make([]interface{}, 0, len(b.Lifecycle))
+	for _, rule := range b.Lifecycle {
+		rules = append(rules, map[string]interface{}{
+			"prefix":          rule.Prefix,
+			"expiration_days": rule.ExpirationDays,
+		})
+	}
+	values := map[string]interface{}{
+		"name":           b.Name,
+		"region":         b.Region,
+		"versioning":     b.Versioning,
+		"lifecycle_rule": rules,
+		"kms_key_id":     b.KMSKeyID,
+		"tags_all":       b.Tags,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeBucketDiff checks the settings the selected cloud requires, and
+// plans tags_all.
+func customizeBucketDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	if providerConfig.Provider.ProviderName() == "gcp" && diff.Get("gcp_project").(string) == "" {
+		return fmt.Errorf("gcp_project is required on GCP")
+	}
+	if !tagsKnown(diff) {
+		return diff.SetNewComputed("tags_all")
+	}
+	tags, err := bucketTags(providerConfig, diff.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	return diff.SetNew("tags_all", tags)
+}
@@ -2,6 +2,8 @@ package multi_cloud_compute
 
 import (
 	"context"
+	"fmt"
+	"github.com/Abubakarr99/multi-cloud-compute/cloud"
 	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
 	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -19,27 +21,141 @@ type CloudProvider interface {
 	SetDataFromVM(VM *vmconfig.VMConfig, data *schema.ResourceData) diag.Diagnostics
 	VMtoMap(VM *vmconfig.VMConfig) map[string]interface{}
 	UpdateInstance(ctx context.Context, new interface{}, old interface{}, client interface{}, vmConfig *vmconfig.VMConfig) error
+	UpdateDataDisks(ctx context.Context, VM *vmconfig.VMConfig, old []vmconfig.DataDisk, client interface{}) error
+	SetPowerState(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error
+	SetDeletionProtection(ctx context.Context, VM *vmconfig.VMConfig, client interface{}) error
 }
 
 func resourceMultiCloudCompute() *schema.Resource {
 	return &schema.Resource{
-		CreateContext: CreateInstance,
-		DeleteContext: DeleteInstance,
-		UpdateContext: UpdateInstance,
-		ReadContext:   ReadInstance,
-		Schema:        schema2.GetVMResourceSchema(),
+		CreateContext:  CreateInstance,
+		DeleteContext:  DeleteInstance,
+		UpdateContext:  UpdateInstance,
+		ReadContext:    ReadInstance,
+		Schema:         schema2.GetVMResourceSchema(),
+		SchemaVersion:  schema2.VMResourceSchemaVersion,
+		StateUpgraders: schema2.VMResourceStateUpgraders(),
+		CustomizeDiff:  customizeVMDiff,
 	}
 }
 
+// customizeVMDiff checks the name and the user data size against the limits of
+// the selected cloud, replaces a renamed server that cannot keep its
+// resources, and plans
+// tags_all from the provider default_tags and the resource tags, as the
+// selected cloud will store them.
+func customizeVMDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	providerName := providerConfig.Provider.ProviderName()
+	if name := diff.Get("name").(string); name != "" && diff.NewValueKnown("name") {
+		if err := validateName(name); err != nil {
+			return err
+		}
+	}
+	// An EC2 instance is renamed in place through its Name tag, unless its
+	// ingress security group, which is named after it, would have to follow.
+	// The name of a GCE instance is fixed once created.
+	if diff.Id() != "" && diff.HasChange("name") && (providerName != "aws" || hasIngress(diff)) {
+		if err := diff.ForceNew("name"); err != nil {
+			return err
+		}
+	}
+	if prefix := diff.Get("name_prefix").(string); prefix != "" && diff.NewValueKnown("name_prefix") {
+		if err := validateNamePrefix(prefix); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range expandIngressRules(diff.Get("ingress").([]interface{})) {
+		if len(rule.Ports) > 0 && rule.Protocol != "tcp" && rule.Protocol != "udp" {
+			return fmt.Errorf("ingress ports can only be set for tcp and udp, not %s", rule.Protocol)
+		}
+		for _, ports := range rule.Ports {
+			if _, _, err := cloud.ParsePortRange(ports); err != nil {
+				return err
+			}
+		}
+	}
+	if err := customizeServiceIdentityDiff(diff, providerName); err != nil {
+		return err
+	}
+	if err := customizeCapacityDiff(diff, providerName); err != nil {
+		return err
+	}
+	if providerName == "aws" && diff.Get("power_state").(string) == "suspended" && !diff.Get("aws.0.hibernation").(bool) {
+		return fmt.Errorf("suspending an AWS instance requires aws.hibernation = true")
+	}
+	if _, ok := diff.GetOk("wait_for"); ok {
+		if diff.Get("power_state").(string) != "running" {
+			return fmt.Errorf("wait_for requires power_state = \"running\"")
+		}
+		if diff.Get("wait_for.0.type").(string) == "tcp" && diff.Get("wait_for.0.port").(int) == 0 {
+			return fmt.Errorf("wait_for.port is required for tcp checks")
+		}
+	}
+	if err := customizeDataDiskDiff(diff, providerName); err != nil {
+		return err
+	}
+	if err := customizeSSHDiff(diff, providerName); err != nil {
+		return err
+	}
+	if diff.NewValueKnown("user_data") && diff.NewValueKnown("cloud_init") {
+		userData, err := userDataFor(diff.Get("user_data").(string), diff.Get("cloud_init").([]interface{}))
+		if err != nil {
+			return err
+		}
+		if err := validateUserDataSize(providerName, userData); err != nil {
+			return err
+		}
+	}
+
+	if !tagsKnown(diff) {
+		return diff.SetNewComputed("tags_all")
+	}
+	tags := providerConfig.mergeTags(diff.Get("tags").(map[string]interface{}))
+	if providerName == "aws" {
+		if _, ok := tags["Name"]; !ok {
+			if !diff.NewValueKnown("name") || diff.Get("name").(string) == "" {
+				return diff.SetNewComputed("tags_all")
+			}
+			tags["Name"] = diff.Get("name").(string)
+		}
+	}
+	if providerName == "gcp" {
+		labels, err := cloud.SanitizeGCELabels(tags)
+		if err != nil {
+			return err
+		}
+		tags = labels
+	}
+	return diff.SetNew("tags_all", tags)
+}
+
+// hasIngress reports whether the server has ingress rules before or after the
+// change.
+func hasIngress(diff *schema.ResourceDiff) bool {
+	before, after := diff.GetChange("ingress")
+	return len(before.([]interface{})) > 0 || len(after.([]interface{})) > 0
+}
+
 func DeleteInstance(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
 	providerConfig, ok := m.(*ProviderConfig)
-	provider := providerConfig.Provider
-	providerName := provider.ProviderName()
-	client := providerConfig.Client
 	if !ok {
 		return diag.Errorf("meta is not of type CloudProvider")
 	}
-	vm, diags := createVMConfig(providerName, data)
+	if data.Get("deletion_protection").(bool) {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Server %q is protected from deletion", data.Get("name").(string)),
+			Detail:   "Set deletion_protection = false and apply before destroying or replacing this server.",
+		}}
+	}
+	provider := providerConfig.Provider
+	client := providerConfig.Client
+	vm, diags := createVMConfig(providerConfig, data)
 	if diags.HasError() {
 		return diags
 	}
@@ -57,16 +173,66 @@ func CreateInstance(ctx context.Context, data *schema.ResourceData, m interface{
 	}
 	provider := providerConfig.Provider
 	client := providerConfig.Client
-	providerName := provider.ProviderName()
-	vm, diags := createVMConfig(providerName, data)
+	if data.Get("name").(string) == "" {
+		prefix := data.Get("name_prefix").(string)
+		if prefix == "" {
+			prefix = defaultNamePrefix
+		}
+		name, err := generateName(prefix)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if err := data.Set("name", name); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	vm, diags := createVMConfig(providerConfig, data)
 	if diags.HasError() {
 		return diags
 	}
 	id, err := provider.CreateInstance(ctx, vm, client)
+	if id != "" {
+		// A half-configured instance is tracked so it gets replaced, not leaked.
+		data.SetId(id)
+	}
 	if err != nil {
 		return diag.FromErr(err)
 	}
-	data.SetId(id)
+	if err := data.Set("zone", vm.Zone); err != nil {
+		return diag.FromErr(err)
+	}
+	if vm.PowerState != "running" {
+		vm.ID = id
+		if err := provider.SetPowerState(ctx, vm, client); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	check, err := expandReadinessCheck(data.Get("wait_for").([]interface{}), vm.SSHUser)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if check != nil {
+		address := func(ctx context.Context) (string, error) {
+			instance, err := provider.GetInstance(ctx, data, client)
+			if err != nil || instance == nil {
+				return "", err
+			}
+			config := provider.GetInstanceConfig(instance, data)
+			if err := data.Set("public_ip_address", config.PublicIP); err != nil {
+				return "", err
+			}
+			if err := data.Set("private_ip_address", config.PrivateIP); err != nil {
+				return "", err
+			}
+			if check.UsePrivateIP {
+				return config.PrivateIP, nil
+			}
+			return config.PublicIP, nil
+		}
+		if err := check.wait(ctx, address); err != nil {
+			return diag.Errorf("server %s was created but is not ready: %s", id, err)
+		}
+	}
 	return nil
 }
 
@@ -78,9 +244,14 @@ func UpdateInstance(ctx context.Context, data *schema.ResourceData, m interface{
 	provider := providerConfig.Provider
 	client := providerConfig.Client
 	oldInstance, err := provider.GetInstance(ctx, data, client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 	newInstance, err := provider.NewInstance(oldInstance, data)
-	providerName := provider.ProviderName()
-	vm, diags := createVMConfig(providerName, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	vm, diags := createVMConfig(providerConfig, data)
 	if diags.HasError() {
 		return diags
 	}
@@ -88,6 +259,23 @@ func UpdateInstance(ctx context.Context, data *schema.ResourceData, m interface{
 	if err != nil {
 		return diag.FromErr(err)
 	}
+	if data.HasChange("deletion_protection") {
+		if err := provider.SetDeletionProtection(ctx, vm, client); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if data.HasChange("power_state") {
+		if err := provider.SetPowerState(ctx, vm, client); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if data.HasChange("data_disk") {
+		old, _ := data.GetChange("data_disk")
+		err = provider.UpdateDataDisks(ctx, vm, expandDataDisks(old.([]interface{})), client)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
 	return nil
 }
 
@@ -99,35 +287,44 @@ func ReadInstance(ctx context.Context, data *schema.ResourceData, m interface{})
 	provider := providerConfig.Provider
 	client := providerConfig.Client
 	instanceResource, err := provider.GetInstance(ctx, data, client)
-	config := provider.GetInstanceConfig(instanceResource, data)
 	if err != nil {
 		return diag.FromErr(err)
 	}
+	if instanceResource == nil {
+		data.SetId("")
+		return nil
+	}
+	config := provider.GetInstanceConfig(instanceResource, data)
+	config.Tags = providerConfig.withoutIgnoredTags(config.Tags)
 	return provider.SetDataFromVM(config, data)
 }
 
-func createVMConfig(providerName string, data *schema.ResourceData) (*vmconfig.VMConfig, diag.Diagnostics) {
+func createVMConfig(providerConfig *ProviderConfig, data *schema.ResourceData) (*vmconfig.VMConfig, diag.Diagnostics) {
 	var vm *vmconfig.VMConfig
 	var diags diag.Diagnostics
 
-	switch providerName {
+	switch providerConfig.Provider.ProviderName() {
 	case "aws":
 		vm = &vmconfig.VMConfig{
+			ID:           data.Id(),
 			Name:         data.Get("name").(string),
 			Region:       data.Get("region").(string),
+			Zone:         data.Get("zone").(string),
 			InstanceType: data.Get("instance_type").(string),
+			KeyPairName:  data.Get("key_pair_name").(string),
 			SubnetID:     data.Get("subnet_id").(string),
-			AWSAMI:       data.Get("aws_ami_id").(string),
+			AWS:          expandAWSOptions(data.Get("aws").([]interface{})),
 		}
 	case "gcp":
 		vm = &vmconfig.VMConfig{
-			Name:            data.Get("name").(string),
-			Region:          data.Get("region").(string),
-			InstanceType:    data.Get("instance_type").(string),
-			GCPImageFamily:  data.Get("gcp_image_family").(string),
-			GCPImageProject: data.Get("gcp_image_project").(string),
-			GCPNetworkName:  data.Get("gcp_network_name").(string),
-			GCPProjectID:    data.Get("gcp_project").(string),
+			ID:           data.Id(),
+			Name:         data.Get("name").(string),
+			Region:       data.Get("region").(string),
+			Zone:         data.Get("zone").(string),
+			InstanceType: data.Get("instance_type").(string),
+			KeyPairName:  data.Get("key_pair_name").(string),
+			GCPProjectID: data.Get("gcp_project").(string),
+			GCP:          expandGCPOptions(data.Get("gcp").([]interface{})),
 		}
 	default:
 		return nil, append(diags, diag.Diagnostic{
@@ -136,6 +333,225 @@ func createVMConfig(providerName string, data *schema.ResourceData) (*vmconfig.V
 			Detail:   "this cloud provider is not supported",
 		})
 	}
+	vm.Ingress = expandIngressRules(data.Get("ingress").([]interface{}))
+	vm.FirewallTags = expandStringList(data.Get("firewall_tags").([]interface{}))
+	if data.HasChange("firewall_tags") {
+		old, _ := data.GetChange("firewall_tags")
+		vm.RemovedFirewallTags = removedStrings(expandStringList(old.([]interface{})), vm.FirewallTags)
+	}
+	vm.Capacity = vmconfig.Capacity{
+		Model:                data.Get("capacity").(string),
+		MaxPrice:             data.Get("max_price").(string),
+		InterruptionBehavior: data.Get("interruption_behavior").(string),
+	}
+	vm.PowerState = data.Get("power_state").(string)
+	vm.DeletionProtection = data.Get("deletion_protection").(bool)
+	vm.PublicIPMode = data.Get("public_ip").(string)
+	vm.SourceImageID = data.Get("source_image_id").(string)
+	vm.SourceSnapshot = data.Get("source_snapshot").(string)
+	vm.BootDisk = expandBootDisk(data.Get("boot_disk").([]interface{}))
+	vm.ServiceIdentity = expandServiceIdentity(data.Get("service_identity").([]interface{}))
+	vm.DataDisks = expandDataDisks(data.Get("data_disk").([]interface{}))
+	vm.SSHPublicKeys = expandStringList(data.Get("ssh_public_keys").([]interface{}))
+	vm.SSHUser = data.Get("ssh_user").(string)
+	userData, err := userDataFor(data.Get("user_data").(string), data.Get("cloud_init").([]interface{}))
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+	vm.UserData = userData
+	vm.Tags = providerConfig.mergeTags(data.Get("tags").(map[string]interface{}))
+	if _, ok := vm.Tags["Name"]; !ok && providerConfig.Provider.ProviderName() == "aws" && vm.Name != "" {
+		vm.Tags["Name"] = vm.Name
+	}
+	vm.IgnoreTagKeys = providerConfig.IgnoreTagKeys
 
 	return vm, diags
 }
+
+func expandAWSOptions(l []interface{}) *vmconfig.AWSOptions {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+	return &vmconfig.AWSOptions{
+		AMI:           m["ami_id"].(string),
+		SecurityGroup: m["security_group"].(string),
+		Hibernation:   m["hibernation"].(bool),
+	}
+}
+
+func expandIngressRules(l []interface{}) []vmconfig.IngressRule {
+	rules := make([]vmconfig.IngressRule, 0, len(l))
+	for _, r := range l {
+		m := r.(map[string]interface{})
+		rules = append(rules, vmconfig.IngressRule{
+			Protocol:   m["protocol"].(string),
+			Ports:      expandStringList(m["ports"].([]interface{})),
+			CIDRBlocks: expandStringList(m["cidr_blocks"].([]interface{})),
+		})
+	}
+	return rules
+}
+
+func expandBootDisk(l []interface{}) *vmconfig.BootDisk {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+	return &vmconfig.BootDisk{
+		SizeGB:   int64(m["size_gb"].(int)),
+		Type:     m["type"].(string),
+		IOPS:     int64(m["iops"].(int)),
+		KMSKeyID: m["kms_key_id"].(string),
+	}
+}
+
+func expandServiceIdentity(l []interface{}) *vmconfig.ServiceIdentity {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+	return &vmconfig.ServiceIdentity{
+		InstanceProfile:     m["instance_profile"].(string),
+		ServiceAccountEmail: m["service_account_email"].(string),
+		Scopes:              expandStringList(m["scopes"].([]interface{})),
+	}
+}
+
+// expandDataDisks reads the data_disk blocks.
+func expandDataDisks(l []interface{}) []vmconfig.DataDisk {
+	disks := make([]vmconfig.DataDisk, 0, len(l))
+	for _, d := range l {
+		m := d.(map[string]interface{})
+		disks = append(disks, vmconfig.DataDisk{
+			DeviceName: m["device_name"].(string),
+			SizeGB:     int64(m["size_gb"].(int)),
+			Type:       m["type"].(string),
+			IOPS:       int64(m["iops"].(int)),
+			KMSKeyID:   m["kms_key_id"].(string),
+			AutoDelete: m["auto_delete"].(bool),
+		})
+	}
+	return disks
+}
+
+func expandGCPOptions(l []interface{}) *vmconfig.GCPOptions {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+	m := l[0].(map[string]interface{})
+	return &vmconfig.GCPOptions{
+		ImageFamily:  m["image_family"].(string),
+		ImageProject: m["image_project"].(string),
+		NetworkName:  m["network_name"].(string),
+	}
+}
+
+// customizeSSHDiff validates the SSH keys. EC2 only reads the key pair at
+// launch, so on AWS a key change replaces the server, while GCE picks up
+// ssh-keys metadata changes in place.
+func customizeSSHDiff(diff *schema.ResourceDiff, providerName string) error {
+	if !diff.NewValueKnown("ssh_public_keys") {
+		return nil
+	}
+	keys := expandStringList(diff.Get("ssh_public_keys").([]interface{}))
+	for _, key := range keys {
+		if err := cloud.ValidateSSHPublicKey(key); err != nil {
+			return err
+		}
+	}
+	if providerName != "aws" {
+		return nil
+	}
+	if len(keys) > 1 {
+		return fmt.Errorf("AWS attaches a single key pair to a server, got %d ssh_public_keys", len(keys))
+	}
+	if len(keys) > 0 && diff.Get("key_pair_name").(string) != "" {
+		return fmt.Errorf("key_pair_name and ssh_public_keys cannot both be set on AWS")
+	}
+	for _, key := range []string{"ssh_public_keys", "key_pair_name"} {
+		if diff.Id() != "" && diff.HasChange(key) {
+			if err := diff.ForceNew(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// customizeDataDiskDiff rejects disk settings the cloud cannot apply, before
+// the apply detaches or deletes any disk.
+func customizeDataDiskDiff(diff *schema.ResourceDiff, providerName string) error {
+	if bootDisk := expandBootDisk(diff.Get("boot_disk").([]interface{})); bootDisk != nil {
+		if bootDisk.Type == "provisioned_iops" && bootDisk.IOPS == 0 {
+			return fmt.Errorf("boot disk: iops is required for provisioned_iops disks")
+		}
+	}
+	old, new := diff.GetChange("data_disk")
+	previous := map[string]vmconfig.DataDisk{}
+	for _, disk := range expandDataDisks(old.([]interface{})) {
+		previous[disk.DeviceName] = disk
+	}
+	seen := map[string]bool{}
+	for i, disk := range expandDataDisks(new.([]interface{})) {
+		if disk.DeviceName != "" && seen[disk.DeviceName] {
+			return fmt.Errorf("data disk %s is defined twice", disk.DeviceName)
+		}
+		seen[disk.DeviceName] = true
+		if disk.Type == "provisioned_iops" && disk.IOPS == 0 {
+			return fmt.Errorf("data disk %s: iops is required for provisioned_iops disks", disk.DeviceName)
+		}
+		old, ok := previous[disk.DeviceName]
+		if !ok {
+			continue
+		}
+		if disk.SizeGB < old.SizeGB {
+			return fmt.Errorf("data disk %s cannot shrink from %d to %d GB", disk.DeviceName, old.SizeGB, disk.SizeGB)
+		}
+		known := func(k string) bool { return diff.NewValueKnown(fmt.Sprintf("data_disk.%d.%s", i, k)) }
+		if disk.KMSKeyID != old.KMSKeyID && known("kms_key_id") {
+			return fmt.Errorf("the encryption key of data disk %s cannot be changed", disk.DeviceName)
+		}
+		if providerName == "gcp" && (disk.Type != old.Type || disk.IOPS != old.IOPS) && known("type") && known("iops") {
+			return fmt.Errorf("the type and iops of data disk %s cannot be changed on GCP", disk.DeviceName)
+		}
+	}
+	return nil
+}
+
+// customizeServiceIdentityDiff rejects service_identity settings the cloud of
+// the provider has no use for.
+func customizeServiceIdentityDiff(diff *schema.ResourceDiff, providerName string) error {
+	if _, ok := diff.GetOk("service_identity"); !ok {
+		return nil
+	}
+	switch providerName {
+	case "aws":
+		if diff.Get("service_identity.0.instance_profile").(string) == "" {
+			return fmt.Errorf("service_identity.instance_profile is required on AWS")
+		}
+		if diff.Get("service_identity.0.service_account_email").(string) != "" || len(diff.Get("service_identity.0.scopes").([]interface{})) > 0 {
+			return fmt.Errorf("service_identity.service_account_email and scopes are not supported on AWS")
+		}
+	case "gcp":
+		if diff.Get("service_identity.0.instance_profile").(string) != "" {
+			return fmt.Errorf("service_identity.instance_profile is not supported on GCP")
+		}
+	}
+	return nil
+}
+
+// customizeCapacityDiff rejects spot settings without spot capacity, and a
+// max price on GCP, which bills Spot VMs at a fixed discount.
+func customizeCapacityDiff(diff *schema.ResourceDiff, providerName string) error {
+	spot := diff.Get("capacity").(string) == "spot"
+	for _, key := range []string{"max_price", "interruption_behavior"} {
+		if !spot && diff.Get(key).(string) != "" {
+			return fmt.Errorf("%s requires capacity = \"spot\"", key)
+		}
+	}
+	if providerName == "gcp" && diff.Get("max_price").(string) != "" {
+		return fmt.Errorf("max_price is not supported on GCP")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hcl2shimUnknown is how Terraform marks an unknown value in a raw config.
+const hcl2shimUnknown = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+func TestDeleteInstanceRefusesProtectedServer(t *testing.T) {
+	data := schema.TestResourceDataRaw(t, resourceMultiCloudCompute().Schema, map[string]interface{}{
+		"name":                "db-1",
+		"gcp_project":         "project",
+		"deletion_protection": true,
+	})
+	data.SetId("i-123")
+
+	diags := DeleteInstance(context.Background(), data, &ProviderConfig{})
+	assert.True(t, diags.HasError())
+	assert.Contains(t, diags[0].Summary, "protected from deletion")
+}
+
+func TestRegionChangeReplacesServer(t *testing.T) {
+	r := resourceMultiCloudCompute()
+	state := &terraform.InstanceState{ID: "i-1", Attributes: map[string]string{"id": "i-1", "name": "web", "region": "eu-west-1", "zone": "eu-west-1a"}}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{"name": "web", "region": "eu-central-1"})
+	for _, cloud := range []string{"aws", "gcp"} {
+		diff, err := r.SimpleDiff(context.Background(), state, config, &ProviderConfig{Provider: backends[cloud]()})
+		require.NoError(t, err, cloud)
+		require.NotNil(t, diff.Attributes["region"], cloud)
+		assert.Equal(t, "eu-central-1", diff.Attributes["region"].New)
+		assert.True(t, diff.Attributes["region"].RequiresNew, "the zone is picked in the region, so a new region needs a new server on %s", cloud)
+	}
+}
+
+func TestRenameReplacesServerOutsideAWS(t *testing.T) {
+	r := resourceMultiCloudCompute()
+	state := &terraform.InstanceState{ID: "i-1", Attributes: map[string]string{"id": "i-1", "name": "web-1", "region": "eu-west-1", "zone": "eu-west-1a"}}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{"name": "web-2", "region": "eu-west-1", "zone": "eu-west-1a"})
+	for cloud, replaced := range map[string]bool{"aws": false, "gcp": true} {
+		diff, err := r.SimpleDiff(context.Background(), state, config, &ProviderConfig{Provider: backends[cloud]()})
+		require.NoError(t, err, cloud)
+		require.NotNil(t, diff.Attributes["name"], cloud)
+		assert.Equal(t, "web-2", diff.Attributes["name"].New)
+		assert.Equal(t, replaced, diff.Attributes["name"].RequiresNew, "renaming a server on %s", cloud)
+	}
+
+	ingress := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name": "web-2", "region": "eu-west-1", "zone": "eu-west-1a",
+		"ingress": []interface{}{map[string]interface{}{"protocol": "tcp", "ports": []interface{}{"22"}, "cidr_blocks": []interface{}{"10.0.0.0/8"}}},
+	})
+	diff, err := r.SimpleDiff(context.Background(), state, ingress, &ProviderConfig{Provider: backends["aws"]()})
+	require.NoError(t, err)
+	require.NotNil(t, diff.Attributes["name"])
+	assert.True(t, diff.Attributes["name"].RequiresNew, "the ingress security group is named after the server")
+}
+
+func TestUnknownTagsLeaveTagsAllUnknown(t *testing.T) {
+	r := resourceMultiCloudCompute()
+	for _, tags := range []interface{}{hcl2shimUnknown, map[string]interface{}{"team": hcl2shimUnknown}} {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name": "web", "region": "eu-west-1", "zone": "eu-west-1a",
+			"tags": tags,
+		})
+		diff, err := r.SimpleDiff(context.Background(), &terraform.InstanceState{}, config, &ProviderConfig{Provider: backends["aws"]()})
+		require.NoError(t, err)
+		require.NotNil(t, diff.Attributes["tags_all.%"])
+		assert.True(t, diff.Attributes["tags_all.%"].NewComputed, "tags_all cannot be planned before the tags are known")
+		assert.Nil(t, diff.Attributes["tags_all.Name"])
+	}
+}
+
+func TestDataDiskChangesCheckedAtPlan(t *testing.T) {
+	r := resourceMultiCloudCompute()
+	state := &terraform.InstanceState{ID: "i-1", Attributes: map[string]string{
+		"id": "i-1", "name": "web", "region": "eu-west-1", "zone": "eu-west-1a",
+		"data_disk.#":             "1",
+		"data_disk.0.device_name": "sdf",
+		"data_disk.0.size_gb":     "10",
+		"data_disk.0.type":        "ssd",
+		"data_disk.0.kms_key_id":  "key-1",
+		"data_disk.0.auto_delete": "true",
+	}}
+	disk := func(diskType, key string) *terraform.ResourceConfig {
+		return terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name": "web", "region": "eu-west-1", "zone": "eu-west-1a",
+			"data_disk": []interface{}{map[string]interface{}{"device_name": "sdf", "size_gb": 20, "type": diskType, "kms_key_id": key}},
+		})
+	}
+	aws := &ProviderConfig{Provider: backends["aws"]()}
+	gcp := &ProviderConfig{Provider: backends["gcp"]()}
+
+	_, err := r.SimpleDiff(context.Background(), state, disk("ssd", "key-2"), aws)
+	assert.ErrorContains(t, err, "the encryption key of data disk sdf cannot be changed")
+	_, err = r.SimpleDiff(context.Background(), state, disk("standard", "key-1"), gcp)
+	assert.ErrorContains(t, err, "cannot be changed on GCP")
+	_, err = r.SimpleDiff(context.Background(), state, disk("standard", "key-1"), aws)
+	assert.NoError(t, err, "EBS volumes change type in place")
+	_, err = r.SimpleDiff(context.Background(), state, disk("ssd", "key-1"), gcp)
+	assert.NoError(t, err, "persistent disks grow in place")
+}
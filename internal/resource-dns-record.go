@@ -0,0 +1,265 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/cloud"
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// DNSProvider is implemented by the backends that support
+// cloudfusion_dns_record.
+type DNSProvider interface {
+	CreateDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error
+	GetDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) (*network.DNSRecordConfig, error)
+	UpdateDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error
+	DeleteDNSRecord(ctx context.Context, rec *network.DNSRecordConfig, client interface{}) error
+}
+
+func resourceDNSRecord() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateDNSRecord,
+		ReadContext:   ReadDNSRecord,
+		UpdateContext: UpdateDNSRecord,
+		DeleteContext: DeleteDNSRecord,
+		Importer: &schema.ResourceImporter{
+			StateContext: importDNSRecord,
+		},
+		Schema:        schema2.GetDNSRecordResourceSchema(),
+		CustomizeDiff: customizeDNSRecordDiff,
+	}
+}
+
+// dnsProvider returns the backend of the provider as a DNSProvider.
+func dnsProvider(m interface{}) (*ProviderConfig, DNSProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(DNSProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_dns_record is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateDNSRecord(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := dnsProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	rec, err := createDNSRecordConfig(ctx, providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.CreateDNSRecord(ctx, rec, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	data.SetId(rec.ID)
+	return setDNSRecordData(rec, data)
+}
+
+func ReadDNSRecord(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := dnsProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	rec, err := backend.GetDNSRecord(ctx, dnsRecordConfig(data), providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if rec == nil {
+		data.SetId("")
+		return nil
+	}
+	return setDNSRecordData(rec, data)
+}
+
+func UpdateDNSRecord(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := dnsProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	rec, err := createDNSRecordConfig(ctx, providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.UpdateDNSRecord(ctx, rec, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return setDNSRecordData(rec, data)
+}
+
+func DeleteDNSRecord(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := dnsProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	if err := backend.DeleteDNSRecord(ctx, dnsRecordConfig(data), providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// importDNSRecord takes an ID of the form <zone>/<name>/<type>. The project
+// of a Cloud DNS zone comes from the configuration.
+func importDNSRecord(_ context.Context, data *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.Split(data.Id(), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("expected an ID of the form <zone>/<name>/<type>, got %q", data.Id())
+	}
+	values := map[string]interface{}{"zone_id": parts[0], "name": parts[1], "type": parts[2]}
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	data.SetId(cloud.DNSRecordID(parts[0], parts[1], parts[2]))
+	return []*schema.ResourceData{data}, nil
+}
+
+// dnsRecordConfig builds the record of data with the values in the state.
+func dnsRecordConfig(data *schema.ResourceData) *network.DNSRecordConfig {
+	return &network.DNSRecordConfig{
+		ID:           data.Id(),
+		ZoneID:       data.Get("zone_id").(string),
+		GCPProjectID: data.Get("gcp_project").(string),
+		Name:         data.Get("name").(string),
+		Type:         data.Get("type").(string),
+		TTL:          int64(data.Get("ttl").(int)),
+		Values:       expandStringList(data.Get("values").([]interface{})),
+	}
+}
+
+// createDNSRecordConfig builds the record of data, taking the value from the
+// server when one is set.
+func createDNSRecordConfig(ctx context.Context, providerConfig *ProviderConfig, data *schema.ResourceData) (*network.DNSRecordConfig, error) {
+	rec := dnsRecordConfig(data)
+	if server := data.Get("server").([]interface{}); len(server) > 0 && server[0] != nil {
+		address, err := serverAddress(ctx, providerConfig, data.Get("gcp_project").(string), server[0].(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		rec.Values = []string{address}
+	}
+	return rec, nil
+}
+
+// serverAddress returns the public or private IP address of the server
+// block of a record.
+func serverAddress(ctx context.Context, providerConfig *ProviderConfig, project string, server map[string]interface{}) (string, error) {
+	id := server["id"].(string)
+	data := resourceMultiCloudCompute().Data(nil)
+	values := map[string]interface{}{
+		"region":      server["region"],
+		"zone":        server["zone"],
+		"gcp_project": project,
+	}
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			return "", err
+		}
+	}
+	data.SetId(id)
+	instance, err := providerConfig.Provider.GetInstance(ctx, data, providerConfig.Client)
+	if err != nil {
+		return "", fmt.Errorf("error reading server %s: %w", id, err)
+	}
+	if instance == nil {
+		return "", fmt.Errorf("server %s not found", id)
+	}
+	vm := providerConfig.Provider.GetInstanceConfig(instance, data)
+	address := vm.PublicIP
+	if server["address"].(string) == "private" {
+		address = vm.PrivateIP
+	}
+	if address == "" {
+		return "", fmt.Errorf("server %s has no %s IP address", id, server["address"].(string))
+	}
+	return address, nil
+}
+
+func setDNSRecordData(rec *network.DNSRecordConfig, data *schema.ResourceData) diag.Diagnostics {
+	values := map[string]interface{}{
+		"ttl":    rec.TTL,
+		"values": rec.Values,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// validateDNSValues checks values against the record type.
+func validateDNSValues(recordType string, values []string) error {
+	if recordType == "CNAME" {
+		if len(values) != 1 {
+			return fmt.Errorf("a CNAME record takes a single value, got %d", len(values))
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("an %s record needs at least one value", recordType)
+	}
+	for _, value := range values {
+		ip := net.ParseIP(value)
+		if ip == nil || (ip.To4() != nil) != (recordType == "A") {
+			return fmt.Errorf("%q is not an %s record value", value, recordType)
+		}
+	}
+	return nil
+}
+
+// customizeDNSRecordDiff checks the values against the record type, and
+// plans the value of a record following a server from its current address,
+// so the record is updated when the server is replaced or its address
+// changes.
+func customizeDNSRecordDiff(ctx context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	recordType := diff.Get("type").(string)
+	server := diff.Get("server").([]interface{})
+	if len(server) == 0 || server[0] == nil {
+		if !diff.NewValueKnown("values") {
+			return nil
+		}
+		return validateDNSValues(recordType, expandStringList(diff.Get("values").([]interface{})))
+	}
+	if recordType == "CNAME" {
+		return fmt.Errorf("a CNAME record cannot take its value from a server")
+	}
+	block := server[0].(map[string]interface{})
+	switch providerConfig.Provider.ProviderName() {
+	case "aws":
+		if block["region"].(string) == "" {
+			return fmt.Errorf("server.region is required on AWS")
+		}
+	case "gcp":
+		if block["zone"].(string) == "" {
+			return fmt.Errorf("server.zone is required on GCP")
+		}
+	}
+	if !diff.NewValueKnown("server") || block["id"].(string) == "" {
+		// The server is created or replaced in the same apply.
+		return diff.SetNewComputed("values")
+	}
+	address, err := serverAddress(ctx, providerConfig, diff.Get("gcp_project").(string), block)
+	if err != nil {
+		return err
+	}
+	if err := validateDNSValues(recordType, []string{address}); err != nil {
+		return err
+	}
+	return diff.SetNew("values", []string{address})
+}
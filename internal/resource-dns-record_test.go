@@ -0,0 +1,32 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDNSValues(t *testing.T) {
+	assert.NoError(t, validateDNSValues("A", []string{"203.0.113.10", "203.0.113.11"}))
+	assert.Error(t, validateDNSValues("A", []string{"2001:db8::1"}), "an A record takes IPv4 addresses")
+	assert.NoError(t, validateDNSValues("AAAA", []string{"2001:db8::1"}))
+	assert.Error(t, validateDNSValues("AAAA", []string{"203.0.113.10"}), "an AAAA record takes IPv6 addresses")
+	assert.Error(t, validateDNSValues("A", nil))
+	assert.NoError(t, validateDNSValues("CNAME", []string{"web.example.com."}))
+	assert.Error(t, validateDNSValues("CNAME", []string{"a.example.com.", "b.example.com."}), "a CNAME record takes a single value")
+}
+
+func TestImportedDNSRecordNameMatchesConfig(t *testing.T) {
+	r := resourceDNSRecord()
+	state := &terraform.InstanceState{ID: "Z1/web.example.com./A", Attributes: map[string]string{
+		"id": "Z1/web.example.com./A", "zone_id": "Z1", "name": "web.example.com.", "type": "A", "ttl": "300",
+		"values.#": "1", "values.0": "203.0.113.10",
+	}}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{"zone_id": "Z1", "name": "web.example.com", "type": "A", "values": []interface{}{"203.0.113.10"}})
+	diff, err := r.SimpleDiff(context.Background(), state, config, &ProviderConfig{Provider: backends["aws"]()})
+	require.NoError(t, err)
+	assert.Empty(t, diff.Attributes, "the trailing dot should not replace the record")
+}
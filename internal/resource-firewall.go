@@ -0,0 +1,266 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// gcpMaxFirewallName leaves room in the 63 characters of a GCE firewall rule
+// name for the "-in-<index>" and "-eg-deny" suffixes.
+const gcpMaxFirewallName = 55
+
+// FirewallProvider is implemented by the backends that support
+// cloudfusion_firewall.
+type FirewallProvider interface {
+	CreateFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error
+	GetFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) (*network.FirewallConfig, error)
+	UpdateFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error
+	DeleteFirewall(ctx context.Context, fw *network.FirewallConfig, client interface{}) error
+}
+
+func resourceFirewall() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateFirewall,
+		ReadContext:   ReadFirewall,
+		UpdateContext: UpdateFirewall,
+		DeleteContext: DeleteFirewall,
+		Importer: &schema.ResourceImporter{
+			StateContext: importFirewall,
+		},
+		Schema:        schema2.GetFirewallResourceSchema(),
+		CustomizeDiff: customizeFirewallDiff,
+	}
+}
+
+// firewallProvider returns the backend of the provider as a FirewallProvider.
+func firewallProvider(m interface{}) (*ProviderConfig, FirewallProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(FirewallProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_firewall is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateFirewall(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := firewallProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	fw := createFirewallConfig(providerConfig, data)
+	err := backend.CreateFirewall(ctx, fw, providerConfig.Client)
+	if fw.ID != "" {
+		// A partly created firewall is tracked so destroy cleans it up.
+		data.SetId(fw.ID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return setFirewallData(providerConfig, fw, data)
+}
+
+func ReadFirewall(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := firewallProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	fw, err := backend.GetFirewall(ctx, createFirewallConfig(providerConfig, data), providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if fw == nil {
+		data.SetId("")
+		return nil
+	}
+	fw.Tags = providerConfig.withoutIgnoredTags(fw.Tags)
+	return setFirewallData(providerConfig, fw, data)
+}
+
+func UpdateFirewall(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := firewallProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	fw := createFirewallConfig(providerConfig, data)
+	if err := backend.UpdateFirewall(ctx, fw, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return setFirewallData(providerConfig, fw, data)
+}
+
+func DeleteFirewall(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := firewallProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	if err := backend.DeleteFirewall(ctx, createFirewallConfig(providerConfig, data), providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// importFirewall takes "<region>/<security group id>" on AWS and
+// "<project>/<name>" on GCP, since the ID alone does not locate the firewall.
+func importFirewall(_ context.Context, data *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, fmt.Errorf("meta is not of type CloudProvider")
+	}
+	location, id, ok := strings.Cut(data.Id(), "/")
+	if !ok || location == "" || id == "" {
+		return nil, fmt.Errorf("expected an ID of the form <region>/<security group id> on AWS or <project>/<name> on GCP, got %q", data.Id())
+	}
+	attribute := "region"
+	if providerConfig.Provider.ProviderName() == "gcp" {
+		attribute = "gcp_project"
+	}
+	if err := data.Set(attribute, location); err != nil {
+		return nil, err
+	}
+	data.SetId(id)
+	return []*schema.ResourceData{data}, nil
+}
+
+func createFirewallConfig(providerConfig *ProviderConfig, data *schema.ResourceData) *network.FirewallConfig {
+	fw := &network.FirewallConfig{
+		ID:            data.Id(),
+		Name:          data.Get("name").(string),
+		Description:   data.Get("description").(string),
+		Region:        data.Get("region").(string),
+		GCPProjectID:  data.Get("gcp_project").(string),
+		Network:       data.Get("network").(string),
+		TargetTags:    expandStringList(data.Get("target_tags").([]interface{})),
+		Ingress:       expandFirewallRules(data.Get("ingress").([]interface{})),
+		Egress:        expandFirewallRules(data.Get("egress").([]interface{})),
+		Tags:          providerConfig.mergeTags(data.Get("tags").(map[string]interface{})),
+		IgnoreTagKeys: providerConfig.IgnoreTagKeys,
+	}
+	if _, ok := fw.Tags["Name"]; !ok && providerConfig.Provider.ProviderName() == "aws" {
+		fw.Tags["Name"] = fw.Name
+	}
+	return fw
+}
+
+func expandFirewallRules(l []interface{}) []network.FirewallRule {
+	rules := make([]network.FirewallRule, 0, len(l))
+	for _, r := range l {
+		m := r.(map[string]interface{})
+		rules = append(rules, network.FirewallRule{
+			Protocol:   m["protocol"].(string),
+			Ports:      expandStringList(m["ports"].([]interface{})),
+			CIDRBlocks: expandStringList(m["cidr_blocks"].([]interface{})),
+		})
+	}
+	return rules
+}
+
+func flattenFirewallRules(rules []network.FirewallRule) []interface{} {
+	l := make([]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		l = append(l, map[string]interface{}{
+			"protocol":    rule.Protocol,
+			"ports":       rule.Ports,
+			"cidr_blocks": rule.CIDRBlocks,
+		})
+	}
+	return l
+}
+
+// firewallResourceTags returns the tags to store in the tags attribute: the
+// tags of the cloud less the ones added by default_tags or the Name tag the
+// provider sets, unless they were configured.
+func firewallResourceTags(providerConfig *ProviderConfig, fw *network.FirewallConfig, data *schema.ResourceData) map[string]string {
+	configured := data.Get("tags").(map[string]interface{})
+	tags := make(map[string]string, len(fw.Tags))
+	for k, v := range fw.Tags {
+		if _, ok := configured[k]; !ok {
+			if defaultValue, ok := providerConfig.DefaultTags[k]; ok && defaultValue == v {
+				continue
+			}
+			if k == "Name" && v == fw.Name {
+				continue
+			}
+		}
+		tags[k] = v
+	}
+	return tags
+}
+
+func setFirewallData(providerConfig *ProviderConfig, fw *network.FirewallConfig, data *schema.ResourceData) diag.Diagnostics {
+	tags := map[string]string{}
+	tagsAll := map[string]string{}
+	if providerConfig.Provider.ProviderName() == "aws" {
+		tags = firewallResourceTags(providerConfig, fw, data)
+		tagsAll = fw.Tags
+	}
+	values := map[string]interface{}{
+		"name":        fw.Name,
+		"description": fw.Description,
+		"network":     fw.Network,
+		"target_tags": fw.TargetTags,
+		"ingress":     flattenFirewallRules(fw.Ingress),
+		"egress":      flattenFirewallRules(fw.Egress),
+		"tags":        tags,
+		"tags_all":    tagsAll,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeFirewallDiff checks the settings the selected cloud requires and
+// plans tags_all.
+func customizeFirewallDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	providerName := providerConfig.Provider.ProviderName()
+	ingress := expandFirewallRules(diff.Get("ingress").([]interface{}))
+	egress := expandFirewallRules(diff.Get("egress").([]interface{}))
+	for _, rule := range append(ingress, egress...) {
+		if len(rule.Ports) > 0 && rule.Protocol != "tcp" && rule.Protocol != "udp" {
+			return fmt.Errorf("ports are only supported with tcp and udp, not %s", rule.Protocol)
+		}
+	}
+	switch providerName {
+	case "aws":
+		if diff.Get("region").(string) == "" {
+			return fmt.Errorf("region is required on AWS")
+		}
+	case "gcp":
+		if len(ingress)+len(egress) == 0 {
+			return fmt.Errorf("a firewall needs at least one ingress or egress rule on GCP, where it is made of rules")
+		}
+		if name := diff.Get("name").(string); len(name) > gcpMaxFirewallName {
+			return fmt.Errorf("name must be at most %d characters on GCP, got %q", gcpMaxFirewallName, name)
+		}
+		if len(diff.Get("tags").(map[string]interface{})) > 0 {
+			return fmt.Errorf("tags are not supported on GCP, where firewall rules have no labels")
+		}
+	}
+	tags := map[string]string{}
+	if providerName == "aws" {
+		if !tagsKnown(diff) {
+			return diff.SetNewComputed("tags_all")
+		}
+		tags = providerConfig.mergeTags(diff.Get("tags").(map[string]interface{}))
+		if _, ok := tags["Name"]; !ok {
+			tags["Name"] = diff.Get("name").(string)
+		}
+	}
+	return diff.SetNew("tags_all", tags)
+}
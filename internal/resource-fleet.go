@@ -0,0 +1,492 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// fleetTag labels the servers of a fleet with its name. It is a valid GCE
+// label key, so it reads the same on both clouds.
+const fleetTag = "cloudfusion-fleet"
+
+// fleetSpecKeys are the attributes deciding the servers of a fleet.
+var fleetSpecKeys = []string{"desired_count", "placement", "key_pair_name", "ssh_public_keys", "ssh_user", "user_data", "public_ip", "firewall_tags"}
+
+func resourceFleet() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateFleet,
+		ReadContext:   ReadFleet,
+		UpdateContext: UpdateFleet,
+		DeleteContext: DeleteFleet,
+		Schema:        schema2.GetFleetResourceSchema(),
+		CustomizeDiff: customizeFleetDiff,
+	}
+}
+
+func CreateFleet(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return diag.Errorf("meta is not of type CloudProvider")
+	}
+	data.SetId(data.Get("name").(string))
+	return reconcileFleet(ctx, providerConfig, data)
+}
+
+func ReadFleet(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return diag.Errorf("meta is not of type CloudProvider")
+	}
+	members := expandFleetMembers(data.Get("members").([]interface{}))
+	found := make([]*fleetMember, len(members))
+	err := forEach(len(members), data.Get("parallelism").(int), func(i int) error {
+		member, err := readFleetMember(ctx, providerConfig, members[i])
+		found[i] = member
+		return err
+	})
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	// Servers deleted outside of Terraform are dropped, and replaced on the
+	// next apply.
+	var current []fleetMember
+	for _, member := range found {
+		if member != nil {
+			current = append(current, *member)
+		}
+	}
+	return setFleetMembers(current, data)
+}
+
+func UpdateFleet(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return diag.Errorf("meta is not of type CloudProvider")
+	}
+	return reconcileFleet(ctx, providerConfig, data)
+}
+
+func DeleteFleet(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return diag.Errorf("meta is not of type CloudProvider")
+	}
+	members := expandFleetMembers(data.Get("members").([]interface{}))
+	remaining, err := deleteFleetMembers(ctx, providerConfig, members, data.Get("parallelism").(int))
+	if err != nil {
+		// The servers that are left stay tracked for the next destroy.
+		if diags := setFleetMembers(remaining, data); diags.HasError() {
+			return diags
+		}
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// reconcileFleet updates the tags of the servers it keeps, creates the
+// servers the fleet is missing, then removes its surplus servers and the
+// servers whose settings changed. Servers are only removed once the new ones
+// exist, so a failed apply never shrinks the fleet.
+func reconcileFleet(ctx context.Context, providerConfig *ProviderConfig, data *schema.ResourceData) diag.Diagnostics {
+	name := data.Get("name").(string)
+	parallelism := data.Get("parallelism").(int)
+	placements := expandFleetPlacements(data.Get("placement").([]interface{}))
+	spec := expandFleetSpec(data.Get)
+	members := expandFleetMembers(data.Get("members").([]interface{}))
+	keep, create, remove := planFleet(members, fleetSlots(data.Get("desired_count").(int), placements, spec))
+
+	if data.HasChange("tags") {
+		if err := updateFleetMembers(ctx, providerConfig, name, keep, placements, spec, parallelism); err != nil {
+			return append(diag.FromErr(err), setFleetMembers(append(keep, remove...), data)...)
+		}
+	}
+	names := memberNames(name, members, len(create))
+	created := make([]*fleetMember, len(create))
+	createErr := forEach(len(create), parallelism, func(i int) error {
+		member, err := createFleetMember(ctx, providerConfig, name, names[i], placements[create[i].Placement], spec, create[i])
+		created[i] = member
+		return err
+	})
+	for _, member := range created {
+		if member != nil {
+			keep = append(keep, *member)
+		}
+	}
+	if createErr != nil {
+		return append(diag.FromErr(createErr), setFleetMembers(append(keep, remove...), data)...)
+	}
+	remaining, removeErr := deleteFleetMembers(ctx, providerConfig, remove, parallelism)
+	diags := setFleetMembers(append(keep, remaining...), data)
+	if removeErr != nil {
+		diags = append(diags, diag.FromErr(removeErr)...)
+	}
+	return diags
+}
+
+// deleteFleetMembers deletes members in parallel and returns the ones it
+// could not delete.
+func deleteFleetMembers(ctx context.Context, providerConfig *ProviderConfig, members []fleetMember, parallelism int) ([]fleetMember, error) {
+	deleted := make([]bool, len(members))
+	err := forEach(len(members), parallelism, func(i int) error {
+		config, err := providerConfig.forCloud(members[i].Cloud)
+		if err != nil {
+			return err
+		}
+		data := fleetMemberData(members[i], nil, nil)
+		vm, diags := createVMConfig(config, data)
+		if diags.HasError() {
+			return fmt.Errorf("server %s: %s", members[i].Name, diags[0].Summary)
+		}
+		if err := config.Provider.DeleteInstance(ctx, vm, config.Client); err != nil {
+			return fmt.Errorf("error deleting server %s: %w", members[i].Name, err)
+		}
+		deleted[i] = true
+		return nil
+	})
+	var remaining []fleetMember
+	for i, member := range members {
+		if !deleted[i] {
+			remaining = append(remaining, member)
+		}
+	}
+	return remaining, err
+}
+
+// updateFleetMembers makes the tags of members match spec with the server
+// update code of cloudfusion_server. Members keep the revision of their
+// placement, so the other settings they are updated with are unchanged.
+func updateFleetMembers(ctx context.Context, providerConfig *ProviderConfig, fleet string, members []fleetMember, placements []fleetPlacement, spec fleetSpec, parallelism int) error {
+	revisions := map[string]int{}
+	for i, p := range placements {
+		revisions[fleetRevision(p, spec)] = i
+	}
+	spec.Tags = mergeStringMaps(spec.Tags, map[string]string{fleetTag: fleet})
+	return forEach(len(members), parallelism, func(i int) error {
+		member := members[i]
+		config, err := providerConfig.forCloud(member.Cloud)
+		if err != nil {
+			return err
+		}
+		p := placements[revisions[member.Revision]]
+		data := fleetMemberData(member, &p, &spec)
+		old, err := config.Provider.GetInstance(ctx, data, config.Client)
+		if err != nil {
+			return fmt.Errorf("error reading server %s: %w", member.Name, err)
+		}
+		if old == nil {
+			// The server is replaced on the next apply.
+			return nil
+		}
+		updated, err := config.Provider.NewInstance(old, data)
+		if err != nil {
+			return err
+		}
+		vm, diags := createVMConfig(config, data)
+		if diags.HasError() {
+			return fmt.Errorf("server %s: %s", member.Name, diags[0].Summary)
+		}
+		if err := config.Provider.UpdateInstance(ctx, updated, old, config.Client, vm); err != nil {
+			return fmt.Errorf("error updating the tags of server %s: %w", member.Name, err)
+		}
+		return nil
+	})
+}
+
+// createFleetMember creates the server of slot with the server code of
+// cloudfusion_server. The member is returned whenever the server exists, even
+// half-configured, so it is tracked rather than leaked.
+func createFleetMember(ctx context.Context, providerConfig *ProviderConfig, fleet, name string, p fleetPlacement, spec fleetSpec, slot fleetSlot) (*fleetMember, error) {
+	config, err := providerConfig.forCloud(p.Cloud)
+	if err != nil {
+		return nil, err
+	}
+	member := fleetMember{
+		Name:         name,
+		Cloud:        p.Cloud,
+		Region:       fleetRegion(p, slot.Zone),
+		Zone:         slot.Zone,
+		GCPProject:   p.GCPProject,
+		PublicIPMode: spec.PublicIP,
+		Revision:     slot.Revision,
+	}
+	spec.Tags = mergeStringMaps(spec.Tags, map[string]string{fleetTag: fleet})
+	data := fleetMemberData(member, &p, &spec)
+	vm, diags := createVMConfig(config, data)
+	if diags.HasError() {
+		return nil, fmt.Errorf("server %s: %s", name, diags[0].Summary)
+	}
+	member.ID, err = config.Provider.CreateInstance(ctx, vm, config.Client)
+	if member.ID == "" {
+		return nil, fmt.Errorf("error creating server %s: %w", name, err)
+	}
+	if err != nil {
+		return &member, fmt.Errorf("error creating server %s: %w", name, err)
+	}
+	found, err := readFleetMember(ctx, providerConfig, member)
+	if err != nil || found == nil {
+		return &member, err
+	}
+	return found, nil
+}
+
+// readFleetMember reads the addresses of member. It returns nil when the
+// server no longer exists.
+func readFleetMember(ctx context.Context, providerConfig *ProviderConfig, member fleetMember) (*fleetMember, error) {
+	config, err := providerConfig.forCloud(member.Cloud)
+	if err != nil {
+		return nil, err
+	}
+	data := fleetMemberData(member, nil, nil)
+	instance, err := config.Provider.GetInstance(ctx, data, config.Client)
+	if err != nil {
+		return nil, fmt.Errorf("error reading server %s: %w", member.Name, err)
+	}
+	if instance == nil {
+		return nil, nil
+	}
+	vm := config.Provider.GetInstanceConfig(instance, data)
+	member.PublicIP = vm.PublicIP
+	member.PrivateIP = vm.PrivateIP
+	return &member, nil
+}
+
+// fleetMemberData returns the cloudfusion_server data of member, with the
+// settings of placement p and spec when creating it. Attributes the fleet
+// does not set take their server defaults.
+func fleetMemberData(member fleetMember, p *fleetPlacement, spec *fleetSpec) *schema.ResourceData {
+	resource := resourceMultiCloudCompute()
+	data := resource.Data(nil)
+	for k, s := range resource.Schema {
+		if s.Default != nil {
+			_ = data.Set(k, s.Default)
+		}
+	}
+	values := map[string]interface{}{
+		"name":        member.Name,
+		"region":      member.Region,
+		"zone":        member.Zone,
+		"gcp_project": member.GCPProject,
+		"public_ip":   member.PublicIPMode,
+	}
+	if p != nil {
+		values["instance_type"] = p.InstanceType
+		values["subnet_id"] = p.SubnetID
+		switch p.Cloud {
+		case "aws":
+			values["aws"] = []interface{}{map[string]interface{}{"ami_id": p.AMI}}
+		case "gcp":
+			values["gcp"] = []interface{}{map[string]interface{}{
+				"image_family":  p.ImageFamily,
+				"image_project": p.ImageProject,
+				"network_name":  p.NetworkName,
+			}}
+		}
+	}
+	if spec != nil {
+		values["key_pair_name"] = spec.KeyPairName
+		values["ssh_public_keys"] = spec.SSHPublicKeys
+		values["ssh_user"] = spec.SSHUser
+		values["user_data"] = spec.UserData
+		values["firewall_tags"] = spec.FirewallTags
+		values["tags"] = spec.Tags
+	}
+	for k, v := range values {
+		_ = data.Set(k, v)
+	}
+	data.SetId(member.ID)
+	return data
+}
+
+// fleetRegion returns the region of the servers of p in zone. GCE zones are
+// named after their region, so it is optional on GCP.
+func fleetRegion(p fleetPlacement, zone string) string {
+	if p.Region != "" || p.Cloud != "gcp" {
+		return p.Region
+	}
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return ""
+}
+
+func mergeStringMaps(maps ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func expandFleetPlacements(l []interface{}) []fleetPlacement {
+	placements := make([]fleetPlacement, 0, len(l))
+	for _, raw := range l {
+		m := raw.(map[string]interface{})
+		placements = append(placements, fleetPlacement{
+			Cloud:        m["cloud"].(string),
+			Weight:       m["weight"].(int),
+			Region:       m["region"].(string),
+			Zones:        expandStringList(m["zones"].([]interface{})),
+			InstanceType: m["instance_type"].(string),
+			GCPProject:   m["gcp_project"].(string),
+			SubnetID:     m["subnet_id"].(string),
+			AMI:          m["ami_id"].(string),
+			ImageFamily:  m["image_family"].(string),
+			ImageProject: m["image_project"].(string),
+			NetworkName:  m["network_name"].(string),
+		})
+	}
+	return placements
+}
+
+// expandFleetSpec reads the shared settings with get, the Get of either the
+// resource data or the diff.
+func expandFleetSpec(get func(string) interface{}) fleetSpec {
+	return fleetSpec{
+		KeyPairName:   get("key_pair_name").(string),
+		SSHPublicKeys: expandStringList(get("ssh_public_keys").([]interface{})),
+		SSHUser:       get("ssh_user").(string),
+		UserData:      get("user_data").(string),
+		PublicIP:      get("public_ip").(string),
+		FirewallTags:  expandStringList(get("firewall_tags").([]interface{})),
+		Tags:          expandStringMap(get("tags").(map[string]interface{})),
+	}
+}
+
+func expandFleetMembers(l []interface{}) []fleetMember {
+	members := make([]fleetMember, 0, len(l))
+	for _, raw := range l {
+		m := raw.(map[string]interface{})
+		members = append(members, fleetMember{
+			Name:         m["name"].(string),
+			ID:           m["id"].(string),
+			Cloud:        m["cloud"].(string),
+			Region:       m["region"].(string),
+			Zone:         m["zone"].(string),
+			GCPProject:   m["gcp_project"].(string),
+			PublicIPMode: m["public_ip"].(string),
+			PublicIP:     m["public_ip_address"].(string),
+			PrivateIP:    m["private_ip_address"].(string),
+			Revision:     m["revision"].(string),
+		})
+	}
+	return members
+}
+
+func setFleetMembers(members []fleetMember, data *schema.ResourceData) diag.Diagnostics {
+	sortMembers(members)
+	flattened := make([]interface{}, 0, len(members))
+	ids := make([]string, 0, len(members))
+	publicIPs := []string{}
+	privateIPs := []string{}
+	for _, member := range members {
+		flattened = append(flattened, map[string]interface{}{
+			"name":               member.Name,
+			"id":                 member.ID,
+			"cloud":              member.Cloud,
+			"region":             member.Region,
+			"zone":               member.Zone,
+			"gcp_project":        member.GCPProject,
+			"public_ip":          member.PublicIPMode,
+			"public_ip_address":  member.PublicIP,
+			"private_ip_address": member.PrivateIP,
+			"revision":           member.Revision,
+		})
+		ids = append(ids, member.ID)
+		if member.PublicIP != "" {
+			publicIPs = append(publicIPs, member.PublicIP)
+		}
+		if member.PrivateIP != "" {
+			privateIPs = append(privateIPs, member.PrivateIP)
+		}
+	}
+	values := map[string]interface{}{
+		"members":     flattened,
+		"member_ids":  ids,
+		"public_ips":  publicIPs,
+		"private_ips": privateIPs,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeFleetDiff checks that each placement can be served, and plans a
+// reconcile when the servers of the fleet no longer match its settings, such
+// as after a server was deleted outside of Terraform.
+func customizeFleetDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	placements := expandFleetPlacements(diff.Get("placement").([]interface{}))
+	count := diff.Get("desired_count").(int)
+	total := 0
+	for i, p := range placements {
+		if !providerConfig.hasCloud(p.Cloud) {
+			return fmt.Errorf("placement %d: servers cannot be placed on %s without fleet_credentials for it", i, p.Cloud)
+		}
+		switch p.Cloud {
+		case "aws":
+			if p.Region == "" {
+				return fmt.Errorf("placement %d: region is required on AWS", i)
+			}
+			if p.SubnetID != "" && len(p.Zones) > 1 {
+				return fmt.Errorf("placement %d: an AWS subnet lies in a single zone, got %d zones", i, len(p.Zones))
+			}
+		case "gcp":
+			if p.GCPProject == "" {
+				return fmt.Errorf("placement %d: gcp_project is required on GCP", i)
+			}
+		}
+		total += p.Weight
+	}
+	if name := diff.Get("name").(string); diff.NewValueKnown("name") && count > 0 {
+		// A replacement creates the new servers before removing the old ones,
+		// so the longest name follows the indexes the current servers take.
+		var members []fleetMember
+		if !diff.HasChange("name") {
+			members = expandFleetMembers(diff.Get("members").([]interface{}))
+		}
+		names := memberNames(name, members, count)
+		if err := validateName(names[len(names)-1]); err != nil {
+			return err
+		}
+	}
+	if count > 0 && total == 0 {
+		return fmt.Errorf("at least one placement needs a weight above 0")
+	}
+	if diff.Id() == "" {
+		return nil
+	}
+	reconcile := false
+	for _, k := range fleetSpecKeys {
+		if !diff.NewValueKnown(k) {
+			reconcile = true
+		}
+	}
+	if !reconcile {
+		members := expandFleetMembers(diff.Get("members").([]interface{}))
+		_, create, remove := planFleet(members, fleetSlots(count, placements, expandFleetSpec(diff.Get)))
+		reconcile = len(create) > 0 || len(remove) > 0
+	}
+	if !reconcile {
+		return nil
+	}
+	for _, k := range []string{"members", "member_ids", "public_ips", "private_ips"} {
+		if err := diff.SetNewComputed(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// ImageProvider is implemented by the backends that support
+// cloudfusion_image.
+type ImageProvider interface {
+	CreateImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error
+	GetImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) (*vmconfig.ImageConfig, error)
+	UpdateImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error
+	DeleteImage(ctx context.Context, img *vmconfig.ImageConfig, client interface{}) error
+}
+
+func resourceImage() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateImage,
+		ReadContext:   ReadImage,
+		UpdateContext: UpdateImage,
+		DeleteContext: DeleteImage,
+		Schema:        schema2.GetImageResourceSchema(),
+		CustomizeDiff: customizeImageDiff,
+	}
+}
+
+// imageProvider returns the backend of the provider as an ImageProvider.
+func imageProvider(m interface{}) (*ProviderConfig, ImageProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(ImageProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_image is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateImage(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := imageProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	img, err := createImageConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = backend.CreateImage(ctx, img, providerConfig.Client)
+	if img.ID != "" {
+		// An image that failed to become available is tracked so destroy
+		// cleans it up.
+		data.SetId(img.ID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return setImageData(img, data)
+}
+
+func ReadImage(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := imageProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	img, err := createImageConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	img, err = backend.GetImage(ctx, img, providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if img == nil {
+		data.SetId("")
+		return nil
+	}
+	img.Tags = providerConfig.withoutIgnoredTags(img.Tags)
+	return setImageData(img, data)
+}
+
+func UpdateImage(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := imageProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	img, err := createImageConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.UpdateImage(ctx, img, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return setImageData(img, data)
+}
+
+func DeleteImage(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := imageProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	img, err := createImageConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.DeleteImage(ctx, img, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+func createImageConfig(providerConfig *ProviderConfig, data *schema.ResourceData) (*vmconfig.ImageConfig, error) {
+	tags, err := providerConfig.labeledTags(data.Get("name").(string), data.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	return &vmconfig.ImageConfig{
+		ID:            data.Id(),
+		Name:          data.Get("name").(string),
+		Description:   data.Get("description").(string),
+		ServerID:      data.Get("server_id").(string),
+		Region:        data.Get("region").(string),
+		Zone:          data.Get("zone").(string),
+		GCPProjectID:  data.Get("gcp_project").(string),
+		NoReboot:      data.Get("no_reboot").(bool),
+		Tags:          tags,
+		IgnoreTagKeys: providerConfig.IgnoreTagKeys,
+	}, nil
+}
+
+func setImageData(img *vmconfig.ImageConfig, data *schema.ResourceData) diag.Diagnostics {
+	values := map[string]interface{}{
+		"name":        img.Name,
+		"description": img.Description,
+		"tags_all":    img.Tags,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeImageDiff checks the settings the selected cloud requires to find
+// the server, and plans tags_all.
+func customizeImageDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	switch providerConfig.Provider.ProviderName() {
+	case "aws":
+		if diff.Get("region").(string) == "" {
+			return fmt.Errorf("region is required on AWS")
+		}
+	case "gcp":
+		if diff.Get("zone").(string) == "" {
+			return fmt.Errorf("zone is required on GCP")
+		}
+		if diff.Get("no_reboot").(bool) {
+			return fmt.Errorf("no_reboot is not supported on GCP, which never reboots the server")
+		}
+	}
+	if name := diff.Get("name").(string); diff.NewValueKnown("name") {
+		if err := validateName(name); err != nil {
+			return err
+		}
+	}
+	if !tagsKnown(diff) {
+		return diff.SetNewComputed("tags_all")
+	}
+	tags, err := providerConfig.labeledTags(diff.Get("name").(string), diff.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	return diff.SetNew("tags_all", tags)
+}
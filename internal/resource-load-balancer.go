@@ -0,0 +1,313 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// gcpMaxForwardingRulePorts is the number of ports a GCE forwarding rule
+// accepts.
+const gcpMaxForwardingRulePorts = 5
+
+// LoadBalancerProvider is implemented by the backends that support
+// cloudfusion_load_balancer.
+type LoadBalancerProvider interface {
+	CreateLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error
+	GetLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) (*network.LoadBalancerConfig, error)
+	UpdateLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error
+	DeleteLoadBalancer(ctx context.Context, lb *network.LoadBalancerConfig, client interface{}) error
+}
+
+func resourceLoadBalancer() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateLoadBalancer,
+		ReadContext:   ReadLoadBalancer,
+		UpdateContext: UpdateLoadBalancer,
+		DeleteContext: DeleteLoadBalancer,
+		Importer: &schema.ResourceImporter{
+			StateContext: importLoadBalancer,
+		},
+		Schema:        schema2.GetLoadBalancerResourceSchema(),
+		CustomizeDiff: customizeLoadBalancerDiff,
+	}
+}
+
+// loadBalancerProvider returns the backend of the provider as a
+// LoadBalancerProvider.
+func loadBalancerProvider(m interface{}) (*ProviderConfig, LoadBalancerProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(LoadBalancerProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_load_balancer is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateLoadBalancer(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := loadBalancerProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	lb, err := createLoadBalancerConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = backend.CreateLoadBalancer(ctx, lb, providerConfig.Client)
+	if lb.ID != "" {
+		// A partly created load balancer is tracked so destroy cleans it up.
+		data.SetId(lb.ID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return ReadLoadBalancer(ctx, data, m)
+}
+
+func ReadLoadBalancer(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := loadBalancerProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	lb, err := createLoadBalancerConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	lb, err = backend.GetLoadBalancer(ctx, lb, providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if lb == nil {
+		data.SetId("")
+		return nil
+	}
+	lb.Tags = providerConfig.withoutIgnoredTags(lb.Tags)
+	return setLoadBalancerData(lb, data)
+}
+
+func UpdateLoadBalancer(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := loadBalancerProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	lb, err := createLoadBalancerConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.UpdateLoadBalancer(ctx, lb, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return ReadLoadBalancer(ctx, data, m)
+}
+
+func DeleteLoadBalancer(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := loadBalancerProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	lb, err := createLoadBalancerConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.DeleteLoadBalancer(ctx, lb, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// importLoadBalancer takes the load balancer ARN on AWS, which holds its
+// region, and "<project>/<region>/<name>" on GCP.
+func importLoadBalancer(_ context.Context, data *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, fmt.Errorf("meta is not of type CloudProvider")
+	}
+	values := map[string]interface{}{}
+	if providerConfig.Provider.ProviderName() == "gcp" {
+		parts := strings.Split(data.Id(), "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("expected an ID of the form <project>/<region>/<name> on GCP, got %q", data.Id())
+		}
+		values["gcp_project"], values["region"], values["name"] = parts[0], parts[1], parts[2]
+		data.SetId(parts[2])
+	} else {
+		// arn:aws:elasticloadbalancing:<region>:<account>:loadbalancer/<type>/<name>/<id>
+		parts := strings.Split(data.Id(), ":")
+		if len(parts) != 6 || parts[2] != "elasticloadbalancing" || parts[3] == "" {
+			return nil, fmt.Errorf("expected a load balancer ARN on AWS, got %q", data.Id())
+		}
+		values["region"] = parts[3]
+	}
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return []*schema.ResourceData{data}, nil
+}
+
+func expandListeners(l []interface{}) []network.Listener {
+	listeners := make([]network.Listener, 0, len(l))
+	for _, raw := range l {
+		listener := raw.(map[string]interface{})
+		listeners = append(listeners, network.Listener{
+			Port:     int64(listener["port"].(int)),
+			Protocol: listener["protocol"].(string),
+		})
+	}
+	return listeners
+}
+
+func expandHealthCheck(l []interface{}) network.HealthCheck {
+	if len(l) == 0 || l[0] == nil {
+		return network.HealthCheck{}
+	}
+	hc := l[0].(map[string]interface{})
+	return network.HealthCheck{
+		Protocol:           hc["protocol"].(string),
+		Port:               int64(hc["port"].(int)),
+		Path:               hc["path"].(string),
+		IntervalSeconds:    int64(hc["interval"].(int)),
+		HealthyThreshold:   int64(hc["healthy_threshold"].(int)),
+		UnhealthyThreshold: int64(hc["unhealthy_threshold"].(int)),
+	}
+}
+
+// createLoadBalancerConfig builds the load balancer of data, with the tags as
+// the selected cloud stores them.
+func createLoadBalancerConfig(providerConfig *ProviderConfig, data *schema.ResourceData) (*network.LoadBalancerConfig, error) {
+	tags, err := providerConfig.labeledTags(data.Get("name").(string), data.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	return &network.LoadBalancerConfig{
+		ID:            data.Id(),
+		Name:          data.Get("name").(string),
+		Region:        data.Get("region").(string),
+		GCPProjectID:  data.Get("gcp_project").(string),
+		Internal:      data.Get("internal").(bool),
+		Network:       data.Get("network").(string),
+		SubnetIDs:     expandStringList(data.Get("subnet_ids").([]interface{})),
+		FirewallIDs:   expandStringList(data.Get("firewall_ids").([]interface{})),
+		Listeners:     expandListeners(data.Get("listener").([]interface{})),
+		HealthCheck:   expandHealthCheck(data.Get("health_check").([]interface{})),
+		ServerIDs:     expandStringSet(data.Get("server_ids").(*schema.Set)),
+		Tags:          tags,
+		IgnoreTagKeys: providerConfig.IgnoreTagKeys,
+	}, nil
+}
+
+func setLoadBalancerData(lb *network.LoadBalancerConfig, data *schema.ResourceData) diag.Diagnostics {
+	listeners := make([]interface{}, 0, len(lb.Listeners))
+	for _, listener := range lb.Listeners {
+		listeners = append(listeners, map[string]interface{}{
+			"port":     listener.Port,
+			"protocol": listener.Protocol,
+		})
+	}
+	path := lb.HealthCheck.Path
+	if lb.HealthCheck.Protocol != "http" {
+		// Only http checks have a path; keep the configured one.
+		path = expandHealthCheck(data.Get("health_check").([]interface{})).Path
+		if path == "" {
+			path = "/"
+		}
+	}
+	healthCheck := []interface{}{map[string]interface{}{
+		"protocol":            lb.HealthCheck.Protocol,
+		"port":                lb.HealthCheck.Port,
+		"path":                path,
+		"interval":            lb.HealthCheck.IntervalSeconds,
+		"healthy_threshold":   lb.HealthCheck.HealthyThreshold,
+		"unhealthy_threshold": lb.HealthCheck.UnhealthyThreshold,
+	}}
+	values := map[string]interface{}{
+		"name":         lb.Name,
+		"internal":     lb.Internal,
+		"network":      lb.Network,
+		"subnet_ids":   lb.SubnetIDs,
+		"firewall_ids": lb.FirewallIDs,
+		"listener":     listeners,
+		"health_check": healthCheck,
+		"server_ids":   lb.ServerIDs,
+		"address":      lb.Address,
+		"tags_all":     lb.Tags,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// validateListeners checks the listeners and health check against what the
+// load balancers of the selected cloud support.
+func validateListeners(providerName string, listeners []network.Listener, hc network.HealthCheck) error {
+	ports := map[int64]bool{}
+	protocols := map[string]bool{}
+	for _, listener := range listeners {
+		if ports[listener.Port] {
+			return fmt.Errorf("listener port %d is used twice", listener.Port)
+		}
+		ports[listener.Port] = true
+		protocols[listener.Protocol] = true
+	}
+	if protocols["http"] && len(protocols) > 1 {
+		return fmt.Errorf("http listeners cannot be mixed with tcp or udp listeners")
+	}
+	switch providerName {
+	case "aws":
+		if protocols["http"] && hc.Protocol != "http" {
+			return fmt.Errorf("http listeners need an http health check on AWS")
+		}
+	case "gcp":
+		if protocols["http"] {
+			return fmt.Errorf("http listeners are not supported on GCP, where load balancers pass tcp or udp traffic through")
+		}
+		if len(protocols) > 1 {
+			return fmt.Errorf("the listeners of a load balancer must all be tcp or all be udp on GCP")
+		}
+		if len(listeners) > gcpMaxForwardingRulePorts {
+			return fmt.Errorf("a load balancer takes at most %d listeners on GCP, got %d", gcpMaxForwardingRulePorts, len(listeners))
+		}
+	}
+	return nil
+}
+
+// customizeLoadBalancerDiff checks the settings the selected cloud requires,
+// and plans tags_all.
+func customizeLoadBalancerDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	providerName := providerConfig.Provider.ProviderName()
+	if diff.NewValueKnown("listener") && diff.NewValueKnown("health_check") {
+		listeners := expandListeners(diff.Get("listener").([]interface{}))
+		hc := expandHealthCheck(diff.Get("health_check").([]interface{}))
+		if err := validateListeners(providerName, listeners, hc); err != nil {
+			return err
+		}
+	}
+	if providerName == "gcp" && diff.Get("gcp_project").(string) == "" {
+		return fmt.Errorf("gcp_project is required on GCP")
+	}
+	if !tagsKnown(diff) {
+		return diff.SetNewComputed("tags_all")
+	}
+	tags, err := providerConfig.labeledTags(diff.Get("name").(string), diff.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	return diff.SetNew("tags_all", tags)
+}
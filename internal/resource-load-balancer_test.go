@@ -0,0 +1,29 @@
+package multi_cloud_compute
+
+import (
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateListeners(t *testing.T) {
+	tcp := network.HealthCheck{Protocol: "tcp", Port: 80}
+	web := []network.Listener{{Port: 80, Protocol: "http"}, {Port: 8080, Protocol: "http"}}
+	assert.NoError(t, validateListeners("aws", web, network.HealthCheck{Protocol: "http", Port: 80, Path: "/"}))
+	assert.Error(t, validateListeners("aws", web, tcp), "an application load balancer checks http")
+	assert.Error(t, validateListeners("gcp", web, tcp), "GCP load balancers pass traffic through")
+
+	mixed := []network.Listener{{Port: 53, Protocol: "udp"}, {Port: 443, Protocol: "tcp"}}
+	assert.NoError(t, validateListeners("aws", mixed, tcp))
+	assert.Error(t, validateListeners("gcp", mixed, tcp), "a forwarding rule takes a single protocol")
+	assert.Error(t, validateListeners("aws", []network.Listener{{Port: 80, Protocol: "http"}, {Port: 443, Protocol: "tcp"}}, tcp))
+	assert.Error(t, validateListeners("aws", []network.Listener{{Port: 53, Protocol: "udp"}, {Port: 53, Protocol: "tcp"}}, tcp), "target groups are named by port")
+
+	var many []network.Listener
+	for port := int64(1); port <= 6; port++ {
+		many = append(many, network.Listener{Port: port, Protocol: "tcp"})
+	}
+	assert.NoError(t, validateListeners("aws", many, tcp))
+	assert.Error(t, validateListeners("gcp", many, tcp))
+}
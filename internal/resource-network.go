@@ -0,0 +1,257 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// NetworkProvider is implemented by the backends that support
+// cloudfusion_network.
+type NetworkProvider interface {
+	CreateNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) error
+	GetNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) (*network.NetworkConfig, error)
+	UpdateNetwork(ctx context.Context, net, old *network.NetworkConfig, client interface{}) error
+	DeleteNetwork(ctx context.Context, net *network.NetworkConfig, client interface{}) error
+}
+
+func resourceNetwork() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateNetwork,
+		ReadContext:   ReadNetwork,
+		UpdateContext: UpdateNetwork,
+		DeleteContext: DeleteNetwork,
+		Schema:        schema2.GetNetworkResourceSchema(),
+		CustomizeDiff: customizeNetworkDiff,
+	}
+}
+
+// networkProvider returns the backend of the provider as a NetworkProvider.
+func networkProvider(m interface{}) (*ProviderConfig, NetworkProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(NetworkProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_network is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateNetwork(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := networkProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	net := createNetworkConfig(providerConfig, data)
+	err := backend.CreateNetwork(ctx, net, providerConfig.Client)
+	if net.ID != "" {
+		// A partly created network is tracked so destroy cleans it up.
+		data.SetId(net.ID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return setNetworkData(providerConfig, net, data)
+}
+
+func ReadNetwork(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := networkProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	net, err := backend.GetNetwork(ctx, createNetworkConfig(providerConfig, data), providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if net == nil {
+		data.SetId("")
+		return nil
+	}
+	net.Tags = providerConfig.withoutIgnoredTags(net.Tags)
+	return setNetworkData(providerConfig, net, data)
+}
+
+func UpdateNetwork(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := networkProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	net := createNetworkConfig(providerConfig, data)
+	old := createNetworkConfig(providerConfig, data)
+	oldSubnets, _ := data.GetChange("subnet")
+	old.Subnets = expandSubnets(oldSubnets.([]interface{}))
+	if err := backend.UpdateNetwork(ctx, net, old, providerConfig.Client); err != nil {
+		// The subnets created before the failure are tracked, so the next
+		// apply does not create them again.
+		net.Subnets = appliedSubnets(old.Subnets, net.Subnets)
+		return append(diag.FromErr(err), setNetworkData(providerConfig, net, data)...)
+	}
+	return setNetworkData(providerConfig, net, data)
+}
+
+// appliedSubnets returns the subnets of a network whose update failed: the
+// subnets that got an ID, and the old subnets of other names. Subnets are
+// deleted before any is created, so those may not be deleted yet; the next
+// apply deletes them again or finds them gone.
+func appliedSubnets(old, subnets []network.Subnet) []network.Subnet {
+	applied := make([]network.Subnet, 0, len(subnets))
+	names := map[string]bool{}
+	for _, subnet := range subnets {
+		if subnet.ID != "" {
+			applied = append(applied, subnet)
+			names[subnet.Name] = true
+		}
+	}
+	for _, subnet := range old {
+		if subnet.ID != "" && !names[subnet.Name] {
+			applied = append(applied, subnet)
+		}
+	}
+	return applied
+}
+
+func DeleteNetwork(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := networkProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	if err := backend.DeleteNetwork(ctx, createNetworkConfig(providerConfig, data), providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+func createNetworkConfig(providerConfig *ProviderConfig, data *schema.ResourceData) *network.NetworkConfig {
+	net := &network.NetworkConfig{
+		ID:                data.Id(),
+		Name:              data.Get("name").(string),
+		Region:            data.Get("region").(string),
+		CIDRBlock:         data.Get("cidr_block").(string),
+		GCPProjectID:      data.Get("gcp_project").(string),
+		InternetGatewayID: data.Get("internet_gateway_id").(string),
+		RouteTableID:      data.Get("route_table_id").(string),
+		Subnets:           expandSubnets(data.Get("subnet").([]interface{})),
+		Tags:              providerConfig.mergeTags(data.Get("tags").(map[string]interface{})),
+		IgnoreTagKeys:     providerConfig.IgnoreTagKeys,
+	}
+	if _, ok := net.Tags["Name"]; !ok && providerConfig.Provider.ProviderName() == "aws" {
+		net.Tags["Name"] = net.Name
+	}
+	return net
+}
+
+func expandSubnets(l []interface{}) []network.Subnet {
+	subnets := make([]network.Subnet, 0, len(l))
+	for _, s := range l {
+		m := s.(map[string]interface{})
+		subnets = append(subnets, network.Subnet{
+			ID:        m["id"].(string),
+			Name:      m["name"].(string),
+			CIDRBlock: m["cidr_block"].(string),
+			Region:    m["region"].(string),
+			Zone:      m["zone"].(string),
+			Public:    m["public"].(bool),
+		})
+	}
+	return subnets
+}
+
+func setNetworkData(providerConfig *ProviderConfig, net *network.NetworkConfig, data *schema.ResourceData) diag.Diagnostics {
+	subnets := make([]interface{}, 0, len(net.Subnets))
+	subnetIDs := make(map[string]interface{}, len(net.Subnets))
+	for _, subnet := range net.Subnets {
+		subnets = append(subnets, map[string]interface{}{
+			"id":         subnet.ID,
+			"name":       subnet.Name,
+			"cidr_block": subnet.CIDRBlock,
+			"region":     subnet.Region,
+			"zone":       subnet.Zone,
+			"public":     subnet.Public,
+		})
+		subnetIDs[subnet.Name] = subnet.ID
+	}
+	tags := map[string]string{}
+	if providerConfig.Provider.ProviderName() == "aws" {
+		tags = net.Tags
+	}
+	values := map[string]interface{}{
+		"cidr_block":          net.CIDRBlock,
+		"subnet":              subnets,
+		"subnet_ids":          subnetIDs,
+		"network_name":        net.Name,
+		"internet_gateway_id": net.InternetGatewayID,
+		"route_table_id":      net.RouteTableID,
+		"tags_all":            tags,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeNetworkDiff checks the settings the selected cloud requires and
+// plans tags_all and subnet_ids.
+func customizeNetworkDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	providerName := providerConfig.Provider.ProviderName()
+	region := diff.Get("region").(string)
+	seen := map[string]bool{}
+	for _, subnet := range expandSubnets(diff.Get("subnet").([]interface{})) {
+		if seen[subnet.Name] {
+			return fmt.Errorf("subnet names must be unique, %q is used twice", subnet.Name)
+		}
+		seen[subnet.Name] = true
+		switch providerName {
+		case "aws":
+			if subnet.Region != "" && subnet.Region != region {
+				return fmt.Errorf("subnet %q must be in the network region %s on AWS", subnet.Name, region)
+			}
+		case "gcp":
+			if subnet.Region == "" && region == "" {
+				return fmt.Errorf("subnet %q needs a region, or the network a default region", subnet.Name)
+			}
+			if subnet.Zone != "" {
+				return fmt.Errorf("subnet %q: zone is not supported on GCP, where subnets span a region", subnet.Name)
+			}
+			if !subnet.Public {
+				return fmt.Errorf("subnet %q: public = false is not supported on GCP, where public IPs are set per server", subnet.Name)
+			}
+		}
+	}
+	if providerName == "aws" {
+		if region == "" {
+			return fmt.Errorf("region is required on AWS")
+		}
+		if diff.Get("cidr_block").(string) == "" {
+			return fmt.Errorf("cidr_block is required on AWS")
+		}
+	}
+	if diff.HasChange("subnet") {
+		if err := diff.SetNewComputed("subnet_ids"); err != nil {
+			return err
+		}
+	}
+	tags := map[string]string{}
+	if providerName == "aws" {
+		if !tagsKnown(diff) {
+			return diff.SetNewComputed("tags_all")
+		}
+		tags = providerConfig.mergeTags(diff.Get("tags").(map[string]interface{}))
+		if _, ok := tags["Name"]; !ok {
+			tags["Name"] = diff.Get("name").(string)
+		}
+	}
+	return diff.SetNew("tags_all", tags)
+}
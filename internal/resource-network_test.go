@@ -0,0 +1,27 @@
+package multi_cloud_compute
+
+import (
+	"testing"
+
+	"github.com/Abubakarr99/multi-cloud-compute/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppliedSubnets(t *testing.T) {
+	old := []network.Subnet{
+		{ID: "subnet-a", Name: "a", CIDRBlock: "10.0.1.0/24"},
+		{ID: "subnet-b", Name: "b", CIDRBlock: "10.0.2.0/24"},
+	}
+	subnets := []network.Subnet{
+		{ID: "subnet-a", Name: "a", CIDRBlock: "10.0.1.0/24"},
+		{ID: "subnet-c", Name: "c", CIDRBlock: "10.0.3.0/24"},
+		{Name: "d", CIDRBlock: "10.0.4.0/24"},
+	}
+	assert.Equal(t, []network.Subnet{subnets[0], subnets[1], old[1]}, appliedSubnets(old, subnets),
+		"created subnets should be tracked, and removed ones kept until they are certainly deleted")
+
+	changed := []network.Subnet{{Name: "a", CIDRBlock: "10.0.5.0/24"}}
+	assert.Equal(t, old, appliedSubnets(old, changed), "a subnet that was not recreated should keep its old settings")
+	changed[0].ID = "subnet-e"
+	assert.Equal(t, []network.Subnet{changed[0], old[1]}, appliedSubnets(old, changed))
+}
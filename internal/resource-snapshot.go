@@ -0,0 +1,183 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// SnapshotProvider is implemented by the backends that support
+// cloudfusion_snapshot.
+type SnapshotProvider interface {
+	CreateSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error
+	GetSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) (*storage.SnapshotConfig, error)
+	UpdateSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error
+	DeleteSnapshot(ctx context.Context, snap *storage.SnapshotConfig, client interface{}) error
+}
+
+func resourceSnapshot() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateSnapshot,
+		ReadContext:   ReadSnapshot,
+		UpdateContext: UpdateSnapshot,
+		DeleteContext: DeleteSnapshot,
+		Schema:        schema2.GetSnapshotResourceSchema(),
+		CustomizeDiff: customizeSnapshotDiff,
+	}
+}
+
+// snapshotProvider returns the backend of the provider as a SnapshotProvider.
+func snapshotProvider(m interface{}) (*ProviderConfig, SnapshotProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(SnapshotProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_snapshot is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateSnapshot(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := snapshotProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	snap, err := createSnapshotConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = backend.CreateSnapshot(ctx, snap, providerConfig.Client)
+	if snap.ID != "" {
+		// A snapshot that failed to complete is tracked so destroy cleans it
+		// up.
+		data.SetId(snap.ID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return ReadSnapshot(ctx, data, m)
+}
+
+func ReadSnapshot(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := snapshotProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	snap, err := createSnapshotConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	snap, err = backend.GetSnapshot(ctx, snap, providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if snap == nil {
+		data.SetId("")
+		return nil
+	}
+	snap.Tags = providerConfig.withoutIgnoredTags(snap.Tags)
+	return setSnapshotData(snap, data)
+}
+
+func UpdateSnapshot(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := snapshotProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	snap, err := createSnapshotConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.UpdateSnapshot(ctx, snap, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	// Only the tags change in place, so the size is the one in the state.
+	snap.SizeGB = int64(data.Get("size_gb").(int))
+	return setSnapshotData(snap, data)
+}
+
+func DeleteSnapshot(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := snapshotProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	snap, err := createSnapshotConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.DeleteSnapshot(ctx, snap, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+func createSnapshotConfig(providerConfig *ProviderConfig, data *schema.ResourceData) (*storage.SnapshotConfig, error) {
+	tags, err := providerConfig.labeledTags(data.Get("name").(string), data.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	return &storage.SnapshotConfig{
+		ID:            data.Id(),
+		Name:          data.Get("name").(string),
+		Description:   data.Get("description").(string),
+		VolumeID:      data.Get("volume_id").(string),
+		Region:        data.Get("region").(string),
+		Zone:          data.Get("zone").(string),
+		GCPProjectID:  data.Get("gcp_project").(string),
+		Tags:          tags,
+		IgnoreTagKeys: providerConfig.IgnoreTagKeys,
+	}, nil
+}
+
+func setSnapshotData(snap *storage.SnapshotConfig, data *schema.ResourceData) diag.Diagnostics {
+	values := map[string]interface{}{
+		"description": snap.Description,
+		"size_gb":     snap.SizeGB,
+		"tags_all":    snap.Tags,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeSnapshotDiff checks the settings the selected cloud requires to
+// find the volume, and plans tags_all.
+func customizeSnapshotDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	switch providerConfig.Provider.ProviderName() {
+	case "aws":
+		if diff.Get("region").(string) == "" {
+			return fmt.Errorf("region is required on AWS")
+		}
+	case "gcp":
+		if diff.Get("zone").(string) == "" {
+			return fmt.Errorf("zone is required on GCP")
+		}
+	}
+	if name := diff.Get("name").(string); diff.NewValueKnown("name") {
+		if err := validateName(name); err != nil {
+			return err
+		}
+	}
+	if !tagsKnown(diff) {
+		return diff.SetNewComputed("tags_all")
+	}
+	tags, err := providerConfig.labeledTags(diff.Get("name").(string), diff.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	return diff.SetNew("tags_all", tags)
+}
@@ -0,0 +1,183 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Abubakarr99/multi-cloud-compute/cloud"
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	vmconfig "github.com/Abubakarr99/multi-cloud-compute/vm"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// SSHKeyProvider is implemented by the backends that support
+// cloudfusion_ssh_key.
+type SSHKeyProvider interface {
+	CreateSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error
+	GetSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) (*vmconfig.SSHKeyConfig, error)
+	UpdateSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error
+	DeleteSSHKey(ctx context.Context, key *vmconfig.SSHKeyConfig, client interface{}) error
+}
+
+func resourceSSHKey() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateSSHKey,
+		ReadContext:   ReadSSHKey,
+		UpdateContext: UpdateSSHKey,
+		DeleteContext: DeleteSSHKey,
+		Importer: &schema.ResourceImporter{
+			StateContext: importSSHKey,
+		},
+		Schema:        schema2.GetSSHKeyResourceSchema(),
+		CustomizeDiff: customizeSSHKeyDiff,
+	}
+}
+
+// sshKeyProvider returns the backend of the provider as an SSHKeyProvider.
+func sshKeyProvider(m interface{}) (*ProviderConfig, SSHKeyProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(SSHKeyProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("cloudfusion_ssh_key is not supported on %s", providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateSSHKey(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := sshKeyProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	key := createSSHKeyConfig(data)
+	if err := backend.CreateSSHKey(ctx, key, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	data.SetId(key.ID)
+	return setSSHKeyData(key, data)
+}
+
+func ReadSSHKey(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := sshKeyProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	key, err := backend.GetSSHKey(ctx, createSSHKeyConfig(data), providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if key == nil {
+		data.SetId("")
+		return nil
+	}
+	return setSSHKeyData(key, data)
+}
+
+func UpdateSSHKey(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := sshKeyProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	key := createSSHKeyConfig(data)
+	if providerConfig.Provider.ProviderName() == "aws" && !data.HasChange("public_key") {
+		// The user only applies to GCP, so the key pair is left alone.
+		key.Fingerprint = data.Get("fingerprint").(string)
+		return setSSHKeyData(key, data)
+	}
+	if err := backend.UpdateSSHKey(ctx, key, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return setSSHKeyData(key, data)
+}
+
+func DeleteSSHKey(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := sshKeyProvider(m)
+	if diags.HasError() {
+		return diags
+	}
+	if err := backend.DeleteSSHKey(ctx, createSSHKeyConfig(data), providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// importSSHKey takes an ID of the form <region>/<name> on AWS or
+// <project>/<name> on GCP.
+func importSSHKey(_ context.Context, data *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, fmt.Errorf("meta is not of type CloudProvider")
+	}
+	location, name, ok := strings.Cut(data.Id(), "/")
+	if !ok || location == "" || name == "" {
+		return nil, fmt.Errorf("expected an ID of the form <region>/<name> on AWS or <project>/<name> on GCP, got %q", data.Id())
+	}
+	attribute := "region"
+	if providerConfig.Provider.ProviderName() == "gcp" {
+		attribute = "gcp_project"
+	}
+	if err := data.Set(attribute, location); err != nil {
+		return nil, err
+	}
+	data.SetId(name)
+	return []*schema.ResourceData{data}, nil
+}
+
+func createSSHKeyConfig(data *schema.ResourceData) *vmconfig.SSHKeyConfig {
+	return &vmconfig.SSHKeyConfig{
+		ID:           data.Id(),
+		Name:         data.Get("name").(string),
+		PublicKey:    data.Get("public_key").(string),
+		User:         data.Get("user").(string),
+		Region:       data.Get("region").(string),
+		GCPProjectID: data.Get("gcp_project").(string),
+	}
+}
+
+func setSSHKeyData(key *vmconfig.SSHKeyConfig, data *schema.ResourceData) diag.Diagnostics {
+	values := map[string]interface{}{
+		"name":        key.Name,
+		"public_key":  key.PublicKey,
+		"user":        key.User,
+		"fingerprint": key.Fingerprint,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeSSHKeyDiff validates the public key and the settings the selected
+// cloud requires. A new key changes the fingerprint.
+func customizeSSHKeyDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	if diff.NewValueKnown("public_key") {
+		if err := cloud.ValidateSSHPublicKey(diff.Get("public_key").(string)); err != nil {
+			return err
+		}
+	}
+	switch providerConfig.Provider.ProviderName() {
+	case "aws":
+		if diff.Get("region").(string) == "" {
+			return fmt.Errorf("region is required on AWS")
+		}
+	case "gcp":
+		if diff.Get("gcp_project").(string) == "" {
+			return fmt.Errorf("gcp_project is required on GCP")
+		}
+	}
+	if diff.Id() != "" && diff.HasChange("public_key") {
+		return diff.SetNewComputed("fingerprint")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceVolumeAttachment() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateVolumeAttachment,
+		ReadContext:   ReadVolumeAttachment,
+		DeleteContext: DeleteVolumeAttachment,
+		Schema:        schema2.GetVolumeAttachmentResourceSchema(),
+		CustomizeDiff: customizeVolumeAttachmentDiff,
+	}
+}
+
+func CreateVolumeAttachment(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := volumeProvider(m, "cloudfusion_volume_attachment")
+	if diags.HasError() {
+		return diags
+	}
+	att := createVolumeAttachment(data)
+	if err := backend.AttachVolume(ctx, att, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	data.SetId(volumeAttachmentID(att))
+	return setVolumeAttachmentData(att, data)
+}
+
+func ReadVolumeAttachment(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := volumeProvider(m, "cloudfusion_volume_attachment")
+	if diags.HasError() {
+		return diags
+	}
+	att, err := backend.GetVolumeAttachment(ctx, createVolumeAttachment(data), providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if att == nil {
+		data.SetId("")
+		return nil
+	}
+	return setVolumeAttachmentData(att, data)
+}
+
+func DeleteVolumeAttachment(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := volumeProvider(m, "cloudfusion_volume_attachment")
+	if diags.HasError() {
+		return diags
+	}
+	if err := backend.DetachVolume(ctx, createVolumeAttachment(data), providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// volumeAttachmentID identifies an attachment by its server and volume.
+func volumeAttachmentID(att *storage.VolumeAttachment) string {
+	return fmt.Sprintf("%s/%s", att.ServerID, att.VolumeID)
+}
+
+func createVolumeAttachment(data *schema.ResourceData) *storage.VolumeAttachment {
+	return &storage.VolumeAttachment{
+		ServerID:     data.Get("server_id").(string),
+		VolumeID:     data.Get("volume_id").(string),
+		DeviceName:   data.Get("device_name").(string),
+		Region:       data.Get("region").(string),
+		Zone:         data.Get("zone").(string),
+		GCPProjectID: data.Get("gcp_project").(string),
+	}
+}
+
+func setVolumeAttachmentData(att *storage.VolumeAttachment, data *schema.ResourceData) diag.Diagnostics {
+	if err := data.Set("device_name", att.DeviceName); err != nil {
+		return diag.Errorf("failed to set device_name: %s", err)
+	}
+	return nil
+}
+
+// customizeVolumeAttachmentDiff checks the settings the selected cloud
+// requires to find the server and the volume.
+func customizeVolumeAttachmentDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	switch providerConfig.Provider.ProviderName() {
+	case "aws":
+		if diff.Get("region").(string) == "" {
+			return fmt.Errorf("region is required on AWS")
+		}
+		// device_name is computed for GCE, so an unset value is only seen in
+		// the configuration.
+		if deviceName := diff.GetRawConfig().GetAttr("device_name"); deviceName.IsKnown() && deviceName.IsNull() {
+			return fmt.Errorf("device_name is required on AWS")
+		}
+	case "gcp":
+		if diff.Get("zone").(string) == "" {
+			return fmt.Errorf("zone is required on GCP")
+		}
+		if name := diff.Get("device_name").(string); strings.HasPrefix(name, "/dev/") {
+			return fmt.Errorf("device_name %q must not start with /dev/ on GCP", name)
+		}
+	}
+	return nil
+}
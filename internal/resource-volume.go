@@ -0,0 +1,206 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"fmt"
+
+	schema2 "github.com/Abubakarr99/multi-cloud-compute/schema"
+	"github.com/Abubakarr99/multi-cloud-compute/storage"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// VolumeProvider is implemented by the backends that support
+// cloudfusion_volume and cloudfusion_volume_attachment.
+type VolumeProvider interface {
+	CreateVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) error
+	GetVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) (*storage.VolumeConfig, error)
+	UpdateVolume(ctx context.Context, vol, old *storage.VolumeConfig, client interface{}) error
+	DeleteVolume(ctx context.Context, vol *storage.VolumeConfig, client interface{}) error
+	AttachVolume(ctx context.Context, att *storage.VolumeAttachment, client interface{}) error
+	GetVolumeAttachment(ctx context.Context, att *storage.VolumeAttachment, client interface{}) (*storage.VolumeAttachment, error)
+	DetachVolume(ctx context.Context, att *storage.VolumeAttachment, client interface{}) error
+}
+
+func resourceVolume() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: CreateVolume,
+		ReadContext:   ReadVolume,
+		UpdateContext: UpdateVolume,
+		DeleteContext: DeleteVolume,
+		Schema:        schema2.GetVolumeResourceSchema(),
+		CustomizeDiff: customizeVolumeDiff,
+	}
+}
+
+// volumeProvider returns the backend of the provider as a VolumeProvider.
+func volumeProvider(m interface{}, resource string) (*ProviderConfig, VolumeProvider, diag.Diagnostics) {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil, nil, diag.Errorf("meta is not of type CloudProvider")
+	}
+	backend, ok := providerConfig.Provider.(VolumeProvider)
+	if !ok {
+		return nil, nil, diag.Errorf("%s is not supported on %s", resource, providerConfig.Provider.ProviderName())
+	}
+	return providerConfig, backend, nil
+}
+
+func CreateVolume(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := volumeProvider(m, "cloudfusion_volume")
+	if diags.HasError() {
+		return diags
+	}
+	vol, err := createVolumeConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = backend.CreateVolume(ctx, vol, providerConfig.Client)
+	if vol.ID != "" {
+		// A volume that failed to become available is tracked so destroy
+		// cleans it up.
+		data.SetId(vol.ID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return setVolumeData(vol, data)
+}
+
+func ReadVolume(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := volumeProvider(m, "cloudfusion_volume")
+	if diags.HasError() {
+		return diags
+	}
+	vol, err := createVolumeConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	vol, err = backend.GetVolume(ctx, vol, providerConfig.Client)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if vol == nil {
+		data.SetId("")
+		return nil
+	}
+	vol.Tags = providerConfig.withoutIgnoredTags(vol.Tags)
+	return setVolumeData(vol, data)
+}
+
+func UpdateVolume(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := volumeProvider(m, "cloudfusion_volume")
+	if diags.HasError() {
+		return diags
+	}
+	vol, err := createVolumeConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	old := *vol
+	oldSize, _ := data.GetChange("size_gb")
+	oldType, _ := data.GetChange("type")
+	oldIOPS, _ := data.GetChange("iops")
+	old.SizeGB = int64(oldSize.(int))
+	old.Type = oldType.(string)
+	old.IOPS = int64(oldIOPS.(int))
+	if err := backend.UpdateVolume(ctx, vol, &old, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return setVolumeData(vol, data)
+}
+
+func DeleteVolume(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
+	providerConfig, backend, diags := volumeProvider(m, "cloudfusion_volume")
+	if diags.HasError() {
+		return diags
+	}
+	vol, err := createVolumeConfig(providerConfig, data)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := backend.DeleteVolume(ctx, vol, providerConfig.Client); err != nil {
+		return diag.FromErr(err)
+	}
+	return nil
+}
+
+// createVolumeConfig builds the volume of data, with the tags as the selected
+// cloud stores them.
+func createVolumeConfig(providerConfig *ProviderConfig, data *schema.ResourceData) (*storage.VolumeConfig, error) {
+	tags, err := providerConfig.labeledTags(data.Get("name").(string), data.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	return &storage.VolumeConfig{
+		ID:            data.Id(),
+		Name:          data.Get("name").(string),
+		Region:        data.Get("region").(string),
+		Zone:          data.Get("zone").(string),
+		GCPProjectID:  data.Get("gcp_project").(string),
+		SizeGB:        int64(data.Get("size_gb").(int)),
+		Type:          data.Get("type").(string),
+		IOPS:          int64(data.Get("iops").(int)),
+		KMSKeyID:      data.Get("kms_key_id").(string),
+		Tags:          tags,
+		IgnoreTagKeys: providerConfig.IgnoreTagKeys,
+	}, nil
+}
+
+func setVolumeData(vol *storage.VolumeConfig, data *schema.ResourceData) diag.Diagnostics {
+	values := map[string]interface{}{
+		"zone":       vol.Zone,
+		"size_gb":    vol.SizeGB,
+		"type":       vol.Type,
+		"iops":       vol.IOPS,
+		"kms_key_id": vol.KMSKeyID,
+		"tags_all":   vol.Tags,
+	}
+	var diags diag.Diagnostics
+	for k, v := range values {
+		if err := data.Set(k, v); err != nil {
+			diags = append(diags, diag.Errorf("failed to set %s: %s", k, err)...)
+		}
+	}
+	return diags
+}
+
+// customizeVolumeDiff checks the settings the selected cloud requires and the
+// changes it supports in place, and plans tags_all.
+func customizeVolumeDiff(_ context.Context, diff *schema.ResourceDiff, m interface{}) error {
+	providerConfig, ok := m.(*ProviderConfig)
+	if !ok {
+		return nil
+	}
+	providerName := providerConfig.Provider.ProviderName()
+	if name := diff.Get("name").(string); name != "" && diff.NewValueKnown("name") {
+		if err := validateName(name); err != nil {
+			return err
+		}
+	}
+	if diff.Get("type").(string) == "provisioned_iops" && diff.Get("iops").(int) == 0 {
+		return fmt.Errorf("iops is required for provisioned_iops volumes")
+	}
+	if volumeType := diff.Get("type").(string); diff.NewValueKnown("type") && volumeType != "provisioned_iops" && diff.Get("iops").(int) != 0 {
+		return fmt.Errorf("iops can only be set for provisioned_iops volumes, not %s", volumeType)
+	}
+	if diff.Id() != "" {
+		if oldSize, newSize := diff.GetChange("size_gb"); newSize.(int) < oldSize.(int) {
+			return fmt.Errorf("size_gb cannot shrink from %d to %d GB", oldSize.(int), newSize.(int))
+		}
+		if providerName == "gcp" && (diff.HasChange("type") || diff.HasChange("iops")) {
+			return fmt.Errorf("the type and iops of a persistent disk cannot be changed on GCP")
+		}
+	}
+	if providerName == "aws" && diff.Get("region").(string) == "" {
+		return fmt.Errorf("region is required on AWS")
+	}
+	if !tagsKnown(diff) {
+		return diff.SetNewComputed("tags_all")
+	}
+	tags, err := providerConfig.labeledTags(diff.Get("name").(string), diff.Get("tags").(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	return diff.SetNew("tags_all", tags)
+}
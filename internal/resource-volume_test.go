@@ -0,0 +1,24 @@
+package multi_cloud_compute
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeIOPSNeedsProvisionedType(t *testing.T) {
+	r := resourceVolume()
+	aws := &ProviderConfig{Provider: backends["aws"]()}
+	volume := func(volumeType string, iops int) *terraform.ResourceConfig {
+		return terraform.NewResourceConfigRaw(map[string]interface{}{"name": "data", "region": "eu-west-1", "zone": "eu-west-1a", "size_gb": 10, "type": volumeType, "iops": iops})
+	}
+
+	_, err := r.SimpleDiff(context.Background(), &terraform.InstanceState{}, volume("ssd", 3000), aws)
+	assert.ErrorContains(t, err, "iops can only be set for provisioned_iops volumes, not ssd")
+	_, err = r.SimpleDiff(context.Background(), &terraform.InstanceState{}, volume("provisioned_iops", 3000), aws)
+	assert.NoError(t, err)
+	_, err = r.SimpleDiff(context.Background(), &terraform.InstanceState{}, volume("ssd", 0), aws)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,13 @@
+package network
+
+// DNSRecordConfig is a record set of a Route 53 hosted zone or a Cloud DNS
+// managed zone.
+type DNSRecordConfig struct {
+	ID           string // <zone>/<name>/<type> on both clouds
+	ZoneID       string // Hosted zone ID on AWS, managed zone name on GCP
+	GCPProjectID string
+	Name         string // Fully qualified, with or without the trailing dot
+	Type         string // A, AAAA or CNAME
+	TTL          int64
+	Values       []string
+}
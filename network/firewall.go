@@ -0,0 +1,25 @@
+package network
+
+// FirewallConfig is a set of rules applied to the servers carrying one of its
+// target tags: an EC2 security group or GCE firewall rules.
+type FirewallConfig struct {
+	ID            string // Security group ID on AWS, firewall name on GCP
+	Name          string
+	Description   string
+	Region        string // AWS only
+	GCPProjectID  string
+	Network       string // VPC ID on AWS, network name on GCP; the default network when empty
+	TargetTags    []string
+	Ingress       []FirewallRule
+	Egress        []FirewallRule // Everything is allowed out when empty
+	Tags          map[string]string
+	IgnoreTagKeys []string // Tags managed outside of Terraform
+}
+
+// FirewallRule allows traffic from or to CIDRBlocks. It has the same layout
+// as vm.IngressRule.
+type FirewallRule struct {
+	Protocol   string   // tcp, udp, icmp or all
+	Ports      []string // Ports or port ranges such as "8000-8080", tcp and udp only
+	CIDRBlocks []string
+}
@@ -0,0 +1,49 @@
+package network
+
+// LoadBalancerConfig spreads traffic over servers: an NLB or ALB with a
+// target group per listener on AWS, and a regional backend service with an
+// instance group per zone and a forwarding rule on GCP.
+type LoadBalancerConfig struct {
+	ID            string // Load balancer ARN on AWS, name on GCP
+	Name          string
+	Region        string
+	GCPProjectID  string
+	Internal      bool
+	Network       string   // GCP only, the network of an internal load balancer
+	SubnetIDs     []string // AWS only, the default subnets of the default VPC when empty
+	FirewallIDs   []string // AWS only, the security groups of an application load balancer
+	Listeners     []Listener
+	HealthCheck   HealthCheck
+	ServerIDs     []string
+	Address       string // DNS name on AWS, forwarding rule IP on GCP; read from the cloud
+	Tags          map[string]string
+	IgnoreTagKeys []string // Tags managed outside of Terraform
+}
+
+// Listener accepts traffic on a port and forwards it to the same port of the
+// servers.
+type Listener struct {
+	Port     int64
+	Protocol string // tcp, udp or http
+}
+
+// HealthCheck decides which servers receive traffic.
+type HealthCheck struct {
+	Protocol           string // tcp or http
+	Port               int64
+	Path               string // http only
+	IntervalSeconds    int64
+	HealthyThreshold   int64
+	UnhealthyThreshold int64
+}
+
+// LoadBalancerType returns "application" when the listeners of lb speak
+// http, and "network" otherwise.
+func (lb *LoadBalancerConfig) LoadBalancerType() string {
+	for _, listener := range lb.Listeners {
+		if listener.Protocol == "http" {
+			return "application"
+		}
+	}
+	return "network"
+}
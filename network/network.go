@@ -0,0 +1,26 @@
+package network
+
+// NetworkConfig is a VPC on AWS or a custom-mode VPC network on GCP, with its
+// subnets.
+type NetworkConfig struct {
+	ID                string // VPC ID on AWS, network name on GCP
+	Name              string
+	Region            string // Default region of the subnets
+	CIDRBlock         string // AWS only
+	GCPProjectID      string
+	InternetGatewayID string // AWS only, read from the cloud
+	RouteTableID      string // AWS only, routes public subnets to the internet gateway
+	Subnets           []Subnet
+	Tags              map[string]string
+	IgnoreTagKeys     []string // Tags managed outside of Terraform
+}
+
+// Subnet is an address range of a network in one region.
+type Subnet struct {
+	ID        string // Subnet ID on AWS, subnetwork path on GCP; read from the cloud
+	Name      string
+	CIDRBlock string
+	Region    string // The network region when empty
+	Zone      string // AWS only, picked by AWS when empty
+	Public    bool   // AWS only, routed to the internet gateway
+}
@@ -40,19 +40,21 @@ func DeleteInstance(ctx context.Context, data *schema.ResourceData, i interface{
 			Region:         data.Get("region").(string),
 			InstanceType:   data.Get("instance_type").(string),
 			SubnetID:       data.Get("subnet_id").(string),
-			AWSAMI:         data.Get("aws_ami_id").(string),
+			AWS:            &vmconfig.AWSOptions{AMI: data.Get("aws_ami_id").(string)},
 			CredentialPath: data.Get("credentials").(string),
 		}
 	case "gcp":
 		provider = &cloud.GCProvider{}
 		vm = &vmconfig.VMConfig{
-			Name:            data.Get("name").(string),
-			Region:          data.Get("region").(string),
-			InstanceType:    data.Get("instance_type").(string),
-			GCPImageFamily:  data.Get("gcp_image_family").(string),
-			GCPImageProject: data.Get("gcp_image_project").(string),
-			GCPNetworkName:  data.Get("gcp_network_name").(string),
-			CredentialPath:  data.Get("credentials").(string),
+			Name:         data.Get("name").(string),
+			Region:       data.Get("region").(string),
+			InstanceType: data.Get("instance_type").(string),
+			GCP: &vmconfig.GCPOptions{
+				ImageFamily:  data.Get("gcp_image_family").(string),
+				ImageProject: data.Get("gcp_image_project").(string),
+				NetworkName:  data.Get("gcp_network_name").(string),
+			},
+			CredentialPath: data.Get("credentials").(string),
 		}
 	default:
 		return append(diags, diag.Diagnostic{
@@ -81,19 +83,21 @@ func CreateInstance(ctx context.Context, data *schema.ResourceData, m interface{
 			Region:         data.Get("region").(string),
 			InstanceType:   data.Get("instance_type").(string),
 			SubnetID:       data.Get("subnet_id").(string),
-			AWSAMI:         data.Get("aws_ami_id").(string),
+			AWS:            &vmconfig.AWSOptions{AMI: data.Get("aws_ami_id").(string)},
 			CredentialPath: data.Get("credentials").(string),
 		}
 	case "gcp":
 		provider = &cloud.GCProvider{}
 		vm = &vmconfig.VMConfig{
-			Name:            data.Get("name").(string),
-			Region:          data.Get("region").(string),
-			InstanceType:    data.Get("instance_type").(string),
-			GCPImageFamily:  data.Get("gcp_image_family").(string),
-			GCPImageProject: data.Get("gcp_image_project").(string),
-			GCPNetworkName:  data.Get("gcp_network_name").(string),
-			CredentialPath:  data.Get("credentials").(string),
+			Name:         data.Get("name").(string),
+			Region:       data.Get("region").(string),
+			InstanceType: data.Get("instance_type").(string),
+			GCP: &vmconfig.GCPOptions{
+				ImageFamily:  data.Get("gcp_image_family").(string),
+				ImageProject: data.Get("gcp_image_project").(string),
+				NetworkName:  data.Get("gcp_network_name").(string),
+			},
+			CredentialPath: data.Get("credentials").(string),
 		}
 	default:
 		return append(diags, diag.Diagnostic{
@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func GetBucketResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`), "must be 3 to 63 lowercase letters, digits, dots and hyphens, starting and ending with a letter or digit"),
+			Description:  "The globally unique name of the bucket.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the bucket.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The AWS region of the bucket, or its GCS location such as US or us-central1.",
+		},
+		"versioning": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether to keep the previous versions of overwritten and deleted objects.",
+		},
+		"lifecycle_rule": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Description: "Rules expiring objects some days after their creation.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"prefix": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The prefix of the objects to expire. Every object when empty.",
+					},
+					"expiration_days": {
+						Type:         schema.TypeInt,
+						Required:     true,
+						ValidateFunc: validation.IntAtLeast(1),
+						Description:  "The age in days at which the objects are deleted.",
+					},
+				},
+			},
+		},
+		"kms_key_id": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The KMS key (AWS) or Cloud KMS key name (GCP) encrypting new objects. Cloud-managed keys are used when empty.",
+		},
+		"force_destroy": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether to delete every object and object version when the bucket is destroyed. Otherwise only empty buckets can be destroyed.",
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the S3 bucket, or labels of the GCS bucket.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the bucket as applied by the cloud, including the provider default_tags.",
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func GetDNSRecordResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"zone_id": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The Route 53 hosted zone ID, or the Cloud DNS managed zone name.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the managed zone.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The fully qualified name of the record, such as web.example.com. The trailing dot is optional.",
+			// The record ID always carries the trailing dot, so an imported
+			// name matches the configuration with or without it.
+			DiffSuppressFunc: func(_, old, new string, _ *schema.ResourceData) bool {
+				return strings.TrimSuffix(old, ".") == strings.TrimSuffix(new, ".")
+			},
+		},
+		"type": {
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringInSlice([]string{"A", "AAAA", "CNAME"}, false),
+			Description:  "The record type: A, AAAA or CNAME.",
+		},
+		"ttl": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      300,
+			ValidateFunc: validation.IntAtLeast(0),
+			Description:  "The time to live of the record in seconds.",
+		},
+		"values": {
+			Type:         schema.TypeList,
+			Optional:     true,
+			Computed:     true,
+			Elem:         &schema.Schema{Type: schema.TypeString},
+			ExactlyOneOf: []string{"values", "server"},
+			Description:  "The addresses of an A or AAAA record, or the single target name of a CNAME record.",
+		},
+		"server": {
+			Type:         schema.TypeList,
+			Optional:     true,
+			MaxItems:     1,
+			ExactlyOneOf: []string{"values", "server"},
+			Description:  "A cloudfusion_server whose IP address is the value of the A record. The record follows the server when it is replaced, so its name stays stable.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"id": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "The id of the cloudfusion_server.",
+					},
+					"region": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The region of the server. Required on AWS.",
+					},
+					"zone": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The zone of the server. Required on GCP.",
+					},
+					"address": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      "public",
+						ValidateFunc: validation.StringInSlice([]string{"public", "private"}, false),
+						Description:  "Which IP address of the server to use: public or private.",
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// targetTagPattern is the format of GCE network tags, which firewall target
+// tags become on GCP.
+var targetTagPattern = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// targetTagSchema is a list of tags linking servers to firewalls.
+func targetTagSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Schema{
+			Type:         schema.TypeString,
+			ValidateFunc: validation.StringMatch(targetTagPattern, "must be 1-63 lowercase letters, digits or hyphens, starting with a letter"),
+		},
+		Description: description,
+	}
+}
+
+// firewallRuleResource is a firewall rule; cidrDescription says whether its
+// CIDR blocks are sources or destinations.
+func firewallRuleResource(cidrDescription string) *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"protocol": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{"tcp", "udp", "icmp", "all"}, false),
+				Description:  "The protocol: tcp, udp, icmp or all.",
+			},
+			"ports": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringMatch(portRangePattern, "must be a port such as \"22\" or a range such as \"8000-8080\""),
+				},
+				Description: "Ports or port ranges such as \"8000-8080\". Only for tcp and udp; all ports when omitted.",
+			},
+			"cidr_blocks": {
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsCIDR},
+				Description: cidrDescription,
+			},
+		},
+	}
+}
+
+func GetFirewallResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The name of the firewall: the security group name on AWS, and the prefix of its firewall rules on GCP.",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     "Managed by cloudfusion",
+			Description: "The description of the security group or firewall rules.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the firewall.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The region of the security group. Required on AWS.",
+		},
+		"network": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: "The VPC ID on AWS or the network name on GCP, such as the id of a cloudfusion_network. Defaults to the default VPC or network.",
+		},
+		"target_tags": targetTagSchema("The servers the rules apply to, by their firewall_tags. On GCP rules without target tags apply to every instance of the network; on AWS the security group is attached to the servers of its VPC with a matching tag, and detached from the others."),
+		"ingress": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Description: "Inbound traffic allowed to the targets.",
+			Elem:        firewallRuleResource("The source CIDR blocks."),
+		},
+		"egress": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Description: "Outbound traffic allowed from the targets. All outbound traffic is allowed when omitted; otherwise everything else is denied.",
+			Elem:        firewallRuleResource("The destination CIDR blocks."),
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the security group. AWS only, as GCE firewall rules do not support labels.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the firewall as applied by the cloud, including the provider default_tags.",
+		},
+	}
+}
@@ -0,0 +1,204 @@
+package schema
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func GetFleetResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The name of the fleet. Its servers are named <name>-<number>.",
+		},
+		"desired_count": {
+			Type:         schema.TypeInt,
+			Required:     true,
+			ValidateFunc: validation.IntAtLeast(0),
+			Description:  "The number of servers of the fleet.",
+		},
+		"parallelism": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      4,
+			ValidateFunc: validation.IntBetween(1, 32),
+			Description:  "The maximum number of servers created, read or deleted at once.",
+		},
+		"placement": {
+			Type:        schema.TypeList,
+			Required:    true,
+			MinItems:    1,
+			Description: "Where the servers go. desired_count is split between the placements in proportion to their weights, and the servers of a placement are spread evenly across its zones.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"cloud": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.StringInSlice([]string{"aws", "gcp"}, false),
+						Description:  "The cloud of the servers: the provider cloud_provider, or a cloud of fleet_credentials.",
+					},
+					"weight": {
+						Type:         schema.TypeInt,
+						Required:     true,
+						ValidateFunc: validation.IntAtLeast(0),
+						Description:  "The share of desired_count placed here, relative to the weights of the other placements, such as 60 and 40.",
+					},
+					"region": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The region of the servers. Required on AWS.",
+					},
+					"zones": {
+						Type:        schema.TypeList,
+						Required:    true,
+						MinItems:    1,
+						Elem:        &schema.Schema{Type: schema.TypeString},
+						Description: "The zones the servers are spread across.",
+					},
+					"instance_type": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "The instance type of the servers, such as t3.micro or e2-micro.",
+					},
+					"gcp_project": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The GCP project of the servers. Required on GCP.",
+					},
+					"subnet_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The subnet of the servers. On AWS a subnet lies in one zone, so it requires a single zone.",
+					},
+					"ami_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The AMI of the servers on AWS.",
+					},
+					"image_family": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The image family of the servers on GCP.",
+					},
+					"image_project": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The project of the image family on GCP.",
+					},
+					"network_name": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Default:     "default",
+						Description: "The network of the servers on GCP.",
+					},
+				},
+			},
+		},
+		"key_pair_name": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "The name of the EC2 key pair of the servers on AWS, such as the name of a cloudfusion_ssh_key.",
+		},
+		"ssh_public_keys": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Public keys in authorized_keys format granted SSH access, as on cloudfusion_server.",
+		},
+		"ssh_user": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Default:     "cloudfusion",
+			Description: "The user ssh_public_keys are granted to on GCP.",
+		},
+		"user_data": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "A script or cloud-init document run on first boot of each server.",
+		},
+		"public_ip": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice([]string{"none", "ephemeral", "static"}, false),
+			Description:  "How the servers get a public IP, as on cloudfusion_server.",
+		},
+		"firewall_tags": targetTagSchema("Tags selecting the cloudfusion_firewall resources that apply to the servers."),
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the servers, applied as EC2 tags or GCE labels. Changing them updates the servers in place.",
+		},
+		"members": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The servers of the fleet. A server whose placement or settings other than tags changed is replaced, the new server being created before the old one is deleted.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"id": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"cloud": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"region": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"zone": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"gcp_project": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"public_ip": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The public_ip the server was created with.",
+					},
+					"public_ip_address": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"private_ip_address": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"revision": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "A digest of the settings the server was created with.",
+					},
+				},
+			},
+		},
+		"member_ids": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The IDs of the servers, in the order of members.",
+		},
+		"public_ips": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The public IP addresses of the servers that have one.",
+		},
+		"private_ips": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The private IP addresses of the servers.",
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func GetImageResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The name of the AMI or GCE image.",
+		},
+		"server_id": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The id of the cloudfusion_server whose boot disk is captured.",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     "Managed by cloudfusion",
+			Description: "The description of the image.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the server and the image.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The region of the server and the AMI. Required on AWS.",
+		},
+		"zone": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The zone of the server. Required on GCP.",
+		},
+		"no_reboot": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether EC2 captures the image without rebooting the server, at the cost of consistency. AWS only; GCE images of a running server are crash consistent.",
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the AMI and its snapshots, or labels of the GCE image.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the image as applied by the cloud, including the provider default_tags.",
+		},
+	}
+}
@@ -0,0 +1,161 @@
+package schema
+
+import (
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// loadBalancerNamePattern leaves room in the 32 characters of an AWS target
+// group name for the "-<port>" suffix.
+var loadBalancerNamePattern = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,24}[a-z0-9])?$`)
+
+func GetLoadBalancerResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringMatch(loadBalancerNamePattern, "must be 1-26 lowercase letters, digits or hyphens, starting with a letter and not ending with a hyphen"),
+			Description:  "The name of the load balancer, which also prefixes the names of its target groups, health check and instance groups.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the load balancer.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The region of the load balancer and its servers.",
+		},
+		"internal": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether the load balancer only has a private address.",
+		},
+		"network": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The network name of an internal load balancer on GCP, such as the id of a cloudfusion_network. Defaults to the default network.",
+		},
+		"subnet_ids": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The subnets of the load balancer on AWS, at least two in different zones for http listeners. Defaults to the default subnets of the default VPC.",
+		},
+		"firewall_ids": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The cloudfusion_firewall ids attached to the load balancer on AWS when its listeners are http. Defaults to the default security group of the VPC.",
+		},
+		"listener": {
+			Type:        schema.TypeList,
+			Required:    true,
+			ForceNew:    true,
+			MinItems:    1,
+			Description: "The ports the load balancer accepts traffic on. Traffic goes to the same port of the servers.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"port": {
+						Type:         schema.TypeInt,
+						Required:     true,
+						ValidateFunc: validation.IsPortNumber,
+						Description:  "The port of the listener and of the servers.",
+					},
+					"protocol": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.StringInSlice([]string{"tcp", "udp", "http"}, false),
+						Description:  "The protocol: tcp, udp or http. http makes an application load balancer on AWS and is not supported on GCP.",
+					},
+				},
+			},
+		},
+		"health_check": {
+			Type:        schema.TypeList,
+			Required:    true,
+			MaxItems:    1,
+			Description: "The check deciding which servers receive traffic.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"protocol": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      "tcp",
+						ValidateFunc: validation.StringInSlice([]string{"tcp", "http"}, false),
+						Description:  "The protocol of the check: tcp or http.",
+					},
+					"port": {
+						Type:         schema.TypeInt,
+						Required:     true,
+						ValidateFunc: validation.IsPortNumber,
+						Description:  "The port of the servers to check.",
+					},
+					"path": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Default:     "/",
+						Description: "The path requested by an http check.",
+					},
+					"interval": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Default:      30,
+						ValidateFunc: validation.IntBetween(10, 300),
+						Description:  "The seconds between two checks of a server.",
+					},
+					"healthy_threshold": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Default:      3,
+						ValidateFunc: validation.IntBetween(2, 10),
+						Description:  "The successful checks after which a server receives traffic.",
+					},
+					"unhealthy_threshold": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Default:      3,
+						ValidateFunc: validation.IntBetween(2, 10),
+						Description:  "The failed checks after which a server no longer receives traffic.",
+					},
+				},
+			},
+		},
+		"server_ids": {
+			Type:        schema.TypeSet,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The ids of the cloudfusion_server resources receiving the traffic. On GCP they must be in the region of the load balancer, and allow the health check ranges through a cloudfusion_firewall.",
+		},
+		"address": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The DNS name of the load balancer on AWS, or the IP address of its forwarding rule on GCP.",
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the load balancer and its target groups, or labels of the forwarding rule on GCP.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the load balancer as applied by the cloud, including the provider default_tags.",
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func GetNetworkResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The name of the network. On AWS it is applied as the Name tag.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the network.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The region of the network on AWS, and the default region of the subnets on both clouds.",
+		},
+		"cidr_block": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.IsCIDR,
+			Description:  "The address range of the VPC. Required on AWS; GCP networks have no range of their own.",
+		},
+		"subnet": {
+			Type:        schema.TypeList,
+			Required:    true,
+			MinItems:    1,
+			Description: "The subnets of the network. Subnets are matched by name, and changing anything but the name replaces that subnet only.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "The name of the subnet, unique within the network.",
+					},
+					"cidr_block": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.IsCIDR,
+						Description:  "The address range of the subnet.",
+					},
+					"region": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The region of the subnet. Defaults to the network region; on AWS it must be the network region.",
+					},
+					"zone": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The availability zone of the subnet, picked by AWS when empty. AWS only.",
+					},
+					"public": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Default:     true,
+						Description: "Whether the subnet is routed to the internet gateway and assigns public IPs on launch. Can only be false on AWS.",
+					},
+					"id": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The ID of the subnet, usable as subnet_id of cloudfusion_server.",
+					},
+				},
+			},
+		},
+		"subnet_ids": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The IDs of the subnets by name.",
+		},
+		"network_name": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The name of the network, usable as gcp.network_name of cloudfusion_server.",
+		},
+		"internet_gateway_id": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The ID of the internet gateway. AWS only.",
+		},
+		"route_table_id": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The ID of the route table of the public subnets. AWS only.",
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags applied to the VPC and everything created with it. GCP networks do not support labels.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the network as applied by the cloud, including the provider default_tags.",
+		},
+	}
+}
@@ -1,6 +1,15 @@
 package schema
 
-import "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+var portRangePattern = regexp.MustCompile(`^[0-9]{1,5}(-[0-9]{1,5})?$`)
 
 func GetVMResourceSchema() map[string]*schema.Schema {
 	return map[string]*schema.Schema{
@@ -10,9 +19,18 @@ func GetVMResourceSchema() map[string]*schema.Schema {
 			Computed: true,
 		},
 		"name": {
-			Type:        schema.TypeString,
-			Required:    true,
-			Description: "The name of the virtual machine.",
+			Type:          schema.TypeString,
+			Optional:      true,
+			Computed:      true,
+			ConflictsWith: []string{"name_prefix"},
+			Description:   "The name of the virtual machine. On AWS it is applied as the Name tag and renaming retags the instance, unless ingress is set; on GCP renaming replaces the virtual machine. Generated when neither name nor name_prefix is set.",
+		},
+		"name_prefix": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"name"},
+			Description:   "Creates a unique name beginning with the prefix followed by 8 random lowercase letters and digits.",
 		},
 		"gcp_project": {
 			Type:        schema.TypeString,
@@ -23,7 +41,15 @@ func GetVMResourceSchema() map[string]*schema.Schema {
 		"region": {
 			Type:        schema.TypeString,
 			Optional:    true,
-			Description: "The region where the virtual machine should be deployed.",
+			ForceNew:    true,
+			Description: "The region where the virtual machine should be deployed. Changing it replaces the virtual machine.",
+		},
+		"zone": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: "The zone where the virtual machine should be deployed. When omitted, a zone of the region that offers the instance type is picked.",
 		},
 		"instance_type": {
 			Type:        schema.TypeString,
@@ -33,38 +59,459 @@ func GetVMResourceSchema() map[string]*schema.Schema {
 		"key_pair_name": {
 			Type:        schema.TypeString,
 			Optional:    true,
-			Description: "The name of the SSH key pair for authentication.",
+			Description: "The name of the SSH key pair for authentication, such as the name of a cloudfusion_ssh_key (AWS-specific). On GCP the keys of cloudfusion_ssh_key are granted project-wide.",
 		},
-		"subnet_id": {
-			Type:        schema.TypeString,
+		"ssh_public_keys": {
+			Type:        schema.TypeList,
 			Optional:    true,
-			Description: "The ID of the subnet where the virtual machine should be placed.",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Public keys in authorized_keys format granted SSH access. Written to the GCE ssh-keys metadata, or imported as an EC2 key pair on AWS, which allows a single key.",
 		},
-		"aws_security_group": {
+		"ssh_user": {
 			Type:        schema.TypeString,
 			Optional:    true,
-			Description: "the security group of the aws instance",
+			Default:     "cloudfusion",
+			Description: "The user ssh_public_keys are granted to on GCP. On AWS the user is defined by the image.",
 		},
-		"aws_ami_id": {
+		"subnet_id": {
 			Type:        schema.TypeString,
 			Optional:    true,
-			Description: "The ID of the AWS AMI to use for the virtual machine (AWS-specific).",
+			Description: "The ID of the subnet where the virtual machine should be placed. On GCP this is the subnetwork path, such as a subnet ID of cloudfusion_network, and gcp.network_name must name its network.",
 		},
-		"gcp_image_family": {
-			Type:        schema.TypeString,
+		"tags": {
+			Type:        schema.TypeMap,
 			Optional:    true,
-			Description: "The image family of the GCP image to use for the virtual machine (GCP-specific).",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the virtual machine, applied as EC2 tags or GCE labels. GCE labels are lowercased, characters other than letters, digits, '_' and '-' become '_', keys not starting with a letter are prefixed with 'tag_', and keys and values are cut to 63 characters.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the virtual machine as applied by the cloud, including the provider default_tags.",
+		},
+		"public_ip": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringInSlice([]string{"none", "ephemeral", "static"}, false),
+			Description:  "How the virtual machine gets a public IP: none, ephemeral, or static for an Elastic IP or reserved GCE address that survives stop and start and is released on delete. Defaults to the subnet default on AWS and ephemeral on GCP.",
+		},
+		"public_ip_address": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The public IP address of the virtual machine, if it has one.",
 		},
-		"gcp_image_project": {
+		"private_ip_address": {
 			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The private IP address of the virtual machine.",
+		},
+		"wait_for": {
+			Type:        schema.TypeList,
 			Optional:    true,
-			Description: "The project ID of the GCP image to use for the virtual machine (GCP-specific).",
+			MaxItems:    1,
+			Description: "A readiness check that must pass before the virtual machine is considered created.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"type": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.StringInSlice([]string{"tcp", "ssh", "http"}, false),
+						Description:  "The kind of check: tcp connects to a port, ssh completes an SSH handshake, http expects a status code from a URL.",
+					},
+					"port": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						ValidateFunc: validation.IsPortNumber,
+						Description:  "The port to check. Defaults to 22 for ssh and 80 for http, and is required for tcp.",
+					},
+					"path": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Default:     "/",
+						Description: "The path requested by http checks.",
+					},
+					"status_code": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Default:      200,
+						ValidateFunc: validation.IntBetween(100, 599),
+						Description:  "The status code an http check expects.",
+					},
+					"private_key": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						Description: "A private key to log in as ssh_user. Without it an ssh check only requires the server to complete the handshake.",
+					},
+					"use_private_ip": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Description: "Whether to check the private IP address instead of the public one.",
+					},
+					"timeout": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      "5m",
+						ValidateFunc: validateDuration,
+						Description:  "How long to keep checking before the create fails.",
+					},
+					"bastion": {
+						Type:        schema.TypeList,
+						Optional:    true,
+						MaxItems:    1,
+						Description: "An SSH host to run the check through.",
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"host": {
+									Type:        schema.TypeString,
+									Required:    true,
+									Description: "The address of the bastion host.",
+								},
+								"port": {
+									Type:         schema.TypeInt,
+									Optional:     true,
+									Default:      22,
+									ValidateFunc: validation.IsPortNumber,
+									Description:  "The SSH port of the bastion host.",
+								},
+								"user": {
+									Type:        schema.TypeString,
+									Required:    true,
+									Description: "The user to log in to the bastion host as.",
+								},
+								"private_key": {
+									Type:        schema.TypeString,
+									Required:    true,
+									Sensitive:   true,
+									Description: "The private key to log in to the bastion host with.",
+								},
+								"host_key": {
+									Type:        schema.TypeString,
+									Optional:    true,
+									Description: "The public key of the bastion host in authorized_keys format. The host key is not verified when empty.",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"user_data": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"cloud_init"},
+			Description:   "A script or cloud-init document run on first boot. Passed as EC2 user data, or as the GCE user-data metadata key for cloud-config documents and the startup-script key otherwise.",
 		},
-		"gcp_network_name": {
+		"cloud_init": {
+			Type:          schema.TypeList,
+			Optional:      true,
+			ForceNew:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{"user_data"},
+			Description:   "A cloud-config document run on first boot, rendered to YAML and passed like user_data.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"package_update": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Description: "Whether to update the package index on first boot.",
+					},
+					"packages": {
+						Type:        schema.TypeList,
+						Optional:    true,
+						Elem:        &schema.Schema{Type: schema.TypeString},
+						Description: "Packages to install on first boot.",
+					},
+					"runcmd": {
+						Type:        schema.TypeList,
+						Optional:    true,
+						Elem:        &schema.Schema{Type: schema.TypeString},
+						Description: "Commands to run on first boot.",
+					},
+					"write_files": {
+						Type:        schema.TypeList,
+						Optional:    true,
+						Description: "Files to write on first boot.",
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"path": {
+									Type:        schema.TypeString,
+									Required:    true,
+									Description: "The absolute path of the file.",
+								},
+								"content": {
+									Type:        schema.TypeString,
+									Required:    true,
+									Description: "The content of the file.",
+								},
+								"owner": {
+									Type:        schema.TypeString,
+									Optional:    true,
+									Description: "The owner of the file, as user:group.",
+								},
+								"permissions": {
+									Type:        schema.TypeString,
+									Optional:    true,
+									Description: "The octal permissions of the file, such as 0644.",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"ingress": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Description: "Inbound traffic allowed to the virtual machine. Managed as a security group on AWS and as firewall rules targeting a network tag on GCP.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"protocol": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.StringInSlice([]string{"tcp", "udp", "icmp", "all"}, false),
+						Description:  "The protocol: tcp, udp, icmp or all.",
+					},
+					"ports": {
+						Type:     schema.TypeList,
+						Optional: true,
+						Elem: &schema.Schema{
+							Type:         schema.TypeString,
+							ValidateFunc: validation.StringMatch(portRangePattern, "must be a port such as \"22\" or a range such as \"8000-8080\""),
+						},
+						Description: "Ports or port ranges such as \"8000-8080\". Only for tcp and udp; all ports when omitted.",
+					},
+					"cidr_blocks": {
+						Type:        schema.TypeList,
+						Required:    true,
+						MinItems:    1,
+						Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsCIDR},
+						Description: "The source CIDR blocks.",
+					},
+				},
+			},
+		},
+		"firewall_tags": targetTagSchema("Tags selecting the cloudfusion_firewall resources that apply to the virtual machine. They become network tags on GCP; on AWS the security groups of matching firewalls in the VPC are attached."),
+		"capacity": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      "on_demand",
+			ValidateFunc: validation.StringInSlice([]string{"on_demand", "spot"}, false),
+			Description:  "The capacity model: on_demand, or spot for discounted capacity the cloud may reclaim (EC2 spot, GCE Spot VMs).",
+		},
+		"max_price": {
 			Type:        schema.TypeString,
 			Optional:    true,
-			Description: "The name of the network to attach the virtual machine to (GCP-specific).",
-			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_NETWORK", "default"),
+			ForceNew:    true,
+			Description: "The maximum hourly price in USD for spot capacity (AWS-specific). Defaults to the on-demand price.",
+		},
+		"interruption_behavior": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringInSlice([]string{"stop", "terminate", "delete"}, false),
+			Description:  "What happens when spot capacity is reclaimed: stop, or terminate/delete the virtual machine. Defaults to the cloud default.",
+		},
+		"power_state": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      "running",
+			ValidateFunc: validation.StringInSlice([]string{"running", "stopped", "suspended"}, false),
+			Description:  "The desired power state: running, stopped or suspended. Suspending an AWS instance hibernates it and requires aws.hibernation.",
+		},
+		"deletion_protection": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether the instance is protected from deletion, both by this provider and by the cloud API. It must be disabled before the server can be destroyed or replaced.",
+		},
+		"service_identity": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			MaxItems:    1,
+			Description: "The cloud identity the virtual machine runs as, so workloads can call cloud APIs without keys.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"instance_profile": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The name or ARN of the IAM instance profile. AWS only.",
+					},
+					"service_account_email": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The email of the service account. Defaults to the Compute Engine default service account. GCP only.",
+					},
+					"scopes": {
+						Type:        schema.TypeList,
+						Optional:    true,
+						Elem:        &schema.Schema{Type: schema.TypeString},
+						Description: "The OAuth scopes granted to the service account, as URLs or short names such as cloud-platform. Defaults to cloud-platform. GCP only.",
+					},
+				},
+			},
+		},
+		"source_image_id": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"source_snapshot"},
+			Description:   "The image to boot from, such as the id of a cloudfusion_image: an AMI ID on AWS, an image name or path on GCP. Overrides aws.ami_id and gcp.image_family.",
+		},
+		"source_snapshot": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			ForceNew:      true,
+			ConflictsWith: []string{"source_image_id"},
+			Description:   "The boot disk snapshot to restore, such as the id of a cloudfusion_snapshot: a snapshot ID on AWS, a snapshot name or path on GCP.",
 		},
+		"boot_disk": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			MaxItems:    1,
+			Description: "Overrides the disk created from the image, as the root block device of the AMI or the GCE boot disk. Unset values keep the image defaults.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"size_gb": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						ForceNew:     true,
+						ValidateFunc: validation.IntAtLeast(1),
+						Description:  "The size of the boot disk in GB. Must be at least the size of the image.",
+					},
+					"type": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						ForceNew:     true,
+						ValidateFunc: validation.StringInSlice([]string{"standard", "ssd", "provisioned_iops"}, false),
+						Description:  "The performance class: standard (EBS standard, pd-standard), ssd (gp3, pd-ssd) or provisioned_iops (io2, pd-extreme).",
+					},
+					"iops": {
+						Type:        schema.TypeInt,
+						Optional:    true,
+						ForceNew:    true,
+						Description: "The provisioned IOPS, required for provisioned_iops disks.",
+					},
+					"kms_key_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						ForceNew:    true,
+						Description: "The KMS key (AWS) or Cloud KMS key name (GCP) encrypting the boot disk.",
+					},
+				},
+			},
+		},
+		"data_disk": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Description: "Additional disks created with the virtual machine, as EBS volumes or GCE persistent disks. Disks are matched by device_name on update.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"device_name": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "The device name, such as \"sdf\". \"/dev/\" is prepended on AWS. It identifies the disk, so renaming it replaces the disk.",
+					},
+					"size_gb": {
+						Type:         schema.TypeInt,
+						Required:     true,
+						ValidateFunc: validation.IntAtLeast(1),
+						Description:  "The size of the disk in GB. Disks can grow but not shrink.",
+					},
+					"type": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      "ssd",
+						ValidateFunc: validation.StringInSlice([]string{"standard", "ssd", "provisioned_iops"}, false),
+						Description:  "The performance class: standard (EBS standard, pd-standard), ssd (gp3, pd-ssd) or provisioned_iops (io2, pd-extreme). Fixed once created on GCP.",
+					},
+					"iops": {
+						Type:        schema.TypeInt,
+						Optional:    true,
+						Description: "The provisioned IOPS, required for provisioned_iops disks. Fixed once created on GCP.",
+					},
+					"kms_key_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The KMS key (AWS) or Cloud KMS key name (GCP) encrypting the disk. Fixed once created.",
+					},
+					"auto_delete": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Default:     true,
+						Description: "Whether the disk is deleted with the virtual machine or when it is removed from the configuration.",
+					},
+				},
+			},
+		},
+		"aws": {
+			Type:          schema.TypeList,
+			Optional:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{"gcp"},
+			Description:   "AWS-specific settings for the virtual machine.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"ami_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The ID of the AWS AMI to use for the virtual machine.",
+					},
+					"security_group": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The ID of an existing security group to attach to the aws instance.",
+					},
+					"hibernation": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						ForceNew:    true,
+						Description: "Whether the instance can hibernate, which suspending it requires.",
+					},
+				},
+			},
+		},
+		"gcp": {
+			Type:          schema.TypeList,
+			Optional:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{"aws"},
+			Description:   "GCP-specific settings for the virtual machine.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"image_family": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The image family of the GCP image to use for the virtual machine.",
+					},
+					"image_project": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The project ID of the GCP image to use for the virtual machine.",
+					},
+					"network_name": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The name of the network to attach the virtual machine to.",
+						DefaultFunc: schema.EnvDefaultFunc("GCLOUD_NETWORK", "default"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// validateDuration checks that a string parses with time.ParseDuration.
+func validateDuration(v interface{}, k string) ([]string, []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%q must be a duration such as 5m or 30s: %w", k, err)}
 	}
+	return nil, nil
 }
@@ -0,0 +1,49 @@
+package schema
+
+import (
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func GetSSHKeyResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[a-zA-Z0-9._-]{1,255}$`), "must be letters, digits, '.', '_' and '-'"),
+			Description:  "The name of the key: the EC2 key pair name, which key_pair_name of cloudfusion_server can reference, or the comment of the ssh-keys entry on GCP after a \"cloudfusion-\" prefix.",
+		},
+		"public_key": {
+			Type:        schema.TypeString,
+			Required:    true,
+			Description: "The public key in authorized_keys format. Changing it rotates the key in place without replacing the servers using it.",
+		},
+		"user": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Default:     "cloudfusion",
+			Description: "The user the key is granted to on GCP. On AWS the user is defined by the image.",
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The region of the key pair. Required on AWS.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project whose ssh-keys metadata holds the key.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"fingerprint": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The fingerprint of the key, as shown by EC2 on AWS and as the MD5 fingerprint on GCP.",
+		},
+	}
+}
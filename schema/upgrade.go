@@ -0,0 +1,646 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// VMResourceSchemaVersion is the current version of the cloudfusion_server schema.
+// Bump it together with a new entry in VMResourceStateUpgraders whenever the
+// layout of the state changes.
+const VMResourceSchemaVersion = 3
+
+// VMResourceStateUpgraders returns the upgraders that bring older
+// cloudfusion_server states up to VMResourceSchemaVersion.
+func VMResourceStateUpgraders() []schema.StateUpgrader {
+	return []schema.StateUpgrader{
+		{
+			Version: 0,
+			Type:    vmResourceV0().CoreConfigSchema().ImpliedType(),
+			Upgrade: UpgradeVMResourceStateV0,
+		},
+		{
+			Version: 1,
+			Type:    vmResourceV1().CoreConfigSchema().ImpliedType(),
+			Upgrade: UpgradeVMResourceStateV1,
+		},
+		{
+			Version: 2,
+			Type:    vmResourceV2().CoreConfigSchema().ImpliedType(),
+			Upgrade: UpgradeVMResourceStateV2,
+		},
+	}
+}
+
+// vmResourceV0 is the flat layout where cloud-specific settings were stored as
+// aws_* and gcp_* attributes. It must not be changed.
+func vmResourceV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"id": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"gcp_project": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"region": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"instance_type": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"key_pair_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"subnet_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"aws_security_group": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"aws_ami_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"gcp_image_family": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"gcp_image_project": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"gcp_network_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+		},
+	}
+}
+
+// UpgradeVMResourceStateV0 moves the flat aws_* and gcp_* attributes into the
+// aws and gcp blocks. A block is only written when it carries something the
+// user configured, so an AWS server does not gain a gcp block just because
+// gcp_network_name defaulted to "default".
+func UpgradeVMResourceStateV0(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+	if rawState == nil {
+		return nil, nil
+	}
+	amiID := popString(rawState, "aws_ami_id")
+	securityGroup := popString(rawState, "aws_security_group")
+	if amiID != "" || securityGroup != "" {
+		rawState["aws"] = []interface{}{
+			map[string]interface{}{
+				"ami_id":         amiID,
+				"security_group": securityGroup,
+			},
+		}
+	}
+
+	imageFamily := popString(rawState, "gcp_image_family")
+	imageProject := popString(rawState, "gcp_image_project")
+	networkName := popString(rawState, "gcp_network_name")
+	if imageFamily != "" || imageProject != "" || (networkName != "" && networkName != "default") {
+		if networkName == "" {
+			networkName = "default"
+		}
+		rawState["gcp"] = []interface{}{
+			map[string]interface{}{
+				"image_family":  imageFamily,
+				"image_project": imageProject,
+				"network_name":  networkName,
+			},
+		}
+	}
+	return rawState, nil
+}
+
+// popString removes key from the raw state and returns its value, or "" when
+// the key is absent or not a string.
+func popString(rawState map[string]interface{}, key string) string {
+	v, _ := rawState[key].(string)
+	delete(rawState, key)
+	return v
+}
+
+// vmResourceV1 is the layout with nested aws and gcp blocks, before zone was
+// split out of region. It must not be changed.
+func vmResourceV1() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"id": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"gcp_project": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"region": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"instance_type": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"key_pair_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"subnet_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"aws": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ami_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"security_group": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"gcp": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"image_family": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"image_project": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"network_name": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+var (
+	// gcpZonePattern matches Compute Engine zones such as europe-west1-b.
+	gcpZonePattern = regexp.MustCompile(`^([a-z]+-[a-z]+[0-9]+)-[a-z]$`)
+	// awsZonePattern matches EC2 availability zones such as eu-west-1a.
+	awsZonePattern = regexp.MustCompile(`^([a-z]{2}(?:-gov)?-[a-z]+-[0-9]+)[a-z]$`)
+)
+
+// UpgradeVMResourceStateV1 splits zone out of region. Before version 2 the
+// GCP backend used region as the zone, so a region such as "europe-west1-b"
+// becomes zone "europe-west1-b" in region "europe-west1".
+func UpgradeVMResourceStateV1(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+	if rawState == nil {
+		return nil, nil
+	}
+	region, _ := rawState["region"].(string)
+	if parent, ok := RegionOfZone(region); ok {
+		rawState["zone"] = region
+		rawState["region"] = parent
+	}
+	return rawState, nil
+}
+
+// RegionOfZone returns the region a GCP or AWS zone belongs to. The boolean is
+// false when zone does not look like a zone.
+func RegionOfZone(zone string) (string, bool) {
+	if m := gcpZonePattern.FindStringSubmatch(zone); m != nil {
+		return m[1], true
+	}
+	if m := awsZonePattern.FindStringSubmatch(zone); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// vmResourceV2 is the layout where data_disk.device_name was optional and
+// defaulted by position. It declares every attribute of that version, since
+// the state is decoded through it before the upgrade. It must not be changed.
+func vmResourceV2() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"id": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			"aws": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ami_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"hibernation": {
+							Type:     schema.TypeBool,
+							Optional: true,
+						},
+						"security_group": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"boot_disk": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"iops": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+						"kms_key_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"size_gb": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"capacity": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"cloud_init": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"package_update": {
+							Type:     schema.TypeBool,
+							Optional: true,
+						},
+						"packages": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"runcmd": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"write_files": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"content": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"owner": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"path": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"permissions": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"data_disk": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"auto_delete": {
+							Type:     schema.TypeBool,
+							Optional: true,
+						},
+						"device_name": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"iops": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+						"kms_key_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"size_gb": {
+							Type:     schema.TypeInt,
+							Required: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"deletion_protection": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+			"firewall_tags": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"gcp": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"image_family": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"image_project": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"network_name": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"gcp_project": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"ingress": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cidr_blocks": {
+							Type:     schema.TypeList,
+							Required: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"ports": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"protocol": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+			"instance_type": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"interruption_behavior": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"key_pair_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"max_price": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"name": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			"name_prefix": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"power_state": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"private_ip_address": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"public_ip": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			"public_ip_address": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"region": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"service_identity": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"instance_profile": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"scopes": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"service_account_email": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"source_image_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"source_snapshot": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"ssh_public_keys": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"ssh_user": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"subnet_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"tags": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"tags_all": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"user_data": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"wait_for": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"bastion": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"host": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"host_key": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"port": {
+										Type:     schema.TypeInt,
+										Optional: true,
+									},
+									"private_key": {
+										Type:      schema.TypeString,
+										Required:  true,
+										Sensitive: true,
+									},
+									"user": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+								},
+							},
+						},
+						"path": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"port": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+						"private_key": {
+							Type:      schema.TypeString,
+							Optional:  true,
+							Sensitive: true,
+						},
+						"status_code": {
+							Type:     schema.TypeInt,
+							Optional: true,
+						},
+						"timeout": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"use_private_ip": {
+							Type:     schema.TypeBool,
+							Optional: true,
+						},
+					},
+				},
+			},
+			"zone": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// UpgradeVMResourceStateV2 writes down the device names that version 2 gave
+// data disks by position, sdf, sdg, ..., which are the names the disks were
+// attached with. device_name is required from version 3 on, so disks are only
+// ever matched by name.
+func UpgradeVMResourceStateV2(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+	if rawState == nil {
+		return nil, nil
+	}
+	disks, _ := rawState["data_disk"].([]interface{})
+	for i, d := range disks {
+		disk, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := disk["device_name"].(string); name == "" {
+			disk["device_name"] = fmt.Sprintf("sd%c", 'f'+i)
+		}
+	}
+	return rawState, nil
+}
@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeVMResourceStateV0_GCP(t *testing.T) {
+	v0 := map[string]interface{}{
+		"id":                "1009513919837837499",
+		"name":              "toto",
+		"gcp_project":       "dantata",
+		"region":            "europe-west1-b",
+		"instance_type":     "e2-small",
+		"gcp_image_family":  "ubuntu-2004-lts",
+		"gcp_image_project": "ubuntu-os-cloud",
+		"gcp_network_name":  "default",
+	}
+	expected := map[string]interface{}{
+		"id":            "1009513919837837499",
+		"name":          "toto",
+		"gcp_project":   "dantata",
+		"region":        "europe-west1-b",
+		"instance_type": "e2-small",
+		"gcp": []interface{}{
+			map[string]interface{}{
+				"image_family":  "ubuntu-2004-lts",
+				"image_project": "ubuntu-os-cloud",
+				"network_name":  "default",
+			},
+		},
+	}
+	actual, err := UpgradeVMResourceStateV0(context.Background(), v0, nil)
+	assert.NoError(t, err, "upgrade should not return an error")
+	assert.Equal(t, expected, actual)
+}
+
+func TestUpgradeVMResourceStateV0_AWS(t *testing.T) {
+	v0 := map[string]interface{}{
+		"id":                 "i-0123456789abcdef0",
+		"name":               "toto",
+		"region":             "eu-west-1",
+		"aws_ami_id":         "ami-12345678",
+		"aws_security_group": "sg-12345678",
+		"gcp_network_name":   "default",
+	}
+	expected := map[string]interface{}{
+		"id":     "i-0123456789abcdef0",
+		"name":   "toto",
+		"region": "eu-west-1",
+		"aws": []interface{}{
+			map[string]interface{}{
+				"ami_id":         "ami-12345678",
+				"security_group": "sg-12345678",
+			},
+		},
+	}
+	actual, err := UpgradeVMResourceStateV0(context.Background(), v0, nil)
+	assert.NoError(t, err, "upgrade should not return an error")
+	assert.Equal(t, expected, actual, "the defaulted gcp network should not create a gcp block")
+}
+
+func TestVMResourceStateUpgraders_Versions(t *testing.T) {
+	upgraders := VMResourceStateUpgraders()
+	assert.Len(t, upgraders, VMResourceSchemaVersion, "every older version needs an upgrader")
+	for i, upgrader := range upgraders {
+		assert.Equal(t, i, upgrader.Version)
+		assert.NotNil(t, upgrader.Upgrade)
+	}
+}
+
+func TestUpgradeVMResourceStateV1(t *testing.T) {
+	cases := []struct {
+		region       string
+		expectRegion string
+		expectZone   string
+	}{
+		{region: "europe-west1-b", expectRegion: "europe-west1", expectZone: "europe-west1-b"},
+		{region: "eu-west-1a", expectRegion: "eu-west-1", expectZone: "eu-west-1a"},
+		{region: "us-gov-west-1b", expectRegion: "us-gov-west-1", expectZone: "us-gov-west-1b"},
+		{region: "europe-west1", expectRegion: "europe-west1"},
+		{region: "eu-west-1", expectRegion: "eu-west-1"},
+	}
+	for _, c := range cases {
+		v1 := map[string]interface{}{
+			"id":     "1009513919837837499",
+			"region": c.region,
+		}
+		actual, err := UpgradeVMResourceStateV1(context.Background(), v1, nil)
+		assert.NoError(t, err, "upgrade should not return an error")
+		assert.Equal(t, c.expectRegion, actual["region"], c.region)
+		if c.expectZone == "" {
+			assert.NotContains(t, actual, "zone", c.region)
+		} else {
+			assert.Equal(t, c.expectZone, actual["zone"], c.region)
+		}
+	}
+}
+
+func TestUpgradeVMResourceStateV2(t *testing.T) {
+	v2 := map[string]interface{}{
+		"id": "i-0123456789abcdef0",
+		"data_disk": []interface{}{
+			map[string]interface{}{"device_name": "", "size_gb": 10},
+			map[string]interface{}{"device_name": "sdx", "size_gb": 20},
+			map[string]interface{}{"device_name": "", "size_gb": 30},
+		},
+	}
+	actual, err := UpgradeVMResourceStateV2(context.Background(), v2, nil)
+	assert.NoError(t, err, "upgrade should not return an error")
+	var names []string
+	for _, disk := range actual["data_disk"].([]interface{}) {
+		names = append(names, disk.(map[string]interface{})["device_name"].(string))
+	}
+	assert.Equal(t, []string{"sdf", "sdx", "sdh"}, names, "unnamed disks keep the name they were attached with")
+
+	actual, err = UpgradeVMResourceStateV2(context.Background(), map[string]interface{}{"id": "1"}, nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, actual, "data_disk")
+}
+
+func TestVMResourceStateV2KeepsEveryAttribute(t *testing.T) {
+	v2 := VMResourceStateUpgraders()[2].Type
+	for _, k := range []string{"name", "gcp_project", "region", "zone", "instance_type", "aws", "gcp", "tags", "tags_all", "boot_disk", "data_disk", "ingress", "power_state", "public_ip_address", "wait_for"} {
+		assert.True(t, v2.HasAttribute(k), "a version 2 state is decoded through its type, so %s would be dropped", k)
+	}
+}
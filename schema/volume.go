@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func GetVolumeResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The name of the volume: the Name tag on AWS and the disk name on GCP.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the disk.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The region of the volume. Required on AWS.",
+		},
+		"zone": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The availability zone of the volume. Only servers in this zone can attach it.",
+		},
+		"size_gb": {
+			Type:         schema.TypeInt,
+			Required:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "The size of the volume in GB. Volumes can grow but not shrink.",
+		},
+		"type": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      "ssd",
+			ValidateFunc: validation.StringInSlice([]string{"standard", "ssd", "provisioned_iops"}, false),
+			Description:  "The performance class: standard (EBS standard, pd-standard), ssd (gp3, pd-ssd) or provisioned_iops (io2, pd-extreme). It can only change on AWS.",
+		},
+		"iops": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Description: "The provisioned IOPS, required for provisioned_iops volumes and rejected for the other types. It can only change on AWS.",
+		},
+		"kms_key_id": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The KMS key (AWS) or Cloud KMS key name (GCP) encrypting the volume.",
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the EBS volume, or labels of the persistent disk.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the volume as applied by the cloud, including the provider default_tags.",
+		},
+	}
+}
+
+func GetVolumeAttachmentResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"server_id": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The id of the cloudfusion_server to attach the volume to.",
+		},
+		"volume_id": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The id of the cloudfusion_volume to attach.",
+		},
+		"device_name": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: "The device name, such as \"sdf\". \"/dev/\" is prepended on AWS. Required on AWS; picked by GCE when omitted.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the server and the disk.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The region of the server and the volume. Required on AWS.",
+		},
+		"zone": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The zone of the server and the disk. Required on GCP.",
+		},
+	}
+}
+
+func GetSnapshotResourceSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The name of the snapshot: the Name tag on AWS and the snapshot name on GCP.",
+		},
+		"volume_id": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "The id of the cloudfusion_volume to snapshot. Any EBS volume ID or persistent disk name in the zone works too.",
+		},
+		"description": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     "Managed by cloudfusion",
+			Description: "The description of the snapshot.",
+		},
+		"gcp_project": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The GCP project of the disk and the snapshot.",
+			DefaultFunc: schema.EnvDefaultFunc("GCLOUD_PROJECT", nil),
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The region of the volume and the snapshot. Required on AWS.",
+		},
+		"zone": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The zone of the disk. Required on GCP.",
+		},
+		"size_gb": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "The size of the snapshotted volume in GB, the minimum size of volumes restored from it.",
+		},
+		"tags": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Tags of the EBS snapshot, or labels of the GCE snapshot.",
+		},
+		"tags_all": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The tags of the snapshot as applied by the cloud, including the provider default_tags.",
+		},
+	}
+}
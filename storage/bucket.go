@@ -0,0 +1,22 @@
+package storage
+
+// BucketConfig is an object storage bucket: an S3 bucket or a GCS bucket.
+type BucketConfig struct {
+	ID            string // The bucket name on both clouds
+	Name          string
+	Region        string // AWS region, or GCS location such as "US" or "us-central1"
+	GCPProjectID  string
+	Versioning    bool
+	Lifecycle     []LifecycleRule
+	KMSKeyID      string // AWS KMS key ID or Cloud KMS key name; cloud-managed keys when empty
+	ForceDestroy  bool   // Delete the objects and their versions with the bucket
+	Tags          map[string]string
+	IgnoreTagKeys []string // Tags managed outside of Terraform
+}
+
+// LifecycleRule expires the objects under Prefix ExpirationDays after their
+// creation.
+type LifecycleRule struct {
+	Prefix         string // Every object when empty
+	ExpirationDays int64
+}
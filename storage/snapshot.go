@@ -0,0 +1,16 @@
+package storage
+
+// SnapshotConfig is a point-in-time copy of a single volume: an EBS snapshot
+// or a GCE disk snapshot.
+type SnapshotConfig struct {
+	ID            string // Snapshot ID on AWS, snapshot name on GCP
+	Name          string
+	Description   string
+	VolumeID      string
+	Region        string // AWS only
+	Zone          string // GCP only, the zone of the disk
+	GCPProjectID  string
+	SizeGB        int64 // Read from the cloud
+	Tags          map[string]string
+	IgnoreTagKeys []string // Tags managed outside of Terraform
+}
@@ -0,0 +1,27 @@
+package storage
+
+// VolumeConfig is a block storage volume whose lifecycle is independent of
+// servers: an EBS volume or a GCE persistent disk.
+type VolumeConfig struct {
+	ID            string // Volume ID on AWS, disk name on GCP
+	Name          string
+	Region        string // AWS only
+	Zone          string
+	GCPProjectID  string
+	SizeGB        int64
+	Type          string // standard, ssd or provisioned_iops
+	IOPS          int64  // provisioned_iops only
+	KMSKeyID      string // AWS KMS key ID or GCE CMEK name
+	Tags          map[string]string
+	IgnoreTagKeys []string // Tags managed outside of Terraform
+}
+
+// VolumeAttachment attaches a volume to a server.
+type VolumeAttachment struct {
+	ServerID     string
+	VolumeID     string
+	DeviceName   string // "sdf" style; "/dev/" is prepended on AWS. Picked by GCE when empty
+	Region       string // AWS only
+	Zone         string // GCP only
+	GCPProjectID string
+}
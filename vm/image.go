@@ -0,0 +1,16 @@
+package vm
+
+// ImageConfig is a machine image captured from the boot disk of a server: an
+// AMI or a GCE image.
+type ImageConfig struct {
+	ID            string // AMI ID on AWS, image name on GCP
+	Name          string
+	Description   string
+	ServerID      string
+	Region        string // AWS only
+	Zone          string // GCP only, the zone of the server
+	GCPProjectID  string
+	NoReboot      bool // AWS only; GCE images of a running server are never rebooted
+	Tags          map[string]string
+	IgnoreTagKeys []string // Tags managed outside of Terraform
+}
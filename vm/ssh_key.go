@@ -0,0 +1,13 @@
+package vm
+
+// SSHKeyConfig is a public key registered with the cloud: an EC2 key pair, or
+// an entry of the project-wide ssh-keys metadata on GCE.
+type SSHKeyConfig struct {
+	ID           string // The key name on both clouds
+	Name         string
+	PublicKey    string // authorized_keys line
+	User         string // The user the key is granted to on GCP
+	Region       string
+	GCPProjectID string
+	Fingerprint  string
+}
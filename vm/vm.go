@@ -1,18 +1,91 @@
 package vm
 
 type VMConfig struct {
-	ID               string
-	Name             string
-	Region           string
-	InstanceType     string
-	KeyPairName      string
-	SubnetID         string // Optional for AWS
-	AWSSecurityGroup string
-	CloudProvider    string
-	CredentialPath   string
-	AWSAMI           string // Optional for AWS
-	GCPImageFamily   string // Optional for GCP
-	GCPImageProject  string // Optional for GCP
-	GCPNetworkName   string // Optional for GCP
-	GCPProjectID     string // Optional fot GCP
+	ID                  string
+	Name                string
+	Region              string
+	Zone                string // Picked from Region when empty
+	InstanceType        string
+	KeyPairName         string
+	SSHPublicKeys       []string // authorized_keys lines
+	SSHUser             string   // User the keys are granted to on GCP
+	SubnetID            string   // Optional for AWS
+	PublicIPMode        string   // none, ephemeral or static; cloud default when empty
+	PublicIP            string   // Read from the cloud
+	PrivateIP           string   // Read from the cloud
+	CloudProvider       string
+	CredentialPath      string
+	GCPProjectID        string // Optional fot GCP
+	UserData            string // Raw script or cloud-config document
+	Ingress             []IngressRule
+	FirewallTags        []string // Select cloudfusion_firewall resources
+	RemovedFirewallTags []string // Firewall tags dropped by an update
+	Capacity            Capacity
+	PowerState          string // running, stopped or suspended
+	DeletionProtection  bool
+	SourceImageID       string    // Overrides the image of the aws or gcp block
+	SourceSnapshot      string    // Boot disk snapshot to restore instead of an image
+	BootDisk            *BootDisk // Image defaults when nil
+	DataDisks           []DataDisk
+	ServiceIdentity     *ServiceIdentity // No cloud identity when nil
+	Tags                map[string]string
+	IgnoreTagKeys       []string    // Tags managed outside of Terraform
+	AWS                 *AWSOptions // Set when the aws block is configured
+	GCP                 *GCPOptions // Set when the gcp block is configured
+}
+
+// ServiceIdentity is the cloud identity the instance runs as. InstanceProfile
+// applies to AWS, ServiceAccountEmail and Scopes to GCP.
+type ServiceIdentity struct {
+	InstanceProfile     string // Name or ARN
+	ServiceAccountEmail string
+	Scopes              []string
+}
+
+// AWSOptions holds the settings that only apply to EC2 instances.
+type AWSOptions struct {
+	AMI           string
+	SecurityGroup string
+	Hibernation   bool // Required to suspend the instance
+}
+
+// GCPOptions holds the settings that only apply to Compute Engine instances.
+type GCPOptions struct {
+	ImageFamily  string
+	ImageProject string
+	NetworkName  string
+}
+
+// IngressRule allows inbound traffic to the server.
+type IngressRule struct {
+	Protocol   string   // tcp, udp, icmp or all
+	Ports      []string // Ports or port ranges such as "8000-8080", tcp and udp only
+	CIDRBlocks []string
+}
+
+// DataDisk is an additional block device created with the server.
+type DataDisk struct {
+	DeviceName string // "sdf" style; "/dev/" is prepended on AWS
+	SizeGB     int64
+	Type       string // standard, ssd or provisioned_iops
+	IOPS       int64  // provisioned_iops only
+	KMSKeyID   string // AWS KMS key ID or GCE CMEK name
+	AutoDelete bool   // Deleted with the server
+}
+
+// BootDisk overrides the size, type and encryption of the disk created from
+// the image. Zero values keep the image defaults.
+type BootDisk struct {
+	SizeGB   int64
+	Type     string // standard, ssd or provisioned_iops
+	IOPS     int64  // provisioned_iops only
+	KMSKeyID string // AWS KMS key ID or GCE CMEK name
+}
+
+// Capacity selects between regular capacity and discounted spot capacity the
+// cloud may reclaim.
+type Capacity struct {
+	Model                string // on_demand or spot
+	MaxPrice             string // Hourly price in USD, AWS only
+	InterruptionBehavior string // stop, terminate or delete; cloud default when empty
 }